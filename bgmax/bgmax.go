@@ -0,0 +1,332 @@
+// Package bgmax parses BgMax files, the format used by Bankgirot to report
+// incoming payments to a bankgiro number
+package bgmax
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Reference codes, describing how the reference of a payment should be interpreted
+const (
+	ReferenceCodeNone      = 0 // No reference
+	ReferenceCodeBlank     = 1 // No reference
+	ReferenceCodeOCR       = 2 // Correct OCR reference
+	ReferenceCodeMultiple  = 3 // One or more references, see ExtraReferences
+	ReferenceCodeSingle    = 4 // One reference
+	ReferenceCodeIncorrect = 5 // Incorrect reference
+)
+
+// Reference is an extra reference connected to a payment (TK22 and TK23)
+type Reference struct {
+	Reference     string
+	Amount        decimal.Decimal
+	ReferenceCode int
+	IsNegative    bool // TK23 - negative reference
+}
+
+// Payment describes a single payment (TK20) or deduction (TK21).
+// The amount of a deduction is negative.
+type Payment struct {
+	SenderBankgiro string
+	Reference      string
+	Amount         decimal.Decimal
+	ReferenceCode  int
+	Channel        int
+	Serial         string // Bankgirot serial number of the payment
+	IsDeduction    bool
+
+	ExtraReferences []Reference
+	Information     []string
+
+	Name     string
+	Address  string
+	Postcode string
+	City     string
+	Country  string
+	OrgNr    string
+
+	// The following fields are copied from the deposit of the payment
+	Date time.Time
+}
+
+// References returns the main reference and all extra references of the payment
+func (p Payment) References() []string {
+	var refs []string
+	if p.Reference != "" {
+		refs = append(refs, p.Reference)
+	}
+	for _, r := range p.ExtraReferences {
+		if r.Reference != "" {
+			refs = append(refs, r.Reference)
+		}
+	}
+	return refs
+}
+
+// Deposit contains all payments deposited to a bank account at a specific date (TK05 - TK15)
+type Deposit struct {
+	Bankgiro    string
+	Plusgiro    string
+	Currency    string
+	BankAccount string
+	Date        time.Time
+	Serial      string
+	Amount      decimal.Decimal
+	Payments    []Payment
+}
+
+// File is a parsed BgMax file
+type File struct {
+	Version   string
+	Timestamp time.Time
+	IsTest    bool
+	Deposits  []Deposit
+}
+
+// Payments returns all payments and deductions in the file
+func (f *File) Payments() []Payment {
+	var payments []Payment
+	for _, d := range f.Deposits {
+		payments = append(payments, d.Payments...)
+	}
+	return payments
+}
+
+// ParseError describes an error on a specific line in the file
+type ParseError struct {
+	Line    int
+	Message string
+}
+
+func (e ParseError) Error() string {
+	return fmt.Sprintf("bgmax: line %d: %s", e.Line, e.Message)
+}
+
+// field returns the (1-indexed, inclusive) positions start-end of the line, without padding
+func field(line string, start, end int) string {
+	r := []rune(line)
+	if start > len(r) {
+		return ""
+	}
+	if end > len(r) {
+		end = len(r)
+	}
+	return strings.TrimSpace(string(r[start-1 : end]))
+}
+
+func parseAmount(s string) (decimal.Decimal, error) {
+	ore, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return decimal.New(ore, -2), nil
+}
+
+func parseInt(s string) int {
+	v, _ := strconv.Atoi(s)
+	return v
+}
+
+// Parse reads a BgMax file from r.
+// The file is expected to be encoded in ISO 8859-1, as specified by Bankgirot.
+func Parse(r io.Reader) (*File, error) {
+	var file File
+	var deposit *Deposit
+	var payment *Payment
+	var lineNr int
+	var seenStart, seenEnd bool
+	var counts [4]int // payments, deductions, extra references, deposits
+
+	fail := func(format string, args ...interface{}) (*File, error) {
+		return nil, ParseError{Line: lineNr, Message: fmt.Sprintf(format, args...)}
+	}
+
+	scanner := bufio.NewScanner(charmap.ISO8859_1.NewDecoder().Reader(r))
+	for scanner.Scan() {
+		lineNr++
+		line := strings.TrimRight(scanner.Text(), "\r\n")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		if len(line) < 2 {
+			return fail("invalid record %q", line)
+		}
+
+		tk := line[0:2]
+		if !seenStart && tk != "01" {
+			return fail("file must start with a start record (TK01)")
+		}
+		if seenEnd {
+			return fail("records found after end record (TK70)")
+		}
+
+		switch tk {
+		case "01": // Start record
+			if seenStart {
+				return fail("duplicate start record")
+			}
+			if field(line, 3, 22) != "BGMAX" {
+				return fail("not a BgMax file")
+			}
+			seenStart = true
+			file.Version = field(line, 23, 24)
+			ts := field(line, 25, 44)
+			if len(ts) >= 14 {
+				t, err := time.ParseInLocation("20060102150405", ts[:14], time.Local)
+				if err != nil {
+					return fail("invalid timestamp %q", ts)
+				}
+				file.Timestamp = t
+			}
+			file.IsTest = field(line, 45, 45) == "T"
+
+		case "05": // Opening record
+			if deposit != nil {
+				return fail("opening record inside an unterminated deposit")
+			}
+			deposit = &Deposit{
+				Bankgiro: strings.TrimLeft(field(line, 3, 12), "0"),
+				Plusgiro: strings.TrimLeft(field(line, 13, 22), "0"),
+				Currency: field(line, 23, 25),
+			}
+			payment = nil
+
+		case "20", "21": // Payment or deduction
+			if deposit == nil {
+				return fail("payment record outside of a deposit")
+			}
+			amount, err := parseAmount(field(line, 38, 55))
+			if err != nil {
+				return fail("invalid amount %q", field(line, 38, 55))
+			}
+			p := Payment{
+				SenderBankgiro: strings.TrimLeft(field(line, 3, 12), "0"),
+				Reference:      field(line, 13, 37),
+				Amount:         amount,
+				ReferenceCode:  parseInt(field(line, 56, 56)),
+				Channel:        parseInt(field(line, 57, 57)),
+				Serial:         field(line, 58, 69),
+			}
+			if tk == "21" {
+				p.IsDeduction = true
+				p.Amount = p.Amount.Neg()
+				counts[1]++
+			} else {
+				counts[0]++
+			}
+			deposit.Payments = append(deposit.Payments, p)
+			payment = &deposit.Payments[len(deposit.Payments)-1]
+
+		case "22", "23": // Extra reference
+			if payment == nil {
+				return fail("reference record without a preceding payment")
+			}
+			amount, err := parseAmount(field(line, 38, 55))
+			if err != nil {
+				return fail("invalid amount %q", field(line, 38, 55))
+			}
+			payment.ExtraReferences = append(payment.ExtraReferences, Reference{
+				Reference:     field(line, 13, 37),
+				Amount:        amount,
+				ReferenceCode: parseInt(field(line, 56, 56)),
+				IsNegative:    tk == "23",
+			})
+			counts[2]++
+
+		case "25", "26", "27", "28", "29":
+			if payment == nil {
+				return fail("information record without a preceding payment")
+			}
+			switch tk {
+			case "25": // Information
+				payment.Information = append(payment.Information, field(line, 3, 52))
+			case "26": // Name
+				payment.Name = strings.TrimSpace(field(line, 3, 37) + " " + field(line, 38, 72))
+			case "27": // Address 1
+				payment.Address = field(line, 3, 37)
+				payment.Postcode = field(line, 38, 46)
+			case "28": // Address 2
+				payment.City = field(line, 3, 37)
+				payment.Country = field(line, 38, 72)
+			case "29": // Organisation number
+				payment.OrgNr = strings.TrimLeft(field(line, 3, 14), "0")
+			}
+
+		case "15": // Deposit record
+			if deposit == nil {
+				return fail("deposit record without a preceding opening record")
+			}
+			date, err := time.ParseInLocation("20060102", field(line, 38, 45), time.Local)
+			if err != nil {
+				return fail("invalid payment date %q", field(line, 38, 45))
+			}
+			amount, err := parseAmount(field(line, 51, 68))
+			if err != nil {
+				return fail("invalid amount %q", field(line, 51, 68))
+			}
+
+			deposit.BankAccount = strings.TrimLeft(field(line, 3, 37), "0")
+			deposit.Date = date
+			deposit.Serial = field(line, 46, 50)
+			deposit.Amount = amount
+
+			sum := decimal.Zero
+			for k := range deposit.Payments {
+				deposit.Payments[k].Date = date
+				sum = sum.Add(deposit.Payments[k].Amount)
+			}
+			if !sum.Equal(amount) {
+				return fail("deposit amount %s does not match the sum of the payments %s", amount.StringFixed(2), sum.StringFixed(2))
+			}
+			if n := parseInt(field(line, 72, 79)); n != len(deposit.Payments) {
+				return fail("deposit contains %d payments, expected %d", len(deposit.Payments), n)
+			}
+
+			file.Deposits = append(file.Deposits, *deposit)
+			deposit = nil
+			payment = nil
+			counts[3]++
+
+		case "70": // End record
+			if deposit != nil {
+				return fail("end record inside of a deposit")
+			}
+			expected := [4]int{
+				parseInt(field(line, 3, 10)),
+				parseInt(field(line, 11, 18)),
+				parseInt(field(line, 19, 26)),
+				parseInt(field(line, 27, 34)),
+			}
+			if expected != counts {
+				return fail("record counts %v do not match end record %v", counts, expected)
+			}
+			seenEnd = true
+
+		default:
+			return fail("unknown record type %q", tk)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if !seenStart {
+		return nil, ParseError{Line: lineNr, Message: "empty file"}
+	}
+
+	if !seenEnd {
+		return nil, ParseError{Line: lineNr, Message: "missing end record (TK70)"}
+	}
+
+	return &file, nil
+}
@@ -0,0 +1,102 @@
+package bgmax
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// record creates a fixed width record from (position, value) pairs
+func record(fields ...interface{}) string {
+	line := []rune(strings.Repeat(" ", 80))
+	for i := 0; i < len(fields); i += 2 {
+		pos := fields[i].(int)
+		copy(line[pos-1:], []rune(fields[i+1].(string)))
+	}
+	return strings.TrimRight(string(line), " ")
+}
+
+func testFile(endCounts string) string {
+	lines := []string{
+		record(1, "01", 3, "BGMAX", 23, "01", 25, "20240115061500123456", 45, "P"),
+		record(1, "05", 3, "0009912346", 23, "SEK"),
+		record(1, "20", 3, "0000123456", 13, "1042", 38, "000000000000125000", 56, "2", 57, "1", 58, "000000000001", 70, "0"),
+		record(1, "26", 3, "ANDERSSON ANNA"),
+		record(1, "27", 3, "ÅGATAN 1", 38, "12345"),
+		record(1, "28", 3, "STOCKHOLM"),
+		record(1, "20", 3, "0000654321", 13, "", 38, "000000000000030000", 56, "3", 57, "1", 58, "000000000002", 70, "0"),
+		record(1, "22", 3, "0000654321", 13, "1043", 38, "000000000000010000", 56, "4"),
+		record(1, "22", 3, "0000654321", 13, "1044", 38, "000000000000020000", 56, "4"),
+		record(1, "25", 3, "Betalning av två fakturor"),
+		record(1, "21", 3, "0000111111", 13, "1045", 38, "000000000000005000", 56, "4", 57, "1", 58, "000000000003", 70, "0", 71, "1"),
+		record(1, "15", 3, "00000000000000000000000000000123456", 38, "20240115", 46, "00001", 51, "000000000000150000", 69, "SEK", 72, "00000003"),
+		endCounts,
+	}
+	contents, _ := charmap.ISO8859_1.NewEncoder().String(strings.Join(lines, "\r\n") + "\r\n")
+	return contents
+}
+
+func TestParse(t *testing.T) {
+	f, err := Parse(strings.NewReader(testFile(record(1, "70", 3, "00000002", 11, "00000001", 19, "00000002", 27, "00000001"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f.IsTest {
+		t.Errorf("expected production file")
+	}
+
+	if len(f.Deposits) != 1 {
+		t.Fatalf("expected 1 deposit, got %d", len(f.Deposits))
+	}
+
+	d := f.Deposits[0]
+	if d.Bankgiro != "9912346" || d.Currency != "SEK" || d.BankAccount != "123456" {
+		t.Errorf("unexpected deposit %+v", d)
+	}
+
+	payments := f.Payments()
+	if len(payments) != 3 {
+		t.Fatalf("expected 3 payments, got %d", len(payments))
+	}
+
+	p := payments[0]
+	if p.Reference != "1042" || !p.Amount.Equal(decimal.NewFromInt(1250)) || p.ReferenceCode != ReferenceCodeOCR {
+		t.Errorf("unexpected payment %+v", p)
+	}
+	if p.Name != "ANDERSSON ANNA" || p.Address != "ÅGATAN 1" || p.Postcode != "12345" || p.City != "STOCKHOLM" {
+		t.Errorf("unexpected payer %+v", p)
+	}
+	if p.Date.Format("2006-01-02") != "2024-01-15" {
+		t.Errorf("unexpected date %s", p.Date)
+	}
+
+	refs := payments[1].References()
+	if len(refs) != 2 || refs[0] != "1043" || refs[1] != "1044" {
+		t.Errorf("unexpected references %v", refs)
+	}
+	if len(payments[1].Information) != 1 || payments[1].Information[0] != "Betalning av två fakturor" {
+		t.Errorf("unexpected information %v", payments[1].Information)
+	}
+
+	if !payments[2].IsDeduction || !payments[2].Amount.Equal(decimal.NewFromInt(-50)) {
+		t.Errorf("unexpected deduction %+v", payments[2])
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]string{
+		"wrong counts": testFile(record(1, "70", 3, "00000003", 11, "00000001", 19, "00000002", 27, "00000001")),
+		"no end":       testFile(""),
+		"not bgmax":    record(1, "01", 3, "BGMIN") + "\n",
+		"no start":     record(1, "05", 3, "0009912346", 23, "SEK") + "\n",
+	}
+
+	for name, contents := range tests {
+		if _, err := Parse(strings.NewReader(contents)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+// Package cii contains the subset of UN/CEFACT Cross Industry Invoice (CII) D16B needed to
+// create invoices and credit notes according to EN 16931, as used by Factur-X and ZUGFeRD
+package cii
+
+import (
+	"encoding/xml"
+	"io"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	TypeCodeInvoice    = "380"
+	TypeCodeCreditNote = "381"
+
+	PaymentMeansCreditTransfer = "30"
+
+	// DateFormat is the format code of dates written as YYYYMMDD
+	DateFormat = "102"
+
+	// FileName is the name of the embedded XML file in Factur-X and ZUGFeRD 2 documents
+	FileName = "factur-x.xml"
+
+	namespaceRSM = "urn:un:unece:uncefact:data:standard:CrossIndustryInvoice:100"
+	namespaceRAM = "urn:un:unece:uncefact:data:standard:ReusableAggregateBusinessInformationEntity:100"
+	namespaceUDT = "urn:un:unece:uncefact:data:standard:UnqualifiedDataType:100"
+	namespaceQDT = "urn:un:unece:uncefact:data:standard:QualifiedDataType:100"
+)
+
+// Profile is a Factur-X profile, deciding how much of the invoice is included in the XML
+type Profile int
+
+const (
+	ProfileEN16931 Profile = iota
+	ProfileMinimum
+	ProfileBasicWL
+	ProfileBasic
+	ProfileExtended
+)
+
+var profileGuidelines = map[Profile]string{
+	ProfileMinimum:  "urn:factur-x.eu:1p0:minimum",
+	ProfileBasicWL:  "urn:factur-x.eu:1p0:basicwl",
+	ProfileBasic:    "urn:cen.eu:en16931:2017#compliant#urn:factur-x.eu:1p0:basic",
+	ProfileEN16931:  "urn:cen.eu:en16931:2017",
+	ProfileExtended: "urn:cen.eu:en16931:2017#conformant#urn:factur-x.eu:1p0:extended",
+}
+
+var profileNames = map[Profile]string{
+	ProfileMinimum:  "MINIMUM",
+	ProfileBasicWL:  "BASIC WL",
+	ProfileBasic:    "BASIC",
+	ProfileEN16931:  "EN 16931",
+	ProfileExtended: "EXTENDED",
+}
+
+// Profiles lists all available profiles
+var Profiles = profileNames
+
+// Guideline returns the specification identifier of the profile (BT-24)
+func (p Profile) Guideline() string {
+	return profileGuidelines[p]
+}
+
+// String returns the name of the profile, as used in the XMP metadata of Factur-X documents
+func (p Profile) String() string {
+	return profileNames[p]
+}
+
+func (p Profile) Validate() bool {
+	_, ok := profileNames[p]
+	return ok
+}
+
+// IsInvoice returns true if the XML of the profile is a complete invoice, and false if
+// the PDF document is the invoice, and the XML only contains parts of it
+func (p Profile) IsInvoice() bool {
+	return p != ProfileMinimum && p != ProfileBasicWL
+}
+
+// Amount is a monetary amount, with an optional currency
+type Amount struct {
+	CurrencyID string `xml:"currencyID,attr,omitempty"`
+	Text       string `xml:",chardata"`
+}
+
+// NewAmount creates a new amount, rounded to two decimals
+func NewAmount(value decimal.Decimal) Amount {
+	return Amount{Text: value.Round(2).StringFixed(2)}
+}
+
+// Quantity is a quantity with unit code (UN/ECE Recommendation 20)
+type Quantity struct {
+	UnitCode string `xml:"unitCode,attr"`
+	Text     string `xml:",chardata"`
+}
+
+// Identifier is an identifier with an optional scheme
+type Identifier struct {
+	SchemeID string `xml:"schemeID,attr,omitempty"`
+	Value    string `xml:",chardata"`
+}
+
+// Date is a date written as YYYYMMDD
+type Date struct {
+	Format string `xml:"format,attr"`
+	Value  string `xml:",chardata"`
+}
+
+// NewDate creates a date from a date formatted as YYYY-MM-DD
+func NewDate(date string) *Date {
+	if date == "" {
+		return nil
+	}
+	d := []byte{}
+	for _, c := range []byte(date) {
+		if c != '-' {
+			d = append(d, c)
+		}
+	}
+	return &Date{Format: DateFormat, Value: string(d)}
+}
+
+type Address struct {
+	Postcode    string `xml:"ram:PostcodeCode,omitempty"`
+	LineOne     string `xml:"ram:LineOne,omitempty"`
+	LineTwo     string `xml:"ram:LineTwo,omitempty"`
+	CityName    string `xml:"ram:CityName,omitempty"`
+	CountryCode string `xml:"ram:CountryID"`
+}
+
+type LegalOrganization struct {
+	ID *Identifier `xml:"ram:ID,omitempty"`
+}
+
+type Telephone struct {
+	Number string `xml:"ram:CompleteNumber"`
+}
+
+type Email struct {
+	URI string `xml:"ram:URIID"`
+}
+
+type Contact struct {
+	Telephone *Telephone `xml:"ram:TelephoneUniversalCommunication,omitempty"`
+	Email     *Email     `xml:"ram:EmailURIUniversalCommunication,omitempty"`
+}
+
+type TaxRegistration struct {
+	ID Identifier `xml:"ram:ID"`
+}
+
+type Party struct {
+	Name              string             `xml:"ram:Name"`
+	LegalOrganization *LegalOrganization `xml:"ram:SpecifiedLegalOrganization,omitempty"`
+	Contact           *Contact           `xml:"ram:DefinedTradeContact,omitempty"`
+	Address           *Address           `xml:"ram:PostalTradeAddress,omitempty"`
+	ElectronicAddress *Identifier        `xml:"ram:URIUniversalCommunication>ram:URIID,omitempty"`
+	TaxRegistrations  []TaxRegistration  `xml:"ram:SpecifiedTaxRegistration"`
+}
+
+// TradeTax is a VAT breakdown on document level, or the VAT category of a line.
+// The amounts are only used on document level.
+type TradeTax struct {
+	CalculatedAmount    *Amount `xml:"ram:CalculatedAmount,omitempty"`
+	TypeCode            string  `xml:"ram:TypeCode"`
+	ExemptionReason     string  `xml:"ram:ExemptionReason,omitempty"`
+	BasisAmount         *Amount `xml:"ram:BasisAmount,omitempty"`
+	CategoryCode        string  `xml:"ram:CategoryCode"`
+	ExemptionReasonCode string  `xml:"ram:ExemptionReasonCode,omitempty"`
+	Percent             string  `xml:"ram:RateApplicablePercent,omitempty"`
+}
+
+type FinancialAccount struct {
+	IBAN          string `xml:"ram:IBANID,omitempty"`
+	ProprietaryID string `xml:"ram:ProprietaryID,omitempty"`
+}
+
+type PaymentMeans struct {
+	TypeCode     string            `xml:"ram:TypeCode"`
+	PayeeAccount *FinancialAccount `xml:"ram:PayeePartyCreditorFinancialAccount,omitempty"`
+}
+
+type PaymentTerms struct {
+	Description string `xml:"ram:Description,omitempty"`
+	DueDate     *Date  `xml:"ram:DueDateDateTime>udt:DateTimeString,omitempty"`
+}
+
+type MonetarySummation struct {
+	LineTotalAmount     *Amount `xml:"ram:LineTotalAmount,omitempty"`
+	TaxBasisTotalAmount Amount  `xml:"ram:TaxBasisTotalAmount"`
+	TaxTotalAmount      Amount  `xml:"ram:TaxTotalAmount"`
+	RoundingAmount      *Amount `xml:"ram:RoundingAmount,omitempty"`
+	GrandTotalAmount    Amount  `xml:"ram:GrandTotalAmount"`
+	TotalPrepaidAmount  *Amount `xml:"ram:TotalPrepaidAmount,omitempty"`
+	DuePayableAmount    Amount  `xml:"ram:DuePayableAmount"`
+}
+
+type ReferencedDocument struct {
+	IssuerAssignedID string `xml:"ram:IssuerAssignedID"`
+	IssueDate        *Date  `xml:"ram:FormattedIssueDateTime>qdt:DateTimeString,omitempty"`
+}
+
+type HeaderAgreement struct {
+	BuyerReference string `xml:"ram:BuyerReference,omitempty"`
+	Seller         Party  `xml:"ram:SellerTradeParty"`
+	Buyer          Party  `xml:"ram:BuyerTradeParty"`
+}
+
+type HeaderSettlement struct {
+	PaymentReference string              `xml:"ram:PaymentReference,omitempty"`
+	CurrencyCode     string              `xml:"ram:InvoiceCurrencyCode"`
+	PaymentMeans     *PaymentMeans       `xml:"ram:SpecifiedTradeSettlementPaymentMeans,omitempty"`
+	Taxes            []TradeTax          `xml:"ram:ApplicableTradeTax"`
+	PaymentTerms     *PaymentTerms       `xml:"ram:SpecifiedTradePaymentTerms,omitempty"`
+	Summation        MonetarySummation   `xml:"ram:SpecifiedTradeSettlementHeaderMonetarySummation"`
+	InvoiceReference *ReferencedDocument `xml:"ram:InvoiceReferencedDocument,omitempty"`
+}
+
+// Line is an invoice line
+type Line struct {
+	ID              string   `xml:"ram:AssociatedDocumentLineDocument>ram:LineID"`
+	Name            string   `xml:"ram:SpecifiedTradeProduct>ram:Name"`
+	NetPrice        Amount   `xml:"ram:SpecifiedLineTradeAgreement>ram:NetPriceProductTradePrice>ram:ChargeAmount"`
+	BilledQuantity  Quantity `xml:"ram:SpecifiedLineTradeDelivery>ram:BilledQuantity"`
+	Tax             TradeTax `xml:"ram:SpecifiedLineTradeSettlement>ram:ApplicableTradeTax"`
+	LineTotalAmount Amount   `xml:"ram:SpecifiedLineTradeSettlement>ram:SpecifiedTradeSettlementLineMonetarySummation>ram:LineTotalAmount"`
+}
+
+type Note struct {
+	Content string `xml:"ram:Content"`
+}
+
+// Invoice describes an invoice or credit note. All amounts of credit notes are positive.
+type Invoice struct {
+	XMLName  xml.Name `xml:"rsm:CrossIndustryInvoice"`
+	XMLNsRSM string   `xml:"xmlns:rsm,attr"`
+	XMLNsRAM string   `xml:"xmlns:ram,attr"`
+	XMLNsUDT string   `xml:"xmlns:udt,attr"`
+	XMLNsQDT string   `xml:"xmlns:qdt,attr"`
+
+	Guideline string `xml:"rsm:ExchangedDocumentContext>ram:GuidelineSpecifiedDocumentContextParameter>ram:ID"`
+	ID        string `xml:"rsm:ExchangedDocument>ram:ID"`
+	TypeCode  string `xml:"rsm:ExchangedDocument>ram:TypeCode"`
+	IssueDate Date   `xml:"rsm:ExchangedDocument>ram:IssueDateTime>udt:DateTimeString"`
+	Notes     []Note `xml:"rsm:ExchangedDocument>ram:IncludedNote"`
+
+	Lines      []Line           `xml:"rsm:SupplyChainTradeTransaction>ram:IncludedSupplyChainTradeLineItem"`
+	Agreement  HeaderAgreement  `xml:"rsm:SupplyChainTradeTransaction>ram:ApplicableHeaderTradeAgreement"`
+	Delivery   struct{}         `xml:"rsm:SupplyChainTradeTransaction>ram:ApplicableHeaderTradeDelivery"`
+	Settlement HeaderSettlement `xml:"rsm:SupplyChainTradeTransaction>ram:ApplicableHeaderTradeSettlement"`
+}
+
+// NewInvoice creates a new invoice
+func NewInvoice() *Invoice {
+	return &Invoice{
+		XMLNsRSM:  namespaceRSM,
+		XMLNsRAM:  namespaceRAM,
+		XMLNsUDT:  namespaceUDT,
+		XMLNsQDT:  namespaceQDT,
+		Guideline: ProfileEN16931.Guideline(),
+		TypeCode:  TypeCodeInvoice,
+	}
+}
+
+// ApplyProfile removes the information that isn't part of the profile p
+func (inv *Invoice) ApplyProfile(p Profile) {
+	inv.Guideline = p.Guideline()
+	if p == ProfileEN16931 || p == ProfileExtended {
+		return
+	}
+
+	// The contacts, and the rounding of the amount to pay, are only part of EN 16931
+	inv.Agreement.Seller.Contact = nil
+	inv.Agreement.Buyer.Contact = nil
+	inv.Settlement.Summation.RoundingAmount = nil
+	if p == ProfileBasic {
+		return
+	}
+
+	inv.Lines = nil
+	if p == ProfileBasicWL {
+		return
+	}
+
+	// The minimum profile only contains the parties, and the totals of the invoice
+	inv.Notes = nil
+	inv.Agreement.Seller.ElectronicAddress = nil
+	if inv.Agreement.Seller.Address != nil {
+		inv.Agreement.Seller.Address = &Address{CountryCode: inv.Agreement.Seller.Address.CountryCode}
+	}
+	inv.Agreement.Buyer.ElectronicAddress = nil
+	inv.Agreement.Buyer.Address = nil
+	inv.Agreement.Buyer.TaxRegistrations = nil
+	inv.Settlement.PaymentReference = ""
+	inv.Settlement.PaymentMeans = nil
+	inv.Settlement.Taxes = nil
+	inv.Settlement.PaymentTerms = nil
+	inv.Settlement.InvoiceReference = nil
+	inv.Settlement.Summation.LineTotalAmount = nil
+	inv.Settlement.Summation.TotalPrepaidAmount = nil
+}
+
+// Write writes the document as XML to w
+func (inv *Invoice) Write(w io.Writer) error {
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(inv)
+}
@@ -1,5 +1,7 @@
 package config
 
+import "time"
+
 type Logging struct {
 	Logfile      string `yaml:"logfile"`
 	Level        string `yaml:"level"`
@@ -25,9 +27,15 @@ type Server struct {
 	CertFile   string `yaml:"cert_file"`
 }
 
+type PDF struct {
+	Workers int           `yaml:"workers"` // Number of documents created by LaTeX at the same time
+	Timeout time.Duration `yaml:"timeout"` // Time limit of each document
+}
+
 type Config struct {
 	Logging  Logging  `yaml:"logging"`
 	Sentry   Sentry   `yaml:"sentry"`
 	Database Database `yaml:"database"`
 	Server   Server   `yaml:"server"`
+	PDF      PDF      `yaml:"pdf"`
 }
@@ -19,6 +19,7 @@ require (
 	github.com/juju/errors v0.0.0-20181118221551-089d3ea4e4d5 // indirect
 	github.com/juju/loggo v0.0.0-20180524022052-584905176618 // indirect
 	github.com/juju/testing v0.0.0-20180920084828-472a3e8b2073 // indirect
+	github.com/jung-kurt/gofpdf v1.16.2
 	github.com/lestrrat-go/strftime v1.0.6
 	github.com/lib/pq v1.10.0
 	github.com/mattn/go-sqlite3 v1.14.6
@@ -0,0 +1,190 @@
+// Package latex creates PDF documents from LaTeX with xelatex, in a bounded pool of workers.
+//
+// Each document is compiled in a temporary directory of its own, with a time limit. Shell escape
+// is disabled, and LaTeX may only read and write files in the directory of the document and in the
+// search paths, so that a template can't read or overwrite other files on the server.
+package latex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout is the time limit of a document, if no other limit is set
+const DefaultTimeout = time.Minute
+
+// excerptLines is the number of lines of the LaTeX log included in errors
+const excerptLines = 10
+
+// ErrTimeout is returned when a document isn't created within the time limit
+var ErrTimeout = errors.New("the document could not be created within the time limit")
+
+// Error is returned when LaTeX fails to create a document, with the part of the LaTeX log describing the error
+type Error struct {
+	Err error
+	Log string
+}
+
+func (e *Error) Error() string {
+	if e.Log == "" {
+		return fmt.Sprintf("latex: %v", e.Err)
+	}
+	return fmt.Sprintf("latex: %v\n\n%s", e.Err, e.Log)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Job is a LaTeX document to create
+type Job struct {
+	Name     string            // Name of the job, used as the name of the files created by LaTeX
+	Source   string            // The LaTeX document
+	Files    map[string][]byte // Additional files used by the document, e.g. images, by file name
+	InputDir string            // Directory where other files used by the document are searched for, e.g. logotypes
+}
+
+// Pool creates documents, with a limited number of documents created at the same time
+type Pool struct {
+	Command string // The LaTeX command, xelatex by default
+
+	timeout time.Duration
+	workers chan struct{}
+}
+
+// NewPool creates a pool where up to 'workers' documents are created at the same time, each within 'timeout'.
+// The number of workers defaults to the number of CPUs, and the timeout to DefaultTimeout.
+func NewPool(workers int, timeout time.Duration) *Pool {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Pool{
+		Command: "xelatex",
+		timeout: timeout,
+		workers: make(chan struct{}, workers),
+	}
+}
+
+var defaultPool = NewPool(0, 0)
+
+// Setup sets the number of workers and the time limit of the documents created by Render
+func Setup(workers int, timeout time.Duration) {
+	defaultPool = NewPool(workers, timeout)
+}
+
+// Render creates a PDF document from the job in the default pool
+func Render(ctx context.Context, job Job) ([]byte, error) {
+	return defaultPool.Render(ctx, job)
+}
+
+// Render creates a PDF document from the job, when a worker is available.
+// The job is aborted if ctx is cancelled, e.g. when the request is cancelled.
+func (p *Pool) Render(ctx context.Context, job Job) ([]byte, error) {
+	select {
+	case p.workers <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-p.workers }()
+
+	jobCtx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	tmpdir, err := ioutil.TempDir("", "faktura-pdf-*")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err := os.RemoveAll(tmpdir)
+		if err != nil {
+			log.Printf("could not remove temp folder: %v", err)
+		}
+	}()
+
+	err = ioutil.WriteFile(filepath.Join(tmpdir, job.Name+".tex"), []byte(job.Source), 0600)
+	if err != nil {
+		return nil, err
+	}
+	for name, data := range job.Files {
+		err = ioutil.WriteFile(filepath.Join(tmpdir, filepath.Base(name)), data, 0600)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The document is read from a file, and the output is written to files, so that nothing is left
+	// waiting for the process when it's killed
+	cmd := exec.CommandContext(jobCtx, p.Command,
+		"-no-shell-escape",
+		"-8bit",
+		"-file-line-error",
+		"-halt-on-error",
+		"-interaction=batchmode",
+		job.Name+".tex")
+	cmd.Dir = tmpdir
+	cmd.Env = append(os.Environ(),
+		"shell_escape=f",
+		"openout_any=p", // Only write files in the working directory, and no dot files
+		"openin_any=p",  // Don't read files in parent directories, or dot files
+		"TEXMFOUTPUT="+tmpdir,
+	)
+	if job.InputDir != "" {
+		// The empty entry at the end is replaced by the default search paths
+		cmd.Env = append(cmd.Env, "TEXINPUTS=.:"+job.InputDir+":")
+	}
+	runErr := cmd.Run()
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	logData, _ := ioutil.ReadFile(filepath.Join(tmpdir, job.Name+".log"))
+	if jobCtx.Err() != nil {
+		return nil, &Error{Err: ErrTimeout, Log: logExcerpt(string(logData))}
+	}
+	if runErr != nil {
+		return nil, &Error{Err: runErr, Log: logExcerpt(string(logData))}
+	}
+
+	pdf, err := ioutil.ReadFile(filepath.Join(tmpdir, job.Name+".pdf"))
+	if err != nil {
+		return nil, &Error{Err: errors.New("no document was created"), Log: logExcerpt(string(logData))}
+	}
+	return pdf, nil
+}
+
+// fileLineError matches errors written with -file-line-error, e.g. "./invoice.tex:12: Undefined control sequence."
+var fileLineError = regexp.MustCompile(`^\S+\.tex:\d+: `)
+
+// logExcerpt returns the lines of a LaTeX log describing the first error, or the end of the log if there's no error
+func logExcerpt(text string) string {
+	lines := strings.Split(strings.Replace(text, "\r\n", "\n", -1), "\n")
+	for k, l := range lines {
+		if !strings.HasPrefix(l, "! ") && !fileLineError.MatchString(l) {
+			continue
+		}
+		end := k + excerptLines
+		if end > len(lines) {
+			end = len(lines)
+		}
+		return strings.TrimSpace(strings.Join(lines[k:end], "\n"))
+	}
+
+	start := len(lines) - excerptLines
+	if start < 0 {
+		start = 0
+	}
+	return strings.TrimSpace(strings.Join(lines[start:], "\n"))
+}
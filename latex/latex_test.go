@@ -0,0 +1,131 @@
+package latex
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testPool returns a pool where LaTeX is replaced by a shell script
+func testPool(t *testing.T, workers int, timeout time.Duration, script string) *Pool {
+	dir, err := ioutil.TempDir("", "latex-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	command := filepath.Join(dir, "xelatex")
+	err = ioutil.WriteFile(command, []byte("#!/bin/sh\n"+script), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewPool(workers, timeout)
+	p.Command = command
+	return p
+}
+
+func TestRender(t *testing.T) {
+	// The script creates the document from the source and the additional file, and checks the restrictions
+	p := testPool(t, 1, time.Second, `
+for arg; do job="$arg"; done
+test "$openout_any" = p || exit 2
+test "$TEXINPUTS" = ".:/templates:" || exit 3
+cat "$job" qrimage.png > "$(basename "$job" .tex).pdf"
+`)
+	data, err := p.Render(context.Background(), Job{
+		Name:     "invoice-1001",
+		Source:   "\\documentclass{article}",
+		Files:    map[string][]byte{"qrimage.png": []byte("PNG")},
+		InputDir: "/templates",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "\\documentclass{article}PNG" {
+		t.Errorf("got %q", data)
+	}
+}
+
+func TestRenderError(t *testing.T) {
+	p := testPool(t, 1, time.Second, `
+cat > invoice.log <<EOF
+This is XeTeX
+(./invoice.tex
+./invoice.tex:12: Undefined control sequence.
+l.12 \foo
+
+No pages of output.
+EOF
+exit 1
+`)
+	_, err := p.Render(context.Background(), Job{Name: "invoice"})
+	var latexErr *Error
+	if !errors.As(err, &latexErr) {
+		t.Fatalf("expected a LaTeX error, got %v", err)
+	}
+	if !strings.HasPrefix(latexErr.Log, "./invoice.tex:12: Undefined control sequence.\nl.12 \\foo") || strings.Contains(latexErr.Log, "XeTeX") {
+		t.Errorf("expected the log to start at the error, got %q", latexErr.Log)
+	}
+}
+
+func TestRenderTimeout(t *testing.T) {
+	p := testPool(t, 1, 100*time.Millisecond, "exec sleep 10\n")
+
+	start := time.Now()
+	_, err := p.Render(context.Background(), Job{Name: "invoice"})
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("expected the job to be aborted")
+	}
+}
+
+func TestRenderCancel(t *testing.T) {
+	p := testPool(t, 1, time.Minute, "exec sleep 10\n")
+
+	// The only worker is busy with the first job, so the second job is waiting when it's cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := p.Render(ctx, Job{Name: "invoice"})
+			done <- err
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-done:
+			if err != context.Canceled {
+				t.Errorf("expected the job to be cancelled, got %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected the jobs to be cancelled")
+		}
+	}
+}
+
+func TestLogExcerpt(t *testing.T) {
+	log := "This is XeTeX\n! LaTeX Error: File `missing.sty' not found.\n\nType X to quit.\n"
+	if excerpt := logExcerpt(log); !strings.HasPrefix(excerpt, "! LaTeX Error: File `missing.sty' not found.") {
+		t.Errorf("got %q", excerpt)
+	}
+
+	// Without any error, the end of the log is returned
+	var lines []string
+	for i := 0; i < 20; i++ {
+		lines = append(lines, strings.Repeat("x", i))
+	}
+	if excerpt := logExcerpt(strings.Join(lines, "\r\n")); !strings.HasPrefix(excerpt, strings.Repeat("x", 10)) {
+		t.Errorf("got %q", excerpt)
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/yzzyx/faktura-pdf/config"
+	"github.com/yzzyx/faktura-pdf/latex"
 	"github.com/yzzyx/faktura-pdf/models"
 	"github.com/yzzyx/faktura-pdf/sqlx"
 	"github.com/yzzyx/zerr"
@@ -66,6 +67,8 @@ func main() {
 	}
 	defer models.Shutdown()
 
+	latex.Setup(cfg.PDF.Workers, cfg.PDF.Timeout)
+
 	// Map from go CamelCase to sql snake_case
 	sqlx.NameMapper = func(s string) string {
 		result := ""
@@ -103,6 +106,8 @@ func main() {
 		http.ServeFile(w, r, filepath.Join(currentDir, "static/img/favicon.ico"))
 	})
 
+	go runScheduler(ctx, lg, schedulerInterval)
+
 	err = RegisterViews("", r, lg)
 	if err != nil {
 		zerr.Wrap(err).LogError(lg)
@@ -0,0 +1,4 @@
+BEGIN;
+ALTER TABLE invoice ADD COLUMN IF NOT EXISTS is_credit_note bool NOT NULL default false;
+ALTER TABLE invoice ADD COLUMN IF NOT EXISTS credit_invoice_id integer REFERENCES invoice(id);
+COMMIT;
@@ -0,0 +1,4 @@
+BEGIN;
+ALTER TABLE customer ADD COLUMN IF NOT EXISTS peppol_id text NOT NULL DEFAULT '';
+ALTER TABLE customer ADD COLUMN IF NOT EXISTS reference text NOT NULL DEFAULT '';
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+ALTER TABLE company ADD COLUMN IF NOT EXISTS ocr_level integer NOT NULL DEFAULT 0;
+ALTER TABLE company ADD COLUMN IF NOT EXISTS ocr_length_digit bool NOT NULL DEFAULT false;
+ALTER TABLE invoice ADD COLUMN IF NOT EXISTS ocr text NOT NULL DEFAULT '';
+-- Invoices that have already been sent use the invoice number as reference
+UPDATE invoice SET ocr = number::text WHERE is_invoiced AND NOT is_offer;
+CREATE INDEX IF NOT EXISTS invoice_ocr_idx ON invoice (company_id, ocr);
+COMMIT;
@@ -0,0 +1,30 @@
+BEGIN;
+CREATE TABLE invoice_payment (
+    id SERIAL PRIMARY KEY,
+    invoice_id int NOT NULL REFERENCES invoice(id),
+    amount numeric(10,2) NOT NULL,
+    date_paid date NOT NULL,
+    method int NOT NULL DEFAULT 0,
+    reference text NOT NULL DEFAULT '',
+    date_created timestamp NOT NULL DEFAULT now()
+);
+CREATE INDEX invoice_payment_invoice_idx ON invoice_payment (invoice_id);
+
+-- Invoices already marked as paid are paid in full, with the ROT/RUT deduction
+-- (30 % or 50 % for ROT, 50 % for RUT) subtracted from the amount paid by the customer
+INSERT INTO invoice_payment (invoice_id, amount, date_paid)
+SELECT invoice.id,
+    ROUND(COALESCE(SUM(r.cost * r.count * CASE
+        WHEN NOT COALESCE(r.is_rot_rut, false) OR r.rot_rut_service_type IS NULL THEN 1
+        WHEN r.rot_rut_service_type >= 7 THEN 0.5
+        WHEN COALESCE(invoice.date_invoiced, invoice.date_created) < '2016-01-01' THEN 0.5
+        WHEN COALESCE(invoice.date_invoiced, invoice.date_created) >= '2025-05-12'
+            AND COALESCE(invoice.date_invoiced, invoice.date_created) < '2026-01-01' THEN 0.5
+        ELSE 0.7
+    END), 0), 2),
+    COALESCE(invoice.date_paid, invoice.date_invoiced, invoice.date_created)::date
+FROM invoice
+LEFT JOIN invoice_row r ON r.invoice_id = invoice.id
+WHERE invoice.is_paid
+GROUP BY invoice.id;
+COMMIT;
@@ -0,0 +1,35 @@
+BEGIN;
+-- Reference rate (referensränta) set by Riksbanken, used to calculate late-payment interest
+CREATE TABLE reference_rate (
+    date_from date PRIMARY KEY,
+    rate numeric(5,2) NOT NULL
+);
+
+INSERT INTO reference_rate (date_from, rate) VALUES
+    ('2020-01-01', 0.00),
+    ('2022-07-01', 0.50),
+    ('2023-01-01', 2.50),
+    ('2023-07-01', 3.50),
+    ('2024-01-01', 4.00),
+    ('2024-07-01', 3.75),
+    ('2025-01-01', 2.50),
+    ('2025-07-01', 2.00)
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE invoice_reminder (
+    id SERIAL PRIMARY KEY,
+    invoice_id int NOT NULL REFERENCES invoice(id),
+    number int NOT NULL,
+    date_sent date NOT NULL,
+    date_due date NOT NULL,
+    fee numeric(10,2) NOT NULL DEFAULT 0,
+    interest numeric(10,2) NOT NULL DEFAULT 0,
+    interest_rate numeric(5,2) NOT NULL DEFAULT 0,
+    interest_from date,
+    interest_to date,
+    date_created timestamp NOT NULL DEFAULT now(),
+    UNIQUE (invoice_id, number)
+);
+
+ALTER TABLE company ADD COLUMN IF NOT EXISTS reminder_fee numeric(10,2) NOT NULL DEFAULT 60;
+COMMIT;
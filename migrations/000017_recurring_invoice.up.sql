@@ -0,0 +1,35 @@
+BEGIN;
+CREATE TABLE recurring_invoice (
+    id SERIAL PRIMARY KEY,
+    company_id int NOT NULL REFERENCES company(id),
+    customer_id int NOT NULL REFERENCES customer(id),
+    name text NOT NULL DEFAULT '',
+    additional_info text NOT NULL DEFAULT '',
+    rut_applicable boolean NOT NULL DEFAULT false,
+    interval int NOT NULL DEFAULT 0,
+    date_start date NOT NULL,
+    date_end date,
+    date_next date,
+    due_days int NOT NULL DEFAULT 30,
+    send_directly boolean NOT NULL DEFAULT false,
+    is_active boolean NOT NULL DEFAULT true,
+    date_created timestamp NOT NULL DEFAULT now()
+);
+CREATE INDEX recurring_invoice_company_idx ON recurring_invoice (company_id);
+
+CREATE TABLE recurring_invoice_row (
+    id SERIAL PRIMARY KEY,
+    recurring_invoice_id int NOT NULL REFERENCES recurring_invoice(id) ON DELETE CASCADE,
+    row_order int NOT NULL DEFAULT 0,
+    description text NOT NULL DEFAULT '',
+    cost numeric(10,2) NOT NULL DEFAULT 0,
+    count numeric(10,2) NOT NULL DEFAULT 0,
+    unit int NOT NULL DEFAULT 0,
+    vat int NOT NULL DEFAULT 0,
+    is_rot_rut boolean NOT NULL DEFAULT false,
+    rot_rut_service_type int
+);
+CREATE INDEX recurring_invoice_row_recurring_idx ON recurring_invoice_row (recurring_invoice_id);
+
+ALTER TABLE invoice ADD COLUMN recurring_invoice_id int REFERENCES recurring_invoice(id);
+COMMIT;
@@ -0,0 +1,4 @@
+BEGIN;
+-- 0 - faktureringsmetoden (accrual), 1 - kontantmetoden (cash)
+ALTER TABLE company ADD COLUMN IF NOT EXISTS accounting_method integer NOT NULL DEFAULT 0;
+COMMIT;
@@ -0,0 +1,32 @@
+BEGIN;
+-- Accounts used when booking invoices, defaults from the BAS chart of accounts
+CREATE TABLE IF NOT EXISTS account_map (
+    company_id integer PRIMARY KEY REFERENCES company(id),
+    receivable integer NOT NULL DEFAULT 1510,
+    rot_rut_receivable integer NOT NULL DEFAULT 1513,
+    bank integer NOT NULL DEFAULT 1930,
+    rounding integer NOT NULL DEFAULT 3740,
+    reminder_fees integer NOT NULL DEFAULT 3930,
+    late_interest integer NOT NULL DEFAULT 8313,
+    output_vat25 integer NOT NULL DEFAULT 2611,
+    output_vat12 integer NOT NULL DEFAULT 2620,
+    output_vat6 integer NOT NULL DEFAULT 2630,
+    goods25 integer NOT NULL DEFAULT 3001,
+    goods12 integer NOT NULL DEFAULT 3002,
+    goods6 integer NOT NULL DEFAULT 3003,
+    goods_exempt integer NOT NULL DEFAULT 3004,
+    services25 integer NOT NULL DEFAULT 3041,
+    services12 integer NOT NULL DEFAULT 3042,
+    services6 integer NOT NULL DEFAULT 3043,
+    services_exempt integer NOT NULL DEFAULT 3044,
+    reverse_charge integer NOT NULL DEFAULT 3231,
+    eu_goods integer NOT NULL DEFAULT 3108,
+    eu_services integer NOT NULL DEFAULT 3308,
+    export_goods integer NOT NULL DEFAULT 3105,
+    export_services integer NOT NULL DEFAULT 3305
+);
+
+-- Rows are services unless marked as goods, and can override the sales account
+ALTER TABLE invoice_row ADD COLUMN IF NOT EXISTS is_goods bool NOT NULL DEFAULT false;
+ALTER TABLE invoice_row ADD COLUMN IF NOT EXISTS account integer;
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+-- Property where ROT work is performed, required when requesting ROT from Skatteverket.
+-- Either the property designation (fastighetsbeteckning) of a house, or the apartment number
+-- and organisation number of the housing cooperative (BRF) is used.
+ALTER TABLE customer ADD COLUMN IF NOT EXISTS property_designation text NOT NULL DEFAULT '';
+ALTER TABLE customer ADD COLUMN IF NOT EXISTS apartment_number text NOT NULL DEFAULT '';
+ALTER TABLE customer ADD COLUMN IF NOT EXISTS housing_cooperative_id text NOT NULL DEFAULT '';
+COMMIT;
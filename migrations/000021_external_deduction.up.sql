@@ -0,0 +1,14 @@
+BEGIN;
+-- ROT/RUT deductions that a buyer has received from other companies
+CREATE TABLE IF NOT EXISTS external_deduction (
+    id SERIAL PRIMARY KEY,
+    company_id int NOT NULL REFERENCES company(id),
+    pnr text NOT NULL,
+    year int NOT NULL,
+    type int NOT NULL DEFAULT 0,
+    amount int NOT NULL DEFAULT 0,
+    description text NOT NULL DEFAULT '',
+    date_created timestamp NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS external_deduction_pnr_idx ON external_deduction (company_id, pnr, year);
+COMMIT;
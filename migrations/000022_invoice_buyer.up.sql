@@ -0,0 +1,11 @@
+BEGIN;
+-- Buyers that share the ROT/RUT deduction of an invoice. If an invoice has no buyers, the customer is the only buyer.
+CREATE TABLE IF NOT EXISTS invoice_buyer (
+    id SERIAL PRIMARY KEY,
+    invoice_id int NOT NULL REFERENCES invoice(id) ON DELETE CASCADE,
+    name text NOT NULL DEFAULT '',
+    pnr text NOT NULL,
+    share int NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS invoice_buyer_invoice_idx ON invoice_buyer (invoice_id);
+COMMIT;
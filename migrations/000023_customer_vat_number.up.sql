@@ -0,0 +1,3 @@
+BEGIN;
+ALTER TABLE customer ADD COLUMN IF NOT EXISTS vat_number text NOT NULL DEFAULT '';
+COMMIT;
@@ -0,0 +1,4 @@
+BEGIN;
+-- 0 - LaTeX templates, 1 - native renderer
+ALTER TABLE company ADD COLUMN IF NOT EXISTS pdf_renderer integer NOT NULL DEFAULT 0;
+COMMIT;
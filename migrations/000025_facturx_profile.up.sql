@@ -0,0 +1,4 @@
+BEGIN;
+-- Factur-X profile of the XML embedded in PDF/A-3 invoices, 0 - EN 16931
+ALTER TABLE company ADD COLUMN IF NOT EXISTS facturx_profile integer NOT NULL DEFAULT 0;
+COMMIT;
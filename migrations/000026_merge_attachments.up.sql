@@ -0,0 +1,6 @@
+BEGIN;
+-- Append attached PDFs and images to the generated invoice or offer
+ALTER TABLE invoice ADD COLUMN IF NOT EXISTS merge_attachments boolean NOT NULL DEFAULT false;
+-- Order of the attachments in the generated document
+ALTER TABLE invoice_attachments ADD COLUMN IF NOT EXISTS sort_order integer NOT NULL DEFAULT 0;
+COMMIT;
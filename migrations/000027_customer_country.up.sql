@@ -0,0 +1,4 @@
+BEGIN;
+-- ISO 3166-1 alpha-2 code of the country of the customer
+ALTER TABLE customer ADD COLUMN IF NOT EXISTS country text NOT NULL DEFAULT 'SE';
+COMMIT;
@@ -0,0 +1,15 @@
+BEGIN;
+-- Bankgirot serial number of payments imported from BgMax files
+ALTER TABLE invoice_payment ADD COLUMN IF NOT EXISTS serial text NOT NULL DEFAULT '';
+
+-- Payments imported from BgMax files. The serial number of a payment is unique per deposit date,
+-- so the same payment can't be imported twice, even if it is distributed over several invoices.
+CREATE TABLE IF NOT EXISTS imported_payment (
+    id SERIAL PRIMARY KEY,
+    company_id int NOT NULL REFERENCES company(id),
+    serial text NOT NULL,
+    date_paid date NOT NULL,
+    date_created timestamp NOT NULL DEFAULT now(),
+    UNIQUE (company_id, serial, date_paid)
+);
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+ALTER TABLE recurring_invoice_row ADD COLUMN IF NOT EXISTS is_goods boolean NOT NULL DEFAULT false;
+ALTER TABLE recurring_invoice_row ADD COLUMN IF NOT EXISTS account int;
+ALTER TABLE recurring_invoice_row ADD COLUMN IF NOT EXISTS rot_rut_hours int;
+COMMIT;
@@ -0,0 +1,12 @@
+BEGIN;
+-- Decisions from Skatteverket on ROT/RUT requests. If the invoice has several buyers, each buyer gets a separate decision,
+-- and the request is closed when all buyers have one.
+CREATE TABLE IF NOT EXISTS rut_decision (
+    id SERIAL PRIMARY KEY,
+    rut_request_id int NOT NULL REFERENCES rut_requests(id) ON DELETE CASCADE,
+    pnr text NOT NULL,
+    amount int NOT NULL DEFAULT 0,
+    date_decided date NOT NULL,
+    UNIQUE (rut_request_id, pnr)
+);
+COMMIT;
@@ -0,0 +1,231 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/yzzyx/faktura-pdf/sie"
+	"github.com/yzzyx/zerr"
+)
+
+// AccountMap describes which accounts in the chart of accounts (BAS) a company uses for bookings
+type AccountMap struct {
+	CompanyID int
+
+	Receivable       int // Kundfordringar
+	RotRutReceivable int // Kundfordringar - delad faktura (ROT/RUT)
+	Bank             int // Företagskonto/checkkonto/affärskonto
+	Rounding         int // Öres- och kronutjämning
+	ReminderFees     int // Påminnelseavgifter
+	LateInterest     int // Ränteintäkter från kundfordringar
+
+	OutputVAT25 int `db:"output_vat25"` // Utgående moms 25 %
+	OutputVAT12 int `db:"output_vat12"` // Utgående moms 12 %
+	OutputVAT6  int `db:"output_vat6"`  // Utgående moms 6 %
+
+	Goods25     int // Försäljning varor inom Sverige, 25 % moms
+	Goods12     int // Försäljning varor inom Sverige, 12 % moms
+	Goods6      int // Försäljning varor inom Sverige, 6 % moms
+	GoodsExempt int // Försäljning varor inom Sverige, momsfri
+
+	Services25     int // Försäljning tjänster inom Sverige, 25 % moms
+	Services12     int // Försäljning tjänster inom Sverige, 12 % moms
+	Services6      int // Försäljning tjänster inom Sverige, 6 % moms
+	ServicesExempt int // Försäljning tjänster inom Sverige, momsfri
+
+	ReverseCharge  int // Försäljning inom byggsektorn, omvänd skattskyldighet
+	EUGoods        int `db:"eu_goods"`    // Försäljning varor till annat EU-land
+	EUServices     int `db:"eu_services"` // Försäljning tjänster till annat EU-land
+	ExportGoods    int // Försäljning varor utanför EU
+	ExportServices int // Försäljning tjänster utanför EU
+}
+
+// DefaultAccountMap contains the accounts from the BAS chart of accounts, used if a company hasn't configured any
+var DefaultAccountMap = AccountMap{
+	Receivable:       1510,
+	RotRutReceivable: 1513,
+	Bank:             1930,
+	Rounding:         3740,
+	ReminderFees:     3930,
+	LateInterest:     8313,
+
+	OutputVAT25: 2611,
+	OutputVAT12: 2620,
+	OutputVAT6:  2630,
+
+	Goods25:     3001,
+	Goods12:     3002,
+	Goods6:      3003,
+	GoodsExempt: 3004,
+
+	Services25:     3041,
+	Services12:     3042,
+	Services6:      3043,
+	ServicesExempt: 3044,
+
+	ReverseCharge:  3231,
+	EUGoods:        3108,
+	EUServices:     3308,
+	ExportGoods:    3105,
+	ExportServices: 3305,
+}
+
+// Names returns the names of the accounts in the map, to be used in the chart of accounts of SIE files.
+// Accounts in the BAS chart of accounts get their BAS name, and other accounts are named after what they are used for.
+func (m AccountMap) Names() map[int]string {
+	accounts := []struct {
+		nr   int
+		name string
+	}{
+		{m.Receivable, "Kundfordringar"},
+		{m.RotRutReceivable, "Kundfordringar - delad faktura"},
+		{m.Bank, "Företagskonto/checkkonto/affärskonto"},
+		{m.Rounding, "Öres- och kronutjämning"},
+		{m.ReminderFees, "Påminnelseavgifter"},
+		{m.LateInterest, "Ränteintäkter från kundfordringar"},
+		{m.OutputVAT25, "Utgående moms, 25 %"},
+		{m.OutputVAT12, "Utgående moms, 12 %"},
+		{m.OutputVAT6, "Utgående moms, 6 %"},
+		{m.Goods25, "Försäljning varor inom Sverige, 25 % moms"},
+		{m.Goods12, "Försäljning varor inom Sverige, 12 % moms"},
+		{m.Goods6, "Försäljning varor inom Sverige, 6 % moms"},
+		{m.GoodsExempt, "Försäljning varor inom Sverige, momsfri"},
+		{m.Services25, "Försäljning tjänster inom Sverige, 25 % moms"},
+		{m.Services12, "Försäljning tjänster inom Sverige, 12 % moms"},
+		{m.Services6, "Försäljning tjänster inom Sverige, 6 % moms"},
+		{m.ServicesExempt, "Försäljning tjänster inom Sverige, momsfri"},
+		{m.ReverseCharge, "Försäljning inom byggsektorn, omvänd skattskyldighet moms"},
+		{m.EUGoods, "Försäljning varor till annat EU-land, momsfri"},
+		{m.EUServices, "Försäljning tjänster till annat EU-land"},
+		{m.ExportGoods, "Försäljning varor till land utanför EU"},
+		{m.ExportServices, "Försäljning tjänster till land utanför EU"},
+	}
+
+	names := map[int]string{}
+	for _, a := range accounts {
+		if _, ok := names[a.nr]; ok || a.nr == 0 {
+			continue
+		}
+
+		if name, ok := sie.BASAccounts[a.nr]; ok {
+			names[a.nr] = name
+		} else {
+			names[a.nr] = a.name
+		}
+	}
+	return names
+}
+
+// Sales returns the account used for the income of an invoice row.
+// The account set on the row is used if there is one, otherwise it depends on the VAT type,
+// and if the row is goods or services.
+func (m AccountMap) Sales(row InvoiceRow) int {
+	if row.Account != nil && *row.Account > 0 {
+		return *row.Account
+	}
+
+	switch row.VAT {
+	case VATTypeReverseCharge:
+		return m.ReverseCharge
+	case VATTypeEU:
+		if row.IsGoods {
+			return m.EUGoods
+		}
+		return m.EUServices
+	case VATTypeExport:
+		if row.IsGoods {
+			return m.ExportGoods
+		}
+		return m.ExportServices
+	}
+
+	goods := map[VATType]int{0: m.Goods25, 1: m.Goods12, 2: m.Goods6, 3: m.GoodsExempt}
+	services := map[VATType]int{0: m.Services25, 1: m.Services12, 2: m.Services6, 3: m.ServicesExempt}
+	if row.IsGoods {
+		return goods[row.VAT]
+	}
+	return services[row.VAT]
+}
+
+// OutputVAT returns the account used for output VAT for a VAT rate, or 0 if no VAT is charged
+func (m AccountMap) OutputVAT(vat VATType) int {
+	switch vat {
+	case 0:
+		return m.OutputVAT25
+	case 1:
+		return m.OutputVAT12
+	case 2:
+		return m.OutputVAT6
+	}
+	return 0
+}
+
+// AccountMapGet returns the account map of a company, or the default map if the company hasn't configured one
+func AccountMapGet(ctx context.Context, companyID int) (AccountMap, error) {
+	var m AccountMap
+	tx := getContextTx(ctx)
+
+	query := `SELECT company_id, receivable, rot_rut_receivable, bank, rounding, reminder_fees, late_interest,
+ output_vat25, output_vat12, output_vat6,
+ goods25, goods12, goods6, goods_exempt,
+ services25, services12, services6, services_exempt,
+ reverse_charge, eu_goods, eu_services, export_goods, export_services
+FROM account_map WHERE company_id = $1`
+	err := tx.Get(ctx, &m, query, companyID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			m = DefaultAccountMap
+			m.CompanyID = companyID
+			return m, nil
+		}
+		return m, zerr.Wrap(err).WithString("query", query).WithInt("company-id", companyID)
+	}
+	return m, nil
+}
+
+// AccountMapSave creates or updates the account map of a company
+func AccountMapSave(ctx context.Context, m AccountMap) error {
+	tx := getContextTx(ctx)
+
+	query := `INSERT INTO account_map
+(company_id, receivable, rot_rut_receivable, bank, rounding, reminder_fees, late_interest,
+ output_vat25, output_vat12, output_vat6,
+ goods25, goods12, goods6, goods_exempt,
+ services25, services12, services6, services_exempt,
+ reverse_charge, eu_goods, eu_services, export_goods, export_services)
+VALUES
+(:company_id, :receivable, :rot_rut_receivable, :bank, :rounding, :reminder_fees, :late_interest,
+ :output_vat25, :output_vat12, :output_vat6,
+ :goods25, :goods12, :goods6, :goods_exempt,
+ :services25, :services12, :services6, :services_exempt,
+ :reverse_charge, :eu_goods, :eu_services, :export_goods, :export_services)
+ON CONFLICT (company_id) DO UPDATE SET
+    receivable = EXCLUDED.receivable,
+    rot_rut_receivable = EXCLUDED.rot_rut_receivable,
+    bank = EXCLUDED.bank,
+    rounding = EXCLUDED.rounding,
+    reminder_fees = EXCLUDED.reminder_fees,
+    late_interest = EXCLUDED.late_interest,
+    output_vat25 = EXCLUDED.output_vat25,
+    output_vat12 = EXCLUDED.output_vat12,
+    output_vat6 = EXCLUDED.output_vat6,
+    goods25 = EXCLUDED.goods25,
+    goods12 = EXCLUDED.goods12,
+    goods6 = EXCLUDED.goods6,
+    goods_exempt = EXCLUDED.goods_exempt,
+    services25 = EXCLUDED.services25,
+    services12 = EXCLUDED.services12,
+    services6 = EXCLUDED.services6,
+    services_exempt = EXCLUDED.services_exempt,
+    reverse_charge = EXCLUDED.reverse_charge,
+    eu_goods = EXCLUDED.eu_goods,
+    eu_services = EXCLUDED.eu_services,
+    export_goods = EXCLUDED.export_goods,
+    export_services = EXCLUDED.export_services`
+
+	_, err := tx.NamedExec(ctx, query, m)
+	if err != nil {
+		return zerr.Wrap(err).WithString("query", query).WithAny("account-map", m)
+	}
+	return nil
+}
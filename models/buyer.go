@@ -0,0 +1,89 @@
+package models
+
+import (
+	"context"
+
+	"github.com/yzzyx/zerr"
+)
+
+// Buyer is one of several buyers that share the ROT/RUT deduction of an invoice,
+// e.g. a couple that both own the house where the work is performed
+type Buyer struct {
+	ID        int
+	InvoiceID int
+	Name      string
+	PNR       string
+	Share     int // Share of the deduction, in percent
+}
+
+// ROTRUTBuyers returns the buyers that share the deduction of the invoice.
+// If no buyers are specified, the customer is the only buyer.
+func (i *Invoice) ROTRUTBuyers() []Buyer {
+	if len(i.Buyers) > 0 {
+		return i.Buyers
+	}
+	return []Buyer{{InvoiceID: i.ID, Name: i.Customer.Name, PNR: i.Customer.PNR, Share: 100}}
+}
+
+// BuyerShares returns the sum of the shares of the buyers of the invoice, in percent
+func (i *Invoice) BuyerShares() int {
+	sum := 0
+	for _, b := range i.ROTRUTBuyers() {
+		sum += b.Share
+	}
+	return sum
+}
+
+// SplitByShare splits amount between buyers in proportion to their shares.
+// What is left when the amount can't be split evenly is given to the last buyer,
+// so that the parts always add up to the amount.
+func SplitByShare(amount int64, buyers []Buyer) []int64 {
+	parts := make([]int64, len(buyers))
+	rest := amount
+	for k, b := range buyers {
+		if k == len(buyers)-1 {
+			parts[k] = rest
+			break
+		}
+		parts[k] = amount * int64(b.Share) / 100
+		rest -= parts[k]
+	}
+	return parts
+}
+
+// BuyerList returns the buyers that share the deduction of an invoice
+func BuyerList(ctx context.Context, invoiceID int) ([]Buyer, error) {
+	var buyers []Buyer
+	tx := getContextTx(ctx)
+
+	query := `SELECT id, invoice_id, name, pnr, share FROM invoice_buyer WHERE invoice_id = $1 ORDER BY id`
+	err := tx.Select(ctx, &buyers, query, invoiceID)
+	if err != nil {
+		return nil, zerr.Wrap(err).WithString("query", query).WithInt("invoice-id", invoiceID)
+	}
+	return buyers, nil
+}
+
+// BuyerAdd adds a buyer to an invoice
+func BuyerAdd(ctx context.Context, b Buyer) (int, error) {
+	tx := getContextTx(ctx)
+
+	query := `INSERT INTO invoice_buyer (invoice_id, name, pnr, share) VALUES ($1, $2, $3, $4) RETURNING id`
+	err := tx.QueryRow(ctx, query, b.InvoiceID, b.Name, b.PNR, b.Share).Scan(&b.ID)
+	if err != nil {
+		return 0, zerr.Wrap(err).WithString("query", query).WithAny("buyer", b)
+	}
+	return b.ID, nil
+}
+
+// BuyerRemove removes a buyer from an invoice
+func BuyerRemove(ctx context.Context, invoiceID int, id int) error {
+	tx := getContextTx(ctx)
+
+	query := `DELETE FROM invoice_buyer WHERE invoice_id = $1 AND id = $2`
+	_, err := tx.Exec(ctx, query, invoiceID, id)
+	if err != nil {
+		return zerr.Wrap(err).WithString("query", query).WithInt("invoice-id", invoiceID).WithInt("id", id)
+	}
+	return nil
+}
@@ -6,6 +6,8 @@ import (
 	"errors"
 	"strings"
 
+	"github.com/shopspring/decimal"
+	"github.com/yzzyx/faktura-pdf/cii"
 	"github.com/yzzyx/zerr"
 )
 
@@ -25,6 +27,56 @@ func (t PaymentType) String() string {
 	return paymentTypeStrings[t]
 }
 
+// AccountingMethod decides when income and VAT are booked
+type AccountingMethod int
+
+const (
+	AccountingMethodAccrual AccountingMethod = iota // Faktureringsmetoden, booked when the invoice is sent
+	AccountingMethodCash                            // Kontantmetoden, booked when the invoice is paid
+)
+
+var accountingMethodStrings = map[AccountingMethod]string{
+	AccountingMethodAccrual: "Faktureringsmetoden",
+	AccountingMethodCash:    "Kontantmetoden",
+}
+
+// AccountingMethods lists all available accounting methods
+var AccountingMethods = accountingMethodStrings
+
+func (m AccountingMethod) String() string {
+	return accountingMethodStrings[m]
+}
+
+func (m AccountingMethod) Validate() bool {
+	_, ok := accountingMethodStrings[m]
+	return ok
+}
+
+// PDFRenderer decides how invoices, offers and reminders are rendered as PDF
+type PDFRenderer int
+
+const (
+	PDFRendererLaTeX  PDFRenderer = iota // Rendered with xelatex from the .tex templates
+	PDFRendererNative                    // Rendered in Go, without external tools
+)
+
+var pdfRendererStrings = map[PDFRenderer]string{
+	PDFRendererLaTeX:  "LaTeX-mallar",
+	PDFRendererNative: "Inbyggd layout",
+}
+
+// PDFRenderers lists all available PDF renderers
+var PDFRenderers = pdfRendererStrings
+
+func (r PDFRenderer) String() string {
+	return pdfRendererStrings[r]
+}
+
+func (r PDFRenderer) Validate() bool {
+	_, ok := pdfRendererStrings[r]
+	return ok
+}
+
 type Company struct {
 	ID        int
 	Name      string
@@ -39,7 +91,15 @@ type Company struct {
 	CompanyID      string
 	PaymentAccount string
 	PaymentType    PaymentType
-	VATNumber      string `db:"vat_number"`
+	VATNumber      string   `db:"vat_number"`
+	OCRLevel       OCRLevel `db:"ocr_level"`
+	OCRLengthDigit bool     `db:"ocr_length_digit"`
+
+	AccountingMethod AccountingMethod
+	PDFRenderer      PDFRenderer `db:"pdf_renderer"`
+	FacturXProfile   cii.Profile `db:"facturx_profile"`
+
+	ReminderFee decimal.Decimal
 
 	InvoiceNumber    int
 	InvoiceDueDays   int
@@ -116,12 +176,18 @@ SELECT
     payment_account,
     payment_type,
     vat_number,
+    ocr_level,
+    ocr_length_digit,
+    accounting_method,
+    pdf_renderer,
+    facturx_profile,
 
     invoice_number,
     invoice_due_days,
     invoice_reference,
     invoice_text,
     invoice_template,
+    reminder_fee,
 
     offer_text,
     offer_template
@@ -204,12 +270,19 @@ func CompanySave(ctx context.Context, c Company) (int, error) {
     payment_account = :payment_account,
     payment_type = :payment_type,
     vat_number = :vat_number,
+    ocr_level = :ocr_level,
+    ocr_length_digit = :ocr_length_digit,
+    accounting_method = :accounting_method,
+    pdf_renderer = :pdf_renderer,
+:facturx_profile,
+    facturx_profile = :facturx_profile,
 
     invoice_number = :invoice_number,
     invoice_due_days = :invoice_due_days,
     invoice_reference = :invoice_reference,
     invoice_text = :invoice_text,
     invoice_template = :invoice_template,
+    reminder_fee = :reminder_fee,
 
     offer_text= :offer_text,
     offer_template = :offer_template 
@@ -236,12 +309,18 @@ WHERE id = :id`
     payment_account,
     payment_type,
     vat_number,
+    ocr_level,
+    ocr_length_digit,
+    accounting_method,
+    pdf_renderer,
+    facturx_profile,
 
     invoice_number,
     invoice_due_days,
     invoice_reference,
     invoice_text,
     invoice_template,
+    reminder_fee,
 
     offer_text,
     offer_template)
@@ -258,11 +337,17 @@ VALUES
 :payment_account,
 :payment_type,
 :vat_number,
+:ocr_level,
+:ocr_length_digit,
+:accounting_method,
+:pdf_renderer,
+:facturx_profile,
 :invoice_number,
 :invoice_due_days,
 :invoice_reference,
 :invoice_text,
 :invoice_template,
+:reminder_fee,
 :offer_text,
 :offer_template)
 RETURNING id`
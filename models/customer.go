@@ -3,6 +3,7 @@ package models
 import (
 	"context"
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/yzzyx/zerr"
@@ -19,9 +20,55 @@ type Customer struct {
 	PNR       string `json:"pnr"`
 	Telephone string `json:"telephone"`
 
+	// Used for electronic invoices (Peppol)
+	PeppolID  string `json:"peppol_id"` // Electronic address, in the format <scheme>:<identifier>
+	Reference string `json:"reference"` // Buyer reference
+
+	// VAT registration number of the buyer, required on invoices with reverse charge and intra-EU sales
+	VATNumber string `json:"vat_number"`
+
+	// Country of the customer, as an ISO 3166-1 alpha-2 code
+	Country string `json:"country"`
+
+	// Property where ROT work is performed, either a house or an apartment in a housing cooperative
+	PropertyDesignation  string `json:"property_designation"`   // Fastighetsbeteckning
+	ApartmentNumber      string `json:"apartment_number"`       // Lägenhetsnummer
+	HousingCooperativeID string `json:"housing_cooperative_id"` // Organisationsnummer för bostadsrättsföreningen
+
 	CompanyID int `json:"company_id"`
 }
 
+// HasROTProperty returns true if the property where ROT work is performed is specified,
+// either by property designation, or by apartment number and housing cooperative
+func (c Customer) HasROTProperty() bool {
+	return c.PropertyDesignation != "" || (c.ApartmentNumber != "" && c.HousingCooperativeID != "")
+}
+
+// CountryCode returns the country code of the customer, defaulting to Sweden
+func (c Customer) CountryCode() string {
+	if code := strings.ToUpper(strings.TrimSpace(c.Country)); code != "" {
+		return code
+	}
+	return "SE"
+}
+
+var rePNR6_4 = regexp.MustCompile(`^\d{6}-\d{4}$`)
+var rePNR8_4 = regexp.MustCompile(`^\d{8}-\d{4}$`)
+
+// NormalizePNR converts a personal identity number to the format YYYYMMDDXXXX, which is the format
+// required by SKV. Numbers in other formats are returned as they are.
+func NormalizePNR(pnr string) string {
+	if rePNR6_4.MatchString(pnr) {
+		if year := pnr[0:2]; year > "20" {
+			return "19" + strings.ReplaceAll(pnr, "-", "")
+		}
+		return "20" + strings.ReplaceAll(pnr, "-", "")
+	} else if rePNR8_4.MatchString(pnr) {
+		return strings.ReplaceAll(pnr, "-", "")
+	}
+	return pnr
+}
+
 type CustomerFilter struct {
 	ID        int
 	CompanyID int
@@ -44,7 +91,14 @@ SELECT
     postcode,
 	city,
     pnr,
-    telephone
+    telephone,
+    peppol_id,
+    reference,
+    property_designation,
+    apartment_number,
+    housing_cooperative_id,
+    vat_number,
+    country
 FROM customer
 `
 	filterstrings := []string{}
@@ -122,7 +176,14 @@ address2 = $5,
 postcode = $6,
 city = $7,
 pnr = $8,
-telephone = $9
+telephone = $9,
+peppol_id = $10,
+reference = $11,
+property_designation = $12,
+apartment_number = $13,
+housing_cooperative_id = $14,
+vat_number = $15,
+country = $16
 WHERE id = $1`
 		_, err := tx.Exec(ctx, query, customer.ID,
 			customer.Name,
@@ -132,7 +193,14 @@ WHERE id = $1`
 			customer.Postcode,
 			customer.City,
 			customer.PNR,
-			customer.Telephone)
+			customer.Telephone,
+			customer.PeppolID,
+			customer.Reference,
+			customer.PropertyDesignation,
+			customer.ApartmentNumber,
+			customer.HousingCooperativeID,
+			customer.VATNumber,
+			customer.CountryCode())
 		if err != nil {
 			return 0, zerr.Wrap(err).WithString("query", query).WithAny("customer", customer)
 		}
@@ -140,9 +208,9 @@ WHERE id = $1`
 	}
 
 	query := `INSERT INTO customer 
-(name, email, address1, address2, postcode, city, pnr, telephone, company_id)
+(name, email, address1, address2, postcode, city, pnr, telephone, peppol_id, reference, property_designation, apartment_number, housing_cooperative_id, vat_number, country, company_id)
 VALUES
-($1, $2, $3, $4, $5, $6, $7, $8, $9)
+($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
 RETURNING id`
 
 	err := tx.QueryRow(ctx, query,
@@ -154,6 +222,13 @@ RETURNING id`
 		customer.City,
 		customer.PNR,
 		customer.Telephone,
+		customer.PeppolID,
+		customer.Reference,
+		customer.PropertyDesignation,
+		customer.ApartmentNumber,
+		customer.HousingCooperativeID,
+		customer.VATNumber,
+		customer.CountryCode(),
 		customer.CompanyID).Scan(&customer.ID)
 	if err != nil {
 		return 0, zerr.Wrap(err).WithString("query", query).WithAny("customer", customer)
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/yzzyx/zerr"
+)
+
+// RUTDecision is the decision from Skatteverket for one buyer of a ROT/RUT request
+type RUTDecision struct {
+	ID          int
+	RUTID       int    `db:"rut_request_id"`
+	PNR         string // Normalized personal identity number of the buyer
+	Amount      int    // Approved amount
+	DateDecided time.Time
+}
+
+// RUTDecisionList returns the decisions registered for a request
+func RUTDecisionList(ctx context.Context, rutID int) ([]RUTDecision, error) {
+	var decisions []RUTDecision
+	tx := getContextTx(ctx)
+
+	query := `SELECT id, rut_request_id, pnr, amount, date_decided FROM rut_decision WHERE rut_request_id = $1 ORDER BY id`
+	err := tx.Select(ctx, &decisions, query, rutID)
+	if err != nil {
+		return nil, zerr.Wrap(err).WithString("query", query).WithInt("rut-id", rutID)
+	}
+	return decisions, nil
+}
+
+// RUTDecisionSave registers the decision for a buyer of a request.
+// A decision that is already registered for the buyer is replaced.
+func RUTDecisionSave(ctx context.Context, d RUTDecision) (int, error) {
+	tx := getContextTx(ctx)
+
+	query := `INSERT INTO rut_decision (rut_request_id, pnr, amount, date_decided) VALUES ($1, $2, $3, $4)
+ON CONFLICT (rut_request_id, pnr) DO UPDATE SET amount = EXCLUDED.amount, date_decided = EXCLUDED.date_decided
+RETURNING id`
+	err := tx.QueryRow(ctx, query, d.RUTID, d.PNR, d.Amount, d.DateDecided).Scan(&d.ID)
+	if err != nil {
+		return 0, zerr.Wrap(err).WithString("query", query).WithAny("decision", d)
+	}
+	return d.ID, nil
+}
@@ -0,0 +1,299 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/yzzyx/zerr"
+)
+
+// Yearly limits for the ROT/RUT deduction of a person, in SEK
+const (
+	DeductionLimit    = 75000 // ROT and RUT combined
+	DeductionLimitROT = 50000 // ROT, which is included in the combined limit
+)
+
+// ExternalDeduction is a ROT/RUT deduction that a buyer has received from another company
+type ExternalDeduction struct {
+	ID          int
+	CompanyID   int
+	PNR         string
+	Year        int
+	Type        RUTType
+	Amount      int
+	Description string
+	DateCreated time.Time
+}
+
+// DeductionUsage is the ROT/RUT deduction used by a person during a calendar year
+type DeductionUsage struct {
+	Year        int
+	ROT         int // ROT requested from our invoices
+	RUT         int // RUT requested from our invoices
+	ExternalROT int // ROT received from other companies
+	ExternalRUT int // RUT received from other companies
+	External    []ExternalDeduction
+}
+
+// TotalROT returns the ROT deduction used during the year
+func (u DeductionUsage) TotalROT() int {
+	return u.ROT + u.ExternalROT
+}
+
+// Total returns the ROT and RUT deduction used during the year
+func (u DeductionUsage) Total() int {
+	return u.ROT + u.RUT + u.ExternalROT + u.ExternalRUT
+}
+
+// Remaining returns the ROT and RUT deduction left for the year
+func (u DeductionUsage) Remaining() int {
+	if u.Total() >= DeductionLimit {
+		return 0
+	}
+	return DeductionLimit - u.Total()
+}
+
+// RemainingROT returns the ROT deduction left for the year.
+// Since ROT is included in the combined limit, this is never more than Remaining.
+func (u DeductionUsage) RemainingROT() int {
+	remaining := DeductionLimitROT - u.TotalROT()
+	if remaining > u.Remaining() {
+		remaining = u.Remaining()
+	}
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// Exceeds returns true if a further deduction of rot and rut would exceed the limits for the year
+func (u DeductionUsage) Exceeds(rot, rut int) bool {
+	return rot > u.RemainingROT() || rot+rut > u.Remaining()
+}
+
+// Deduction returns the ROT and RUT deduction of the invoice, rounded down to whole kronor
+func (i *Invoice) Deduction() (rot int, rut int) {
+	rotSum := decimal.Zero
+	rutSum := decimal.Zero
+	date := i.RateDate()
+	for _, row := range i.Rows {
+		if !row.IsRotRut || row.RotRutServiceType == nil {
+			continue
+		}
+
+		totals := row.Totals(date, true, true)
+		if row.RotRutServiceType.IsROT() {
+			rotSum = rotSum.Add(totals.ROTRUT)
+		} else {
+			rutSum = rutSum.Add(totals.ROTRUT)
+		}
+	}
+	return int(rotSum.IntPart()), int(rutSum.IntPart())
+}
+
+// deductionRequest is a ROT/RUT request, with the fields needed to count it against the yearly limit
+type deductionRequest struct {
+	Type                RUTType
+	Status              RUTStatus
+	RequestedSum        *int
+	ReceivedSum         *int
+	InvoiceID           int
+	InvoiceDateInvoiced *time.Time
+	PNR                 string
+}
+
+// deductionRow is a row of an invoice with a ROT/RUT request, or of a credit note for the invoice
+type deductionRow struct {
+	InvoiceID int `db:"invoice_id"`
+	InvoiceRow
+}
+
+// pnrFormats returns the formats that the personal identity number pnr may be stored in,
+// i.e. all formats that NormalizePNR converts to the same number
+func pnrFormats(pnr string) []string {
+	pnr = NormalizePNR(pnr)
+	formats := []string{pnr}
+	if len(pnr) != 12 {
+		return formats
+	}
+
+	for _, f := range []string{pnr[:8] + "-" + pnr[8:], pnr[2:8] + "-" + pnr[8:]} {
+		if NormalizePNR(f) == pnr {
+			formats = append(formats, f)
+		}
+	}
+	return formats
+}
+
+// DeductionUsageGet returns the ROT/RUT deduction used by the person with personal identity number pnr
+// during a year, from all requests of the company and the deductions registered from other companies.
+// If an invoice has several buyers, only the person's share of the deduction is counted.
+// The deduction counts towards the year that the buyer paid the invoice. Rejected and withdrawn requests are not counted,
+// and neither are the requests of the invoice excludeInvoiceID.
+func DeductionUsageGet(ctx context.Context, companyID int, pnr string, year int, excludeInvoiceID int) (DeductionUsage, error) {
+	usage := DeductionUsage{Year: year}
+	pnr = NormalizePNR(pnr)
+	formats := pnrFormats(pnr)
+	tx := getContextTx(ctx)
+
+	// The person is either one of the buyers of the invoice, or the customer if the invoice has no buyers.
+	// Invoices that are not paid yet count towards the current year.
+	var requests []deductionRequest
+	query := `SELECT
+rut_requests.type,
+rut_requests.status,
+rut_requests.requested_sum,
+rut_requests.received_sum,
+invoice.id AS invoice_id,
+invoice.date_invoiced AS invoice_date_invoiced,
+customer.pnr
+FROM rut_requests
+INNER JOIN invoice ON invoice.id = rut_requests.invoice_id
+INNER JOIN customer ON customer.id = invoice.customer_id
+WHERE invoice.company_id = $1 AND invoice.id <> $2 AND NOT invoice.is_deleted AND rut_requests.status NOT IN ($3, $4)
+AND EXTRACT(YEAR FROM COALESCE(invoice.date_paid, now())) = $5
+AND (EXISTS (SELECT 1 FROM invoice_buyer WHERE invoice_buyer.invoice_id = invoice.id AND invoice_buyer.pnr = ANY($6))
+	OR (customer.pnr = ANY($6) AND NOT EXISTS (SELECT 1 FROM invoice_buyer WHERE invoice_buyer.invoice_id = invoice.id)))`
+	err := tx.Select(ctx, &requests, query, companyID, excludeInvoiceID, RUTStatusRejected, RUTStatusWithdrawn, year, formats)
+	if err != nil {
+		return usage, zerr.Wrap(err).WithString("query", query).WithInt("company-id", companyID).WithInt("year", year)
+	}
+
+	var invoiceIDs, undecidedIDs []int
+	for _, r := range requests {
+		invoiceIDs = append(invoiceIDs, r.InvoiceID)
+		if r.RequestedSum == nil && (r.Status != RUTStatusPaid || r.ReceivedSum == nil) {
+			undecidedIDs = append(undecidedIDs, r.InvoiceID)
+		}
+	}
+
+	// Invoices with several buyers share the deduction between them
+	invoiceBuyers := map[int][]Buyer{}
+	if len(invoiceIDs) > 0 {
+		var buyers []Buyer
+		query = `SELECT id, invoice_id, name, pnr, share FROM invoice_buyer WHERE invoice_id = ANY($1) ORDER BY id`
+		err = tx.Select(ctx, &buyers, query, invoiceIDs)
+		if err != nil {
+			return usage, zerr.Wrap(err).WithString("query", query).WithInt("company-id", companyID)
+		}
+
+		for _, b := range buyers {
+			invoiceBuyers[b.InvoiceID] = append(invoiceBuyers[b.InvoiceID], b)
+		}
+	}
+
+	// Requests without a decided amount use the deduction on the invoice, less any credit notes
+	invoiceRows := map[int][]InvoiceRow{}
+	if len(undecidedIDs) > 0 {
+		var rows []deductionRow
+		query = `SELECT COALESCE(invoice.credit_invoice_id, invoice.id) AS invoice_id,
+invoice_row.cost, invoice_row.count, invoice_row.is_rot_rut, invoice_row.rot_rut_service_type
+FROM invoice_row
+INNER JOIN invoice ON invoice.id = invoice_row.invoice_id
+WHERE NOT invoice.is_deleted AND (invoice.id = ANY($1) OR invoice.credit_invoice_id = ANY($1))`
+		err = tx.Select(ctx, &rows, query, undecidedIDs)
+		if err != nil {
+			return usage, zerr.Wrap(err).WithString("query", query).WithInt("company-id", companyID)
+		}
+
+		for _, r := range rows {
+			invoiceRows[r.InvoiceID] = append(invoiceRows[r.InvoiceID], r.InvoiceRow)
+		}
+	}
+
+	for _, r := range requests {
+		requestBuyers, ok := invoiceBuyers[r.InvoiceID]
+		if !ok {
+			requestBuyers = []Buyer{{PNR: r.PNR, Share: 100}}
+		}
+
+		buyer := -1
+		for k, b := range requestBuyers {
+			if NormalizePNR(b.PNR) == pnr {
+				buyer = k
+			}
+		}
+		if buyer < 0 {
+			continue
+		}
+
+		var amount int
+		switch {
+		case r.Status == RUTStatusPaid && r.ReceivedSum != nil:
+			amount = *r.ReceivedSum
+		case r.RequestedSum != nil:
+			amount = *r.RequestedSum
+		default:
+			// The amount hasn't been decided yet, so we use the deduction on the invoice
+			inv := Invoice{DateInvoiced: r.InvoiceDateInvoiced, Rows: invoiceRows[r.InvoiceID]}
+			rot, rut := inv.Deduction()
+			amount = rut
+			if r.Type == RUTTypeROT {
+				amount = rot
+			}
+		}
+
+		amount = int(SplitByShare(int64(amount), requestBuyers)[buyer])
+		if r.Type == RUTTypeROT {
+			usage.ROT += amount
+		} else {
+			usage.RUT += amount
+		}
+	}
+
+	usage.External, err = ExternalDeductionList(ctx, companyID, pnr, year)
+	if err != nil {
+		return usage, err
+	}
+
+	for _, d := range usage.External {
+		if d.Type == RUTTypeROT {
+			usage.ExternalROT += d.Amount
+		} else {
+			usage.ExternalRUT += d.Amount
+		}
+	}
+	return usage, nil
+}
+
+// ExternalDeductionList returns the deductions from other companies registered for a person during a year
+func ExternalDeductionList(ctx context.Context, companyID int, pnr string, year int) ([]ExternalDeduction, error) {
+	var deductions []ExternalDeduction
+	tx := getContextTx(ctx)
+
+	query := `SELECT id, company_id, pnr, year, type, amount, description, date_created
+FROM external_deduction
+WHERE company_id = $1 AND pnr = $2 AND year = $3
+ORDER BY date_created, id`
+	err := tx.Select(ctx, &deductions, query, companyID, NormalizePNR(pnr), year)
+	if err != nil {
+		return nil, zerr.Wrap(err).WithString("query", query).WithInt("company-id", companyID).WithInt("year", year)
+	}
+	return deductions, nil
+}
+
+// ExternalDeductionAdd registers a deduction that a person has received from another company
+func ExternalDeductionAdd(ctx context.Context, d ExternalDeduction) (int, error) {
+	tx := getContextTx(ctx)
+
+	query := `INSERT INTO external_deduction (company_id, pnr, year, type, amount, description)
+VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+	err := tx.QueryRow(ctx, query, d.CompanyID, NormalizePNR(d.PNR), d.Year, d.Type, d.Amount, d.Description).Scan(&d.ID)
+	if err != nil {
+		return 0, zerr.Wrap(err).WithString("query", query).WithAny("deduction", d)
+	}
+	return d.ID, nil
+}
+
+// ExternalDeductionRemove removes a registered deduction from another company
+func ExternalDeductionRemove(ctx context.Context, companyID int, id int) error {
+	tx := getContextTx(ctx)
+
+	query := `DELETE FROM external_deduction WHERE company_id = $1 AND id = $2`
+	_, err := tx.Exec(ctx, query, companyID, id)
+	if err != nil {
+		return zerr.Wrap(err).WithString("query", query).WithInt("id", id)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestDeductionUsage(t *testing.T) {
+	tests := []struct {
+		usage        DeductionUsage
+		remaining    int
+		remainingROT int
+	}{
+		{DeductionUsage{}, 75000, 50000},
+		{DeductionUsage{ROT: 20000, ExternalROT: 10000}, 45000, 20000},
+		{DeductionUsage{RUT: 30000, ExternalRUT: 10000}, 35000, 35000},
+		{DeductionUsage{ROT: 50000, RUT: 10000}, 15000, 0},
+		{DeductionUsage{RUT: 70000, ExternalROT: 10000}, 0, 0},
+	}
+
+	for k, tt := range tests {
+		if r := tt.usage.Remaining(); r != tt.remaining {
+			t.Errorf("test %d: expected %d remaining, got %d", k, tt.remaining, r)
+		}
+		if r := tt.usage.RemainingROT(); r != tt.remainingROT {
+			t.Errorf("test %d: expected %d remaining ROT, got %d", k, tt.remainingROT, r)
+		}
+	}
+
+	usage := DeductionUsage{ROT: 40000, RUT: 20000}
+	if usage.Exceeds(10000, 5000) {
+		t.Errorf("expected deduction of 10000 ROT and 5000 RUT to be within the limits")
+	}
+	if !usage.Exceeds(10001, 0) {
+		t.Errorf("expected deduction of 10001 ROT to exceed the ROT limit")
+	}
+	if !usage.Exceeds(0, 15001) {
+		t.Errorf("expected deduction of 15001 RUT to exceed the combined limit")
+	}
+}
+
+func TestInvoiceDeduction(t *testing.T) {
+	rot := ROTServiceTypeBygg
+	rut := RUTServiceTypeTradgardsarbete
+	invoiced := time.Date(2024, time.June, 1, 0, 0, 0, 0, time.Local)
+	inv := Invoice{
+		DateInvoiced: &invoiced,
+		Rows: []InvoiceRow{
+			{Cost: decimal.NewFromInt(1000), Count: decimal.NewFromInt(2), IsRotRut: true, RotRutServiceType: &rot},
+			{Cost: decimal.NewFromInt(500), Count: decimal.NewFromInt(1), IsRotRut: true, RotRutServiceType: &rut},
+			{Cost: decimal.NewFromInt(300), Count: decimal.NewFromInt(1)},
+		},
+	}
+
+	rotSum, rutSum := inv.Deduction()
+	if rotSum != 600 || rutSum != 250 {
+		t.Errorf("expected ROT 600 and RUT 250, got ROT %d and RUT %d", rotSum, rutSum)
+	}
+}
+
+func TestPNRFormats(t *testing.T) {
+	tests := []struct {
+		pnr     string
+		formats []string
+	}{
+		{"800101-1231", []string{"198001011231", "19800101-1231", "800101-1231"}},
+		{"200101011231", []string{"200101011231", "20010101-1231", "010101-1231"}},
+		// Born more than 100 years ago, which can't be written with six digits
+		{"19150101-1231", []string{"191501011231", "19150101-1231"}},
+	}
+
+	for _, tt := range tests {
+		formats := pnrFormats(tt.pnr)
+		if strings.Join(formats, ",") != strings.Join(tt.formats, ",") {
+			t.Errorf("%s: got formats %v, expected %v", tt.pnr, formats, tt.formats)
+		}
+	}
+}
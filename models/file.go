@@ -12,6 +12,7 @@ type File struct {
 	Name      string
 	CompanyID int
 	MIMEType  string
+	SortOrder int `db:"sort_order"` // Order of the attachment, when listed by invoice
 
 	// Used for storage
 	Backend  *string
@@ -30,6 +31,11 @@ func (f File) IsImage() bool {
 	return strings.HasPrefix(f.MIMEType, "image/")
 }
 
+// IsPDF returns true if the file is a PDF document
+func (f File) IsPDF() bool {
+	return f.MIMEType == "application/pdf"
+}
+
 func FileAdd(ctx context.Context, f File) (int, error) {
 	tx := getContextTx(ctx)
 
@@ -80,6 +86,9 @@ func FileList(ctx context.Context, f FileFilter) ([]File, error) {
 	if f.IncludeContent {
 		additionalCol = ", contents"
 	}
+	if f.InvoiceID != 0 {
+		additionalCol += ", ia.sort_order"
+	}
 	query := `SELECT
 id,
 name,
@@ -109,6 +118,10 @@ FROM file
 		query += "\nWHERE\n" + strings.Join(filterStrings, " AND ")
 	}
 
+	if f.InvoiceID != 0 {
+		query += "\nORDER BY ia.sort_order, file.id"
+	}
+
 	rows, err := tx.NamedQuery(ctx, query, f)
 	if err != nil {
 		return nil, zerr.Wrap(err).WithString("query", query).WithAny("filter", f)
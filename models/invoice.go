@@ -4,11 +4,13 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/shopspring/decimal"
+	"github.com/yzzyx/faktura-pdf/rates"
 	"github.com/yzzyx/zerr"
 )
 
@@ -33,16 +35,29 @@ type Invoice struct {
 	TotalSum       decimal.Decimal
 	Customer       Customer
 	Rows           []InvoiceRow
+	Payments       []Payment
+	Reminders      []Reminder
+	Buyers         []Buyer // Buyers sharing the ROT/RUT deduction, if there are more than one
 	IsInvoiced     bool
 	IsPaid         bool
 	IsDeleted      bool
 	RutApplicable  bool // Is ROT/RUT applicable for this invoice?
 	AdditionalInfo string
 	Status         InvoiceStatus
+	OCR            string // Payment reference, set when the invoice is sent
 
 	IsOffer bool // Is this an offer, instead of an invoice?
 	OfferID *int // Was this invoice created from an offer?
 
+	IsCreditNote        bool       // Is this a credit note (kreditfaktura)?
+	CreditInvoiceID     *int       // Invoice credited by this credit note
+	CreditInvoiceNumber *int       // Number of the invoice credited by this credit note
+	CreditInvoiceDate   *time.Time // Invoice date of the invoice credited by this credit note
+
+	RecurringInvoiceID *int // Was this invoice issued from a recurring invoice?
+
+	MergeAttachments bool // Are attached PDFs and images appended to the generated document?
+
 	Company Company
 }
 
@@ -67,18 +82,31 @@ func (u UnitType) String() string {
 
 type VATType int
 
+// VAT types where no VAT is charged, since the buyer accounts for the VAT or the sale is exempt
+const (
+	VATTypeReverseCharge VATType = 4 // Omvänd betalningsskyldighet inom byggsektorn
+	VATTypeEU            VATType = 5 // Försäljning till momsregistrerad köpare i annat EU-land
+	VATTypeExport        VATType = 6 // Export utanför EU
+)
+
 var vatTypeString = map[int]string{
 	0: "25 %",
 	1: "12 %",
 	2: "6 %",
 	3: "0 %",
+	4: "0 % (omvänd)",
+	5: "0 % (EU)",
+	6: "0 % (export)",
 }
 
-var vatTypeAmount = map[VATType]decimal.Decimal{
-	0: decimal.NewFromFloat(0.25),
-	1: decimal.NewFromFloat(0.12),
-	2: decimal.NewFromFloat(0.6),
-	3: decimal.NewFromFloat(0),
+var vatTypeRates = map[VATType]rates.Table{
+	0:                    rates.VAT25,
+	1:                    rates.VAT12,
+	2:                    rates.VAT6,
+	3:                    rates.VAT0,
+	VATTypeReverseCharge: rates.VAT0,
+	VATTypeEU:            rates.VAT0,
+	VATTypeExport:        rates.VAT0,
 }
 
 func (u VATType) Validate() bool {
@@ -91,9 +119,64 @@ func (u VATType) String() string {
 	return v
 }
 
-func (u VATType) Amount() decimal.Decimal {
-	v := vatTypeAmount[u]
-	return v
+// Rate returns the VAT rate in force at date t, as a fraction
+func (u VATType) Rate(t time.Time) decimal.Decimal {
+	return vatTypeRates[u].Fraction(t)
+}
+
+// VATTypeList returns all VAT types, in order
+func VATTypeList() []VATType {
+	var types []VATType
+	for k := range vatTypeString {
+		types = append(types, VATType(k))
+	}
+	sort.Slice(types, func(a, b int) bool { return types[a] < types[b] })
+	return types
+}
+
+// RequiresBuyerVATNumber returns true if the VAT number of the buyer must be given on the invoice
+func (u VATType) RequiresBuyerVATNumber() bool {
+	return u == VATTypeReverseCharge || u == VATTypeEU
+}
+
+// VATNotes returns the wording that must be printed on the invoice for the rows where no VAT is charged
+// because the buyer accounts for the VAT, or the sale is exempt
+func (i *Invoice) VATNotes() []string {
+	var notes []string
+	seen := map[string]bool{}
+	for _, row := range i.Rows {
+		var note string
+		switch {
+		case row.VAT == VATTypeReverseCharge:
+			note = "Omvänd betalningsskyldighet för byggtjänster"
+		case row.VAT == VATTypeEU && row.IsGoods:
+			note = "Unionsintern leverans, undantagen från skatteplikt (Intra-Community supply, VAT exempt, article 138 Directive 2006/112/EC)"
+		case row.VAT == VATTypeEU:
+			note = "Omvänd betalningsskyldighet (Reverse charge, article 196 Directive 2006/112/EC)"
+		case row.VAT == VATTypeExport && row.IsGoods:
+			note = "Export, undantagen från skatteplikt (Export of goods, VAT exempt, article 146 Directive 2006/112/EC)"
+		case row.VAT == VATTypeExport:
+			note = "Tjänsten är omsatt utanför EU (Services supplied outside the EU, not subject to Swedish VAT)"
+		default:
+			continue
+		}
+
+		if !seen[note] {
+			seen[note] = true
+			notes = append(notes, note)
+		}
+	}
+	return notes
+}
+
+// RequiresBuyerVATNumber returns true if the VAT number of the buyer must be given on the invoice
+func (i *Invoice) RequiresBuyerVATNumber() bool {
+	for _, row := range i.Rows {
+		if row.VAT.RequiresBuyerVATNumber() {
+			return true
+		}
+	}
+	return false
 }
 
 type ROTRUTServiceType int
@@ -165,6 +248,14 @@ func (s ROTRUTServiceType) IsRUT() bool {
 	return ok
 }
 
+// DeductionRate returns the part of the labour cost that is deducted for the type of service at date t, as a fraction
+func (s ROTRUTServiceType) DeductionRate(t time.Time) decimal.Decimal {
+	if s.IsROT() {
+		return rates.ROT.Fraction(t)
+	}
+	return rates.RUT.Fraction(t)
+}
+
 type InvoiceRow struct {
 	ID          int
 	RowOrder    int             `json:"row_order"`
@@ -179,6 +270,10 @@ type InvoiceRow struct {
 	RotRutServiceType *ROTRUTServiceType `json:"rot_rut_service_type"`
 	RotRutHours       *int               `json:"rot_rut_hours"` // used when a row has a fixed price
 
+	// Fields used for bookkeeping
+	IsGoods bool `json:"is_goods"` // Goods or services, used to select the sales account
+	Account *int `json:"account"`  // Sales account, overriding the account map of the company
+
 	// Calculated fields
 	Total decimal.Decimal
 }
@@ -193,6 +288,14 @@ type InvoiceFilter struct {
 	Direction  string
 	Status     []InvoiceStatus // Accepted statuses
 
+	CreditInvoiceID int    // List credit notes for this invoice
+	Number          int    // Only list invoice with this number
+	OCR             string // Only list invoice with this payment reference
+	Overdue         bool   // Only list sent, unpaid invoices with a passed due date
+	OnlyInvoiced    bool   // Only list sent invoices
+
+	RecurringInvoiceID int // List invoices issued from this recurring invoice
+
 	IncludeCompany bool
 }
 
@@ -247,37 +350,50 @@ func (totals InvoiceTotals) Add(rowTotals InvoiceTotals) (combined InvoiceTotals
 	return combined
 }
 
+// RateDate returns the date that decides which VAT and ROT/RUT rates apply to the invoice.
+// Invoices that have been issued keep the rates in force when they were issued,
+// and invoices that are not yet issued use the rates in force today.
+// A credit note reverses the amounts of the credited invoice, and uses the rates of that invoice.
+func (i *Invoice) RateDate() time.Time {
+	if i.IsCreditNote && i.CreditInvoiceDate != nil {
+		return *i.CreditInvoiceDate
+	}
+	if i.DateInvoiced != nil {
+		return *i.DateInvoiced
+	}
+	return time.Now()
+}
+
 func (i *Invoice) Totals(IncludeVAT, IncludeROTRUT bool) (totals InvoiceTotals) {
+	date := i.RateDate()
 	for _, row := range i.Rows {
-		rowTotals := row.Totals(IncludeVAT, IncludeROTRUT)
+		rowTotals := row.Totals(date, IncludeVAT, IncludeROTRUT)
 		totals = totals.Add(rowTotals)
 	}
 
 	return totals
 }
 
-func (row *InvoiceRow) Totals(IncludeVAT bool, IncludeROTRUT bool) (totals InvoiceTotals) {
+// Totals calculates the totals of the row, with the VAT and ROT/RUT rates in force at date
+func (row *InvoiceRow) Totals(date time.Time, IncludeVAT bool, IncludeROTRUT bool) (totals InvoiceTotals) {
 	priceInclRUT := row.Cost
 	totals.PPUIncl = row.Cost
+	vatRate := row.VAT.Rate(date)
 
 	if row.IsRotRut && row.RotRutServiceType != nil {
-		if row.RotRutServiceType.IsROT() {
-			priceInclRUT = row.Cost.Mul(decimal.NewFromFloat(0.7))
-			totals.ROTRUT = totals.ROTRUT.Add(row.Cost.Mul(decimal.NewFromFloat(0.3)).Mul(row.Count))
-		} else {
-			priceInclRUT = row.Cost.Mul(decimal.NewFromFloat(0.5))
-			totals.ROTRUT = totals.ROTRUT.Add(priceInclRUT.Mul(row.Count))
-		}
+		deductionRate := row.RotRutServiceType.DeductionRate(date)
+		priceInclRUT = row.Cost.Mul(decimal.NewFromInt(1).Sub(deductionRate))
+		totals.ROTRUT = totals.ROTRUT.Add(row.Cost.Mul(deductionRate).Mul(row.Count))
 
 		totals.ROTRUTTotals.Incl = totals.ROTRUT
-		totals.ROTRUTTotals.Excl = totals.ROTRUT.Div(decimal.NewFromInt(1).Add(row.VAT.Amount()))
+		totals.ROTRUTTotals.Excl = totals.ROTRUT.Div(decimal.NewFromInt(1).Add(vatRate))
 		totals.ROTRUTPerUnit = row.Cost.Mul(row.Count).Sub(priceInclRUT)
 	}
 
 	totals.Customer = totals.Customer.Add(priceInclRUT.Mul(row.Count))
 	totals.Incl = totals.Incl.Add(row.Total)
 
-	priceExcl := row.Cost.Div(decimal.NewFromInt(1).Add(row.VAT.Amount()))
+	priceExcl := row.Cost.Div(decimal.NewFromInt(1).Add(vatRate))
 	totals.Excl = priceExcl.Mul(row.Count)
 	totals.PPUExcl = priceExcl
 	vatAmount := totals.Incl.Sub(totals.Excl)
@@ -289,7 +405,7 @@ func (row *InvoiceRow) Totals(IncludeVAT bool, IncludeROTRUT bool) (totals Invoi
 		if IncludeROTRUT {
 			totals.Total = totals.Customer
 			totals.PPU = priceInclRUT
-			vatAmount = priceInclRUT.Sub(priceInclRUT.Div(decimal.NewFromInt(1).Add(row.VAT.Amount()))).Mul(row.Count)
+			vatAmount = priceInclRUT.Sub(priceInclRUT.Div(decimal.NewFromInt(1).Add(vatRate))).Mul(row.Count)
 		}
 	} else {
 		totals.Total = totals.Excl
@@ -346,7 +462,9 @@ func InvoiceAddAttachment(ctx context.Context, inv Invoice, f File) error {
 
 	tx := getContextTx(ctx)
 
-	query := `INSERT INTO invoice_attachments (invoice_id, file_id) VALUES ($1, $2)`
+	// New attachments are placed last
+	query := `INSERT INTO invoice_attachments (invoice_id, file_id, sort_order)
+SELECT $1, $2, COALESCE(MAX(sort_order), 0) + 1 FROM invoice_attachments WHERE invoice_id = $1`
 
 	_, err = tx.Exec(ctx, query, inv.ID, f.ID)
 	if err != nil {
@@ -355,6 +473,20 @@ func InvoiceAddAttachment(ctx context.Context, inv Invoice, f File) error {
 	return nil
 }
 
+// InvoiceSetAttachmentOrder sets the order of the attachments of an invoice, from the list of file ids
+func InvoiceSetAttachmentOrder(ctx context.Context, inv Invoice, fileIDs []int) error {
+	tx := getContextTx(ctx)
+
+	query := `UPDATE invoice_attachments SET sort_order = $3 WHERE invoice_id = $1 AND file_id = $2`
+	for k, id := range fileIDs {
+		_, err := tx.Exec(ctx, query, inv.ID, id, k+1)
+		if err != nil {
+			return zerr.Wrap(err).WithString("query", query).WithInt("invoice_id", inv.ID).WithInt("file_id", id)
+		}
+	}
+	return nil
+}
+
 func InvoiceRemoveAttachment(ctx context.Context, inv Invoice, attachmentID int) error {
 	var err error
 	tx := getContextTx(ctx)
@@ -400,7 +532,9 @@ date_due = $8,
 date_paid = $9,
 rut_applicable = $10,
 is_deleted = $11,
-status = $12
+status = $12,
+ocr = $13,
+merge_attachments = $14
 WHERE id = $1`
 		_, err := tx.Exec(ctx, query, invoice.ID,
 			invoice.Name,
@@ -413,15 +547,21 @@ WHERE id = $1`
 			invoice.DatePaid,
 			invoice.RutApplicable,
 			invoice.IsDeleted,
-			invoice.Status)
+			invoice.Status,
+			invoice.OCR,
+			invoice.MergeAttachments)
 		if err != nil {
 			return 0, zerr.Wrap(err).WithString("query", query).WithAny("invoice", invoice)
 		}
 		return invoice.ID, nil
 	}
 
-	query := `INSERT INTO invoice (number, name, customer_id, rut_applicable, company_id, is_offer, offer_id, status) VALUES($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
-	err := tx.QueryRow(ctx, query, invoice.Number, invoice.Name, invoice.Customer.ID, invoice.RutApplicable, invoice.Company.ID, invoice.IsOffer, invoice.OfferID, invoice.Status).Scan(&invoice.ID)
+	query := `INSERT INTO invoice (number, name, customer_id, rut_applicable, company_id, is_offer, offer_id, status, is_credit_note, credit_invoice_id, recurring_invoice_id, merge_attachments,
+is_invoiced, is_paid, additional_info, date_invoiced, date_due, date_paid, ocr)
+VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19) RETURNING id`
+	err := tx.QueryRow(ctx, query, invoice.Number, invoice.Name, invoice.Customer.ID, invoice.RutApplicable, invoice.Company.ID,
+		invoice.IsOffer, invoice.OfferID, invoice.Status, invoice.IsCreditNote, invoice.CreditInvoiceID, invoice.RecurringInvoiceID, invoice.MergeAttachments,
+		invoice.IsInvoiced, invoice.IsPaid, invoice.AdditionalInfo, invoice.DateInvoiced, invoice.DateDue, invoice.DatePaid, invoice.OCR).Scan(&invoice.ID)
 	if err != nil {
 		return 0, zerr.Wrap(err).WithString("query", query).WithAny("invoice", invoice)
 	}
@@ -451,6 +591,30 @@ func invoiceBuildQuery(q string, f InvoiceFilter) string {
 		filterStrings = append(filterStrings, "invoice.company_id = :company_id")
 	}
 
+	if f.CreditInvoiceID > 0 {
+		filterStrings = append(filterStrings, "invoice.credit_invoice_id = :credit_invoice_id")
+	}
+
+	if f.Number > 0 {
+		filterStrings = append(filterStrings, "invoice.number = :number")
+	}
+
+	if f.OCR != "" {
+		filterStrings = append(filterStrings, "invoice.ocr = :ocr")
+	}
+
+	if f.RecurringInvoiceID > 0 {
+		filterStrings = append(filterStrings, "invoice.recurring_invoice_id = :recurring_invoice_id")
+	}
+
+	if f.OnlyInvoiced {
+		filterStrings = append(filterStrings, "is_invoiced")
+	}
+
+	if f.Overdue {
+		filterStrings = append(filterStrings, "is_invoiced AND NOT is_paid AND NOT is_credit_note AND date_due < current_date")
+	}
+
 	if len(f.Status) > 0 {
 		statusFilter := make([]string, len(f.Status))
 		for k, v := range f.Status {
@@ -479,7 +643,14 @@ func InvoiceList(ctx context.Context, f InvoiceFilter) ([]Invoice, error) {
 		is_deleted,
 		is_offer,
 		status,
+		ocr,
 		offer_id,
+		is_credit_note,
+		credit_invoice_id,
+		recurring_invoice_id,
+		merge_attachments,
+		(SELECT ci.number FROM invoice ci WHERE ci.id = invoice.credit_invoice_id) AS credit_invoice_number,
+		(SELECT ci.date_invoiced FROM invoice ci WHERE ci.id = invoice.credit_invoice_id) AS credit_invoice_date,
 		additional_info,
 		invoice.company_id AS "company.id",
 		customer.id AS "customer.id",
@@ -491,6 +662,13 @@ func InvoiceList(ctx context.Context, f InvoiceFilter) ([]Invoice, error) {
 		customer.city AS "customer.city",
 		customer.pnr AS "customer.pnr",
 		customer.telephone AS "customer.telephone",
+		customer.peppol_id AS "customer.peppol_id",
+		customer.reference AS "customer.reference",
+		customer.property_designation AS "customer.property_designation",
+		customer.apartment_number AS "customer.apartment_number",
+		customer.housing_cooperative_id AS "customer.housing_cooperative_id",
+		customer.vat_number AS "customer.vat_number",
+		customer.country AS "customer.country",
 		COALESCE((SELECT SUM(r.cost*r.count) FROM invoice_row r WHERE r.invoice_id = invoice.id), 0) AS total_sum
 FROM invoice
 INNER JOIN customer ON customer.id = invoice.customer_id`
@@ -535,12 +713,27 @@ INNER JOIN customer ON customer.id = invoice.customer_id`
 
 	for k := range invoices {
 		inv := &invoices[k]
-		query := "SELECT id, row_order, description, cost, count, unit, vat, is_rot_rut, rot_rut_service_type, rot_rut_hours, cost*count AS total FROM invoice_row WHERE invoice_id = $1 ORDER BY row_order"
+		query := "SELECT id, row_order, description, cost, count, unit, vat, is_rot_rut, rot_rut_service_type, rot_rut_hours, is_goods, account, cost*count AS total FROM invoice_row WHERE invoice_id = $1 ORDER BY row_order"
 		err = tx.Select(ctx, &inv.Rows, query, inv.ID)
 		if err != nil {
 			return nil, zerr.Wrap(err).WithString("query", query).WithInt("invoice.ID", inv.ID)
 		}
 
+		inv.Payments, err = PaymentList(ctx, PaymentFilter{InvoiceID: inv.ID})
+		if err != nil {
+			return nil, err
+		}
+
+		inv.Reminders, err = ReminderList(ctx, ReminderFilter{InvoiceID: inv.ID})
+		if err != nil {
+			return nil, err
+		}
+
+		inv.Buyers, err = BuyerList(ctx, inv.ID)
+		if err != nil {
+			return nil, err
+		}
+
 		if f.IncludeCompany {
 			inv.Company, err = CompanyGet(ctx, CompanyFilter{ID: inv.Company.ID})
 			if err != nil {
@@ -587,7 +780,9 @@ func InvoiceRowUpdate(ctx context.Context, row InvoiceRow) error {
 		vat = $7,
 		is_rot_rut = $8,
 		rot_rut_service_type = $9,
-		rot_rut_hours = $10
+		rot_rut_hours = $10,
+		is_goods = $11,
+		account = $12
 	WHERE id = $1
 `
 	tx := getContextTx(ctx)
@@ -601,7 +796,9 @@ func InvoiceRowUpdate(ctx context.Context, row InvoiceRow) error {
 		row.VAT,
 		row.IsRotRut,
 		row.RotRutServiceType,
-		row.RotRutHours)
+		row.RotRutHours,
+		row.IsGoods,
+		row.Account)
 	if err != nil {
 		return zerr.Wrap(err).WithString("query", query).WithAny("row", row)
 	}
@@ -611,10 +808,10 @@ func InvoiceRowUpdate(ctx context.Context, row InvoiceRow) error {
 
 func InvoiceRowAdd(ctx context.Context, invoiceID int, row InvoiceRow) error {
 	tx := getContextTx(ctx)
-	query := `INSERT INTO invoice_row (invoice_id, row_order, description, cost, count, unit, vat, is_rot_rut, rot_rut_service_type)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	query := `INSERT INTO invoice_row (invoice_id, row_order, description, cost, count, unit, vat, is_rot_rut, rot_rut_service_type, rot_rut_hours, is_goods, account)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
 	_, err := tx.Exec(ctx, query,
-		invoiceID, row.RowOrder, row.Description, row.Cost, row.Count, row.Unit, row.VAT, row.IsRotRut, row.RotRutServiceType)
+		invoiceID, row.RowOrder, row.Description, row.Cost, row.Count, row.Unit, row.VAT, row.IsRotRut, row.RotRutServiceType, row.RotRutHours, row.IsGoods, row.Account)
 	if err != nil {
 		return zerr.Wrap(err).WithString("query", query).WithAny("row", row).WithAny("invoice-id", invoiceID)
 	}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestInvoiceTotalsRates(t *testing.T) {
+	rot := ROTServiceTypeBygg
+	inv := Invoice{
+		Rows: []InvoiceRow{
+			{Cost: decimal.NewFromInt(1000), Count: decimal.NewFromInt(1), VAT: 0, IsRotRut: true, RotRutServiceType: &rot, Total: decimal.NewFromInt(1000)},
+			{Cost: decimal.NewFromInt(106), Count: decimal.NewFromInt(1), VAT: 2, Total: decimal.NewFromInt(106)},
+		},
+	}
+
+	tests := []struct {
+		invoiced time.Time
+		rotrut   int64
+	}{
+		{time.Date(2025, time.May, 11, 0, 0, 0, 0, time.Local), 300},
+		{time.Date(2025, time.May, 12, 0, 0, 0, 0, time.Local), 500},
+		{time.Date(2026, time.January, 1, 0, 0, 0, 0, time.Local), 300},
+	}
+
+	for _, tt := range tests {
+		invoiced := tt.invoiced
+		inv.DateInvoiced = &invoiced
+
+		totals := inv.Totals(true, true)
+		if !totals.ROTRUT.Equal(decimal.NewFromInt(tt.rotrut)) {
+			t.Errorf("invoiced %s: expected ROT %d, got %s", invoiced.Format("2006-01-02"), tt.rotrut, totals.ROTRUT)
+		}
+
+		if !totals.VAT6.Equal(decimal.NewFromInt(6)) {
+			t.Errorf("invoiced %s: expected 6 kr VAT at 6 %%, got %s", invoiced.Format("2006-01-02"), totals.VAT6)
+		}
+	}
+}
+
+func TestInvoiceVATNotes(t *testing.T) {
+	inv := Invoice{Rows: []InvoiceRow{
+		{VAT: 0},
+		{VAT: VATTypeEU, IsGoods: true},
+		{VAT: VATTypeEU, IsGoods: true},
+		{VAT: VATTypeReverseCharge},
+	}}
+
+	if notes := inv.VATNotes(); len(notes) != 2 {
+		t.Errorf("expected 2 notes, got %v", notes)
+	}
+	if !inv.RequiresBuyerVATNumber() {
+		t.Errorf("expected buyer VAT number to be required")
+	}
+
+	inv.Rows = []InvoiceRow{{VAT: VATTypeExport}, {VAT: 3}}
+	if notes := inv.VATNotes(); len(notes) != 1 {
+		t.Errorf("expected 1 note, got %v", notes)
+	}
+	if inv.RequiresBuyerVATNumber() {
+		t.Errorf("expected buyer VAT number not to be required for exports")
+	}
+}
+
+func TestCreditNoteRates(t *testing.T) {
+	rot := ROTServiceTypeBygg
+	invoiced := time.Date(2025, time.May, 11, 0, 0, 0, 0, time.Local)
+	credited := time.Date(2025, time.June, 2, 0, 0, 0, 0, time.Local)
+
+	// The invoice is issued while ROT is 30 %, and credited after the rate has been raised to 50 %
+	creditNote := Invoice{
+		IsCreditNote:      true,
+		DateInvoiced:      &credited,
+		CreditInvoiceDate: &invoiced,
+		Rows: []InvoiceRow{
+			{Cost: decimal.NewFromInt(1000), Count: decimal.NewFromInt(-1), VAT: 0, IsRotRut: true, RotRutServiceType: &rot, Total: decimal.NewFromInt(-1000)},
+		},
+	}
+
+	if !creditNote.RateDate().Equal(invoiced) {
+		t.Errorf("expected the rates of the credited invoice, got the rates at %s", creditNote.RateDate().Format("2006-01-02"))
+	}
+
+	totals := creditNote.Totals(true, true)
+	if !totals.ROTRUT.Equal(decimal.NewFromInt(-300)) {
+		t.Errorf("expected ROT -300 as on the credited invoice, got %s", totals.ROTRUT)
+	}
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"strconv"
+)
+
+// OCRLevel describes the level of OCR control configured for the bankgiro/plusgiro account
+type OCRLevel int
+
+const (
+	OCRLevelNone OCRLevel = iota // No OCR control, the invoice number is used as reference
+	OCRLevelSoft                 // Check digit is validated, but payments with incorrect references are accepted
+	OCRLevelHard                 // Check digit is validated, and payments with incorrect references are rejected
+)
+
+var ocrLevelStrings = map[OCRLevel]string{
+	OCRLevelNone: "Ingen OCR-kontroll",
+	OCRLevelSoft: "Mjuk kontroll",
+	OCRLevelHard: "Hård kontroll",
+}
+
+func (l OCRLevel) String() string {
+	return ocrLevelStrings[l]
+}
+
+func (l OCRLevel) Validate() bool {
+	_, ok := ocrLevelStrings[l]
+	return ok
+}
+
+// LuhnDigit calculates the mod-10 (Luhn) check digit for a string of digits
+func LuhnDigit(digits string) int {
+	sum := 0
+	double := true
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return (10 - sum%10) % 10
+}
+
+// GenerateOCR creates an OCR reference from an invoice number.
+// If lengthDigit is set, a digit containing the total length (modulo 10) of the reference
+// is added before the check digit.
+func GenerateOCR(number int, lengthDigit bool) string {
+	ocr := strconv.Itoa(number)
+	if lengthDigit {
+		// The length includes the length digit and the check digit
+		ocr += strconv.Itoa((len(ocr) + 2) % 10)
+	}
+	return ocr + strconv.Itoa(LuhnDigit(ocr))
+}
+
+// ValidateOCR checks that ocr has a correct check digit, and optionally a correct length digit
+func ValidateOCR(ocr string, lengthDigit bool) bool {
+	minLength := 2
+	if lengthDigit {
+		minLength = 3
+	}
+
+	if len(ocr) < minLength || len(ocr) > 25 {
+		return false
+	}
+
+	for _, r := range ocr {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	if lengthDigit && int(ocr[len(ocr)-2]-'0') != len(ocr)%10 {
+		return false
+	}
+
+	return LuhnDigit(ocr[:len(ocr)-1]) == int(ocr[len(ocr)-1]-'0')
+}
+
+// OCR returns the payment reference to use for an invoice number,
+// according to the OCR settings of the company
+func (c *Company) OCR(number int) string {
+	if c.OCRLevel == OCRLevelNone {
+		return strconv.Itoa(number)
+	}
+	return GenerateOCR(number, c.OCRLengthDigit)
+}
+
+// ValidateOCR checks if ocr is a valid reference according to the OCR settings of the company
+func (c *Company) ValidateOCR(ocr string) bool {
+	if c.OCRLevel == OCRLevelNone {
+		return true
+	}
+	return ValidateOCR(ocr, c.OCRLengthDigit)
+}
+
+// PaymentReference returns the reference the customer should use when paying the invoice
+func (i *Invoice) PaymentReference() string {
+	if i.OCR != "" {
+		return i.OCR
+	}
+	return i.Company.OCR(i.Number)
+}
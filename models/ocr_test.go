@@ -0,0 +1,48 @@
+package models
+
+import "testing"
+
+func TestGenerateOCR(t *testing.T) {
+	tests := []struct {
+		number      int
+		lengthDigit bool
+		expected    string
+	}{
+		{number: 7992739871, expected: "79927398713"},
+		{number: 1000, expected: "10009"},
+		{number: 1000, lengthDigit: true, expected: "100065"},
+		{number: 12345678, lengthDigit: true, expected: "1234567806"},
+	}
+
+	for _, tt := range tests {
+		ocr := GenerateOCR(tt.number, tt.lengthDigit)
+		if ocr != tt.expected {
+			t.Errorf("GenerateOCR(%d, %v) = %s, expected %s", tt.number, tt.lengthDigit, ocr, tt.expected)
+		}
+
+		if !ValidateOCR(ocr, tt.lengthDigit) {
+			t.Errorf("ValidateOCR(%s, %v) failed", ocr, tt.lengthDigit)
+		}
+	}
+}
+
+func TestValidateOCR(t *testing.T) {
+	tests := []struct {
+		ocr         string
+		lengthDigit bool
+		valid       bool
+	}{
+		{ocr: "79927398713", valid: true},
+		{ocr: "79927398710", valid: false},
+		{ocr: "100065", lengthDigit: true, valid: true},
+		{ocr: "100057", lengthDigit: true, valid: false}, // Wrong length digit
+		{ocr: "1000a", valid: false},
+		{ocr: "5", valid: false},
+	}
+
+	for _, tt := range tests {
+		if ValidateOCR(tt.ocr, tt.lengthDigit) != tt.valid {
+			t.Errorf("ValidateOCR(%s, %v) expected %v", tt.ocr, tt.lengthDigit, tt.valid)
+		}
+	}
+}
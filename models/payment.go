@@ -0,0 +1,200 @@
+package models
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/yzzyx/zerr"
+)
+
+// PaymentMethod describes how a payment was made
+type PaymentMethod int
+
+const (
+	PaymentMethodBankgiro PaymentMethod = iota
+	PaymentMethodPlusgiro
+	PaymentMethodBankTransfer
+	PaymentMethodSwish
+	PaymentMethodCard
+	PaymentMethodCash
+	PaymentMethodOther
+)
+
+var paymentMethodStrings = map[PaymentMethod]string{
+	PaymentMethodBankgiro:     "Bankgiro",
+	PaymentMethodPlusgiro:     "Plusgiro",
+	PaymentMethodBankTransfer: "Banköverföring",
+	PaymentMethodSwish:        "Swish",
+	PaymentMethodCard:         "Kort",
+	PaymentMethodCash:         "Kontant",
+	PaymentMethodOther:        "Annat",
+}
+
+// PaymentMethods lists all available payment methods
+var PaymentMethods = paymentMethodStrings
+
+func (m PaymentMethod) String() string {
+	return paymentMethodStrings[m]
+}
+
+func (m PaymentMethod) Validate() bool {
+	_, ok := paymentMethodStrings[m]
+	return ok
+}
+
+// Payment is a single (possibly partial) payment of an invoice
+type Payment struct {
+	ID          int
+	InvoiceID   int
+	Amount      decimal.Decimal
+	DatePaid    time.Time
+	Method      PaymentMethod
+	Reference   string
+	Serial      string // Bankgirot serial number, for payments imported from BgMax files
+	DateCreated time.Time
+}
+
+type PaymentFilter struct {
+	ID        int
+	InvoiceID int
+	CompanyID int
+}
+
+// AmountDue returns the amount the customer should pay for the invoice,
+// i.e. the total including VAT, excluding ROT/RUT, with any reminder fees and interest added
+func (i *Invoice) AmountDue() decimal.Decimal {
+	totals := i.Totals(true, true)
+	return totals.Incl.Sub(totals.ROTRUT).Round(2).Add(i.ReminderCharges())
+}
+
+// AmountPaid returns the sum of all registered payments
+func (i *Invoice) AmountPaid() decimal.Decimal {
+	sum := decimal.Zero
+	for _, p := range i.Payments {
+		sum = sum.Add(p.Amount)
+	}
+	return sum
+}
+
+// Outstanding returns the amount left to pay
+func (i *Invoice) Outstanding() decimal.Decimal {
+	return i.AmountDue().Sub(i.AmountPaid())
+}
+
+// IsFullyPaid returns true if the registered payments cover the amount due.
+// For credit notes, the amount due and the payments (refunds) are negative.
+func (i *Invoice) IsFullyPaid() bool {
+	if i.AmountDue().IsNegative() {
+		return !i.Outstanding().IsNegative()
+	}
+	return !i.Outstanding().IsPositive()
+}
+
+func PaymentList(ctx context.Context, f PaymentFilter) ([]Payment, error) {
+	var payments []Payment
+
+	query := `SELECT
+	invoice_payment.id,
+	invoice_payment.invoice_id,
+	invoice_payment.amount,
+	invoice_payment.date_paid,
+	invoice_payment.method,
+	invoice_payment.reference,
+	invoice_payment.serial,
+	invoice_payment.date_created
+FROM invoice_payment
+INNER JOIN invoice ON invoice.id = invoice_payment.invoice_id`
+
+	filterStrings := []string{}
+	if f.ID > 0 {
+		filterStrings = append(filterStrings, "invoice_payment.id = :id")
+	}
+
+	if f.InvoiceID > 0 {
+		filterStrings = append(filterStrings, "invoice_payment.invoice_id = :invoice_id")
+	}
+
+	if f.CompanyID > 0 {
+		filterStrings = append(filterStrings, "invoice.company_id = :company_id")
+	}
+
+	if len(filterStrings) > 0 {
+		query += " WHERE " + strings.Join(filterStrings, " AND ")
+	}
+	query += " ORDER BY invoice_payment.date_paid, invoice_payment.id"
+
+	tx := getContextTx(ctx)
+	rows, err := tx.NamedQuery(ctx, query, f)
+	if err != nil {
+		return nil, zerr.Wrap(err).WithString("query", query).WithAny("filter", f)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p Payment
+		err = rows.StructScan(&p)
+		if err != nil {
+			return nil, zerr.Wrap(err).WithString("query", query).WithAny("filter", f)
+		}
+		payments = append(payments, p)
+	}
+
+	return payments, nil
+}
+
+func PaymentAdd(ctx context.Context, p Payment) (int, error) {
+	tx := getContextTx(ctx)
+
+	query := `INSERT INTO invoice_payment (invoice_id, amount, date_paid, method, reference, serial)
+VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+	err := tx.QueryRow(ctx, query, p.InvoiceID, p.Amount, p.DatePaid, p.Method, p.Reference, p.Serial).Scan(&p.ID)
+	if err != nil {
+		return 0, zerr.Wrap(err).WithString("query", query).WithAny("payment", p)
+	}
+	return p.ID, nil
+}
+
+func PaymentRemove(ctx context.Context, invoiceID int, paymentID int) error {
+	tx := getContextTx(ctx)
+
+	query := `DELETE FROM invoice_payment WHERE invoice_id = $1 AND id = $2`
+	_, err := tx.Exec(ctx, query, invoiceID, paymentID)
+	if err != nil {
+		return zerr.Wrap(err).WithString("query", query).WithInt("invoice-id", invoiceID).WithInt("payment-id", paymentID)
+	}
+	return nil
+}
+
+// ImportedPaymentExists returns true if the payment with serial number 'serial' in the deposit on 'date'
+// already has been imported from a BgMax file
+func ImportedPaymentExists(ctx context.Context, companyID int, serial string, date time.Time) (bool, error) {
+	var exists bool
+	if serial == "" {
+		return false, nil
+	}
+
+	tx := getContextTx(ctx)
+
+	query := `SELECT EXISTS (SELECT 1 FROM imported_payment WHERE company_id = $1 AND serial = $2 AND date_paid = $3)`
+	err := tx.QueryRow(ctx, query, companyID, serial, date).Scan(&exists)
+	if err != nil {
+		return false, zerr.Wrap(err).WithString("query", query).WithInt("company-id", companyID).WithString("serial", serial)
+	}
+	return exists, nil
+}
+
+// ImportedPaymentAdd records that the payment with serial number 'serial' in the deposit on 'date'
+// has been imported from a BgMax file. It returns false if the payment already has been imported.
+func ImportedPaymentAdd(ctx context.Context, companyID int, serial string, date time.Time) (bool, error) {
+	tx := getContextTx(ctx)
+
+	query := `INSERT INTO imported_payment (company_id, serial, date_paid) VALUES ($1, $2, $3)
+ON CONFLICT (company_id, serial, date_paid) DO NOTHING`
+	res, err := tx.Exec(ctx, query, companyID, serial, date)
+	if err != nil {
+		return false, zerr.Wrap(err).WithString("query", query).WithInt("company-id", companyID).WithString("serial", serial)
+	}
+	return res.RowsAffected() == 1, nil
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestInvoiceOutstanding(t *testing.T) {
+	inv := Invoice{
+		Rows: []InvoiceRow{
+			{Cost: decimal.NewFromInt(125), Count: decimal.NewFromInt(2), VAT: 0, Total: decimal.NewFromInt(250)},
+		},
+	}
+
+	if !inv.AmountDue().Equal(decimal.NewFromInt(250)) {
+		t.Fatalf("expected amount due 250, got %s", inv.AmountDue())
+	}
+
+	inv.Payments = append(inv.Payments, Payment{Amount: decimal.NewFromInt(100)})
+	if !inv.Outstanding().Equal(decimal.NewFromInt(150)) || inv.IsFullyPaid() {
+		t.Errorf("expected outstanding 150, got %s", inv.Outstanding())
+	}
+
+	inv.Payments = append(inv.Payments, Payment{Amount: decimal.NewFromInt(150)})
+	if !inv.Outstanding().IsZero() || !inv.IsFullyPaid() {
+		t.Errorf("expected invoice to be fully paid, outstanding %s", inv.Outstanding())
+	}
+
+	// Credit notes are refunded with negative payments
+	credit := Invoice{
+		IsCreditNote: true,
+		Rows: []InvoiceRow{
+			{Cost: decimal.NewFromInt(125), Count: decimal.NewFromInt(-1), VAT: 0, Total: decimal.NewFromInt(-125)},
+		},
+	}
+	if credit.IsFullyPaid() {
+		t.Errorf("expected credit note to not be refunded")
+	}
+
+	credit.Payments = append(credit.Payments, Payment{Amount: decimal.NewFromInt(-125)})
+	if !credit.IsFullyPaid() {
+		t.Errorf("expected credit note to be refunded, outstanding %s", credit.Outstanding())
+	}
+}
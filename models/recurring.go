@@ -0,0 +1,357 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/yzzyx/zerr"
+)
+
+// RecurringInterval describes how often a recurring invoice is issued
+type RecurringInterval int
+
+const (
+	RecurringIntervalMonthly RecurringInterval = iota
+	RecurringIntervalQuarterly
+	RecurringIntervalYearly
+)
+
+var recurringIntervalStrings = map[RecurringInterval]string{
+	RecurringIntervalMonthly:   "Varje månad",
+	RecurringIntervalQuarterly: "Varje kvartal",
+	RecurringIntervalYearly:    "Varje år",
+}
+
+var recurringIntervalMonths = map[RecurringInterval]int{
+	RecurringIntervalMonthly:   1,
+	RecurringIntervalQuarterly: 3,
+	RecurringIntervalYearly:    12,
+}
+
+// RecurringIntervals is the list of all available intervals
+var RecurringIntervals = recurringIntervalStrings
+
+func (i RecurringInterval) String() string {
+	return recurringIntervalStrings[i]
+}
+
+func (i RecurringInterval) Validate() bool {
+	_, ok := recurringIntervalStrings[i]
+	return ok
+}
+
+// Months returns the number of months between two runs
+func (i RecurringInterval) Months() int {
+	return recurringIntervalMonths[i]
+}
+
+// RecurringInvoice is a template for invoices that are issued on a schedule
+type RecurringInvoice struct {
+	ID             int
+	Name           string
+	AdditionalInfo string
+	RutApplicable  bool
+	Customer       Customer
+	Company        Company
+	Rows           []InvoiceRow
+
+	Interval     RecurringInterval
+	DateStart    time.Time
+	DateEnd      *time.Time // No invoices are issued after this date
+	DateNext     *time.Time // Date of the next run, nil if there are no more runs
+	DueDays      int        // Number of days until the issued invoice is due
+	SendDirectly bool       // Mark issued invoices as sent, instead of creating drafts
+	IsActive     bool
+	DateCreated  time.Time
+}
+
+type RecurringInvoiceFilter struct {
+	ID        int
+	CompanyID int
+
+	OnlyActive bool
+	DueBefore  *time.Time // Only list recurring invoices with a run on or before this date
+	ForUpdate  bool       // Lock the recurring invoices until the end of the transaction
+}
+
+// addMonths adds n months to t. If the day does not exist in the resulting month,
+// the last day of the month is used instead, so that e.g. January 31st is followed by February 28th.
+func addMonths(t time.Time, n int) time.Time {
+	firstOfMonth := time.Date(t.Year(), t.Month()+time.Month(n), 1, 0, 0, 0, 0, t.Location())
+	lastDay := firstOfMonth.AddDate(0, 1, -1).Day()
+	day := t.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return time.Date(firstOfMonth.Year(), firstOfMonth.Month(), day, 0, 0, 0, 0, t.Location())
+}
+
+// RunDate returns the date of run number n, where the first run (0) is on the start date
+func (r *RecurringInvoice) RunDate(n int) time.Time {
+	return addMonths(r.DateStart, n*r.Interval.Months())
+}
+
+// NextRun returns the first run on or after the date 'from', or nil if there are no more runs
+func (r *RecurringInvoice) NextRun(from time.Time) *time.Time {
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, r.DateStart.Location())
+	for n := 0; ; n++ {
+		d := r.RunDate(n)
+		if r.DateEnd != nil && d.After(*r.DateEnd) {
+			return nil
+		}
+
+		if !d.Before(from) {
+			return &d
+		}
+	}
+}
+
+// Upcoming returns the dates of all runs from the next run up to and including 'until'
+func (r *RecurringInvoice) Upcoming(until time.Time) []time.Time {
+	var dates []time.Time
+	if !r.IsActive {
+		return dates
+	}
+
+	for next := r.DateNext; next != nil && !next.After(until); next = r.NextRun(next.AddDate(0, 0, 1)) {
+		dates = append(dates, *next)
+	}
+	return dates
+}
+
+// Invoice creates a new invoice from the template, issued at date
+func (r *RecurringInvoice) Invoice(date time.Time) Invoice {
+	invoice := Invoice{
+		Name:               r.Name,
+		Customer:           r.Customer,
+		Company:            r.Company,
+		RutApplicable:      r.RutApplicable,
+		AdditionalInfo:     r.AdditionalInfo,
+		RecurringInvoiceID: &r.ID,
+	}
+
+	for k, row := range r.Rows {
+		row.ID = 0
+		row.RowOrder = k
+		row.Total = row.Cost.Mul(row.Count)
+		invoice.Rows = append(invoice.Rows, row)
+	}
+
+	if r.SendDirectly {
+		dueDate := date.AddDate(0, 0, r.DueDays)
+		invoice.IsInvoiced = true
+		invoice.DateInvoiced = &date
+		invoice.DateDue = &dueDate
+	}
+	return invoice
+}
+
+// Total returns the amount the customer pays for each issued invoice
+func (r RecurringInvoice) Total() decimal.Decimal {
+	invoice := r.Invoice(r.DateStart)
+	return invoice.Totals(true, true).Customer
+}
+
+func RecurringInvoiceList(ctx context.Context, f RecurringInvoiceFilter) ([]RecurringInvoice, error) {
+	var result []RecurringInvoice
+
+	query := `SELECT
+	recurring_invoice.id,
+	recurring_invoice.name,
+	recurring_invoice.additional_info,
+	recurring_invoice.rut_applicable,
+	recurring_invoice.interval,
+	recurring_invoice.date_start,
+	recurring_invoice.date_end,
+	recurring_invoice.date_next,
+	recurring_invoice.due_days,
+	recurring_invoice.send_directly,
+	recurring_invoice.is_active,
+	recurring_invoice.date_created,
+	recurring_invoice.company_id AS "company.id",
+	customer.id AS "customer.id",
+	customer.name AS "customer.name",
+	customer.email AS "customer.email"
+FROM recurring_invoice
+INNER JOIN customer ON customer.id = recurring_invoice.customer_id`
+
+	filterStrings := []string{}
+	if f.ID > 0 {
+		filterStrings = append(filterStrings, "recurring_invoice.id = :id")
+	}
+
+	if f.CompanyID > 0 {
+		filterStrings = append(filterStrings, "recurring_invoice.company_id = :company_id")
+	}
+
+	if f.OnlyActive {
+		filterStrings = append(filterStrings, "recurring_invoice.is_active")
+	}
+
+	if f.DueBefore != nil {
+		filterStrings = append(filterStrings, "recurring_invoice.date_next <= :due_before")
+	}
+
+	if len(filterStrings) > 0 {
+		query += " WHERE " + strings.Join(filterStrings, " AND ")
+	}
+	query += " ORDER BY recurring_invoice.date_next NULLS LAST, recurring_invoice.id"
+
+	if f.ForUpdate {
+		query += " FOR UPDATE OF recurring_invoice"
+	}
+
+	tx := getContextTx(ctx)
+	rows, err := tx.NamedQuery(ctx, query, f)
+	if err != nil {
+		return nil, zerr.Wrap(err).WithString("query", query).WithAny("filter", f)
+	}
+
+	for rows.Next() {
+		var r RecurringInvoice
+		err = rows.StructScan(&r)
+		if err != nil {
+			rows.Close()
+			return nil, zerr.Wrap(err).WithString("query", query).WithAny("filter", f)
+		}
+		result = append(result, r)
+	}
+	rows.Close()
+
+	for k := range result {
+		r := &result[k]
+		query := "SELECT id, row_order, description, cost, count, unit, vat, is_rot_rut, rot_rut_service_type, rot_rut_hours, is_goods, account, cost*count AS total FROM recurring_invoice_row WHERE recurring_invoice_id = $1 ORDER BY row_order"
+		err = tx.Select(ctx, &r.Rows, query, r.ID)
+		if err != nil {
+			return nil, zerr.Wrap(err).WithString("query", query).WithInt("recurring_invoice.ID", r.ID)
+		}
+	}
+	return result, nil
+}
+
+func RecurringInvoiceGet(ctx context.Context, f RecurringInvoiceFilter) (RecurringInvoice, error) {
+	lst, err := RecurringInvoiceList(ctx, f)
+	if err != nil {
+		return RecurringInvoice{}, err
+	}
+
+	if len(lst) == 0 {
+		return RecurringInvoice{}, sql.ErrNoRows
+	}
+
+	if len(lst) > 1 {
+		return RecurringInvoice{}, zerr.Wrap(errTooManyRows).WithAny("filter", f)
+	}
+	return lst[0], nil
+}
+
+// RecurringInvoiceSave creates or updates a recurring invoice, and replaces all of its rows
+func RecurringInvoiceSave(ctx context.Context, r RecurringInvoice) (int, error) {
+	tx := getContextTx(ctx)
+
+	if r.ID > 0 {
+		query := `UPDATE recurring_invoice SET
+name = $2,
+additional_info = $3,
+rut_applicable = $4,
+customer_id = $5,
+interval = $6,
+date_start = $7,
+date_end = $8,
+date_next = $9,
+due_days = $10,
+send_directly = $11,
+is_active = $12
+WHERE id = $1`
+		_, err := tx.Exec(ctx, query, r.ID, r.Name, r.AdditionalInfo, r.RutApplicable, r.Customer.ID, r.Interval,
+			r.DateStart, r.DateEnd, r.DateNext, r.DueDays, r.SendDirectly, r.IsActive)
+		if err != nil {
+			return 0, zerr.Wrap(err).WithString("query", query).WithAny("recurring", r)
+		}
+	} else {
+		query := `INSERT INTO recurring_invoice (company_id, name, additional_info, rut_applicable, customer_id, interval,
+date_start, date_end, date_next, due_days, send_directly, is_active)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id`
+		err := tx.QueryRow(ctx, query, r.Company.ID, r.Name, r.AdditionalInfo, r.RutApplicable, r.Customer.ID, r.Interval,
+			r.DateStart, r.DateEnd, r.DateNext, r.DueDays, r.SendDirectly, r.IsActive).Scan(&r.ID)
+		if err != nil {
+			return 0, zerr.Wrap(err).WithString("query", query).WithAny("recurring", r)
+		}
+	}
+
+	query := `DELETE FROM recurring_invoice_row WHERE recurring_invoice_id = $1`
+	_, err := tx.Exec(ctx, query, r.ID)
+	if err != nil {
+		return 0, zerr.Wrap(err).WithString("query", query).WithInt("recurring_invoice.ID", r.ID)
+	}
+
+	query = `INSERT INTO recurring_invoice_row (recurring_invoice_id, row_order, description, cost, count, unit, vat, is_rot_rut, rot_rut_service_type, rot_rut_hours, is_goods, account)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+	for k, row := range r.Rows {
+		_, err = tx.Exec(ctx, query, r.ID, k, row.Description, row.Cost, row.Count, row.Unit, row.VAT, row.IsRotRut, row.RotRutServiceType,
+			row.RotRutHours, row.IsGoods, row.Account)
+		if err != nil {
+			return 0, zerr.Wrap(err).WithString("query", query).WithAny("row", row).WithInt("recurring_invoice.ID", r.ID)
+		}
+	}
+	return r.ID, nil
+}
+
+// RecurringInvoiceIssue creates invoices for all runs of r up to and including 'until',
+// and moves the next run forward. The issued invoices are returned.
+// The recurring invoice is locked and reloaded first, so that runs that already have been
+// issued by a concurrent call are not issued again. It must therefore be called within a transaction.
+func RecurringInvoiceIssue(ctx context.Context, r RecurringInvoice, until time.Time) ([]Invoice, error) {
+	var issued []Invoice
+
+	r, err := RecurringInvoiceGet(ctx, RecurringInvoiceFilter{ID: r.ID, ForUpdate: true})
+	if err != nil {
+		return nil, err
+	}
+
+	dates := r.Upcoming(until)
+	if len(dates) == 0 {
+		return issued, nil
+	}
+
+	company, err := CompanyGet(ctx, CompanyFilter{ID: r.Company.ID})
+	if err != nil {
+		return nil, err
+	}
+	r.Company = company
+
+	for _, date := range dates {
+		invoice := r.Invoice(date)
+		invoice.Number, err = company.GetNextInvoiceNumber(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if invoice.IsInvoiced {
+			invoice.OCR = company.OCR(invoice.Number)
+		}
+
+		invoice.ID, err = InvoiceSave(ctx, invoice)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, row := range invoice.Rows {
+			err = InvoiceRowAdd(ctx, invoice.ID, row)
+			if err != nil {
+				return nil, err
+			}
+		}
+		issued = append(issued, invoice)
+	}
+
+	r.DateNext = r.NextRun(dates[len(dates)-1].AddDate(0, 0, 1))
+	_, err = RecurringInvoiceSave(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return issued, nil
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestRecurringInvoiceNextRun(t *testing.T) {
+	end := date("2025-12-31")
+	r := RecurringInvoice{
+		Interval:  RecurringIntervalMonthly,
+		DateStart: date("2025-01-31"),
+		DateEnd:   &end,
+	}
+
+	tests := []struct {
+		interval RecurringInterval
+		from     string
+		expected string
+	}{
+		{interval: RecurringIntervalMonthly, from: "2024-06-01", expected: "2025-01-31"},
+		{interval: RecurringIntervalMonthly, from: "2025-01-31", expected: "2025-01-31"},
+		{interval: RecurringIntervalMonthly, from: "2025-02-01", expected: "2025-02-28"},
+		{interval: RecurringIntervalMonthly, from: "2025-03-01", expected: "2025-03-31"},
+		{interval: RecurringIntervalQuarterly, from: "2025-02-01", expected: "2025-04-30"},
+		{interval: RecurringIntervalQuarterly, from: "2025-11-01", expected: ""},
+		{interval: RecurringIntervalYearly, from: "2025-02-01", expected: ""},
+	}
+
+	for _, tt := range tests {
+		r.Interval = tt.interval
+		next := r.NextRun(date(tt.from))
+		if tt.expected == "" {
+			if next != nil {
+				t.Errorf("NextRun(%s) with interval %s = %s, expected no run", tt.from, tt.interval, next.Format("2006-01-02"))
+			}
+			continue
+		}
+
+		if next == nil || !next.Equal(date(tt.expected)) {
+			t.Errorf("NextRun(%s) with interval %s = %v, expected %s", tt.from, tt.interval, next, tt.expected)
+		}
+	}
+}
+
+func TestRecurringInvoiceUpcoming(t *testing.T) {
+	next := date("2025-11-15")
+	end := date("2026-02-01")
+	r := RecurringInvoice{
+		Interval:  RecurringIntervalMonthly,
+		DateStart: date("2025-09-15"),
+		DateEnd:   &end,
+		DateNext:  &next,
+		IsActive:  true,
+	}
+
+	expected := []string{"2025-11-15", "2025-12-15", "2026-01-15"}
+	upcoming := r.Upcoming(date("2026-06-01"))
+	if len(upcoming) != len(expected) {
+		t.Fatalf("Upcoming returned %d runs, expected %d", len(upcoming), len(expected))
+	}
+
+	for k := range expected {
+		if upcoming[k].Format("2006-01-02") != expected[k] {
+			t.Errorf("run %d is %s, expected %s", k, upcoming[k].Format("2006-01-02"), expected[k])
+		}
+	}
+
+	r.IsActive = false
+	if len(r.Upcoming(time.Now())) != 0 {
+		t.Errorf("inactive recurring invoices should not have upcoming runs")
+	}
+}
+
+func TestRecurringInvoiceInvoice(t *testing.T) {
+	account := 3108
+	hours := 4
+	r := RecurringInvoice{
+		ID:           7,
+		DateStart:    date("2025-09-15"),
+		DueDays:      30,
+		SendDirectly: true,
+		Rows: []InvoiceRow{
+			{ID: 3, Description: "Reservdelar", Cost: decimal.NewFromInt(100), Count: decimal.NewFromInt(2), VAT: VATTypeEU, IsGoods: true, Account: &account, RotRutHours: &hours},
+		},
+	}
+
+	invoice := r.Invoice(date("2025-10-15"))
+	if !invoice.IsInvoiced || invoice.DateDue == nil || invoice.DateDue.Format("2006-01-02") != "2025-11-14" {
+		t.Errorf("expected a sent invoice due in 30 days, got %+v", invoice)
+	}
+
+	row := invoice.Rows[0]
+	if row.ID != 0 || !row.IsGoods || row.Account == nil || *row.Account != account || row.RotRutHours == nil || *row.RotRutHours != hours {
+		t.Errorf("expected the row to keep goods, account and hours, got %+v", row)
+	}
+	if !row.Total.Equal(decimal.NewFromInt(200)) {
+		t.Errorf("got row total %s, expected 200", row.Total)
+	}
+}
@@ -0,0 +1,210 @@
+package models
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/yzzyx/zerr"
+)
+
+// MaxReminderFee is the highest reminder fee allowed by the
+// Swedish act on compensation for debt collection costs (1981:739)
+var MaxReminderFee = decimal.NewFromInt(60)
+
+// LateInterestMargin is the number of percentage points added to the
+// reference rate when calculating late-payment interest (räntelagen 6 §)
+var LateInterestMargin = decimal.NewFromInt(8)
+
+// ReferenceRate is the reference rate set by Riksbanken, valid from DateFrom
+type ReferenceRate struct {
+	DateFrom time.Time
+	Rate     decimal.Decimal // Percent
+}
+
+// Reminder is a payment reminder (påminnelse) sent for an overdue invoice
+type Reminder struct {
+	ID           int
+	InvoiceID    int
+	Number       int // 1 for the first reminder, 2 for the second etc.
+	DateSent     time.Time
+	DateDue      time.Time
+	Fee          decimal.Decimal
+	Interest     decimal.Decimal
+	InterestRate decimal.Decimal // Late-payment interest rate in percent, at the time the reminder was sent
+	InterestFrom *time.Time
+	InterestTo   *time.Time
+	DateCreated  time.Time
+}
+
+// Total returns the sum of fee and interest of the reminder
+func (r Reminder) Total() decimal.Decimal {
+	return r.Fee.Add(r.Interest)
+}
+
+type ReminderFilter struct {
+	ID        int
+	InvoiceID int
+	CompanyID int
+}
+
+// ReminderCharges returns the sum of all fees and interest charged by reminders
+func (i *Invoice) ReminderCharges() decimal.Decimal {
+	sum := decimal.Zero
+	for _, r := range i.Reminders {
+		sum = sum.Add(r.Total())
+	}
+	return sum
+}
+
+// IsOverdue returns true if the invoice has been sent, is not paid, and the due date has passed.
+// It uses a value receiver so that it can be called from templates.
+func (i Invoice) IsOverdue() bool {
+	if !i.IsInvoiced || i.IsPaid || i.IsOffer || i.IsCreditNote || i.DateDue == nil {
+		return false
+	}
+	return time.Now().Truncate(24 * time.Hour).After(*i.DateDue)
+}
+
+// ReferenceRateAt returns the reference rate in effect at date t
+func ReferenceRateAt(rates []ReferenceRate, t time.Time) decimal.Decimal {
+	rate := decimal.Zero
+	var latest time.Time
+	for _, r := range rates {
+		if !r.DateFrom.After(t) && !r.DateFrom.Before(latest) {
+			rate = r.Rate
+			latest = r.DateFrom
+		}
+	}
+	return rate
+}
+
+// LateInterestRate returns the late-payment interest rate in percent at date t
+func LateInterestRate(rates []ReferenceRate, t time.Time) decimal.Decimal {
+	return ReferenceRateAt(rates, t).Add(LateInterestMargin)
+}
+
+// LateInterest calculates the late-payment interest for amount, from the date 'from' to the date 'to'.
+// According to räntelagen 9 §, the reference rate in effect at the start of each
+// half-year (January 1st and July 1st) is used for the whole half-year.
+// Interest is calculated on the actual number of days, with 365 days per year.
+func LateInterest(amount decimal.Decimal, from, to time.Time, rates []ReferenceRate) decimal.Decimal {
+	interest := decimal.Zero
+	if !amount.IsPositive() {
+		return interest
+	}
+
+	daysInYear := decimal.NewFromInt(365)
+	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	end := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	for start.Before(end) {
+		halfYear := time.Date(start.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+		if start.Month() >= time.July {
+			halfYear = time.Date(start.Year(), time.July, 1, 0, 0, 0, 0, time.UTC)
+		}
+
+		periodEnd := halfYear.AddDate(0, 6, 0)
+		if periodEnd.After(end) {
+			periodEnd = end
+		}
+
+		days := decimal.NewFromInt(int64(periodEnd.Sub(start).Hours() / 24))
+		rate := LateInterestRate(rates, halfYear).Div(decimal.NewFromInt(100))
+		interest = interest.Add(amount.Mul(rate).Mul(days).Div(daysInYear))
+		start = periodEnd
+	}
+	return interest.Round(2)
+}
+
+func ReferenceRateList(ctx context.Context) ([]ReferenceRate, error) {
+	var rates []ReferenceRate
+	tx := getContextTx(ctx)
+
+	query := `SELECT date_from, rate FROM reference_rate ORDER BY date_from`
+	err := tx.Select(ctx, &rates, query)
+	if err != nil {
+		return nil, zerr.Wrap(err).WithString("query", query)
+	}
+	return rates, nil
+}
+
+func ReferenceRateSave(ctx context.Context, r ReferenceRate) error {
+	tx := getContextTx(ctx)
+
+	query := `INSERT INTO reference_rate (date_from, rate) VALUES ($1, $2)
+ON CONFLICT (date_from) DO UPDATE SET rate = EXCLUDED.rate`
+	_, err := tx.Exec(ctx, query, r.DateFrom, r.Rate)
+	if err != nil {
+		return zerr.Wrap(err).WithString("query", query).WithAny("rate", r)
+	}
+	return nil
+}
+
+func ReminderList(ctx context.Context, f ReminderFilter) ([]Reminder, error) {
+	var reminders []Reminder
+
+	query := `SELECT
+	invoice_reminder.id,
+	invoice_reminder.invoice_id,
+	invoice_reminder.number,
+	invoice_reminder.date_sent,
+	invoice_reminder.date_due,
+	invoice_reminder.fee,
+	invoice_reminder.interest,
+	invoice_reminder.interest_rate,
+	invoice_reminder.interest_from,
+	invoice_reminder.interest_to,
+	invoice_reminder.date_created
+FROM invoice_reminder
+INNER JOIN invoice ON invoice.id = invoice_reminder.invoice_id`
+
+	filterStrings := []string{}
+	if f.ID > 0 {
+		filterStrings = append(filterStrings, "invoice_reminder.id = :id")
+	}
+
+	if f.InvoiceID > 0 {
+		filterStrings = append(filterStrings, "invoice_reminder.invoice_id = :invoice_id")
+	}
+
+	if f.CompanyID > 0 {
+		filterStrings = append(filterStrings, "invoice.company_id = :company_id")
+	}
+
+	if len(filterStrings) > 0 {
+		query += " WHERE " + strings.Join(filterStrings, " AND ")
+	}
+	query += " ORDER BY invoice_reminder.number"
+
+	tx := getContextTx(ctx)
+	rows, err := tx.NamedQuery(ctx, query, f)
+	if err != nil {
+		return nil, zerr.Wrap(err).WithString("query", query).WithAny("filter", f)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r Reminder
+		err = rows.StructScan(&r)
+		if err != nil {
+			return nil, zerr.Wrap(err).WithString("query", query).WithAny("filter", f)
+		}
+		reminders = append(reminders, r)
+	}
+
+	return reminders, nil
+}
+
+func ReminderAdd(ctx context.Context, r Reminder) (int, error) {
+	tx := getContextTx(ctx)
+
+	query := `INSERT INTO invoice_reminder (invoice_id, number, date_sent, date_due, fee, interest, interest_rate, interest_from, interest_to)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`
+	err := tx.QueryRow(ctx, query, r.InvoiceID, r.Number, r.DateSent, r.DateDue, r.Fee, r.Interest, r.InterestRate,
+		r.InterestFrom, r.InterestTo).Scan(&r.ID)
+	if err != nil {
+		return 0, zerr.Wrap(err).WithString("query", query).WithAny("reminder", r)
+	}
+	return r.ID, nil
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+var testRates = []ReferenceRate{
+	{DateFrom: date("2024-01-01"), Rate: decimal.NewFromFloat(4)},
+	{DateFrom: date("2024-07-01"), Rate: decimal.NewFromFloat(3.75)},
+	{DateFrom: date("2025-01-01"), Rate: decimal.NewFromFloat(2.5)},
+}
+
+func TestReferenceRateAt(t *testing.T) {
+	tests := []struct {
+		date     string
+		expected string
+	}{
+		{date: "2023-12-31", expected: "0"},
+		{date: "2024-01-01", expected: "4"},
+		{date: "2024-06-30", expected: "4"},
+		{date: "2024-07-01", expected: "3.75"},
+		{date: "2026-03-01", expected: "2.5"},
+	}
+
+	for _, tt := range tests {
+		rate := ReferenceRateAt(testRates, date(tt.date))
+		if !rate.Equal(decimal.RequireFromString(tt.expected)) {
+			t.Errorf("ReferenceRateAt(%s) = %s, expected %s", tt.date, rate, tt.expected)
+		}
+	}
+}
+
+func TestLateInterest(t *testing.T) {
+	tests := []struct {
+		amount   string
+		from     string
+		to       string
+		expected string
+	}{
+		// 10000 * 12 % * 30 / 365
+		{amount: "10000", from: "2024-03-01", to: "2024-03-31", expected: "98.63"},
+		// 10000 * (12 % * 10 / 365 + 11.75 % * 20 / 365)
+		{amount: "10000", from: "2024-06-21", to: "2024-07-21", expected: "97.26"},
+		{amount: "10000", from: "2024-03-31", to: "2024-03-01", expected: "0"},
+		{amount: "0", from: "2024-03-01", to: "2024-03-31", expected: "0"},
+	}
+
+	for _, tt := range tests {
+		interest := LateInterest(decimal.RequireFromString(tt.amount), date(tt.from), date(tt.to), testRates)
+		if !interest.Equal(decimal.RequireFromString(tt.expected)) {
+			t.Errorf("LateInterest(%s, %s, %s) = %s, expected %s", tt.amount, tt.from, tt.to, interest, tt.expected)
+		}
+	}
+}
@@ -14,17 +14,19 @@ import (
 type RUTStatus int
 
 const (
-	RUTStatusPending  RUTStatus = 0
-	RUTStatusSent     RUTStatus = 1
-	RUTStatusPaid     RUTStatus = 2
-	RUTStatusRejected RUTStatus = 3
+	RUTStatusPending   RUTStatus = 0
+	RUTStatusSent      RUTStatus = 1
+	RUTStatusPaid      RUTStatus = 2
+	RUTStatusRejected  RUTStatus = 3
+	RUTStatusWithdrawn RUTStatus = 4 // The invoice has been credited before the request was sent
 )
 
 var rutStatusString = map[RUTStatus]string{
-	RUTStatusPending:  "skall skickas in",
-	RUTStatusSent:     "inskickad",
-	RUTStatusPaid:     "betalad",
-	RUTStatusRejected: "avslagen",
+	RUTStatusPending:   "skall skickas in",
+	RUTStatusSent:      "inskickad",
+	RUTStatusPaid:      "betalad",
+	RUTStatusRejected:  "avslagen",
+	RUTStatusWithdrawn: "återkallad",
 }
 
 func (r RUTStatus) String() string {
@@ -90,9 +92,10 @@ WHERE id = $1`
 			return 0, zerr.Wrap(err).WithString("query", query).WithAny("rut", rut)
 		}
 
-		// If rows are supplied, and rot_rut_hours are set, we update those as well
+		// If rows are supplied, and rot_rut_hours are set, we update those as well.
+		// Rows without deduction are also updated, since they may be marked as material for a type of service.
 		for _, row := range rut.Invoice.Rows {
-			if row.RotRutHours == nil {
+			if row.RotRutHours == nil && row.IsRotRut {
 				continue
 			}
 
@@ -0,0 +1,427 @@
+package pdfa
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+)
+
+// xrefEntry describes where an object is stored
+type xrefEntry struct {
+	offset int // Offset in file of uncompressed objects
+	gen    int
+	stream int // Object number of the object stream of compressed objects
+	index  int // Index in the object stream
+}
+
+// document is a parsed PDF file
+type document struct {
+	data    []byte
+	xref    map[int]xrefEntry
+	trailer dict
+
+	objects map[int]object // Objects that have been read, by object number
+	streams map[int][]object
+}
+
+// readDocument reads the cross-reference table and trailer of a PDF file
+func readDocument(data []byte) (*document, error) {
+	doc := &document{
+		data:    data,
+		xref:    map[int]xrefEntry{},
+		objects: map[int]object{},
+		streams: map[int][]object{},
+	}
+
+	pos := bytes.LastIndex(data, []byte("startxref"))
+	if pos < 0 {
+		return nil, fmt.Errorf("pdfa: startxref not found")
+	}
+	l := &lexer{data: data, pos: pos + len("startxref")}
+	offset, err := l.int()
+	if err != nil {
+		return nil, err
+	}
+
+	// Follow the chain of cross-reference sections, from the newest to the oldest.
+	// Entries in newer sections replaces the older entries.
+	seen := map[int]bool{}
+	for offset > 0 && !seen[offset] {
+		seen[offset] = true
+
+		trailer, err := doc.readXRef(offset)
+		if err != nil {
+			return nil, err
+		}
+		if doc.trailer == nil {
+			doc.trailer = trailer
+		}
+
+		// Hybrid files have the compressed objects in a separate cross-reference stream
+		if stm, ok := trailer["XRefStm"].(token); ok {
+			o, _ := strconv.Atoi(string(stm))
+			if _, err := doc.readXRef(o); err != nil {
+				return nil, err
+			}
+		}
+
+		prev, _ := trailer["Prev"].(token)
+		offset, _ = strconv.Atoi(string(prev))
+	}
+
+	if _, ok := doc.trailer["Encrypt"]; ok {
+		return nil, fmt.Errorf("pdfa: encrypted documents are not supported")
+	}
+	return doc, nil
+}
+
+// addXRef adds an entry to the cross-reference table, unless a newer entry already exists
+func (doc *document) addXRef(num int, e xrefEntry) {
+	if _, ok := doc.xref[num]; !ok {
+		doc.xref[num] = e
+	}
+}
+
+// readXRef reads a cross-reference table or stream at offset, and returns the trailer
+func (doc *document) readXRef(offset int) (dict, error) {
+	if offset >= len(doc.data) {
+		return nil, fmt.Errorf("pdfa: invalid xref offset %d", offset)
+	}
+
+	l := &lexer{data: doc.data, pos: offset}
+	l.skipSpace()
+	if !bytes.HasPrefix(doc.data[l.pos:], []byte("xref")) {
+		return doc.readXRefStream(offset)
+	}
+	l.pos += len("xref")
+
+	// Free objects are added as well, so that they aren't replaced by entries in older sections
+	for {
+		pos := l.pos
+		if l.keyword() == "trailer" {
+			break
+		}
+		l.pos = pos
+
+		start, err := l.int()
+		if err != nil {
+			return nil, err
+		}
+		count, err := l.int()
+		if err != nil {
+			return nil, err
+		}
+		for k := 0; k < count; k++ {
+			o, err := l.int()
+			if err != nil {
+				return nil, err
+			}
+			gen, err := l.int()
+			if err != nil {
+				return nil, err
+			}
+			e := xrefEntry{offset: o, gen: gen}
+			if l.keyword() == "f" {
+				e.offset = -1
+			}
+			doc.addXRef(start+k, e)
+		}
+	}
+
+	obj, err := l.object()
+	if err != nil {
+		return nil, err
+	}
+	trailer, ok := obj.(dict)
+	if !ok {
+		return nil, fmt.Errorf("pdfa: invalid trailer")
+	}
+	return trailer, nil
+}
+
+// readXRefStream reads a cross-reference stream at offset, and returns its dictionary as the trailer
+func (doc *document) readXRefStream(offset int) (dict, error) {
+	_, obj, err := doc.readIndirect(offset)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := obj.(*stream)
+	if !ok || s.dict["Type"] != name("XRef") {
+		return nil, fmt.Errorf("pdfa: expected xref at offset %d", offset)
+	}
+
+	data, err := doc.decode(s)
+	if err != nil {
+		return nil, err
+	}
+
+	w, _ := s.dict["W"].(array)
+	if len(w) != 3 {
+		return nil, fmt.Errorf("pdfa: invalid xref stream")
+	}
+	var widths [3]int
+	for k := range widths {
+		widths[k] = doc.int(w[k])
+	}
+
+	index, _ := s.dict["Index"].(array)
+	if index == nil {
+		index = array{token("0"), s.dict["Size"]}
+	}
+
+	pos := 0
+	field := func(k int, def int) int {
+		if widths[k] == 0 {
+			return def
+		}
+		v := 0
+		for i := 0; i < widths[k]; i++ {
+			v = v<<8 | int(data[pos])
+			pos++
+		}
+		return v
+	}
+
+	entrySize := widths[0] + widths[1] + widths[2]
+	for k := 0; k+1 < len(index); k += 2 {
+		start, count := doc.int(index[k]), doc.int(index[k+1])
+		for i := 0; i < count; i++ {
+			if pos+entrySize > len(data) {
+				return nil, fmt.Errorf("pdfa: xref stream is too short")
+			}
+			typ, f2, f3 := field(0, 1), field(1, 0), field(2, 0)
+			switch typ {
+			case 0:
+				doc.addXRef(start+i, xrefEntry{offset: -1})
+			case 1:
+				doc.addXRef(start+i, xrefEntry{offset: f2, gen: f3})
+			case 2:
+				doc.addXRef(start+i, xrefEntry{offset: -1, stream: f2, index: f3})
+			}
+		}
+	}
+	return s.dict, nil
+}
+
+// int returns the value of an integer object, that can be given as a reference
+func (doc *document) int(obj object) int {
+	if r, ok := obj.(ref); ok {
+		obj, _ = doc.object(r.num)
+	}
+	t, _ := obj.(token)
+	v, _ := strconv.Atoi(string(t))
+	return v
+}
+
+// readIndirect reads the indirect object at offset
+func (doc *document) readIndirect(offset int) (num int, obj object, err error) {
+	l := &lexer{data: doc.data, pos: offset}
+	if num, err = l.int(); err != nil {
+		return 0, nil, err
+	}
+	if _, err = l.int(); err != nil {
+		return 0, nil, err
+	}
+	if err = l.expect("obj"); err != nil {
+		return 0, nil, err
+	}
+
+	obj, err = l.object()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	d, ok := obj.(dict)
+	if !ok {
+		return num, obj, nil
+	}
+
+	length := -1
+	if _, ok := d["Length"]; ok {
+		length = doc.int(d["Length"])
+	}
+	data, err := l.streamData(length)
+	if err != nil {
+		return 0, nil, err
+	}
+	if data != nil {
+		return num, &stream{dict: d, data: data}, nil
+	}
+	return num, obj, nil
+}
+
+// object returns the object with number num, or nil if the object doesn't exist
+func (doc *document) object(num int) (object, error) {
+	if obj, ok := doc.objects[num]; ok {
+		return obj, nil
+	}
+
+	e, ok := doc.xref[num]
+	if !ok || (e.offset < 0 && e.stream == 0) {
+		return nil, nil
+	}
+
+	// Mark the object as read, to avoid loops if the length of a stream refers back to the object
+	doc.objects[num] = nil
+
+	var obj object
+	if e.offset >= 0 {
+		n, o, err := doc.readIndirect(e.offset)
+		if err != nil {
+			return nil, err
+		}
+		if n != num {
+			return nil, fmt.Errorf("pdfa: expected object %d at offset %d, found %d", num, e.offset, n)
+		}
+		obj = o
+	} else {
+		objs, err := doc.objectStream(e.stream)
+		if err != nil {
+			return nil, err
+		}
+		if e.index >= len(objs) {
+			return nil, fmt.Errorf("pdfa: object %d not found in object stream %d", num, e.stream)
+		}
+		obj = objs[e.index]
+	}
+
+	doc.objects[num] = obj
+	return obj, nil
+}
+
+// objectStream returns the objects stored in the object stream num
+func (doc *document) objectStream(num int) ([]object, error) {
+	if objs, ok := doc.streams[num]; ok {
+		return objs, nil
+	}
+
+	obj, err := doc.object(num)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := obj.(*stream)
+	if !ok || s.dict["Type"] != name("ObjStm") {
+		return nil, fmt.Errorf("pdfa: object %d is not an object stream", num)
+	}
+
+	data, err := doc.decode(s)
+	if err != nil {
+		return nil, err
+	}
+
+	n, first := doc.int(s.dict["N"]), doc.int(s.dict["First"])
+	l := &lexer{data: data}
+	offsets := make([]int, n)
+	for k := range offsets {
+		if _, err = l.int(); err != nil {
+			return nil, err
+		}
+		if offsets[k], err = l.int(); err != nil {
+			return nil, err
+		}
+	}
+
+	objs := make([]object, n)
+	for k, o := range offsets {
+		l.pos = first + o
+		if objs[k], err = l.object(); err != nil {
+			return nil, err
+		}
+	}
+	doc.streams[num] = objs
+	return objs, nil
+}
+
+// decode returns the decoded data of a stream. Only FlateDecode, with or without PNG predictors, is supported.
+func (doc *document) decode(s *stream) ([]byte, error) {
+	filter := s.dict["Filter"]
+	if a, ok := filter.(array); ok && len(a) == 1 {
+		filter = a[0]
+	}
+
+	switch filter {
+	case nil:
+		return s.data, nil
+	case name("FlateDecode"):
+	default:
+		return nil, fmt.Errorf("pdfa: unsupported filter %v", filter)
+	}
+
+	r, err := zlib.NewReader(bytes.NewReader(s.data))
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	params, _ := s.dict["DecodeParms"].(dict)
+	if doc.int(params["Predictor"]) < 10 {
+		return data, nil
+	}
+
+	columns := doc.int(params["Columns"])
+	if columns <= 0 {
+		columns = 1
+	}
+	return pngUnpredict(data, columns)
+}
+
+// pngUnpredict reverses PNG prediction of rows with the given number of bytes
+func pngUnpredict(data []byte, columns int) ([]byte, error) {
+	var out []byte
+	prev := make([]byte, columns)
+	for pos := 0; pos < len(data); pos += columns + 1 {
+		if pos+columns+1 > len(data) {
+			return nil, fmt.Errorf("pdfa: invalid predictor data")
+		}
+		typ, row := data[pos], data[pos+1:pos+1+columns]
+		cur := make([]byte, columns)
+		for k := range row {
+			var left, upLeft byte
+			if k > 0 {
+				left, upLeft = cur[k-1], prev[k-1]
+			}
+			up := prev[k]
+
+			switch typ {
+			case 0:
+				cur[k] = row[k]
+			case 1:
+				cur[k] = row[k] + left
+			case 2:
+				cur[k] = row[k] + up
+			case 3:
+				cur[k] = row[k] + byte((int(left)+int(up))/2)
+			case 4:
+				cur[k] = row[k] + paeth(left, up, upLeft)
+			default:
+				return nil, fmt.Errorf("pdfa: invalid PNG predictor %d", typ)
+			}
+		}
+		out = append(out, cur...)
+		prev = cur
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	abs := func(v int) int {
+		if v < 0 {
+			return -v
+		}
+		return v
+	}
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
@@ -0,0 +1,103 @@
+package pdfa
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+)
+
+// s15Fixed16 converts v to the fixed point format used in ICC profiles
+func s15Fixed16(v float64) uint32 {
+	return uint32(int32(math.Round(v * 65536)))
+}
+
+// srgbProfile returns an ICC version 2 display profile for sRGB, used as the output intent of the documents.
+// The primaries are adapted to the D50 illuminant of the profile connection space.
+func srgbProfile() []byte {
+	xyz := func(x, y, z float64) []byte {
+		b := make([]byte, 20)
+		copy(b, "XYZ ")
+		binary.BigEndian.PutUint32(b[8:], s15Fixed16(x))
+		binary.BigEndian.PutUint32(b[12:], s15Fixed16(y))
+		binary.BigEndian.PutUint32(b[16:], s15Fixed16(z))
+		return b
+	}
+
+	// The sRGB transfer function, as a table of 1024 entries
+	trc := &bytes.Buffer{}
+	trc.WriteString("curv\x00\x00\x00\x00")
+	binary.Write(trc, binary.BigEndian, uint32(1024))
+	for k := 0; k < 1024; k++ {
+		v := float64(k) / 1023
+		if v <= 0.04045 {
+			v = v / 12.92
+		} else {
+			v = math.Pow((v+0.055)/1.055, 2.4)
+		}
+		binary.Write(trc, binary.BigEndian, uint16(math.Round(v*65535)))
+	}
+
+	desc := &bytes.Buffer{}
+	description := "sRGB IEC61966-2.1\x00"
+	desc.WriteString("desc\x00\x00\x00\x00")
+	binary.Write(desc, binary.BigEndian, uint32(len(description)))
+	desc.WriteString(description)
+	desc.Write(make([]byte, 4+4+2+1+67)) // Empty Unicode and ScriptCode descriptions
+
+	tags := []struct {
+		sig  string
+		data []byte
+	}{
+		{"desc", desc.Bytes()},
+		{"cprt", []byte("text\x00\x00\x00\x00No copyright, use freely\x00")},
+		{"wtpt", xyz(0.9642, 1.0, 0.8249)},
+		{"rXYZ", xyz(0.4361, 0.2225, 0.0139)},
+		{"gXYZ", xyz(0.3851, 0.7169, 0.0971)},
+		{"bXYZ", xyz(0.1431, 0.0606, 0.7141)},
+		{"rTRC", trc.Bytes()},
+		{"gTRC", trc.Bytes()},
+		{"bTRC", trc.Bytes()},
+	}
+
+	// The tag data follows the header and the tag table, aligned to four bytes.
+	// The curves are the same for all channels, and are only stored once.
+	table := &bytes.Buffer{}
+	data := &bytes.Buffer{}
+	offset := 128 + 4 + 12*len(tags)
+	trcOffset := 0
+	binary.Write(table, binary.BigEndian, uint32(len(tags)))
+	for _, t := range tags {
+		pos := offset + data.Len()
+		if t.sig[1:] == "TRC" {
+			if trcOffset == 0 {
+				trcOffset = pos
+				data.Write(t.data)
+			}
+			pos = trcOffset
+		} else {
+			data.Write(t.data)
+		}
+		for data.Len()%4 != 0 {
+			data.WriteByte(0)
+		}
+
+		table.WriteString(t.sig)
+		binary.Write(table, binary.BigEndian, uint32(pos))
+		binary.Write(table, binary.BigEndian, uint32(len(t.data)))
+	}
+
+	header := make([]byte, 128)
+	binary.BigEndian.PutUint32(header[0:], uint32(128+table.Len()+data.Len()))
+	binary.BigEndian.PutUint32(header[8:], 0x02100000) // Version 2.1
+	copy(header[12:], "mntrRGB XYZ ")
+	binary.BigEndian.PutUint16(header[24:], 2000) // Creation date
+	binary.BigEndian.PutUint16(header[26:], 1)
+	binary.BigEndian.PutUint16(header[28:], 1)
+	copy(header[36:], "acsp")
+	binary.BigEndian.PutUint32(header[68:], s15Fixed16(0.9642)) // Illuminant of the profile connection space
+	binary.BigEndian.PutUint32(header[72:], s15Fixed16(1.0))
+	binary.BigEndian.PutUint32(header[76:], s15Fixed16(0.8249))
+
+	profile := append(header, table.Bytes()...)
+	return append(profile, data.Bytes()...)
+}
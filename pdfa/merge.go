@@ -0,0 +1,273 @@
+package pdfa
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// inheritable are the attributes of a page that can be inherited from the nodes of the page tree
+var inheritable = []name{"Resources", "MediaBox", "CropBox", "Rotate"}
+
+// page is a page of a document
+type page struct {
+	ref  ref
+	dict dict // The page dictionary, including the inherited attributes
+}
+
+// resolve returns the object that obj refers to, or obj itself if it isn't a reference
+func (doc *document) resolve(obj object) (object, error) {
+	if r, ok := obj.(ref); ok {
+		return doc.object(r.num)
+	}
+	return obj, nil
+}
+
+// pages returns the pages of the document, in order
+func (doc *document) pages() ([]page, error) {
+	rootRef, ok := doc.trailer["Root"].(ref)
+	if !ok {
+		return nil, fmt.Errorf("pdfa: document catalog not found")
+	}
+	root, err := doc.object(rootRef.num)
+	if err != nil {
+		return nil, err
+	}
+	catalog, ok := root.(dict)
+	if !ok {
+		return nil, fmt.Errorf("pdfa: document catalog not found")
+	}
+	treeRef, ok := catalog["Pages"].(ref)
+	if !ok {
+		return nil, fmt.Errorf("pdfa: page tree not found")
+	}
+
+	var pages []page
+	seen := map[int]bool{}
+	var walk func(r ref, inherited dict) error
+	walk = func(r ref, inherited dict) error {
+		if seen[r.num] {
+			return fmt.Errorf("pdfa: loop in page tree at object %d", r.num)
+		}
+		seen[r.num] = true
+
+		obj, err := doc.object(r.num)
+		if err != nil {
+			return err
+		}
+		node, ok := obj.(dict)
+		if !ok {
+			return fmt.Errorf("pdfa: invalid page tree node %d", r.num)
+		}
+
+		attrs := dict{}
+		for k, v := range inherited {
+			attrs[k] = v
+		}
+		for _, k := range inheritable {
+			if v, ok := node[k]; ok {
+				attrs[k] = v
+			}
+		}
+
+		if node["Type"] == name("Page") {
+			p := dict{}
+			for k, v := range node {
+				p[k] = v
+			}
+			for k, v := range attrs {
+				p[k] = v
+			}
+			pages = append(pages, page{ref: r, dict: p})
+			return nil
+		}
+
+		kids, err := doc.resolve(node["Kids"])
+		if err != nil {
+			return err
+		}
+		kidsArray, _ := kids.(array)
+		for _, kid := range kidsArray {
+			kidRef, ok := kid.(ref)
+			if !ok {
+				return fmt.Errorf("pdfa: invalid page tree node %d", r.num)
+			}
+			if err := walk(kidRef, attrs); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := walk(treeRef, dict{}); err != nil {
+		return nil, err
+	}
+	return pages, nil
+}
+
+// copier copies objects from a document, and the objects they refer to, with new object numbers
+type copier struct {
+	doc     *document
+	objects map[int]object // Copied objects, by new object number
+	nums    map[int]int    // New object numbers, by object number in doc
+	next    int            // Next unused object number
+}
+
+// reserve returns a reference to the new object number of object num in doc
+func (c *copier) reserve(num int) ref {
+	n, ok := c.nums[num]
+	if !ok {
+		n = c.next
+		c.next++
+		c.nums[num] = n
+	}
+	return ref{num: n}
+}
+
+// copy returns a copy of obj, where references refer to copies of the objects
+func (c *copier) copy(obj object) (object, error) {
+	switch o := obj.(type) {
+	case ref:
+		if n, ok := c.nums[o.num]; ok {
+			return ref{num: n}, nil
+		}
+		r := c.reserve(o.num)
+		target, err := c.doc.object(o.num)
+		if err != nil {
+			return nil, err
+		}
+		copied, err := c.copy(target)
+		if err != nil {
+			return nil, err
+		}
+		c.objects[r.num] = copied
+		return r, nil
+	case array:
+		a := make(array, len(o))
+		for k, v := range o {
+			copied, err := c.copy(v)
+			if err != nil {
+				return nil, err
+			}
+			a[k] = copied
+		}
+		return a, nil
+	case dict:
+		return c.copyDict(o)
+	case *stream:
+		d, err := c.copyDict(o.dict)
+		if err != nil {
+			return nil, err
+		}
+		return &stream{dict: d, data: o.data}, nil
+	}
+	return obj, nil
+}
+
+// copyDict returns a copy of d. The parents of pages are left out, since only the pages
+// are copied from the page tree.
+func (c *copier) copyDict(d dict) (dict, error) {
+	isPage := d["Type"] == name("Page") || d["Type"] == name("Pages")
+	out := dict{}
+	for k, v := range d {
+		if isPage && k == "Parent" {
+			continue
+		}
+		copied, err := c.copy(v)
+		if err != nil {
+			return nil, err
+		}
+		out[k] = copied
+	}
+	return out, nil
+}
+
+// PageCount returns the number of pages in the PDF document data
+func PageCount(data []byte) (int, error) {
+	doc, err := readDocument(data)
+	if err != nil {
+		return 0, err
+	}
+	pages, err := doc.pages()
+	if err != nil {
+		return 0, err
+	}
+	return len(pages), nil
+}
+
+// Merge appends the pages of the other documents to the first document. Only the pages, and the objects
+// used by them, are copied from the other documents, e.g. their outlines and embedded files are not included.
+func Merge(documents ...[]byte) ([]byte, error) {
+	if len(documents) == 0 {
+		return nil, fmt.Errorf("pdfa: no documents to merge")
+	}
+
+	doc, err := readDocument(documents[0])
+	if err != nil {
+		return nil, err
+	}
+
+	rootRef, ok := doc.trailer["Root"].(ref)
+	if !ok {
+		return nil, fmt.Errorf("pdfa: document catalog not found")
+	}
+
+	objects, gens, next, err := loadObjects(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	root, ok := objects[rootRef.num].(dict)
+	if !ok {
+		return nil, fmt.Errorf("pdfa: document catalog not found")
+	}
+	treeRef, ok := root["Pages"].(ref)
+	if !ok {
+		return nil, fmt.Errorf("pdfa: page tree not found")
+	}
+	tree, ok := objects[treeRef.num].(dict)
+	if !ok {
+		return nil, fmt.Errorf("pdfa: page tree not found")
+	}
+
+	// The page tree of the document is placed under a new root, next to the appended pages,
+	// so that the appended pages don't inherit the attributes of the original page tree
+	newRoot := ref{num: next}
+	next++
+	tree["Parent"] = newRoot
+	kids := array{treeRef}
+	count := doc.int(tree["Count"])
+
+	for _, data := range documents[1:] {
+		other, err := readDocument(data)
+		if err != nil {
+			return nil, err
+		}
+		pages, err := other.pages()
+		if err != nil {
+			return nil, err
+		}
+
+		c := &copier{doc: other, objects: objects, nums: map[int]int{}, next: next}
+		for _, p := range pages {
+			r := c.reserve(p.ref.num)
+			copied, err := c.copyDict(p.dict)
+			if err != nil {
+				return nil, err
+			}
+			copied["Parent"] = newRoot
+			objects[r.num] = copied
+			kids = append(kids, r)
+			count++
+		}
+		next = c.next
+	}
+
+	objects[newRoot.num] = dict{
+		"Type":  name("Pages"),
+		"Kids":  kids,
+		"Count": token(strconv.Itoa(count)),
+	}
+	root["Pages"] = newRoot
+
+	return write(objects, gens, next, rootRef, documents[0]), nil
+}
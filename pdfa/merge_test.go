@@ -0,0 +1,68 @@
+package pdfa
+
+import (
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	out, err := Merge(gofpdfPDF(t), objectStreamPDF(), gofpdfPDF(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := PageCount(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Fatalf("got %d pages, expected 3", count)
+	}
+
+	doc, err := readDocument(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages, err := doc.pages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The appended pages must have the attributes inherited from their original page tree
+	for k, p := range pages {
+		if _, ok := p.dict["MediaBox"].(array); !ok {
+			t.Errorf("page %d: expected a media box", k+1)
+		}
+		if _, ok := p.dict["Parent"].(ref); !ok {
+			t.Errorf("page %d: expected a parent", k+1)
+		}
+	}
+	for _, k := range []int{0, 2} {
+		if _, ok := pages[k].dict["Resources"]; !ok {
+			t.Errorf("page %d: expected resources", k+1)
+		}
+		contents, _ := pages[k].dict["Contents"].(ref)
+		obj, _ := doc.object(contents.num)
+		s, ok := obj.(*stream)
+		if !ok {
+			t.Fatalf("page %d: expected the contents of the page", k+1)
+		}
+		if data, err := doc.decode(s); err != nil || len(data) == 0 {
+			t.Errorf("page %d: could not decode the contents of the page: %v", k+1, err)
+		}
+	}
+	if pages[0].ref == pages[2].ref || pages[0].dict["Contents"] == pages[2].dict["Contents"] {
+		t.Errorf("expected the appended pages to be copied")
+	}
+}
+
+func TestPageCount(t *testing.T) {
+	count, err := PageCount(objectStreamPDF())
+	if err != nil || count != 1 {
+		t.Errorf("got %d pages, %v, expected 1 page", count, err)
+	}
+
+	_, err = PageCount([]byte("not a pdf"))
+	if err == nil {
+		t.Errorf("expected an error")
+	}
+}
@@ -0,0 +1,319 @@
+package pdfa
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// object is a PDF object. Only the parts needed to find and rewrite objects are parsed,
+// numbers, booleans, null and strings are kept as they are written in the file.
+type object interface{}
+
+// name is a PDF name, without the leading slash
+type name string
+
+// token is a number, a boolean or null
+type token string
+
+// rawString is a literal or hexadecimal string, including its delimiters
+type rawString string
+
+// ref is a reference to an indirect object
+type ref struct {
+	num int
+	gen int
+}
+
+type array []object
+
+type dict map[name]object
+
+// stream is a stream object, with its data as it is stored in the file
+type stream struct {
+	dict dict
+	data []byte
+}
+
+// writeObject writes obj in PDF syntax to buf
+func writeObject(buf *bytes.Buffer, obj object) {
+	switch o := obj.(type) {
+	case nil:
+		buf.WriteString("null")
+	case name:
+		buf.WriteString("/" + string(o))
+	case token:
+		buf.WriteString(string(o))
+	case rawString:
+		buf.WriteString(string(o))
+	case ref:
+		fmt.Fprintf(buf, "%d %d R", o.num, o.gen)
+	case array:
+		buf.WriteString("[")
+		for k, v := range o {
+			if k > 0 {
+				buf.WriteString(" ")
+			}
+			writeObject(buf, v)
+		}
+		buf.WriteString("]")
+	case dict:
+		keys := make([]string, 0, len(o))
+		for k := range o {
+			keys = append(keys, string(k))
+		}
+		sort.Strings(keys)
+
+		buf.WriteString("<<")
+		for _, k := range keys {
+			buf.WriteString("/" + k + " ")
+			writeObject(buf, o[name(k)])
+			buf.WriteString("\n")
+		}
+		buf.WriteString(">>")
+	case *stream:
+		o.dict["Length"] = token(strconv.Itoa(len(o.data)))
+		writeObject(buf, o.dict)
+		buf.WriteString("\nstream\n")
+		buf.Write(o.data)
+		buf.WriteString("\nendstream")
+	default:
+		panic(fmt.Sprintf("pdfa: cannot write object of type %T", obj))
+	}
+}
+
+// literalString returns s as a PDF string. Strings that aren't plain ASCII are written as UTF-16BE.
+func literalString(s string) rawString {
+	ascii := true
+	for _, r := range s {
+		if r < 32 || r > 126 {
+			ascii = false
+			break
+		}
+	}
+
+	if !ascii {
+		buf := &bytes.Buffer{}
+		buf.WriteString("<FEFF")
+		for _, r := range s {
+			if r > 0xffff {
+				r1, r2 := (((r-0x10000)>>10)&0x3ff)+0xd800, ((r-0x10000)&0x3ff)+0xdc00
+				fmt.Fprintf(buf, "%04X%04X", r1, r2)
+				continue
+			}
+			fmt.Fprintf(buf, "%04X", r)
+		}
+		buf.WriteString(">")
+		return rawString(buf.String())
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString("(")
+	for _, c := range []byte(s) {
+		if c == '(' || c == ')' || c == '\\' {
+			buf.WriteByte('\\')
+		}
+		buf.WriteByte(c)
+	}
+	buf.WriteString(")")
+	return rawString(buf.String())
+}
+
+// lexer reads PDF objects from data
+type lexer struct {
+	data []byte
+	pos  int
+}
+
+func isWhitespace(c byte) bool {
+	return c == 0 || c == '\t' || c == '\n' || c == '\f' || c == '\r' || c == ' '
+}
+
+func isDelimiter(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+// skipSpace skips whitespace and comments
+func (l *lexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+			continue
+		}
+		if !isWhitespace(c) {
+			return
+		}
+		l.pos++
+	}
+}
+
+// keyword reads a regular token, such as a number or a keyword
+func (l *lexer) keyword() string {
+	l.skipSpace()
+	start := l.pos
+	for l.pos < len(l.data) && !isWhitespace(l.data[l.pos]) && !isDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	return string(l.data[start:l.pos])
+}
+
+// expect reads the keyword kw
+func (l *lexer) expect(kw string) error {
+	pos := l.pos
+	if k := l.keyword(); k != kw {
+		return fmt.Errorf("pdfa: expected %q at offset %d, got %q", kw, pos, k)
+	}
+	return nil
+}
+
+// int reads an integer
+func (l *lexer) int() (int, error) {
+	pos := l.pos
+	k := l.keyword()
+	n, err := strconv.Atoi(k)
+	if err != nil {
+		return 0, fmt.Errorf("pdfa: expected integer at offset %d, got %q", pos, k)
+	}
+	return n, nil
+}
+
+// object reads the next object. References (<num> <gen> R) are returned as ref.
+func (l *lexer) object() (object, error) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, fmt.Errorf("pdfa: unexpected end of data")
+	}
+
+	start := l.pos
+	switch c := l.data[l.pos]; {
+	case c == '/':
+		l.pos++
+		for l.pos < len(l.data) && !isWhitespace(l.data[l.pos]) && !isDelimiter(l.data[l.pos]) {
+			l.pos++
+		}
+		return name(l.data[start+1 : l.pos]), nil
+	case c == '(':
+		depth := 0
+		for l.pos < len(l.data) {
+			switch l.data[l.pos] {
+			case '\\':
+				l.pos++
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+			l.pos++
+			if depth == 0 {
+				return rawString(l.data[start:l.pos]), nil
+			}
+		}
+		return nil, fmt.Errorf("pdfa: unterminated string at offset %d", start)
+	case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		l.pos += 2
+		d := dict{}
+		for {
+			l.skipSpace()
+			if bytes.HasPrefix(l.data[l.pos:], []byte(">>")) {
+				l.pos += 2
+				return d, nil
+			}
+			key, err := l.object()
+			if err != nil {
+				return nil, err
+			}
+			k, ok := key.(name)
+			if !ok {
+				return nil, fmt.Errorf("pdfa: expected name as dictionary key at offset %d", l.pos)
+			}
+			v, err := l.object()
+			if err != nil {
+				return nil, err
+			}
+			d[k] = v
+		}
+	case c == '<':
+		end := bytes.IndexByte(l.data[l.pos:], '>')
+		if end < 0 {
+			return nil, fmt.Errorf("pdfa: unterminated string at offset %d", start)
+		}
+		l.pos += end + 1
+		return rawString(l.data[start:l.pos]), nil
+	case c == '[':
+		l.pos++
+		a := array{}
+		for {
+			l.skipSpace()
+			if l.pos < len(l.data) && l.data[l.pos] == ']' {
+				l.pos++
+				return a, nil
+			}
+			v, err := l.object()
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+		}
+	case isDelimiter(c):
+		return nil, fmt.Errorf("pdfa: unexpected %q at offset %d", c, start)
+	}
+
+	k := l.keyword()
+	if k == "null" {
+		return nil, nil
+	}
+
+	// Check if the number is the start of a reference
+	if num, err := strconv.Atoi(k); err == nil {
+		pos := l.pos
+		if gen, err := l.int(); err == nil && l.keyword() == "R" {
+			return ref{num: num, gen: gen}, nil
+		}
+		l.pos = pos
+	}
+	return token(k), nil
+}
+
+// streamData reads the data of a stream that starts at the current position, directly after the dictionary.
+// Returns nil if the object isn't a stream.
+func (l *lexer) streamData(length int) ([]byte, error) {
+	l.skipSpace()
+	if !bytes.HasPrefix(l.data[l.pos:], []byte("stream")) {
+		return nil, nil
+	}
+	l.pos += len("stream")
+
+	// The keyword is followed by CRLF or LF
+	if l.pos < len(l.data) && l.data[l.pos] == '\r' {
+		l.pos++
+	}
+	if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+		l.pos++
+	}
+
+	start := l.pos
+	if length >= 0 && start+length <= len(l.data) {
+		l.pos = start + length
+		if l.expect("endstream") == nil {
+			return l.data[start : start+length], nil
+		}
+	}
+
+	// Some files have the wrong length in the stream dictionary, use the position of 'endstream' instead
+	end := bytes.Index(l.data[start:], []byte("endstream"))
+	if end < 0 {
+		return nil, fmt.Errorf("pdfa: unterminated stream at offset %d", start)
+	}
+	l.pos = start + end + len("endstream")
+
+	data := bytes.TrimSuffix(l.data[start:start+end], []byte("\n"))
+	return bytes.TrimSuffix(data, []byte("\r")), nil
+}
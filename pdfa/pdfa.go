@@ -0,0 +1,343 @@
+// Package pdfa converts PDF documents to PDF/A-3b, with embedded files, and merges PDF documents.
+//
+// The document is rewritten with an sRGB output intent, XMP metadata and the embedded files
+// associated with the document. The content of the pages is not changed, so all fonts must
+// already be embedded for the result to conform to PDF/A.
+package pdfa
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Relationships between an embedded file and the document
+const (
+	RelationshipSource      = "Source"
+	RelationshipData        = "Data"
+	RelationshipAlternative = "Alternative"
+	RelationshipSupplement  = "Supplement"
+	RelationshipUnspecified = "Unspecified"
+)
+
+// Attachment is a file that is embedded in the document
+type Attachment struct {
+	Name         string
+	Description  string
+	MIMEType     string
+	Relationship string
+	Data         []byte
+}
+
+// Options describes the metadata of the converted document
+type Options struct {
+	Title    string
+	Author   string
+	Producer string
+	Date     time.Time
+
+	Attachments []Attachment
+
+	// XMP is added to the rdf:RDF element of the metadata, e.g. the properties of an extension schema
+	XMP string
+}
+
+// pdfDate formats t as a PDF date string
+func pdfDate(t time.Time) rawString {
+	_, offset := t.Zone()
+	sign := '+'
+	if offset < 0 {
+		sign = '-'
+		offset = -offset
+	}
+	return rawString(fmt.Sprintf("(D:%s%c%02d'%02d')", t.Format("20060102150405"), sign, offset/3600, offset/60%60))
+}
+
+// compress returns a stream with data compressed with FlateDecode
+func compress(d dict, data []byte) *stream {
+	buf := &bytes.Buffer{}
+	w := zlib.NewWriter(buf)
+	w.Write(data)
+	w.Close()
+
+	d["Filter"] = name("FlateDecode")
+	return &stream{dict: d, data: buf.Bytes()}
+}
+
+// xmlEscape escapes s for use as XML text
+func xmlEscape(s string) string {
+	buf := &bytes.Buffer{}
+	xml.EscapeText(buf, []byte(s))
+	return buf.String()
+}
+
+// metadata returns the XMP metadata of the document
+func metadata(opts Options) []byte {
+	date := opts.Date.Format(time.RFC3339)
+	buf := &bytes.Buffer{}
+	buf.WriteString("<?xpacket begin=\"\xef\xbb\xbf\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	buf.WriteString(`<x:xmpmeta xmlns:x="adobe:ns:meta/">
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description rdf:about="" xmlns:pdfaid="http://www.aiim.org/pdfa/ns/id/">
+<pdfaid:part>3</pdfaid:part>
+<pdfaid:conformance>B</pdfaid:conformance>
+</rdf:Description>
+<rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<dc:format>application/pdf</dc:format>
+`)
+	fmt.Fprintf(buf, "<dc:title><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></dc:title>\n", xmlEscape(opts.Title))
+	fmt.Fprintf(buf, "<dc:creator><rdf:Seq><rdf:li>%s</rdf:li></rdf:Seq></dc:creator>\n", xmlEscape(opts.Author))
+	buf.WriteString(`</rdf:Description>
+<rdf:Description rdf:about="" xmlns:xmp="http://ns.adobe.com/xap/1.0/">
+`)
+	fmt.Fprintf(buf, "<xmp:CreateDate>%s</xmp:CreateDate>\n<xmp:ModifyDate>%s</xmp:ModifyDate>\n", date, date)
+	fmt.Fprintf(buf, "<xmp:CreatorTool>%s</xmp:CreatorTool>\n", xmlEscape(opts.Producer))
+	buf.WriteString(`</rdf:Description>
+<rdf:Description rdf:about="" xmlns:pdf="http://ns.adobe.com/pdf/1.3/">
+`)
+	fmt.Fprintf(buf, "<pdf:Producer>%s</pdf:Producer>\n", xmlEscape(opts.Producer))
+	buf.WriteString("</rdf:Description>\n")
+	buf.WriteString(opts.XMP)
+	buf.WriteString("\n</rdf:RDF>\n</x:xmpmeta>\n<?xpacket end=\"w\"?>")
+	return buf.Bytes()
+}
+
+// Convert rewrites the PDF document data as PDF/A-3b, with the attachments embedded
+func Convert(data []byte, opts Options) ([]byte, error) {
+	doc, err := readDocument(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Date.IsZero() {
+		opts.Date = time.Now()
+	}
+
+	rootRef, ok := doc.trailer["Root"].(ref)
+	if !ok {
+		return nil, fmt.Errorf("pdfa: document catalog not found")
+	}
+
+	objects, gens, next, err := loadObjects(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	root, ok := objects[rootRef.num].(dict)
+	if !ok {
+		return nil, fmt.Errorf("pdfa: document catalog not found")
+	}
+
+	add := func(obj object) ref {
+		r := ref{num: next}
+		objects[next] = obj
+		next++
+		return r
+	}
+
+	root["Metadata"] = add(&stream{
+		dict: dict{"Type": name("Metadata"), "Subtype": name("XML")},
+		data: metadata(opts),
+	})
+
+	icc := add(compress(dict{"N": token("3")}, srgbProfile()))
+	root["OutputIntents"] = array{dict{
+		"Type":                      name("OutputIntent"),
+		"S":                         name("GTS_PDFA1"),
+		"OutputConditionIdentifier": literalString("sRGB"),
+		"Info":                      literalString("sRGB IEC61966-2.1"),
+		"DestOutputProfile":         icc,
+	}}
+	delete(root, "Version")
+
+	// Embed the attachments, and list them in the name tree of embedded files
+	var files array
+	names := map[string]ref{}
+	for _, a := range opts.Attachments {
+		sum := md5.Sum(a.Data)
+		ef := add(compress(dict{
+			"Type":    name("EmbeddedFile"),
+			"Subtype": name(mimeName(a.MIMEType)),
+			"Params": dict{
+				"Size":     token(strconv.Itoa(len(a.Data))),
+				"ModDate":  pdfDate(opts.Date),
+				"CheckSum": rawString("<" + hex.EncodeToString(sum[:]) + ">"),
+			},
+		}, a.Data))
+
+		relationship := a.Relationship
+		if relationship == "" {
+			relationship = RelationshipUnspecified
+		}
+		spec := add(dict{
+			"Type":           name("Filespec"),
+			"F":              literalString(a.Name),
+			"UF":             literalString(a.Name),
+			"Desc":           literalString(a.Description),
+			"AFRelationship": name(relationship),
+			"EF":             dict{"F": ef, "UF": ef},
+		})
+		files = append(files, spec)
+		names[a.Name] = spec
+	}
+
+	if len(files) > 0 {
+		if af, ok := root["AF"].(array); ok {
+			files = append(af, files...)
+		}
+		root["AF"] = files
+
+		err = addEmbeddedFiles(objects, root, names)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return write(objects, gens, next, rootRef, data), nil
+}
+
+// loadObjects reads all objects of the document, except the ones that only describe the structure of the file.
+// The returned number is the first unused object number.
+func loadObjects(doc *document) (objects map[int]object, gens map[int]int, next int, err error) {
+	objects = map[int]object{}
+	gens = map[int]int{}
+	next = doc.int(doc.trailer["Size"])
+	for num, e := range doc.xref {
+		if num >= next {
+			next = num + 1
+		}
+
+		obj, err := doc.object(num)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		if obj == nil {
+			continue
+		}
+		if s, ok := obj.(*stream); ok && (s.dict["Type"] == name("XRef") || s.dict["Type"] == name("ObjStm")) {
+			continue
+		}
+		objects[num] = obj
+		gens[num] = e.gen
+	}
+	return objects, gens, next, nil
+}
+
+// mimeName returns a MIME type as a PDF name, e.g. text#2Fxml
+func mimeName(mimeType string) string {
+	buf := &bytes.Buffer{}
+	for _, c := range []byte(mimeType) {
+		if c <= 32 || c > 126 || c == '#' || isDelimiter(c) {
+			fmt.Fprintf(buf, "#%02X", c)
+			continue
+		}
+		buf.WriteByte(c)
+	}
+	return buf.String()
+}
+
+// addEmbeddedFiles adds the files to the name tree of embedded files in the catalog.
+// Existing embedded files are kept, if they are listed directly in the root of the tree.
+func addEmbeddedFiles(objects map[int]object, root dict, files map[string]ref) error {
+	namesDict := dict{}
+	switch n := root["Names"].(type) {
+	case dict:
+		namesDict = n
+	case ref:
+		if d, ok := objects[n.num].(dict); ok {
+			namesDict = d
+		}
+	}
+	if _, ok := root["Names"].(ref); !ok {
+		root["Names"] = namesDict
+	}
+
+	var existing array
+	switch ef := namesDict["EmbeddedFiles"].(type) {
+	case dict:
+		existing, _ = ef["Names"].(array)
+		if _, ok := ef["Kids"]; ok {
+			return fmt.Errorf("pdfa: documents with a tree of embedded files are not supported")
+		}
+	case ref:
+		d, _ := objects[ef.num].(dict)
+		existing, _ = d["Names"].(array)
+		if _, ok := d["Kids"]; ok {
+			return fmt.Errorf("pdfa: documents with a tree of embedded files are not supported")
+		}
+	}
+
+	// The names must be sorted
+	type entry struct {
+		key   string
+		value object
+	}
+	var entries []entry
+	for k := 0; k+1 < len(existing); k += 2 {
+		if s, ok := existing[k].(rawString); ok {
+			entries = append(entries, entry{key: string(s), value: existing[k+1]})
+		}
+	}
+	for n, r := range files {
+		entries = append(entries, entry{key: string(literalString(n)), value: r})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key < entries[j].key
+	})
+
+	var tree array
+	for _, e := range entries {
+		tree = append(tree, rawString(e.key), e.value)
+	}
+	namesDict["EmbeddedFiles"] = dict{"Names": tree}
+	return nil
+}
+
+// write writes the objects as a new PDF file, with a cross-reference table.
+// The identifier of the document is based on the original data.
+func write(objects map[int]object, gens map[int]int, size int, root ref, original []byte) []byte {
+	buf := &bytes.Buffer{}
+
+	// The header is followed by a comment with binary characters, so that the file is treated as binary
+	buf.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+
+	offsets := make([]int, size)
+	for num := 1; num < size; num++ {
+		obj, ok := objects[num]
+		if !ok {
+			continue
+		}
+		offsets[num] = buf.Len()
+		fmt.Fprintf(buf, "%d %d obj\n", num, gens[num])
+		writeObject(buf, obj)
+		buf.WriteString("\nendobj\n")
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(buf, "xref\n0 %d\n", size)
+	buf.WriteString("0000000000 65535 f\r\n")
+	for num := 1; num < size; num++ {
+		if _, ok := objects[num]; !ok {
+			buf.WriteString("0000000000 00000 f\r\n")
+			continue
+		}
+		fmt.Fprintf(buf, "%010d %05d n\r\n", offsets[num], gens[num])
+	}
+
+	sum := md5.Sum(original)
+	id := rawString("<" + hex.EncodeToString(sum[:]) + ">")
+	buf.WriteString("trailer\n")
+	writeObject(buf, dict{
+		"Size": token(strconv.Itoa(size)),
+		"Root": root,
+		"ID":   array{id, id},
+	})
+	fmt.Fprintf(buf, "\nstartxref\n%d\n%%%%EOF\n", xref)
+	return buf.Bytes()
+}
@@ -0,0 +1,194 @@
+package pdfa
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// objectStreamPDF returns a document where all objects are stored in an object stream,
+// with a compressed cross-reference stream using the PNG up predictor
+func objectStreamPDF() []byte {
+	objs := []string{
+		"<</Type/Catalog/Pages 2 0 R/Version/1.5>>",
+		"<</Type/Pages/Kids[3 0 R]/Count 1>>",
+		"<</Type/Page/Parent 2 0 R/MediaBox[0 0 595 842]>>",
+	}
+	header, body := "", ""
+	for k, o := range objs {
+		header += fmt.Sprintf("%d %d ", k+1, len(body))
+		body += o + "\n"
+	}
+
+	zip := func(data []byte) []byte {
+		buf := &bytes.Buffer{}
+		w := zlib.NewWriter(buf)
+		w.Write(data)
+		w.Close()
+		return buf.Bytes()
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString("%PDF-1.5\n")
+	objStm := buf.Len()
+	stm := zip([]byte(header + body))
+	fmt.Fprintf(buf, "4 0 obj\n<</Type/ObjStm/N 3/First %d/Filter/FlateDecode/Length %d>>\nstream\n", len(header), len(stm))
+	buf.Write(stm)
+	buf.WriteString("\nendstream\nendobj\n")
+
+	// Entries are type, offset/object stream and generation/index
+	entries := [][]int{{0, 0, 255}, {2, 4, 0}, {2, 4, 1}, {2, 4, 2}, {1, objStm, 0}, {1, buf.Len(), 0}}
+	var rows, prev []byte
+	for _, e := range entries {
+		row := []byte{byte(e[0]), byte(e[1] >> 8), byte(e[1]), byte(e[2])}
+		rows = append(rows, 2)
+		for k := range row {
+			var up byte
+			if prev != nil {
+				up = prev[k]
+			}
+			rows = append(rows, row[k]-up)
+		}
+		prev = row
+	}
+	xref := buf.Len()
+	data := zip(rows)
+	fmt.Fprintf(buf, "5 0 obj\n<</Type/XRef/Size 6/W[1 2 1]/Root 1 0 R/Filter/FlateDecode/DecodeParms<</Columns 4/Predictor 12>>/Length %d>>\nstream\n", len(data))
+	buf.Write(data)
+	fmt.Fprintf(buf, "\nendstream\nendobj\nstartxref\n%d\n%%%%EOF\n", xref)
+	return buf.Bytes()
+}
+
+func gofpdfPDF(t *testing.T) []byte {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.Cell(40, 10, "Faktura")
+	buf := &bytes.Buffer{}
+	if err := pdf.Output(buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestConvert(t *testing.T) {
+	xmlData := []byte("<?xml version=\"1.0\"?><Invoice/>")
+	opts := Options{
+		Title:    "Faktura 1001",
+		Author:   "Smith & Söner",
+		Producer: "FakturaPDF",
+		XMP:      `<rdf:Description rdf:about="" xmlns:fx="urn:factur-x:pdfa:CrossIndustryDocument:invoice:1p0#"/>`,
+		Attachments: []Attachment{{
+			Name:         "factur-x.xml",
+			MIMEType:     "text/xml",
+			Relationship: RelationshipAlternative,
+			Data:         xmlData,
+		}},
+	}
+
+	tests := map[string][]byte{
+		"xref table":  gofpdfPDF(t),
+		"xref stream": objectStreamPDF(),
+	}
+	for desc, input := range tests {
+		out, err := Convert(input, opts)
+		if err != nil {
+			t.Fatalf("%s: %v", desc, err)
+		}
+		if !bytes.HasPrefix(out, []byte("%PDF-1.7\n%\xe2")) {
+			t.Errorf("%s: expected a PDF 1.7 header followed by a binary comment", desc)
+		}
+
+		doc, err := readDocument(out)
+		if err != nil {
+			t.Fatalf("%s: could not read converted document: %v", desc, err)
+		}
+		if _, ok := doc.trailer["ID"].(array); !ok {
+			t.Errorf("%s: expected the document to have an identifier", desc)
+		}
+
+		root, _ := doc.trailer["Root"].(ref)
+		catalog, _ := doc.object(root.num)
+		c, ok := catalog.(dict)
+		if !ok || c["Type"] != name("Catalog") {
+			t.Fatalf("%s: catalog not found", desc)
+		}
+		if _, ok := c["Pages"].(ref); !ok {
+			t.Errorf("%s: expected the catalog to refer to the pages", desc)
+		}
+		if _, ok := c["Version"]; ok {
+			t.Errorf("%s: expected the version of the catalog to be removed", desc)
+		}
+
+		metaRef, _ := c["Metadata"].(ref)
+		meta, _ := doc.object(metaRef.num)
+		if s, ok := meta.(*stream); !ok || !strings.Contains(string(s.data), "<pdfaid:part>3</pdfaid:part>") ||
+			!strings.Contains(string(s.data), "Smith &amp; Söner") || !strings.Contains(string(s.data), "xmlns:fx=") {
+			t.Errorf("%s: expected XMP metadata", desc)
+		}
+
+		intents, _ := c["OutputIntents"].(array)
+		if len(intents) != 1 {
+			t.Fatalf("%s: expected an output intent", desc)
+		}
+		iccRef, _ := intents[0].(dict)["DestOutputProfile"].(ref)
+		icc, _ := doc.object(iccRef.num)
+		profile, err := doc.decode(icc.(*stream))
+		if err != nil || string(profile[36:40]) != "acsp" || int(profile[3])|int(profile[2])<<8 != len(profile) {
+			t.Errorf("%s: expected an ICC profile", desc)
+		}
+
+		af, _ := c["AF"].(array)
+		if len(af) != 1 {
+			t.Fatalf("%s: expected one associated file, got %v", desc, c["AF"])
+		}
+		specRef := af[0].(ref)
+		spec, _ := doc.object(specRef.num)
+		if spec.(dict)["AFRelationship"] != name("Alternative") {
+			t.Errorf("%s: expected the relationship to be Alternative", desc)
+		}
+		efRef := spec.(dict)["EF"].(dict)["F"].(ref)
+		ef, _ := doc.object(efRef.num)
+		if ef.(*stream).dict["Subtype"] != name("text#2Fxml") {
+			t.Errorf("%s: got subtype %v", desc, ef.(*stream).dict["Subtype"])
+		}
+		data, err := doc.decode(ef.(*stream))
+		if err != nil || !bytes.Equal(data, xmlData) {
+			t.Errorf("%s: expected the embedded file to contain the attachment", desc)
+		}
+
+		names := c["Names"].(dict)["EmbeddedFiles"].(dict)["Names"].(array)
+		if len(names) != 2 || names[0] != rawString("(factur-x.xml)") || names[1] != specRef {
+			t.Errorf("%s: got embedded files %v", desc, names)
+		}
+	}
+}
+
+func TestConvertInvalid(t *testing.T) {
+	_, err := Convert([]byte("not a pdf"), Options{})
+	if err == nil {
+		t.Errorf("expected an error")
+	}
+
+	_, err = Convert(bytes.Replace(gofpdfPDF(t), []byte("trailer\n<<"), []byte("trailer\n<</Encrypt 1 0 R"), 1), Options{})
+	if err == nil || !strings.Contains(err.Error(), "encrypted") {
+		t.Errorf("expected encrypted documents to be rejected, got %v", err)
+	}
+}
+
+func TestPNGUnpredict(t *testing.T) {
+	// Rows of 2 bytes using the None, Sub, Up, Average and Paeth predictors
+	data := []byte{0, 1, 2, 1, 1, 1, 2, 1, 1, 3, 1, 1, 4, 1, 1}
+	out, err := pngUnpredict(data, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte{1, 2, 1, 2, 2, 3, 2, 3, 3, 4}
+	if !bytes.Equal(out, expected) {
+		t.Errorf("got %v, expected %v", out, expected)
+	}
+}
@@ -0,0 +1,62 @@
+// Package rates contains the VAT and ROT/RUT rates set by Swedish legislation, with the periods they are in force.
+package rates
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Rate is a rate in percent, in force from DateFrom until the date of the next rate in the same table
+type Rate struct {
+	DateFrom time.Time
+	Rate     decimal.Decimal // Percent
+}
+
+// Table is a list of rates, sorted by the date they come into force
+type Table []Rate
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// At returns the rate in percent that is in force at date t.
+// Only the date of t is used, so that the time zone of t does not change which rate is used.
+func (tbl Table) At(t time.Time) decimal.Decimal {
+	day := date(t.Year(), t.Month(), t.Day())
+	rate := decimal.Zero
+	for _, r := range tbl {
+		if r.DateFrom.After(day) {
+			break
+		}
+		rate = r.Rate
+	}
+	return rate
+}
+
+// Fraction returns the rate in force at date t as a fraction, e.g. 0.25 for 25 %
+func (tbl Table) Fraction(t time.Time) decimal.Decimal {
+	return tbl.At(t).Div(decimal.NewFromInt(100))
+}
+
+// VAT rates (mervärdesskattelagen 9 kap.)
+var (
+	VAT25 = Table{{DateFrom: time.Time{}, Rate: decimal.NewFromInt(25)}}
+	VAT12 = Table{{DateFrom: time.Time{}, Rate: decimal.NewFromInt(12)}}
+	VAT6  = Table{{DateFrom: time.Time{}, Rate: decimal.NewFromInt(6)}}
+	VAT0  = Table{{DateFrom: time.Time{}, Rate: decimal.Zero}}
+)
+
+// ROT is the part of the labour cost that is deducted for ROT work.
+// It was temporarily raised to 50 % for invoices dated between 2025-05-12 and 2025-12-31.
+var ROT = Table{
+	{DateFrom: time.Time{}, Rate: decimal.NewFromInt(50)},
+	{DateFrom: date(2016, time.January, 1), Rate: decimal.NewFromInt(30)},
+	{DateFrom: date(2025, time.May, 12), Rate: decimal.NewFromInt(50)},
+	{DateFrom: date(2026, time.January, 1), Rate: decimal.NewFromInt(30)},
+}
+
+// RUT is the part of the labour cost that is deducted for RUT work
+var RUT = Table{
+	{DateFrom: time.Time{}, Rate: decimal.NewFromInt(50)},
+}
@@ -0,0 +1,32 @@
+package rates
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestTableAt(t *testing.T) {
+	tests := []struct {
+		date time.Time
+		rate int64
+	}{
+		{time.Date(2015, time.December, 31, 0, 0, 0, 0, time.Local), 50},
+		{time.Date(2024, time.June, 1, 0, 0, 0, 0, time.Local), 30},
+		{time.Date(2025, time.May, 11, 23, 59, 0, 0, time.Local), 30},
+		{time.Date(2025, time.May, 12, 0, 0, 0, 0, time.Local), 50},
+		{time.Date(2025, time.December, 31, 0, 0, 0, 0, time.Local), 50},
+		{time.Date(2026, time.January, 1, 0, 0, 0, 0, time.Local), 30},
+	}
+
+	for _, tt := range tests {
+		if r := ROT.At(tt.date); !r.Equal(decimal.NewFromInt(tt.rate)) {
+			t.Errorf("ROT at %s: expected %d %%, got %s %%", tt.date.Format("2006-01-02"), tt.rate, r)
+		}
+	}
+
+	if r := VAT6.Fraction(time.Now()); !r.Equal(decimal.RequireFromString("0.06")) {
+		t.Errorf("expected VAT 6 %% to be 0.06, got %s", r)
+	}
+}
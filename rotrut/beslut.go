@@ -0,0 +1,87 @@
+package rotrut
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// BeslutArende is Skatteverket's decision for one case in a request
+type BeslutArende struct {
+	Kopare            PeOrgNrTYPE   `xml:"Kopare"`
+	FakturaNr         FakturaNrTYPE `xml:"FakturaNr"`
+	BegartBelopp      BeloppTYPE    `xml:"BegartBelopp"`
+	GodkantBelopp     BeloppTYPE    `xml:"GodkantBelopp"`
+	Beslutsdatum      DatumTYPE     `xml:"Beslutsdatum"`
+	Utbetalningsdatum DatumTYPE     `xml:"Utbetalningsdatum"`
+}
+
+// Date returns the date the approved amount was paid out, or the date of the decision
+// if no payout date is given
+func (a BeslutArende) Date() (time.Time, error) {
+	date := a.Utbetalningsdatum
+	if date == "" {
+		date = a.Beslutsdatum
+	}
+	return time.ParseInLocation("2006-01-02", string(date), time.Local)
+}
+
+// charsetReader handles the encodings used in files from Skatteverket, in addition to UTF-8
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "latin1":
+		return charmap.ISO8859_1.NewDecoder().Reader(input), nil
+	case "windows-1252", "cp1252":
+		return charmap.Windows1252.NewDecoder().Reader(input), nil
+	}
+	return nil, fmt.Errorf("unsupported charset %s", charset)
+}
+
+// ParseBeslut reads the decisions in a decision file from Skatteverket.
+// Every element named 'Arende' or 'Arenden' is read as the decision of a case.
+// Elements are matched by name only, since the namespace differs between versions of the file.
+func ParseBeslut(r io.Reader) ([]BeslutArende, error) {
+	var arenden []BeslutArende
+
+	d := xml.NewDecoder(r)
+	d.CharsetReader = charsetReader
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		start, ok := t.(xml.StartElement)
+		if !ok || (start.Name.Local != "Arende" && start.Name.Local != "Arenden") {
+			continue
+		}
+
+		var a BeslutArende
+		err = d.DecodeElement(&a, &start)
+		if err != nil {
+			return nil, err
+		}
+
+		if a.FakturaNr == "" || a.Kopare == "" {
+			return nil, fmt.Errorf("ärende %d saknar fakturanummer eller köpare", len(arenden)+1)
+		}
+
+		if _, err = a.Date(); err != nil {
+			return nil, fmt.Errorf("ärende %d (faktura %s) saknar beslutsdatum", len(arenden)+1, a.FakturaNr)
+		}
+		arenden = append(arenden, a)
+	}
+
+	if len(arenden) == 0 {
+		return nil, errors.New("filen innehåller inga ärenden")
+	}
+	return arenden, nil
+}
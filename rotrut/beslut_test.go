@@ -0,0 +1,76 @@
+package rotrut
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+const testBeslut = `<?xml version="1.0" encoding="ISO-8859-1"?>
+<Beslut xmlns="http://xmls.skatteverket.se/se/skatteverket/ht/beslut/6.0">
+  <NamnPaBegaran>RUT-241017-1</NamnPaBegaran>
+  <Arenden>
+    <Kopare>198001011231</Kopare>
+    <FakturaNr>1001</FakturaNr>
+    <BegartBelopp>2000</BegartBelopp>
+    <GodkantBelopp>2000</GodkantBelopp>
+    <Beslutsdatum>2024-10-20</Beslutsdatum>
+    <Utbetalningsdatum>2024-10-22</Utbetalningsdatum>
+    <Kommentar>Godkänd</Kommentar>
+  </Arenden>
+  <Arenden>
+    <Kopare>198112189876</Kopare>
+    <FakturaNr>1002</FakturaNr>
+    <BegartBelopp>1500</BegartBelopp>
+    <GodkantBelopp>0</GodkantBelopp>
+    <Beslutsdatum>2024-10-20</Beslutsdatum>
+  </Arenden>
+</Beslut>`
+
+func TestParseBeslut(t *testing.T) {
+	contents, err := charmap.ISO8859_1.NewEncoder().String(testBeslut)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	arenden, err := ParseBeslut(strings.NewReader(contents))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(arenden) != 2 {
+		t.Fatalf("got %d cases, expected 2", len(arenden))
+	}
+
+	a := arenden[0]
+	if a.Kopare != "198001011231" || a.FakturaNr != "1001" || a.BegartBelopp != 2000 || a.GodkantBelopp != 2000 {
+		t.Errorf("unexpected case %+v", a)
+	}
+
+	date, err := a.Date()
+	if err != nil || !date.Equal(time.Date(2024, time.October, 22, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("expected payout date to be used, got %s", date)
+	}
+
+	date, err = arenden[1].Date()
+	if err != nil || !date.Equal(time.Date(2024, time.October, 20, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("expected decision date to be used, got %s", date)
+	}
+}
+
+func TestParseBeslutErrors(t *testing.T) {
+	tests := map[string]string{
+		"empty":        `<Beslut></Beslut>`,
+		"invalid xml":  `<Beslut><Arenden>`,
+		"missing date": `<Beslut><Arenden><Kopare>198001011231</Kopare><FakturaNr>1001</FakturaNr></Arenden></Beslut>`,
+		"missing case": `<Beslut><Arenden><Beslutsdatum>2024-10-20</Beslutsdatum></Arenden></Beslut>`,
+	}
+
+	for name, contents := range tests {
+		if _, err := ParseBeslut(strings.NewReader(contents)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
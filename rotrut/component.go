@@ -45,10 +45,6 @@ type ArendeUtfortArbeteRutTYPE struct {
 type RotArendeTYPE struct {
 	XMLName xml.Name
 
-	Fastighetsbeteckning *FastighetsbeteckningTYPE  `xml:"Fastighetsbeteckning"`
-	LagenhetsNr          *LagenhetsNrTYPE           `xml:"LagenhetsNr"`
-	BrfOrgNr             *BrfOrgNrTYPE              `xml:"BrfOrgNr"`
-	UtfortArbete         *ArendeUtfortArbeteRotTYPE `xml:"UtfortArbete"`
 	Kopare               PeOrgNrTYPE                `xml:"Kopare"`
 	BetalningsDatum      DatumTYPE                  `xml:"BetalningsDatum"`
 	PrisForArbete        string                     `xml:"PrisForArbete"`
@@ -56,6 +52,10 @@ type RotArendeTYPE struct {
 	BegartBelopp         BeloppTYPE                 `xml:"BegartBelopp"`
 	FakturaNr            *FakturaNrTYPE             `xml:"FakturaNr"`
 	Ovrigkostnad         *OvrigKostnadTYPE          `xml:"Ovrigkostnad"`
+	Fastighetsbeteckning *FastighetsbeteckningTYPE  `xml:"Fastighetsbeteckning"`
+	LagenhetsNr          *LagenhetsNrTYPE           `xml:"LagenhetsNr"`
+	BrfOrgNr             *BrfOrgNrTYPE              `xml:"BrfOrgNr"`
+	UtfortArbete         *ArendeUtfortArbeteRotTYPE `xml:"UtfortArbete"`
 
 	InnerXml string `xml:",innerxml"`
 }
@@ -92,9 +92,14 @@ type RotBegaranTYPE struct {
 
 	Arenden []RotArendeTYPE `xml:",any"`
 
+	XMLNs    string `xml:"xmlns,attr"`
 	InnerXml string `xml:",innerxml"`
 }
 
+func (t *RotBegaranTYPE) SetXMLNS() {
+	t.XMLNs = "http://xmls.skatteverket.se/se/skatteverket/ht/komponent/begaran/6.0"
+}
+
 type TimmarMaterialTYPE struct {
 	XMLName xml.Name
 
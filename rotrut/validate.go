@@ -0,0 +1,265 @@
+package rotrut
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/shopspring/decimal"
+	"github.com/yzzyx/faktura-pdf/rates"
+)
+
+// MaxArenden is the maximum number of cases that Skatteverket accepts in one request
+const MaxArenden = 100
+
+// ValidationError describes a rule that a request does not follow
+type ValidationError struct {
+	FakturaNr string // Invoice number of the case, if the error concerns a single case
+	Message   string
+}
+
+func (e ValidationError) Error() string {
+	if e.FakturaNr != "" {
+		return fmt.Sprintf("Faktura %s: %s", e.FakturaNr, e.Message)
+	}
+	return e.Message
+}
+
+type validator struct {
+	fakturaNr    string
+	invoiceDates map[string]time.Time // Date of each invoice, by invoice number
+	errors       []error
+}
+
+func (v *validator) check(ok bool, format string, args ...interface{}) {
+	if !ok {
+		v.errors = append(v.errors, ValidationError{FakturaNr: v.fakturaNr, Message: fmt.Sprintf(format, args...)})
+	}
+}
+
+var rePeOrgNr = regexp.MustCompile(`^(19|20)\d{10}$`)
+var reOrgNr = regexp.MustCompile(`^\d{10}$`)
+var reLagenhetsNr = regexp.MustCompile(`^\d{4}$`)
+var reBelopp = regexp.MustCompile(`^\d+$`)
+
+// luhn returns true if the last digit of s is a valid check digit for the other digits
+func luhn(s string) bool {
+	sum := 0
+	for k := 0; k < len(s); k++ {
+		d := int(s[len(s)-1-k] - '0')
+		if k%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// validPNR returns true if pnr is a personal identity number or a coordination number in the format YYYYMMDDXXXX
+func validPNR(pnr string) bool {
+	if !rePeOrgNr.MatchString(pnr) {
+		return false
+	}
+
+	// Coordination numbers have 60 added to the day of birth
+	day, _ := strconv.Atoi(pnr[6:8])
+	if day > 60 {
+		day -= 60
+	}
+
+	date := fmt.Sprintf("%s-%s-%02d", pnr[0:4], pnr[4:6], day)
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		return false
+	}
+
+	return luhn(pnr[2:])
+}
+
+// serviceWork is the hours and material for a named type of service
+type serviceWork struct {
+	name string
+	work *TimmarMaterialTYPE
+}
+
+// arende contains the fields that are common for ROT and RUT cases
+type arende struct {
+	kopare          PeOrgNrTYPE
+	betalningsDatum DatumTYPE
+	prisForArbete   string
+	betaltBelopp    BeloppTYPE
+	begartBelopp    BeloppTYPE
+	ovrigkostnad    OvrigKostnadTYPE
+	utfortArbete    []serviceWork
+	maxShare        rates.Table // Maximum part of the price for the work that can be requested, by invoice date
+}
+
+func (v *validator) checkArende(a arende) {
+	now := time.Now()
+
+	v.check(validPNR(string(a.kopare)), "Köparens personnummer '%s' är felaktigt, det ska anges som ÅÅÅÅMMDDNNNN", a.kopare)
+
+	paid, err := time.Parse("2006-01-02", string(a.betalningsDatum))
+	v.check(a.betalningsDatum != "", "Betalningsdatum saknas")
+	if a.betalningsDatum != "" {
+		v.check(err == nil, "Betalningsdatum '%s' är felaktigt", a.betalningsDatum)
+		v.check(err != nil || !paid.After(now), "Betalningsdatum %s har inte inträffat ännu", a.betalningsDatum)
+	}
+	if err != nil {
+		paid = now
+	}
+
+	price, err := strconv.ParseInt(a.prisForArbete, 10, 64)
+	priceValid := reBelopp.MatchString(a.prisForArbete) && err == nil
+	v.check(priceValid, "Pris för arbete '%s' måste anges i hela kronor", a.prisForArbete)
+	v.check(!priceValid || price > 0, "Pris för arbete måste vara större än noll")
+
+	v.check(a.begartBelopp > 0, "Begärt belopp måste vara större än noll")
+	v.check(a.betaltBelopp >= 0, "Betalt belopp får inte vara negativt")
+	v.check(a.ovrigkostnad >= 0, "Övrig kostnad får inte vara negativ")
+
+	// The deduction rate is decided by the date of the invoice, like the deduction on the invoice itself
+	invoiced, ok := v.invoiceDates[v.fakturaNr]
+	v.check(ok, "Fakturadatum saknas")
+
+	if priceValid && ok {
+		maxShare := a.maxShare.At(invoiced)
+		v.check(decimal.NewFromInt(int64(a.begartBelopp)*100).LessThanOrEqual(maxShare.Mul(decimal.NewFromInt(price))),
+			"Begärt belopp %d kr är mer än %s %% av priset för arbetet (%d kr)", a.begartBelopp, maxShare, price)
+		v.check(int64(a.betaltBelopp)+int64(a.begartBelopp) <= price, "Betalt belopp och begärt belopp är tillsammans mer än priset för arbetet")
+	}
+
+	var hours AntalTimmarTYPE
+	for _, s := range a.utfortArbete {
+		if s.work == nil {
+			continue
+		}
+
+		v.check(s.work.AntalTimmar >= 0, "Antal timmar för %s får inte vara negativt", s.name)
+		v.check(s.work.Materialkostnad >= 0, "Materialkostnad för %s får inte vara negativ", s.name)
+		v.check(s.work.Materialkostnad == 0 || s.work.AntalTimmar > 0, "Materialkostnad är angiven för %s, men inga timmar", s.name)
+		hours += s.work.AntalTimmar
+	}
+	v.check(hours > 0, "Antal timmar måste anges för minst en typ av arbete")
+}
+
+func (v *validator) checkROT(a RotArendeTYPE) {
+	v.fakturaNr = ""
+	if a.FakturaNr != nil {
+		v.fakturaNr = string(*a.FakturaNr)
+	}
+
+	ovrigkostnad := OvrigKostnadTYPE(0)
+	if a.Ovrigkostnad != nil {
+		ovrigkostnad = *a.Ovrigkostnad
+	}
+
+	var ua ArendeUtfortArbeteRotTYPE
+	if a.UtfortArbete != nil {
+		ua = *a.UtfortArbete
+	}
+
+	v.checkArende(arende{
+		kopare:          a.Kopare,
+		betalningsDatum: a.BetalningsDatum,
+		prisForArbete:   a.PrisForArbete,
+		betaltBelopp:    a.BetaltBelopp,
+		begartBelopp:    a.BegartBelopp,
+		ovrigkostnad:    ovrigkostnad,
+		maxShare:        rates.ROT,
+		utfortArbete: []serviceWork{
+			{"bygg", ua.Bygg},
+			{"el", ua.El},
+			{"glas- och plåtarbete", ua.GlasPlatarbete},
+			{"mark- och dräneringsarbete", ua.MarkDraneringarbete},
+			{"murning", ua.Murning},
+			{"målning och tapetsering", ua.MalningTapetsering},
+			{"VVS", ua.Vvs},
+		},
+	})
+
+	// The property is given either as a property designation, or as an apartment in a housing cooperative
+	apartment := a.LagenhetsNr != nil || a.BrfOrgNr != nil
+	v.check(a.Fastighetsbeteckning != nil || apartment, "Fastighetsbeteckning, eller lägenhetsnummer och bostadsrättsföreningens organisationsnummer, saknas")
+	v.check(a.Fastighetsbeteckning == nil || !apartment, "Endast en av fastighetsbeteckning och lägenhet i bostadsrättsförening får anges")
+
+	if a.Fastighetsbeteckning != nil {
+		n := utf8.RuneCountInString(string(*a.Fastighetsbeteckning))
+		v.check(n > 0 && n <= 40, "Fastighetsbeteckningen måste vara mellan 1 och 40 tecken")
+	}
+
+	if apartment {
+		v.check(a.LagenhetsNr != nil && reLagenhetsNr.MatchString(string(*a.LagenhetsNr)), "Lägenhetsnumret måste anges med fyra siffror")
+		v.check(a.BrfOrgNr != nil && reOrgNr.MatchString(string(*a.BrfOrgNr)) && luhn(string(*a.BrfOrgNr)), "Bostadsrättsföreningens organisationsnummer är felaktigt")
+	}
+}
+
+func (v *validator) checkRUT(a HushallArendeTYPE) {
+	v.fakturaNr = string(a.FakturaNr)
+
+	var ua ArendeUtfortArbeteRutTYPE
+	if a.UtfortArbete != nil {
+		ua = *a.UtfortArbete
+	}
+
+	v.checkArende(arende{
+		kopare:          a.Kopare,
+		betalningsDatum: a.BetalningsDatum,
+		prisForArbete:   a.PrisForArbete,
+		betaltBelopp:    a.BetaltBelopp,
+		begartBelopp:    a.BegartBelopp,
+		ovrigkostnad:    a.Ovrigkostnad,
+		maxShare:        rates.RUT,
+		utfortArbete: []serviceWork{
+			{"städning", ua.Stadning},
+			{"kläd- och textilvård", ua.KladOchTextilvard},
+			{"snöskottning", ua.Snoskottning},
+			{"trädgårdsarbete", ua.Tradgardsarbete},
+			{"barnpassning", ua.Barnpassning},
+			{"personlig omsorg", ua.Personligomsorg},
+			{"flyttjänster", ua.Flyttjanster},
+			{"IT-tjänster", ua.ItTjanster},
+			{"reparation av vitvaror", ua.ReparationAvVitvaror},
+			{"möblering", ua.Moblering},
+			{"tillsyn av bostad", ua.TillsynAvBostad},
+		},
+	})
+}
+
+// Validate checks the request against the rules of Skatteverket's schema for requests (version 6.0),
+// and the rules that are checked when Skatteverket receives the request.
+// invoiceDates contains the date of each invoice in the request, by invoice number,
+// which decides how large part of the price for the work that can be requested.
+// A list of all broken rules is returned.
+func (b *Begaran) Validate(invoiceDates map[string]time.Time) []error {
+	v := &validator{invoiceDates: invoiceDates}
+
+	n := utf8.RuneCountInString(b.NamnPaBegaran.Namn)
+	v.check(n > 0 && n <= 16, "Namnet på begäran måste vara mellan 1 och 16 tecken")
+	v.check((b.RotBegaran == nil) != (b.HushallBegaran == nil), "Begäran måste innehålla antingen ROT- eller RUT-ärenden")
+
+	count := 0
+	if b.RotBegaran != nil {
+		count += len(b.RotBegaran.Arenden)
+		for _, a := range b.RotBegaran.Arenden {
+			v.checkROT(a)
+		}
+	}
+
+	if b.HushallBegaran != nil {
+		count += len(b.HushallBegaran.Arenden)
+		for _, a := range b.HushallBegaran.Arenden {
+			v.checkRUT(a)
+		}
+	}
+
+	v.fakturaNr = ""
+	v.check(count > 0, "Begäran innehåller inga ärenden")
+	v.check(count <= MaxArenden, "Begäran innehåller %d ärenden, högst %d är tillåtna", count, MaxArenden)
+
+	return v.errors
+}
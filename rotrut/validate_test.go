@@ -0,0 +1,172 @@
+package rotrut
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func testRUTBegaran() *Begaran {
+	b := NewBegaran("RUT-1001")
+	b.HushallBegaran = &HushallBegaranTYPE{
+		Arenden: []HushallArendeTYPE{
+			{
+				Kopare:          "198001011231",
+				BetalningsDatum: "2024-03-01",
+				PrisForArbete:   "4000",
+				BetaltBelopp:    2000,
+				BegartBelopp:    2000,
+				FakturaNr:       "1001",
+				UtfortArbete: &ArendeUtfortArbeteRutTYPE{
+					Stadning: &TimmarMaterialTYPE{AntalTimmar: 8},
+				},
+			},
+		},
+	}
+	return b
+}
+
+func invoiceDates(fakturaNr string, year int, month time.Month, day int) map[string]time.Time {
+	return map[string]time.Time{fakturaNr: time.Date(year, month, day, 0, 0, 0, 0, time.Local)}
+}
+
+func TestValidPNR(t *testing.T) {
+	tests := map[string]bool{
+		"198001011231":  true,
+		"198001611238":  true, // Coordination number
+		"198001011234":  false,
+		"8001011231":    false,
+		"198013011231":  false,
+		"19800101-1231": false,
+	}
+
+	for pnr, expected := range tests {
+		if validPNR(pnr) != expected {
+			t.Errorf("validPNR(%s) should be %t", pnr, expected)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if errs := testRUTBegaran().Validate(invoiceDates("1001", 2024, time.February, 1)); len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	b := testRUTBegaran()
+	a := &b.HushallBegaran.Arenden[0]
+	a.Kopare = "800101-1231"
+	a.BetalningsDatum = ""
+	a.PrisForArbete = "4000.50"
+	a.BegartBelopp = 2500
+	a.UtfortArbete.Stadning = &TimmarMaterialTYPE{Materialkostnad: 100}
+
+	errs := b.Validate(invoiceDates("1001", 2024, time.February, 1))
+	expected := []string{
+		"Faktura 1001: Köparens personnummer",
+		"Faktura 1001: Betalningsdatum saknas",
+		"Faktura 1001: Pris för arbete '4000.50' måste anges i hela kronor",
+		"Faktura 1001: Materialkostnad är angiven för städning, men inga timmar",
+		"Faktura 1001: Antal timmar måste anges för minst en typ av arbete",
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("got %d errors, expected %d: %v", len(errs), len(expected), errs)
+	}
+	for k, e := range expected {
+		if !strings.HasPrefix(errs[k].Error(), e) {
+			t.Errorf("got error '%s', expected '%s'", errs[k], e)
+		}
+	}
+
+	// At most 50 % of the price for the work can be requested for RUT
+	b = testRUTBegaran()
+	b.HushallBegaran.Arenden[0].BegartBelopp = 2001
+	b.HushallBegaran.Arenden[0].BetaltBelopp = 1999
+	if errs := b.Validate(invoiceDates("1001", 2024, time.February, 1)); len(errs) != 1 {
+		t.Errorf("expected error when requesting more than 50 %%, got %v", errs)
+	}
+}
+
+func TestValidateROT(t *testing.T) {
+	fakturaNr := FakturaNrTYPE("1002")
+	lagenhet := LagenhetsNrTYPE("1101")
+	brf := BrfOrgNrTYPE("7164001237")
+
+	b := NewBegaran("ROT-1002")
+	b.RotBegaran = &RotBegaranTYPE{
+		Arenden: []RotArendeTYPE{
+			{
+				Kopare:          "198001011231",
+				BetalningsDatum: "2024-03-01",
+				PrisForArbete:   "10000",
+				BetaltBelopp:    7000,
+				BegartBelopp:    3000,
+				FakturaNr:       &fakturaNr,
+				LagenhetsNr:     &lagenhet,
+				BrfOrgNr:        &brf,
+				UtfortArbete:    &ArendeUtfortArbeteRotTYPE{Bygg: &TimmarMaterialTYPE{AntalTimmar: 10, Materialkostnad: 1500}},
+			},
+		},
+	}
+
+	dates := invoiceDates("1002", 2024, time.February, 1)
+	if errs := b.Validate(dates); len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	a := &b.RotBegaran.Arenden[0]
+	a.BegartBelopp = 3001
+	a.BetaltBelopp = 6999
+	a.BrfOrgNr = nil
+	errs := b.Validate(dates)
+	if len(errs) != 2 {
+		t.Errorf("expected errors for requested amount and housing cooperative, got %v", errs)
+	}
+
+	a = &b.RotBegaran.Arenden[0]
+	a.BegartBelopp = 3000
+	a.BetaltBelopp = 7000
+	a.BrfOrgNr = nil
+	a.LagenhetsNr = nil
+	if errs := b.Validate(dates); len(errs) != 1 {
+		t.Errorf("expected error for missing property, got %v", errs)
+	}
+
+	b.HushallBegaran = &HushallBegaranTYPE{}
+	if errs := b.Validate(dates); len(errs) != 2 {
+		t.Errorf("expected error for both ROT and RUT in the same request, got %v", errs)
+	}
+}
+
+func TestValidateROTRate(t *testing.T) {
+	fakturaNr := FakturaNrTYPE("1003")
+	fastighet := FastighetsbeteckningTYPE("Uppsala Kåbo 1:1")
+
+	// The invoice is issued while the ROT deduction is 50 %, but paid after it has been lowered again
+	b := NewBegaran("ROT-1003")
+	b.RotBegaran = &RotBegaranTYPE{
+		Arenden: []RotArendeTYPE{
+			{
+				Kopare:               "198001011231",
+				BetalningsDatum:      "2026-01-10",
+				PrisForArbete:        "10000",
+				BetaltBelopp:         5000,
+				BegartBelopp:         5000,
+				FakturaNr:            &fakturaNr,
+				Fastighetsbeteckning: &fastighet,
+				UtfortArbete:         &ArendeUtfortArbeteRotTYPE{Bygg: &TimmarMaterialTYPE{AntalTimmar: 10}},
+			},
+		},
+	}
+
+	if errs := b.Validate(invoiceDates("1003", 2025, time.December, 15)); len(errs) > 0 {
+		t.Errorf("unexpected errors for invoice issued in 2025: %v", errs)
+	}
+
+	if errs := b.Validate(invoiceDates("1003", 2026, time.January, 2)); len(errs) != 1 {
+		t.Errorf("expected error when requesting more than 30 %% for invoice issued in 2026, got %v", errs)
+	}
+
+	if errs := b.Validate(nil); len(errs) != 1 {
+		t.Errorf("expected error for missing invoice date, got %v", errs)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/yzzyx/faktura-pdf/models"
+	"github.com/yzzyx/zerr"
+	"go.uber.org/zap"
+)
+
+// schedulerInterval is how often the scheduler checks for recurring invoices to issue
+const schedulerInterval = time.Hour
+
+// runScheduler issues recurring invoices that are due, at startup and then every interval, until ctx is cancelled
+func runScheduler(ctx context.Context, lg *zap.Logger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		issueRecurringInvoices(ctx, lg, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// issueRecurringInvoices issues all recurring invoices with runs on or before 'now'.
+// Each recurring invoice is issued in its own transaction, so that one failure doesn't stop the others.
+func issueRecurringInvoices(ctx context.Context, lg *zap.Logger, now time.Time) {
+	due, err := listDueRecurringInvoices(ctx, now)
+	if err != nil {
+		zerr.Wrap(err).LogError(lg)
+		return
+	}
+
+	for _, r := range due {
+		issued, err := issueRecurringInvoice(ctx, r, now)
+		if err != nil {
+			zerr.Wrap(err).WithInt("recurring_invoice.ID", r.ID).LogError(lg)
+			continue
+		}
+
+		for _, invoice := range issued {
+			lg.Info("issued recurring invoice",
+				zap.Int("recurring_invoice.ID", r.ID),
+				zap.Int("invoice.ID", invoice.ID),
+				zap.Int("invoice.Number", invoice.Number))
+		}
+	}
+}
+
+func listDueRecurringInvoices(ctx context.Context, now time.Time) (due []models.RecurringInvoice, err error) {
+	ctx, err = models.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer models.CommitOrRollback(ctx, &err)
+
+	return models.RecurringInvoiceList(ctx, models.RecurringInvoiceFilter{OnlyActive: true, DueBefore: &now})
+}
+
+func issueRecurringInvoice(ctx context.Context, r models.RecurringInvoice, now time.Time) (issued []models.Invoice, err error) {
+	ctx, err = models.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer models.CommitOrRollback(ctx, &err)
+
+	return models.RecurringInvoiceIssue(ctx, r, now)
+}
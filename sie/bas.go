@@ -0,0 +1,27 @@
+package sie
+
+// BASAccounts contains the names of the accounts in the BAS chart of accounts that are used by FakturaPDF
+var BASAccounts = map[int]string{
+	1510: "Kundfordringar",
+	1513: "Kundfordringar - delad faktura",
+	1930: "Företagskonto/checkkonto/affärskonto",
+	2611: "Utgående moms på försäljning inom Sverige, 25 %",
+	2620: "Utgående moms, 12 %",
+	2630: "Utgående moms, 6 %",
+	3001: "Försäljning inom Sverige, 25 % moms",
+	3002: "Försäljning inom Sverige, 12 % moms",
+	3003: "Försäljning inom Sverige, 6 % moms",
+	3004: "Försäljning inom Sverige, momsfri",
+	3041: "Försäljning tjänster inom Sverige, 25 % moms",
+	3042: "Försäljning tjänster inom Sverige, 12 % moms",
+	3043: "Försäljning tjänster inom Sverige, 6 % moms",
+	3044: "Försäljning tjänster inom Sverige, momsfri",
+	3105: "Försäljning varor till land utanför EU",
+	3108: "Försäljning varor till annat EU-land, momsfri",
+	3231: "Försäljning inom byggsektorn, omvänd skattskyldighet moms",
+	3305: "Försäljning tjänster till land utanför EU",
+	3308: "Försäljning tjänster till annat EU-land",
+	3740: "Öres- och kronutjämning",
+	3930: "Påminnelseavgifter",
+	8313: "Ränteintäkter från kundfordringar",
+}
@@ -0,0 +1,401 @@
+package sie
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/shopspring/decimal"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// ParseError describes an error on a specific line in the file
+type ParseError struct {
+	Line    int
+	Message string
+}
+
+func (e ParseError) Error() string {
+	return fmt.Sprintf("sie: line %d: %s", e.Line, e.Message)
+}
+
+// splitFields splits a record into fields.
+// Quoted strings are returned without quotes, and object lists are returned with braces.
+func splitFields(line string) ([]string, error) {
+	var fields []string
+	var field strings.Builder
+	inQuote := false
+	inList := false
+	hasField := false
+
+	flush := func() {
+		if hasField {
+			fields = append(fields, field.String())
+		}
+		field.Reset()
+		hasField = false
+	}
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case inQuote && c == '\\' && i+1 < len(line) && line[i+1] == '"':
+			field.WriteByte('"')
+			i++
+		case c == '"' && !inList:
+			inQuote = !inQuote
+			hasField = true
+		case inQuote:
+			field.WriteByte(c)
+		case c == '{':
+			inList = true
+			hasField = true
+			field.WriteByte(c)
+		case c == '}' && inList:
+			inList = false
+			field.WriteByte(c)
+		case inList:
+			// Object lists may contain spaces and quoted strings
+			field.WriteByte(c)
+		case c == ' ' || c == '\t':
+			flush()
+		default:
+			hasField = true
+			field.WriteByte(c)
+		}
+	}
+
+	if inQuote {
+		return nil, fmt.Errorf("unterminated string")
+	}
+	if inList {
+		return nil, fmt.Errorf("unterminated object list")
+	}
+	flush()
+	return fields, nil
+}
+
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation("20060102", s, time.Local)
+}
+
+func parseAmount(s string) (decimal.Decimal, error) {
+	if s == "" {
+		return decimal.Zero, nil
+	}
+	return decimal.NewFromString(s)
+}
+
+// field returns field n, or an empty string if it doesn't exist
+func field(fields []string, n int) string {
+	if n < len(fields) {
+		return fields[n]
+	}
+	return ""
+}
+
+// decode converts data to UTF-8. Files are usually encoded in CP437 (#FORMAT PC8),
+// but files that are valid UTF-8 are read as is.
+func decode(data []byte) ([]byte, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if utf8.Valid(data) {
+		return data, nil
+	}
+	return charmap.CodePage437.NewDecoder().Bytes(data)
+}
+
+// Parse reads a SIE file of type 1-4 from r.
+// Records that are not used by the SIE type are ignored, as are unknown records.
+// If the file contains a #KSUMMA checksum, it is verified.
+func Parse(r io.Reader) (*SIE, error) {
+	raw, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := decode(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SIE{Kind: KindImport}
+	rawLines := bytes.Split(raw, []byte("\n"))
+	lines := strings.Split(string(data), "\n")
+
+	lineNr := 0
+	fail := func(format string, args ...interface{}) (*SIE, error) {
+		return nil, ParseError{Line: lineNr, Message: fmt.Sprintf(format, args...)}
+	}
+
+	var verification *Verification
+	verificationLine := 0
+	inBlock := false
+	var checksum = NewChecksum()
+	checksumStarted := false
+	checksumVerified := false
+	accountTypes := map[int]AccountType{}
+
+	for k, line := range lines {
+		lineNr = k + 1
+		line = strings.TrimRight(line, "\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+
+		// Verification blocks start and end with lines containing only '{' or '}'
+		fields := []string{trimmed}
+		if trimmed != "{" && trimmed != "}" {
+			fields, err = splitFields(trimmed)
+			if err != nil {
+				return fail("%v", err)
+			}
+		}
+		label := strings.ToUpper(fields[0])
+		fields = fields[1:]
+
+		if label == "#KSUMMA" {
+			if len(fields) == 0 {
+				checksumStarted = true
+				s.Checksum = true
+				continue
+			}
+
+			if !checksumStarted {
+				return fail("checksum without start record")
+			}
+
+			expected, err := strconv.ParseUint(fields[0], 10, 32)
+			if err != nil {
+				return fail("invalid checksum '%s'", fields[0])
+			}
+
+			if uint32(expected) != checksum.Sum32() {
+				return fail("checksum mismatch, file is damaged or has been modified")
+			}
+			checksumStarted = false
+			checksumVerified = true
+			continue
+		}
+
+		if checksumStarted {
+			// The checksum is calculated on the bytes in the file, not on the decoded text
+			if k < len(rawLines) {
+				ChecksumLine(checksum, bytes.TrimRight(rawLines[k], "\r"))
+			}
+		}
+
+		switch label {
+		case "{":
+			if verification == nil || inBlock {
+				return fail("unexpected '{'")
+			}
+			inBlock = true
+			continue
+		case "}":
+			if !inBlock {
+				return fail("unexpected '}'")
+			}
+			s.Verifications = append(s.Verifications, *verification)
+			verification = nil
+			inBlock = false
+			continue
+		}
+
+		if inBlock {
+			switch label {
+			case "#TRANS":
+				t, err := parseTransaction(fields)
+				if err != nil {
+					return fail("%v", err)
+				}
+				verification.Transactions = append(verification.Transactions, t)
+			case "#RTRANS", "#BTRANS":
+				// Added (#RTRANS) and removed (#BTRANS) transactions are ignored. Added transactions
+				// are always followed by an identical #TRANS, for programs that don't support #RTRANS.
+			default:
+				return fail("unexpected record %s in verification", label)
+			}
+			continue
+		}
+
+		if verification != nil {
+			return fail("expected '{' after #VER")
+		}
+
+		switch label {
+		case "#FLAGGA":
+			s.Flag, err = strconv.Atoi(field(fields, 0))
+		case "#PROGRAM":
+			s.Program = field(fields, 0)
+			s.ProgramVersion = field(fields, 1)
+		case "#SIETYP":
+			s.Type, err = strconv.Atoi(field(fields, 0))
+		case "#FNAMN":
+			s.Fnamn = field(fields, 0)
+		case "#ORGNR":
+			s.OrgNr = field(fields, 0)
+		case "#VALUTA":
+			s.Valuta = field(fields, 0)
+		case "#OMFATTN":
+			s.Omfattning, err = parseDate(field(fields, 0))
+		case "#RAR":
+			err = s.parseFiscalYear(fields)
+		case "#KONTO":
+			var nr int
+			nr, err = strconv.Atoi(field(fields, 0))
+			s.Accounts = append(s.Accounts, Account{Number: nr, Name: field(fields, 1)})
+		case "#KTYP":
+			var nr int
+			nr, err = strconv.Atoi(field(fields, 0))
+			accountTypes[nr] = AccountType(strings.ToUpper(field(fields, 1)))
+		case "#IB", "#UB", "#RES":
+			var b Balance
+			b, err = parseBalance(fields)
+			switch label {
+			case "#IB":
+				s.OpeningBalances = append(s.OpeningBalances, b)
+			case "#UB":
+				s.ClosingBalances = append(s.ClosingBalances, b)
+			case "#RES":
+				s.Results = append(s.Results, b)
+			}
+			s.Kind = KindExport
+		case "#VER":
+			verificationLine = lineNr
+			verification = &Verification{
+				Serie:   field(fields, 0),
+				VerNr:   field(fields, 1),
+				VerText: field(fields, 3),
+				Sign:    field(fields, 5),
+			}
+
+			verification.VerDatum, err = parseDate(field(fields, 2))
+			if err == nil {
+				verification.RegDatum, err = parseDate(field(fields, 4))
+			}
+		case "#TRANS", "#RTRANS", "#BTRANS":
+			return fail("%s outside of verification", label)
+		}
+
+		if err != nil {
+			return fail("invalid %s record: %v", label, err)
+		}
+	}
+
+	if verification != nil {
+		lineNr = verificationLine
+		return fail("unterminated verification")
+	}
+
+	if checksumStarted && !checksumVerified {
+		return fail("missing checksum")
+	}
+
+	for k := range s.Accounts {
+		s.Accounts[k].Type = accountTypes[s.Accounts[k].Number]
+	}
+
+	return s, nil
+}
+
+func (s *SIE) parseFiscalYear(fields []string) error {
+	year, err := strconv.Atoi(field(fields, 0))
+	if err != nil {
+		return err
+	}
+
+	if year > 0 {
+		return fmt.Errorf("invalid year %d", year)
+	}
+
+	var fy FiscalYear
+	fy.Start, err = parseDate(field(fields, 1))
+	if err != nil {
+		return err
+	}
+
+	fy.End, err = parseDate(field(fields, 2))
+	if err != nil {
+		return err
+	}
+
+	// Year 0 is stored first, -1 second etc.
+	idx := -year
+	for len(s.FiscalYears) <= idx {
+		s.FiscalYears = append(s.FiscalYears, FiscalYear{})
+	}
+	s.FiscalYears[idx] = fy
+	return nil
+}
+
+func parseBalance(fields []string) (Balance, error) {
+	var b Balance
+	var err error
+
+	if len(fields) < 3 {
+		return b, fmt.Errorf("expected 3 fields, got %d", len(fields))
+	}
+
+	// Object balances (#OIB/#OUB) have an object list before the amount, but #IB/#UB/#RES don't
+	b.Year, err = strconv.Atoi(fields[0])
+	if err != nil {
+		return b, err
+	}
+
+	b.KontoNr, err = strconv.Atoi(fields[1])
+	if err != nil {
+		return b, err
+	}
+
+	b.Belopp, err = parseAmount(fields[2])
+	return b, err
+}
+
+func parseTransaction(fields []string) (Transaction, error) {
+	var t Transaction
+	var err error
+
+	if len(fields) < 3 {
+		return t, fmt.Errorf("#TRANS: expected at least 3 fields, got %d", len(fields))
+	}
+
+	t.KontoNr, err = strconv.Atoi(fields[0])
+	if err != nil {
+		return t, fmt.Errorf("#TRANS: invalid account '%s'", fields[0])
+	}
+
+	list := fields[1]
+	if !strings.HasPrefix(list, "{") || !strings.HasSuffix(list, "}") {
+		return t, fmt.Errorf("#TRANS: invalid object list '%s'", list)
+	}
+	t.ObjektLista = strings.TrimSpace(list[1 : len(list)-1])
+
+	t.Belopp, err = parseAmount(fields[2])
+	if err != nil {
+		return t, fmt.Errorf("#TRANS: invalid amount '%s'", fields[2])
+	}
+
+	t.TransDat, err = parseDate(field(fields, 3))
+	if err != nil {
+		return t, fmt.Errorf("#TRANS: invalid date '%s'", field(fields, 3))
+	}
+
+	t.TransText = field(fields, 4)
+
+	t.Kvantitet, err = parseAmount(field(fields, 5))
+	if err != nil {
+		return t, fmt.Errorf("#TRANS: invalid quantity '%s'", field(fields, 5))
+	}
+
+	t.Sign = field(fields, 6)
+	return t, nil
+}
@@ -0,0 +1,239 @@
+package sie
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestSplitFields(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected []string
+	}{
+		{`#KONTO 1510 "Kundfordringar"`, []string{"#KONTO", "1510", "Kundfordringar"}},
+		{`#TRANS 1510 {1 "100" 6 "P 1"} 100.00`, []string{"#TRANS", "1510", `{1 "100" 6 "P 1"}`, "100.00"}},
+		{"#VER\tA 1 20250301 \"Text med \\\"citat\\\"\"", []string{"#VER", "A", "1", "20250301", `Text med "citat"`}},
+		{`#TRANS 1510 {} 100.00 "" "" 2`, []string{"#TRANS", "1510", "{}", "100.00", "", "", "2"}},
+	}
+
+	for _, tt := range tests {
+		fields, err := splitFields(tt.line)
+		if err != nil {
+			t.Errorf("%s: %v", tt.line, err)
+			continue
+		}
+		if strings.Join(fields, "|") != strings.Join(tt.expected, "|") {
+			t.Errorf("%s: got %q, expected %q", tt.line, fields, tt.expected)
+		}
+	}
+
+	_, err := splitFields(`#FNAMN "Företaget AB`)
+	if err == nil {
+		t.Errorf("expected error for unterminated string")
+	}
+}
+
+func TestParse(t *testing.T) {
+	data := `#FLAGGA 0
+#PROGRAM "Bokföring" "1.0"
+#FORMAT PC8
+#SIETYP 4
+#FNAMN "Företaget AB"
+#RAR 0 20250101 20251231
+#RAR -1 20240101 20241231
+#KONTO 1930 "Företagskonto"
+#KONTO 3001 "Försäljning 25 %"
+#KTYP 1930 T
+#IB 0 1930 1000.50
+#IB -1 1930 200
+#VER A 1 20250301 "Faktura"
+{
+	#TRANS 1930 {1 "100"} 125.00 20250301 "Insättning" 2
+	#RTRANS 3001 {} -125.00
+	#TRANS 3001 {} -125.00
+}
+#VER "A" "2" 20250302 "Tom"
+{
+}
+`
+	s, err := Parse(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Type != 4 || s.Kind != KindExport || s.Fnamn != "Företaget AB" || s.Program != "Bokföring" {
+		t.Errorf("unexpected header: %+v", s)
+	}
+
+	if len(s.FiscalYears) != 2 || s.FiscalYears[1].Start.Format("20060102") != "20240101" {
+		t.Errorf("unexpected fiscal years: %+v", s.FiscalYears)
+	}
+
+	if len(s.Accounts) != 2 || s.Accounts[0].Type != AccountTypeAsset || s.Accounts[1].Name != "Försäljning 25 %" {
+		t.Errorf("unexpected accounts: %+v", s.Accounts)
+	}
+
+	if len(s.OpeningBalances) != 2 || !s.OpeningBalances[0].Belopp.Equal(decimal.RequireFromString("1000.5")) || s.OpeningBalances[1].Year != -1 {
+		t.Errorf("unexpected opening balances: %+v", s.OpeningBalances)
+	}
+
+	if len(s.Verifications) != 2 {
+		t.Fatalf("got %d verifications, expected 2", len(s.Verifications))
+	}
+
+	v := s.Verifications[0]
+	if v.Serie != "A" || v.VerNr != "1" || v.VerText != "Faktura" || len(v.Transactions) != 2 {
+		t.Fatalf("unexpected verification: %+v", v)
+	}
+
+	tr := v.Transactions[0]
+	if tr.KontoNr != 1930 || tr.ObjektLista != `1 "100"` || !tr.Belopp.Equal(decimal.NewFromInt(125)) ||
+		tr.TransText != "Insättning" || !tr.Kvantitet.Equal(decimal.NewFromInt(2)) {
+		t.Errorf("unexpected transaction: %+v", tr)
+	}
+
+	errs := s.Validate()
+	if len(errs) != 1 {
+		t.Fatalf("got %d validation errors, expected 1: %v", len(errs), errs)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		data string
+		line int
+	}{
+		{"#FLAGGA 0\n#TRANS 1930 {} 100\n", 2},
+		{"#VER A 1 20250301\n#TRANS 1930 {} 100\n", 2},
+		{"#VER A 1 20250301\n{\n#TRANS 1930 {} 100\n", 1},
+		{"#VER A 1 20250301\n{\n#TRANS 1930 100\n}\n", 3},
+		{"#RAR 0 2025-01-01 20251231\n", 1},
+		{"#FLAGGA 0\n#KSUMMA\n#SIETYP 4\n#KSUMMA 12345\n", 4},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(strings.NewReader(tt.data))
+		var perr ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("%q: expected parse error, got %v", tt.data, err)
+			continue
+		}
+		if perr.Line != tt.line {
+			t.Errorf("%q: got error on line %d, expected line %d (%v)", tt.data, perr.Line, tt.line, err)
+		}
+	}
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	s := SIE{
+		Kind:  KindExport,
+		Fnamn: "Företaget AB",
+		OrgNr: "556677-8899",
+		FiscalYears: []FiscalYear{
+			{Start: time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local), End: time.Date(2025, 12, 31, 0, 0, 0, 0, time.Local)},
+		},
+		OpeningBalances: []Balance{{KontoNr: 1930, Belopp: decimal.NewFromInt(1000)}},
+		Verifications: []Verification{
+			{
+				Serie:    "A",
+				VerNr:    "1",
+				VerDatum: time.Date(2025, 3, 1, 0, 0, 0, 0, time.Local),
+				VerText:  `Faktura "1" till Åsa`,
+				Transactions: []Transaction{
+					{KontoNr: 1510, Belopp: decimal.NewFromInt(125)},
+					{KontoNr: 2611, Belopp: decimal.NewFromInt(-25)},
+					{KontoNr: 3001, Belopp: decimal.NewFromInt(-100)},
+				},
+			},
+		},
+		Checksum: true,
+	}
+	s.CalculateBalances()
+	s.AddAccounts(BASAccounts)
+
+	b := &bytes.Buffer{}
+	err := s.Write(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	written := b.Bytes()
+
+	parsed, err := Parse(bytes.NewReader(written))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if errs := parsed.Validate(); len(errs) > 0 {
+		t.Errorf("unexpected validation errors: %v", errs)
+	}
+
+	if !parsed.Checksum || parsed.Fnamn != s.Fnamn || parsed.OrgNr != s.OrgNr || parsed.Kind != KindExport {
+		t.Errorf("unexpected header: %+v", parsed)
+	}
+
+	if len(parsed.Accounts) != len(s.Accounts) || len(parsed.ClosingBalances) != len(s.ClosingBalances) || len(parsed.Results) != len(s.Results) {
+		t.Errorf("accounts or balances differ: %+v", parsed)
+	}
+
+	if len(parsed.Verifications) != 1 || parsed.Verifications[0].VerText != s.Verifications[0].VerText {
+		t.Fatalf("unexpected verifications: %+v", parsed.Verifications)
+	}
+
+	// Writing the parsed file should give the same result
+	b2 := &bytes.Buffer{}
+	err = parsed.Write(b2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(written, b2.Bytes()) {
+		t.Errorf("round trip differs:\n%s\n---\n%s", written, b2.Bytes())
+	}
+
+	// Modifying the file should break the checksum
+	modified := bytes.Replace(written, []byte("-100.00"), []byte("-101.00"), 1)
+	_, err = Parse(bytes.NewReader(modified))
+	if err == nil {
+		t.Errorf("expected checksum error for modified file")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s := SIE{
+		Accounts: []Account{{Number: 1930}, {Number: 3001}},
+		Verifications: []Verification{
+			{
+				Serie:    "A",
+				VerNr:    "1",
+				VerDatum: time.Now(),
+				Transactions: []Transaction{
+					{KontoNr: 1930, Belopp: decimal.NewFromInt(100)},
+					{KontoNr: 3001, Belopp: decimal.NewFromInt(-100)},
+				},
+			},
+			{
+				Serie:    "A",
+				VerNr:    "2",
+				VerDatum: time.Now(),
+				Transactions: []Transaction{
+					{KontoNr: 1930, Belopp: decimal.NewFromInt(100)},
+					{KontoNr: 2611, Belopp: decimal.NewFromInt(-99)},
+				},
+			},
+		},
+	}
+
+	errs := s.Validate()
+	if len(errs) != 2 {
+		t.Fatalf("got %d errors, expected 2: %v", len(errs), errs)
+	}
+
+	var verr ValidationError
+	if !errors.As(errs[0], &verr) || verr.VerNr != "2" {
+		t.Errorf("unexpected error: %v", errs[0])
+	}
+}
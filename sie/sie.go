@@ -1,8 +1,13 @@
 package sie
 
 import (
+	"bytes"
 	"fmt"
+	"hash"
+	"hash/crc32"
 	"io"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -12,9 +17,97 @@ import (
 
 // esc escapes strings used in SIE file
 func esc(s string) string {
+	s = strings.Map(func(r rune) rune {
+		// Line breaks and other control characters are not allowed in fields
+		if r < ' ' {
+			return ' '
+		}
+		return r
+	}, s)
 	return strings.ReplaceAll(s, `"`, `\"`)
 }
 
+// quote returns s as a quoted field
+func quote(s string) string {
+	return `"` + esc(s) + `"`
+}
+
+// date formats t as a SIE date field, or an empty field if t is not set
+func date(t time.Time) string {
+	if t.IsZero() {
+		return `""`
+	}
+	return t.Format("20060102")
+}
+
+// amount formats d as a SIE amount
+func amount(d decimal.Decimal) string {
+	return d.StringFixedBank(2)
+}
+
+// Kind is the kind of SIE 4 file
+type Kind int
+
+const (
+	// KindImport (4I) contains verifications that should be imported into an existing bookkeeping
+	KindImport Kind = iota
+	// KindExport (4E) is a complete export, including balances and results
+	KindExport
+)
+
+// Account describes an account in the chart of accounts
+type Account struct {
+	Number int
+	Name   string
+	Type   AccountType // Optional, derived from the account number if not set
+}
+
+// AccountType is the type of an account, as used in #KTYP
+type AccountType string
+
+const (
+	AccountTypeAsset     AccountType = "T" // Tillgång
+	AccountTypeLiability AccountType = "S" // Skuld / eget kapital
+	AccountTypeCost      AccountType = "K" // Kostnad
+	AccountTypeIncome    AccountType = "I" // Intäkt
+)
+
+// AccountTypeFromNumber returns the account type of a BAS account number
+func AccountTypeFromNumber(number int) AccountType {
+	switch {
+	case number < 2000:
+		return AccountTypeAsset
+	case number < 3000:
+		return AccountTypeLiability
+	case number < 4000:
+		return AccountTypeIncome
+	case number >= 8200 && number < 8400:
+		// Financial income, e.g. interest
+		return AccountTypeIncome
+	default:
+		return AccountTypeCost
+	}
+}
+
+// IsBalanceAccount returns true if the account is a balance account (class 1 and 2),
+// and false if it is a result account (class 3-8)
+func IsBalanceAccount(number int) bool {
+	return number < 3000
+}
+
+// FiscalYear describes a fiscal year (räkenskapsår)
+type FiscalYear struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Balance is the balance of an account for a fiscal year, as used in #IB, #UB and #RES
+type Balance struct {
+	Year    int // 0 is the current year, -1 the previous year etc.
+	KontoNr int
+	Belopp  decimal.Decimal
+}
+
 // Transaction describes a single transaction in a verification
 type Transaction struct {
 	KontoNr     int
@@ -22,10 +115,43 @@ type Transaction struct {
 	Belopp      decimal.Decimal
 	TransDat    time.Time
 	TransText   string
-	Kvantitet   string
+	Kvantitet   decimal.Decimal
 	Sign        string
 }
 
+// fields returns the fields of the transaction.
+// Optional fields at the end are left out, but empty fields are added if a later field is set.
+func (t *Transaction) fields() []string {
+	fields := []string{
+		strconv.Itoa(t.KontoNr),
+		"{" + t.ObjektLista + "}",
+		amount(t.Belopp),
+		date(t.TransDat),
+		quote(t.TransText),
+		t.Kvantitet.String(),
+		quote(t.Sign),
+	}
+
+	last := 3
+	if !t.TransDat.IsZero() {
+		last = 4
+	}
+	if t.TransText != "" {
+		last = 5
+	}
+	if !t.Kvantitet.IsZero() {
+		last = 6
+	}
+	if t.Sign != "" {
+		last = 7
+	}
+
+	if t.Kvantitet.IsZero() {
+		fields[5] = `""`
+	}
+	return fields[:last]
+}
+
 // Write writes the transaction to w
 func (t *Transaction) Write(w io.Writer) error {
 	if t.Belopp.IsZero() {
@@ -33,7 +159,7 @@ func (t *Transaction) Write(w io.Writer) error {
 	}
 
 	// #TRANS kontonr {objektlista} belopp transdat transtext kvantitet sign
-	_, err := fmt.Fprintf(w, "    #TRANS %d {%s} %s\n", t.KontoNr, t.ObjektLista, t.Belopp.StringFixedBank(2))
+	_, err := fmt.Fprintf(w, "    #TRANS %s\n", strings.Join(t.fields(), " "))
 	return err
 }
 
@@ -49,17 +175,36 @@ type Verification struct {
 	Transactions []Transaction
 }
 
-// Write writes the verification to w
+// Sum returns the sum of all transactions in the verification, which is zero if the verification is balanced
+func (v *Verification) Sum() decimal.Decimal {
+	sum := decimal.Zero
+	for _, t := range v.Transactions {
+		sum = sum.Add(t.Belopp)
+	}
+	return sum
+}
+
+// Write writes the verification to w.
+// An error is returned if the amounts, as written to the file, are not balanced.
 func (v *Verification) Write(w io.Writer) error {
+	sum := decimal.Zero
+	for _, t := range v.Transactions {
+		sum = sum.Add(t.Belopp.RoundBank(2))
+	}
+	if !sum.IsZero() {
+		return fmt.Errorf("verification %q at %s is not balanced, the difference is %s", v.VerText, v.VerDatum.Format("2006-01-02"), amount(sum))
+	}
+
 	// #VER serie vernr verdatum vertext regdatum sign
-	_, err := fmt.Fprintf(w,
-		`#VER "%s" "%s" %s "%s"
-{
-`, esc(v.Serie),
-		esc(v.VerNr),
-		v.VerDatum.Format("20060102"),
-		esc(v.VerText))
+	fields := []string{quote(v.Serie), quote(v.VerNr), date(v.VerDatum), quote(v.VerText)}
+	if !v.RegDatum.IsZero() || v.Sign != "" {
+		fields = append(fields, date(v.RegDatum))
+	}
+	if v.Sign != "" {
+		fields = append(fields, quote(v.Sign))
+	}
 
+	_, err := fmt.Fprintf(w, "#VER %s\n{\n", strings.Join(fields, " "))
 	if err != nil {
 		return err
 	}
@@ -76,43 +221,261 @@ func (v *Verification) Write(w io.Writer) error {
 
 // SIE describes a SIE file
 type SIE struct {
+	Kind           Kind
 	Flag           int
 	Fnamn          string
+	OrgNr          string
 	Program        string
 	ProgramVersion string
 	Type           int
+	Valuta         string // Currency, SEK if not set
+
+	// Fiscal years, where the first entry is the current year (0), the second the previous year (-1) etc.
+	FiscalYears []FiscalYear
+	Omfattning  time.Time // Date up to which balances and results are included (4E only)
+	Accounts    []Account
+
+	// Balances and results are only written to export files (4E)
+	OpeningBalances []Balance
+	ClosingBalances []Balance
+	Results         []Balance
 
 	Verifications []Verification
+
+	Checksum bool // Add a #KSUMMA checksum
+}
+
+// AddAccounts adds all accounts used in verifications or balances to the chart of accounts,
+// with names from 'names'. Accounts that are not in 'names' are named from the BAS chart of accounts if possible.
+// Accounts that are already in the chart are left as is.
+func (s *SIE) AddAccounts(names map[int]string) {
+	existing := map[int]bool{}
+	for _, a := range s.Accounts {
+		existing[a.Number] = true
+	}
+
+	add := func(nr int) {
+		if existing[nr] {
+			return
+		}
+		existing[nr] = true
+
+		name, ok := names[nr]
+		if !ok {
+			name, ok = BASAccounts[nr]
+		}
+		if !ok {
+			name = fmt.Sprintf("Konto %d", nr)
+		}
+		s.Accounts = append(s.Accounts, Account{Number: nr, Name: name})
+	}
+
+	for _, v := range s.Verifications {
+		for _, t := range v.Transactions {
+			if !t.Belopp.IsZero() {
+				add(t.KontoNr)
+			}
+		}
+	}
+
+	for _, balances := range [][]Balance{s.OpeningBalances, s.ClosingBalances, s.Results} {
+		for _, b := range balances {
+			add(b.KontoNr)
+		}
+	}
+
+	sort.Slice(s.Accounts, func(i, j int) bool {
+		return s.Accounts[i].Number < s.Accounts[j].Number
+	})
+}
+
+// CalculateBalances sets the closing balances and results of the current year (0),
+// from the opening balances and all verifications
+func (s *SIE) CalculateBalances() {
+	balances := map[int]decimal.Decimal{}
+	results := map[int]decimal.Decimal{}
+
+	for _, b := range s.OpeningBalances {
+		if b.Year == 0 {
+			balances[b.KontoNr] = balances[b.KontoNr].Add(b.Belopp)
+		}
+	}
+
+	for _, v := range s.Verifications {
+		for _, t := range v.Transactions {
+			if t.Belopp.IsZero() {
+				continue
+			}
+
+			if IsBalanceAccount(t.KontoNr) {
+				balances[t.KontoNr] = balances[t.KontoNr].Add(t.Belopp)
+			} else {
+				results[t.KontoNr] = results[t.KontoNr].Add(t.Belopp)
+			}
+		}
+	}
+
+	s.ClosingBalances = sortedBalances(balances)
+	s.Results = sortedBalances(results)
+}
+
+func sortedBalances(m map[int]decimal.Decimal) []Balance {
+	var balances []Balance
+	for nr, belopp := range m {
+		balances = append(balances, Balance{KontoNr: nr, Belopp: belopp})
+	}
+
+	sort.Slice(balances, func(i, j int) bool {
+		return balances[i].KontoNr < balances[j].KontoNr
+	})
+	return balances
+}
+
+// recordWriter writes records (lines) in CP437 to w, and keeps a checksum of all written records
+type recordWriter struct {
+	w   io.Writer
+	crc hash.Hash32
+	err error
+}
+
+// record writes a single record with a label and fields
+func (rw *recordWriter) record(label string, fields ...string) {
+	line := label
+	if len(fields) > 0 {
+		line += " " + strings.Join(fields, " ")
+	}
+	rw.write(line + "\n")
+}
+
+// write writes s, converted to CP437, to the underlying writer
+func (rw *recordWriter) write(s string) {
+	if rw.err != nil {
+		return
+	}
+
+	b, err := charmap.CodePage437.NewEncoder().Bytes([]byte(s))
+	if err != nil {
+		rw.err = err
+		return
+	}
+
+	if rw.crc != nil {
+		ChecksumLine(rw.crc, b)
+	}
+	_, rw.err = rw.w.Write(b)
+}
+
+// ChecksumLine adds a line (in CP437) to a #KSUMMA checksum.
+// Only labels and the contents of fields are included in the checksum,
+// i.e. not field separators, quotes around strings, braces around object lists or line breaks.
+func ChecksumLine(crc hash.Hash32, line []byte) {
+	var content []byte
+	inQuote := false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\\' && inQuote && i+1 < len(line) && line[i+1] == '"':
+			content = append(content, '"')
+			i++
+		case c == '"':
+			inQuote = !inQuote
+		case inQuote:
+			content = append(content, c)
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '{' || c == '}':
+		default:
+			content = append(content, c)
+		}
+	}
+	crc.Write(content)
+}
+
+// NewChecksum returns a new hash used to calculate #KSUMMA (CRC-32)
+func NewChecksum() hash.Hash32 {
+	return crc32.NewIEEE()
+}
+
+func (s *SIE) writeBalances(rw *recordWriter, label string, balances []Balance) {
+	for _, b := range balances {
+		rw.record(label, strconv.Itoa(b.Year), strconv.Itoa(b.KontoNr), amount(b.Belopp))
+	}
 }
 
 // Write writes the SIE to w, converted to CP437
 func (s *SIE) Write(w io.Writer) error {
-	w = charmap.CodePage437.NewEncoder().Writer(w)
-
-	_, err := fmt.Fprintf(w,
-		`#FLAGGA %d
-#FNAMN "%s"
-#FORMAT PC8
-#GEN %s
-#PROGRAM "%s" %s
-#SIETYP 4
-
-`, s.Flag,
-		esc(s.Fnamn),
-		time.Now().Format("20060102"),
-		esc(s.Program),
-		s.ProgramVersion)
+	rw := &recordWriter{w: w}
 
-	if err != nil {
-		return err
+	rw.record("#FLAGGA", strconv.Itoa(s.Flag))
+	if s.Checksum {
+		rw.record("#KSUMMA")
+		rw.crc = NewChecksum()
+	}
+
+	sieType := s.Type
+	if sieType == 0 {
+		sieType = 4
+	}
+
+	rw.record("#PROGRAM", quote(s.Program), quote(s.ProgramVersion))
+	rw.record("#FORMAT", "PC8")
+	rw.record("#GEN", time.Now().Format("20060102"))
+	rw.record("#SIETYP", strconv.Itoa(sieType))
+
+	if s.OrgNr != "" {
+		rw.record("#ORGNR", quote(s.OrgNr))
+	}
+	rw.record("#FNAMN", quote(s.Fnamn))
+
+	for k, y := range s.FiscalYears {
+		rw.record("#RAR", strconv.Itoa(-k), date(y.Start), date(y.End))
+	}
+
+	if s.Kind == KindExport && !s.Omfattning.IsZero() {
+		rw.record("#OMFATTN", date(s.Omfattning))
+	}
+
+	valuta := s.Valuta
+	if valuta == "" {
+		valuta = "SEK"
+	}
+	rw.record("#VALUTA", valuta)
+
+	for _, a := range s.Accounts {
+		rw.record("#KONTO", strconv.Itoa(a.Number), quote(a.Name))
+	}
+
+	for _, a := range s.Accounts {
+		accountType := a.Type
+		if accountType == "" {
+			accountType = AccountTypeFromNumber(a.Number)
+		}
+		rw.record("#KTYP", strconv.Itoa(a.Number), string(accountType))
+	}
+
+	if s.Kind == KindExport {
+		s.writeBalances(rw, "#IB", s.OpeningBalances)
+		s.writeBalances(rw, "#UB", s.ClosingBalances)
+		s.writeBalances(rw, "#RES", s.Results)
 	}
 
 	for _, v := range s.Verifications {
-		err = v.Write(w)
+		b := &bytes.Buffer{}
+		err := v.Write(b)
 		if err != nil {
 			return err
 		}
+
+		for _, line := range strings.SplitAfter(b.String(), "\n") {
+			if line != "" {
+				rw.write(line)
+			}
+		}
+	}
+
+	if s.Checksum && rw.crc != nil {
+		sum := rw.crc.Sum32()
+		rw.crc = nil
+		rw.record("#KSUMMA", strconv.FormatUint(uint64(sum), 10))
 	}
 
-	return nil
+	return rw.err
 }
@@ -0,0 +1,202 @@
+package sie
+
+import (
+	"bufio"
+	"bytes"
+	"hash/crc32"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestTransactionFields(t *testing.T) {
+	tests := []struct {
+		t        Transaction
+		expected string
+	}{
+		{
+			t:        Transaction{KontoNr: 1510, Belopp: decimal.NewFromInt(100)},
+			expected: `1510 {} 100.00`,
+		},
+		{
+			t:        Transaction{KontoNr: 1510, Belopp: decimal.NewFromInt(100), TransText: "Kund"},
+			expected: `1510 {} 100.00 "" "Kund"`,
+		},
+		{
+			t:        Transaction{KontoNr: 3001, Belopp: decimal.NewFromInt(-80), TransDat: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), Sign: "AB"},
+			expected: `3001 {} -80.00 20250301 "" "" "AB"`,
+		},
+		{
+			t:        Transaction{KontoNr: 3001, Belopp: decimal.NewFromInt(-80), Kvantitet: decimal.NewFromFloat(2.5)},
+			expected: `3001 {} -80.00 "" "" 2.5`,
+		},
+	}
+
+	for _, tt := range tests {
+		fields := strings.Join(tt.t.fields(), " ")
+		if fields != tt.expected {
+			t.Errorf("got '%s', expected '%s'", fields, tt.expected)
+		}
+	}
+}
+
+func TestChecksumLine(t *testing.T) {
+	crc := NewChecksum()
+	ChecksumLine(crc, []byte(`#TRANS 1510 {1 "100"} 100.00 20250101 "Text med \"citat\""`+"\n"))
+
+	expected := crc32.ChecksumIEEE([]byte(`#TRANS15101100100.0020250101Text med "citat"`))
+	if crc.Sum32() != expected {
+		t.Errorf("got checksum %d, expected %d", crc.Sum32(), expected)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	date := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	s := SIE{
+		Kind:           KindExport,
+		Fnamn:          "Företaget AB",
+		OrgNr:          "556677-8899",
+		Program:        "FakturaPDF",
+		ProgramVersion: "0.1",
+		FiscalYears: []FiscalYear{
+			{Start: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC)},
+		},
+		OpeningBalances: []Balance{{KontoNr: 1930, Belopp: decimal.NewFromInt(1000)}},
+		Verifications: []Verification{
+			{
+				VerDatum: date,
+				VerText:  "Faktura #1",
+				Transactions: []Transaction{
+					{KontoNr: 1510, Belopp: decimal.NewFromInt(125)},
+					{KontoNr: 2611, Belopp: decimal.NewFromInt(-25)},
+					{KontoNr: 3001, Belopp: decimal.NewFromInt(-100)},
+					{KontoNr: 3002, Belopp: decimal.Zero},
+				},
+			},
+		},
+		Checksum: true,
+	}
+	s.CalculateBalances()
+	s.AddAccounts(BASAccounts)
+
+	b := &bytes.Buffer{}
+	err := s.Write(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(b)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	expected := []string{
+		`#FLAGGA 0`,
+		`#KSUMMA`,
+		`#SIETYP 4`,
+		`#ORGNR "556677-8899"`,
+		`#RAR 0 20250101 20251231`,
+		`#KONTO 1510 "Kundfordringar"`,
+		`#KTYP 2611 S`,
+		`#KTYP 3001 I`,
+		`#IB 0 1930 1000.00`,
+		`#UB 0 1510 125.00`,
+		`#UB 0 1930 1000.00`,
+		`#RES 0 3001 -100.00`,
+		`#VER "" "" 20250301 "Faktura #1"`,
+		`    #TRANS 2611 {} -25.00`,
+	}
+
+	for _, e := range expected {
+		found := false
+		for _, l := range lines {
+			if l == e {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("expected line '%s' not found", e)
+		}
+	}
+
+	for _, l := range lines {
+		if strings.Contains(l, "3002") {
+			t.Errorf("unused account 3002 should not be included: '%s'", l)
+		}
+	}
+
+	// The checksum covers all lines between the two #KSUMMA records
+	last := lines[len(lines)-1]
+	if !strings.HasPrefix(last, "#KSUMMA ") {
+		t.Fatalf("last line is '%s', expected checksum", last)
+	}
+
+	crc := NewChecksum()
+	for _, l := range lines[2 : len(lines)-1] {
+		ChecksumLine(crc, []byte(l))
+	}
+
+	if strconv.FormatUint(uint64(crc.Sum32()), 10) != strings.TrimPrefix(last, "#KSUMMA ") {
+		t.Errorf("checksum %s does not match calculated checksum %d", last, crc.Sum32())
+	}
+}
+
+func TestVerificationWriteUnbalanced(t *testing.T) {
+	v := Verification{
+		VerDatum: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+		VerText:  "Faktura #1",
+		Transactions: []Transaction{
+			{KontoNr: 1510, Belopp: decimal.RequireFromString("69.96")},
+			{KontoNr: 1513, Belopp: decimal.RequireFromString("29.98")},
+			{KontoNr: 3041, Belopp: decimal.RequireFromString("-79.96")},
+			{KontoNr: 2611, Belopp: decimal.RequireFromString("-19.99")},
+		},
+	}
+
+	b := &bytes.Buffer{}
+	if err := v.Write(b); err == nil {
+		t.Errorf("expected an error for an unbalanced verification")
+	}
+	if b.Len() > 0 {
+		t.Errorf("expected nothing to be written, got %s", b.String())
+	}
+
+	// Amounts that balance, but not after being rounded to öre, are also unbalanced in the file
+	v.Transactions = []Transaction{
+		{KontoNr: 1510, Belopp: decimal.RequireFromString("0.005")},
+		{KontoNr: 1513, Belopp: decimal.RequireFromString("0.005")},
+		{KontoNr: 3041, Belopp: decimal.RequireFromString("-0.01")},
+	}
+	if err := v.Write(b); err == nil {
+		t.Errorf("expected an error for a verification that is unbalanced when rounded")
+	}
+
+	v.Transactions = append(v.Transactions, Transaction{KontoNr: 3740, Belopp: decimal.RequireFromString("0.01")})
+	if err := v.Write(b); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAddAccounts(t *testing.T) {
+	s := SIE{Verifications: []Verification{{Transactions: []Transaction{
+		{KontoNr: 1511, Belopp: decimal.NewFromInt(100)},
+		{KontoNr: 3001, Belopp: decimal.NewFromInt(-80)},
+		{KontoNr: 3999, Belopp: decimal.NewFromInt(-20)},
+	}}}}
+	s.AddAccounts(map[int]string{1511: "Kundfordringar"})
+
+	expected := []Account{{1511, "Kundfordringar", ""}, {3001, BASAccounts[3001], ""}, {3999, "Konto 3999", ""}}
+	if len(s.Accounts) != len(expected) {
+		t.Fatalf("got %d accounts, expected %d", len(s.Accounts), len(expected))
+	}
+	for k := range expected {
+		if s.Accounts[k] != expected[k] {
+			t.Errorf("got account %+v, expected %+v", s.Accounts[k], expected[k])
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package sie
+
+import (
+	"fmt"
+)
+
+// ValidationError describes a problem with a verification
+type ValidationError struct {
+	Serie   string
+	VerNr   string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("sie: verification %s%s: %s", e.Serie, e.VerNr, e.Message)
+}
+
+// Validate checks that each verification is balanced, is dated and only uses
+// accounts in the chart of accounts (if the file has one).
+// A list of all problems is returned.
+func (s *SIE) Validate() []error {
+	var errors []error
+
+	accounts := map[int]bool{}
+	for _, a := range s.Accounts {
+		accounts[a.Number] = true
+	}
+
+	for _, v := range s.Verifications {
+		check := func(ok bool, format string, args ...interface{}) {
+			if !ok {
+				errors = append(errors, ValidationError{Serie: v.Serie, VerNr: v.VerNr, Message: fmt.Sprintf(format, args...)})
+			}
+		}
+
+		check(!v.VerDatum.IsZero(), "verifikationsdatum saknas")
+		check(len(v.Transactions) > 0, "verifikationen saknar transaktioner")
+		if sum := v.Sum(); !sum.IsZero() {
+			check(false, "verifikationen är inte balanserad, differens %s", sum.StringFixed(2))
+		}
+
+		if len(accounts) == 0 {
+			continue
+		}
+		for _, t := range v.Transactions {
+			check(accounts[t.KontoNr], "konto %d finns inte i kontoplanen", t.KontoNr)
+		}
+	}
+
+	return errors
+}
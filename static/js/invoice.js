@@ -50,6 +50,8 @@ $(function () {
     $("#invoice-show-add-row").on('click', function (ev) {
         $("#invoice-row-description").val("");
         $("#invoice-row-id").val(0);
+        $("#invoice-row-goods").val("0");
+        $("#invoice-row-account").val("");
         $(".invoice-row-update").hide();
 
         // Only show ROT/RUT info if the invoice accepts it
@@ -81,6 +83,8 @@ $(function () {
         $("#invoice-row-count").val(v.count);
         $("#invoice-row-unit").val(v.unit);
         $("#invoice-row-vat").val(v.vat);
+        $("#invoice-row-goods").val(v.is_goods ? "1" : "0");
+        $("#invoice-row-account").val(v.account ? v.account : "");
 
         $("#invoice-row-rut-rot-service-type").val(v.rot_rut_service_type);
 
@@ -119,12 +123,9 @@ $(function () {
         $("#save-btn").show();
     });
 
-    let vatAmounts = {
-        0: 0.25,
-        1: 0.12,
-        2: 0.06,
-        3: 0
-    }
+    // VAT and ROT/RUT rates in force at the date of the invoice
+    let rates = $("#invoice_rows").data("rates");
+    let vatAmounts = rates.vat;
 
     function update_totals(ev) {
         let totalIncl = 0;
@@ -152,11 +153,11 @@ $(function () {
             }
 
             if (isROTRUT && v.rot_rut_service_type < 7) { // ROT
-                priceInclRUT = v.cost * 0.7;
-                totalROTRUT = totalROTRUT + (v.cost * 0.3 * v.count)
+                priceInclRUT = v.cost * (1 - rates.rot);
+                totalROTRUT = totalROTRUT + (v.cost * rates.rot * v.count)
             } else if (isROTRUT && v.rot_rut_service_type > 6) { // RUT
-                priceInclRUT = v.cost * 0.5;
-                totalROTRUT = totalROTRUT + (v.cost * 0.5 * v.count)
+                priceInclRUT = v.cost * (1 - rates.rut);
+                totalROTRUT = totalROTRUT + (v.cost * rates.rut * v.count)
             }
 
             totalIncl = totalIncl + rowTotal;
@@ -256,6 +257,8 @@ $(function () {
             unit: parseInt($("#invoice-row-unit").val()),
             vat: parseInt($("#invoice-row-vat").val()),
             is_rot_rut: $("#invoice-row-rut-rot").is(":checked"),
+            is_goods: $("#invoice-row-goods").val() === "1",
+            account: parseInt($("#invoice-row-account").val()) || null,
             row_order: document.querySelectorAll("#invoice_rows tbody tr").length,
         };
 
@@ -274,7 +277,7 @@ $(function () {
 
 
         let units = ["-", "st", "timmar", "dagar"];
-        let vat = ["25 %", "12 %", "6 %", "0 %"];
+        let vat = ["25 %", "12 %", "6 %", "0 %", "0 % (omvänd)", "0 % (EU)", "0 % (export)"];
         let countText =  entry.count;
         if (entry.unit > 0) {
             countText += " " + units[entry.unit];
@@ -354,6 +357,17 @@ $(function () {
         $("#save-btn").show();
     });
 
+    $("#attachments").sortable({
+        "stop": function () {
+            let attachmentorder = [];
+            $("#attachments .attachment").each(function () {
+                attachmentorder.push(parseInt($(this).data("id")));
+            });
+            $("input[name=attachmentorder]").val(JSON.stringify(attachmentorder));
+            $("#save-btn").show();
+        }
+    });
+
     $(".attachment-button-remove").click(function () {
         let $att = $(this).closest(".attachment");
         let id = $att.data("id");
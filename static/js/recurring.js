@@ -0,0 +1,24 @@
+'use strict';
+$(function () {
+    $(".datepicker").datepicker({
+        dateFormat: "yy-mm-dd",
+        firstDay: 1,
+        dayNames: [ "Söndag", "Måndag", "Tisdag", "Onsdag", "Torsdag", "Fredag", "Lördag" ],
+        dayNamesMin: [ "Sö", "Må", "Ti", "On", "To", "Fr", "Lö" ],
+        dayNamesShort: [ "Sön", "Mån", "Tis", "Ons", "Tor", "Fre", "Lör" ],
+        monthNames: [ "Januari", "Februari", "Mars", "April", "Maj", "Juni", "Juli", "Augusti", "September", "Oktober", "November", "December" ],
+        monthNamesShort: [ "Jan", "Feb", "Mar", "Apr", "Maj", "Jun", "Jul", "Aug", "Sep", "Okt", "Nov", "Dec" ]
+    });
+
+    $("#recurring-add-row").click(function () {
+        let row = $("#recurring-rows tbody tr:last");
+        let clone = row.clone();
+        clone.find("input[name='row.description[]']").val("");
+        clone.find("input[name='row.cost[]']").val("0");
+        clone.find("input[name='row.count[]']").val("1");
+        clone.find("input[name='row.account[]']").val("");
+        clone.find("input[name='row.rot_rut_hours[]']").val("");
+        clone.find("select").prop("selectedIndex", 0);
+        row.after(clone);
+    });
+});
@@ -10,7 +10,7 @@ $(function () {
         monthNamesShort: [ "Jan", "Feb", "Mar", "Apr", "Maj", "Jun", "Jul", "Aug", "Sep", "Okt", "Nov", "Dec" ]
     });
 
-    $("#invoice_rows input").change(function (){
+    $("#invoice_rows input, #other_rows select").change(function (){
         $("#save-btn").show();
     }).keyup(function (){
         $("#save-btn").show();
@@ -0,0 +1,12 @@
+'use strict';
+$(function () {
+    $(".datepicker").datepicker({
+        dateFormat: "yy-mm-dd",
+        firstDay: 1,
+        dayNames: [ "Söndag", "Måndag", "Tisdag", "Onsdag", "Torsdag", "Fredag", "Lördag" ],
+        dayNamesMin: [ "Sö", "Må", "Ti", "On", "To", "Fr", "Lö" ],
+        dayNamesShort: [ "Sön", "Mån", "Tis", "Ons", "Tor", "Fre", "Lör" ],
+        monthNames: [ "Januari", "Februari", "Mars", "April", "Maj", "Juni", "Juli", "Augusti", "September", "Oktober", "November", "December" ],
+        monthNamesShort: [ "Jan", "Feb", "Mar", "Apr", "Maj", "Jun", "Jul", "Aug", "Sep", "Okt", "Nov", "Dec" ]
+    });
+});
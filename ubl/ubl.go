@@ -0,0 +1,278 @@
+// Package ubl contains the subset of UBL 2.1 needed to create
+// invoices and credit notes according to Peppol BIS Billing 3.0
+package ubl
+
+import (
+	"encoding/xml"
+	"io"
+
+	"github.com/shopspring/decimal"
+)
+
+// Identifiers used for Peppol BIS Billing 3.0
+const (
+	CustomizationID = "urn:cen.eu:en16931:2017#compliant#urn:fdc:peppol.eu:2017:poacc:billing:3.0"
+	ProfileID       = "urn:fdc:peppol.eu:2017:poacc:billing:01:1.0"
+
+	TypeCodeInvoice    = "380"
+	TypeCodeCreditNote = "381"
+
+	PaymentMeansCreditTransfer = "30"
+
+	namespaceInvoice    = "urn:oasis:names:specification:ubl:schema:xsd:Invoice-2"
+	namespaceCreditNote = "urn:oasis:names:specification:ubl:schema:xsd:CreditNote-2"
+	namespaceCAC        = "urn:oasis:names:specification:ubl:schema:xsd:CommonAggregateComponents-2"
+	namespaceCBC        = "urn:oasis:names:specification:ubl:schema:xsd:CommonBasicComponents-2"
+)
+
+// VAT category codes (UNCL5305)
+const (
+	VATCategoryStandard       = "S"
+	VATCategoryZero           = "Z"
+	VATCategoryExempt         = "E"
+	VATCategoryReverseCharge  = "AE"
+	VATCategoryIntraCommunity = "K"
+	VATCategoryExport         = "G"
+)
+
+// Amount is a monetary amount with currency
+type Amount struct {
+	Value      decimal.Decimal `xml:"-"`
+	CurrencyID string          `xml:"currencyID,attr"`
+	Text       string          `xml:",chardata"`
+}
+
+// NewAmount creates a new amount, rounded to two decimals
+func NewAmount(value decimal.Decimal, currency string) Amount {
+	value = value.Round(2)
+	return Amount{Value: value, CurrencyID: currency, Text: value.StringFixed(2)}
+}
+
+// Quantity is a quantity with unit code (UN/ECE Recommendation 20)
+type Quantity struct {
+	Value    decimal.Decimal `xml:"-"`
+	UnitCode string          `xml:"unitCode,attr"`
+	Text     string          `xml:",chardata"`
+}
+
+// NewQuantity creates a new quantity
+func NewQuantity(value decimal.Decimal, unitCode string) Quantity {
+	return Quantity{Value: value, UnitCode: unitCode, Text: value.String()}
+}
+
+// Identifier is an identifier with an optional scheme
+type Identifier struct {
+	SchemeID string `xml:"schemeID,attr,omitempty"`
+	Value    string `xml:",chardata"`
+}
+
+type Address struct {
+	StreetName           string `xml:"cbc:StreetName,omitempty"`
+	AdditionalStreetName string `xml:"cbc:AdditionalStreetName,omitempty"`
+	CityName             string `xml:"cbc:CityName,omitempty"`
+	PostalZone           string `xml:"cbc:PostalZone,omitempty"`
+	CountryCode          string `xml:"cac:Country>cbc:IdentificationCode"`
+}
+
+type TaxScheme struct {
+	ID string `xml:"cbc:ID"`
+}
+
+type PartyTaxScheme struct {
+	CompanyID string    `xml:"cbc:CompanyID"`
+	TaxScheme TaxScheme `xml:"cac:TaxScheme"`
+}
+
+type PartyLegalEntity struct {
+	RegistrationName string      `xml:"cbc:RegistrationName"`
+	CompanyID        *Identifier `xml:"cbc:CompanyID,omitempty"`
+}
+
+type Contact struct {
+	Name      string `xml:"cbc:Name,omitempty"`
+	Telephone string `xml:"cbc:Telephone,omitempty"`
+	Email     string `xml:"cbc:ElectronicMail,omitempty"`
+}
+
+type Party struct {
+	EndpointID       Identifier       `xml:"cbc:EndpointID"`
+	Name             string           `xml:"cac:PartyName>cbc:Name,omitempty"`
+	PostalAddress    Address          `xml:"cac:PostalAddress"`
+	PartyTaxScheme   []PartyTaxScheme `xml:"cac:PartyTaxScheme"`
+	PartyLegalEntity PartyLegalEntity `xml:"cac:PartyLegalEntity"`
+	Contact          *Contact         `xml:"cac:Contact,omitempty"`
+}
+
+type BillingReference struct {
+	ID        string `xml:"cac:InvoiceDocumentReference>cbc:ID"`
+	IssueDate string `xml:"cac:InvoiceDocumentReference>cbc:IssueDate,omitempty"`
+}
+
+// Delivery describes when and where goods or services were delivered
+type Delivery struct {
+	ActualDeliveryDate string `xml:"cbc:ActualDeliveryDate,omitempty"`
+	CountryCode        string `xml:"cac:DeliveryLocation>cac:Address>cac:Country>cbc:IdentificationCode,omitempty"`
+}
+
+type FinancialAccount struct {
+	ID       string `xml:"cbc:ID"`
+	BranchID string `xml:"cac:FinancialInstitutionBranch>cbc:ID,omitempty"`
+}
+
+type PaymentMeans struct {
+	Code         string            `xml:"cbc:PaymentMeansCode"`
+	DueDate      string            `xml:"cbc:PaymentDueDate,omitempty"` // Only used for credit notes
+	PaymentID    string            `xml:"cbc:PaymentID,omitempty"`
+	PayeeAccount *FinancialAccount `xml:"cac:PayeeFinancialAccount,omitempty"`
+}
+
+type TaxCategory struct {
+	ID                  string    `xml:"cbc:ID"`
+	Percent             string    `xml:"cbc:Percent,omitempty"`
+	ExemptionReasonCode string    `xml:"cbc:TaxExemptionReasonCode,omitempty"`
+	ExemptionReason     string    `xml:"cbc:TaxExemptionReason,omitempty"`
+	TaxScheme           TaxScheme `xml:"cac:TaxScheme"`
+}
+
+type TaxSubtotal struct {
+	TaxableAmount Amount      `xml:"cbc:TaxableAmount"`
+	TaxAmount     Amount      `xml:"cbc:TaxAmount"`
+	TaxCategory   TaxCategory `xml:"cac:TaxCategory"`
+}
+
+type TaxTotal struct {
+	TaxAmount    Amount        `xml:"cbc:TaxAmount"`
+	TaxSubtotals []TaxSubtotal `xml:"cac:TaxSubtotal"`
+}
+
+type MonetaryTotal struct {
+	LineExtensionAmount   Amount  `xml:"cbc:LineExtensionAmount"`
+	TaxExclusiveAmount    Amount  `xml:"cbc:TaxExclusiveAmount"`
+	TaxInclusiveAmount    Amount  `xml:"cbc:TaxInclusiveAmount"`
+	PrepaidAmount         *Amount `xml:"cbc:PrepaidAmount,omitempty"`
+	PayableRoundingAmount *Amount `xml:"cbc:PayableRoundingAmount,omitempty"`
+	PayableAmount         Amount  `xml:"cbc:PayableAmount"`
+}
+
+type Item struct {
+	Name        string      `xml:"cbc:Name"`
+	TaxCategory TaxCategory `xml:"cac:ClassifiedTaxCategory"`
+}
+
+type Price struct {
+	PriceAmount Amount `xml:"cbc:PriceAmount"`
+}
+
+// Line is an invoice line (cac:InvoiceLine) or a credit note line (cac:CreditNoteLine)
+type Line struct {
+	ID                  string    `xml:"cbc:ID"`
+	InvoicedQuantity    *Quantity `xml:"cbc:InvoicedQuantity,omitempty"`
+	CreditedQuantity    *Quantity `xml:"cbc:CreditedQuantity,omitempty"`
+	LineExtensionAmount Amount    `xml:"cbc:LineExtensionAmount"`
+	Item                Item      `xml:"cac:Item"`
+	Price               Price     `xml:"cac:Price"`
+}
+
+// Quantity returns the invoiced or credited quantity of the line
+func (l *Line) Quantity() *Quantity {
+	if l.CreditedQuantity != nil {
+		return l.CreditedQuantity
+	}
+	return l.InvoicedQuantity
+}
+
+// Invoice describes a Peppol BIS Billing 3.0 invoice or credit note.
+// The same structure is used for both, since the only differences are the
+// names of the root element, the type code, the lines and the due date.
+type Invoice struct {
+	XMLName  xml.Name
+	XMLNs    string `xml:"xmlns,attr"`
+	XMLNsCAC string `xml:"xmlns:cac,attr"`
+	XMLNsCBC string `xml:"xmlns:cbc,attr"`
+
+	CustomizationID         string            `xml:"cbc:CustomizationID"`
+	ProfileID               string            `xml:"cbc:ProfileID"`
+	ID                      string            `xml:"cbc:ID"`
+	IssueDate               string            `xml:"cbc:IssueDate"`
+	DueDate                 string            `xml:"cbc:DueDate,omitempty"`
+	InvoiceTypeCode         string            `xml:"cbc:InvoiceTypeCode,omitempty"`
+	CreditNoteTypeCode      string            `xml:"cbc:CreditNoteTypeCode,omitempty"`
+	Note                    string            `xml:"cbc:Note,omitempty"`
+	DocumentCurrencyCode    string            `xml:"cbc:DocumentCurrencyCode"`
+	BuyerReference          string            `xml:"cbc:BuyerReference,omitempty"`
+	BillingReference        *BillingReference `xml:"cac:BillingReference,omitempty"`
+	AccountingSupplierParty Party             `xml:"cac:AccountingSupplierParty>cac:Party"`
+	AccountingCustomerParty Party             `xml:"cac:AccountingCustomerParty>cac:Party"`
+	Delivery                *Delivery         `xml:"cac:Delivery,omitempty"`
+	PaymentMeans            *PaymentMeans     `xml:"cac:PaymentMeans,omitempty"`
+	PaymentTerms            string            `xml:"cac:PaymentTerms>cbc:Note,omitempty"`
+	TaxTotal                TaxTotal          `xml:"cac:TaxTotal"`
+	LegalMonetaryTotal      MonetaryTotal     `xml:"cac:LegalMonetaryTotal"`
+	InvoiceLines            []Line            `xml:"cac:InvoiceLine"`
+	CreditNoteLines         []Line            `xml:"cac:CreditNoteLine"`
+}
+
+// NewInvoice creates a new invoice with the Peppol BIS Billing 3.0 identifiers set
+func NewInvoice() *Invoice {
+	return &Invoice{
+		XMLName:         xml.Name{Local: "Invoice"},
+		XMLNs:           namespaceInvoice,
+		XMLNsCAC:        namespaceCAC,
+		XMLNsCBC:        namespaceCBC,
+		CustomizationID: CustomizationID,
+		ProfileID:       ProfileID,
+		InvoiceTypeCode: TypeCodeInvoice,
+	}
+}
+
+// IsCreditNote returns true if the document is a credit note
+func (inv *Invoice) IsCreditNote() bool {
+	return inv.XMLName.Local == "CreditNote"
+}
+
+// Lines returns the invoice or credit note lines of the document
+func (inv *Invoice) Lines() []Line {
+	if inv.IsCreditNote() {
+		return inv.CreditNoteLines
+	}
+	return inv.InvoiceLines
+}
+
+// AddLine adds an invoice line to the document
+func (inv *Invoice) AddLine(l Line) {
+	inv.InvoiceLines = append(inv.InvoiceLines, l)
+}
+
+// ConvertToCreditNote changes the document into a credit note.
+// The amounts of a credit note are expected to be positive
+func (inv *Invoice) ConvertToCreditNote() {
+	inv.XMLName.Local = "CreditNote"
+	inv.XMLNs = namespaceCreditNote
+	inv.InvoiceTypeCode = ""
+	inv.CreditNoteTypeCode = TypeCodeCreditNote
+
+	// Credit notes does not have a due date on document level
+	if inv.PaymentMeans != nil {
+		inv.PaymentMeans.DueDate = inv.DueDate
+	}
+	inv.DueDate = ""
+
+	for _, l := range inv.InvoiceLines {
+		l.CreditedQuantity = l.InvoicedQuantity
+		l.InvoicedQuantity = nil
+		inv.CreditNoteLines = append(inv.CreditNoteLines, l)
+	}
+	inv.InvoiceLines = nil
+}
+
+// Write writes the document as XML to w
+func (inv *Invoice) Write(w io.Writer) error {
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(inv)
+}
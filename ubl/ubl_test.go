@@ -0,0 +1,140 @@
+package ubl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func testInvoice() *Invoice {
+	amount := func(s string) Amount {
+		return NewAmount(decimal.RequireFromString(s), "SEK")
+	}
+	quantity := NewQuantity(decimal.NewFromInt(2), "C62")
+	standard := TaxCategory{ID: VATCategoryStandard, Percent: "25.00", TaxScheme: TaxScheme{ID: "VAT"}}
+
+	inv := NewInvoice()
+	inv.ID = "1001"
+	inv.IssueDate = "2024-12-15"
+	inv.DueDate = "2025-01-14"
+	inv.DocumentCurrencyCode = "SEK"
+	inv.BuyerReference = "Anna"
+	inv.AccountingSupplierParty = Party{
+		EndpointID:       Identifier{SchemeID: "0007", Value: "5560360793"},
+		PostalAddress:    Address{CountryCode: "SE"},
+		PartyTaxScheme:   []PartyTaxScheme{{CompanyID: "SE556036079301", TaxScheme: TaxScheme{ID: "VAT"}}},
+		PartyLegalEntity: PartyLegalEntity{RegistrationName: "Säljaren AB"},
+	}
+	inv.AccountingCustomerParty = Party{
+		EndpointID:       Identifier{SchemeID: "0007", Value: "5561234567"},
+		PostalAddress:    Address{CountryCode: "SE"},
+		PartyLegalEntity: PartyLegalEntity{RegistrationName: "Köparen AB"},
+	}
+	inv.PaymentMeans = &PaymentMeans{Code: PaymentMeansCreditTransfer, PayeeAccount: &FinancialAccount{ID: "1234567"}}
+	inv.AddLine(Line{
+		ID:                  "1",
+		InvoicedQuantity:    &quantity,
+		LineExtensionAmount: amount("100.01"),
+		Item:                Item{Name: "Konsulttimme", TaxCategory: standard},
+		Price:               Price{PriceAmount: amount("50.005")},
+	})
+	inv.TaxTotal = TaxTotal{
+		TaxAmount:    amount("25.00"),
+		TaxSubtotals: []TaxSubtotal{{TaxableAmount: amount("100.01"), TaxAmount: amount("25.00"), TaxCategory: standard}},
+	}
+
+	// 125.01 is rounded to 125.00
+	rounding := amount("-0.01")
+	inv.LegalMonetaryTotal = MonetaryTotal{
+		LineExtensionAmount:   amount("100.01"),
+		TaxExclusiveAmount:    amount("100.01"),
+		TaxInclusiveAmount:    amount("125.01"),
+		PayableRoundingAmount: &rounding,
+		PayableAmount:         amount("125.00"),
+	}
+	return inv
+}
+
+// rules returns the rules broken by the errors
+func rules(errs []error) []string {
+	var lst []string
+	for _, err := range errs {
+		lst = append(lst, err.(ValidationError).Rule)
+	}
+	return lst
+}
+
+func TestValidate(t *testing.T) {
+	if errs := testInvoice().Validate(); len(errs) > 0 {
+		t.Fatalf("expected a valid invoice, got %v", errs)
+	}
+
+	tests := []struct {
+		rule   string
+		modify func(inv *Invoice)
+	}{
+		{"BR-CO-16", func(inv *Invoice) { inv.LegalMonetaryTotal.PayableRoundingAmount = nil }},
+		{"BR-CO-15", func(inv *Invoice) {
+			inv.LegalMonetaryTotal.TaxInclusiveAmount = NewAmount(decimal.NewFromInt(125), "SEK")
+		}},
+		{"BR-CO-17", func(inv *Invoice) { inv.TaxTotal.TaxSubtotals[0].TaxAmount = NewAmount(decimal.NewFromInt(20), "SEK") }},
+		{"BR-11", func(inv *Invoice) { inv.AccountingCustomerParty.PostalAddress.CountryCode = "" }},
+		{"PEPPOL-EN16931-R010", func(inv *Invoice) { inv.AccountingCustomerParty.EndpointID = Identifier{} }},
+		{"BR-S-02", func(inv *Invoice) { inv.AccountingSupplierParty.PartyTaxScheme = nil }},
+		{"BR-CO-25", func(inv *Invoice) { inv.DueDate = "" }},
+		{"PEPPOL-EN16931-R080", func(inv *Invoice) { inv.ConvertToCreditNote() }},
+		{"BR-IC-12", func(inv *Invoice) {
+			category := TaxCategory{ID: VATCategoryIntraCommunity, Percent: "0.00", ExemptionReasonCode: "VATEX-EU-IC", TaxScheme: TaxScheme{ID: "VAT"}}
+			inv.InvoiceLines[0].Item.TaxCategory = category
+			inv.TaxTotal.TaxSubtotals[0].TaxCategory = category
+			inv.Delivery = &Delivery{ActualDeliveryDate: "2024-12-15"}
+		}},
+	}
+
+	for _, test := range tests {
+		inv := testInvoice()
+		test.modify(inv)
+		found := false
+		for _, rule := range rules(inv.Validate()) {
+			found = found || rule == test.rule
+		}
+		if !found {
+			t.Errorf("expected %s to be broken, got %v", test.rule, rules(inv.Validate()))
+		}
+	}
+}
+
+func TestConvertToCreditNote(t *testing.T) {
+	inv := testInvoice()
+	inv.BillingReference = &BillingReference{ID: "1000"}
+	inv.ConvertToCreditNote()
+
+	if !inv.IsCreditNote() || inv.CreditNoteTypeCode != TypeCodeCreditNote || inv.InvoiceTypeCode != "" {
+		t.Errorf("expected a credit note, got %s with type code %s", inv.XMLName.Local, inv.CreditNoteTypeCode)
+	}
+	if inv.DueDate != "" || inv.PaymentMeans.DueDate != "2025-01-14" {
+		t.Errorf("expected the due date to be moved to the payment means")
+	}
+	if len(inv.InvoiceLines) != 0 || len(inv.CreditNoteLines) != 1 {
+		t.Fatalf("expected the lines to be converted to credit note lines")
+	}
+	if l := inv.CreditNoteLines[0]; l.InvoicedQuantity != nil || l.CreditedQuantity == nil || l.CreditedQuantity.Text != "2" {
+		t.Errorf("expected a credited quantity, got %+v", l)
+	}
+	if errs := inv.Validate(); len(errs) > 0 {
+		t.Errorf("expected a valid credit note, got %v", errs)
+	}
+
+	buf := &bytes.Buffer{}
+	err := inv.Write(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{`<CreditNote xmlns="` + namespaceCreditNote + `"`, "<cbc:CreditNoteTypeCode>381</cbc:CreditNoteTypeCode>", `<cbc:CreditedQuantity unitCode="C62">2</cbc:CreditedQuantity>`} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("expected the document to contain %s", s)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package ubl
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ValidationError describes a broken business rule
+type ValidationError struct {
+	Rule    string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("[%s] %s", e.Rule, e.Message)
+}
+
+type validator struct {
+	errors []error
+}
+
+func (v *validator) check(ok bool, rule string, format string, args ...interface{}) {
+	if !ok {
+		v.errors = append(v.errors, ValidationError{Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+}
+
+func isDate(s string) bool {
+	_, err := time.Parse("2006-01-02", s)
+	return err == nil
+}
+
+// Validate checks the mandatory business rules of Peppol BIS Billing 3.0 (EN 16931)
+// that can be broken by the data we put in the document.
+// A list of all broken rules is returned.
+func (inv *Invoice) Validate() []error {
+	v := &validator{}
+	seller := inv.AccountingSupplierParty
+	buyer := inv.AccountingCustomerParty
+
+	v.check(inv.CustomizationID != "", "BR-01", "Specifikationsidentifierare saknas")
+	v.check(inv.ID != "", "BR-02", "Fakturanummer saknas")
+	v.check(isDate(inv.IssueDate), "BR-03", "Fakturadatum saknas eller är felaktigt")
+	v.check(inv.InvoiceTypeCode != "" || inv.CreditNoteTypeCode != "", "BR-04", "Fakturatyp saknas")
+	v.check(inv.DocumentCurrencyCode != "", "BR-05", "Valuta saknas")
+	v.check(seller.PartyLegalEntity.RegistrationName != "", "BR-06", "Säljarens namn saknas")
+	v.check(buyer.PartyLegalEntity.RegistrationName != "", "BR-07", "Köparens namn saknas")
+	v.check(seller.PostalAddress.CountryCode != "", "BR-09", "Säljarens landskod saknas")
+	v.check(buyer.PostalAddress.CountryCode != "", "BR-11", "Köparens landskod saknas")
+	v.check(inv.BuyerReference != "", "PEPPOL-EN16931-R003", "Köparens referens saknas (anges som 'Er referens' på kunden)")
+	v.check(seller.EndpointID.Value != "" && seller.EndpointID.SchemeID != "", "PEPPOL-EN16931-R020", "Säljarens elektroniska adress saknas (organisationsnummer)")
+	v.check(buyer.EndpointID.Value != "" && buyer.EndpointID.SchemeID != "", "PEPPOL-EN16931-R010", "Köparens elektroniska adress saknas (Peppol-ID på kunden)")
+
+	if inv.IsCreditNote() {
+		v.check(inv.BillingReference != nil && inv.BillingReference.ID != "", "PEPPOL-EN16931-R080", "Kreditfakturan saknar referens till den krediterade fakturan")
+	}
+
+	if inv.PaymentMeans != nil && inv.PaymentMeans.Code == PaymentMeansCreditTransfer {
+		v.check(inv.PaymentMeans.PayeeAccount != nil && inv.PaymentMeans.PayeeAccount.ID != "", "BR-61", "Kontonummer för betalning saknas")
+	}
+
+	if inv.DueDate != "" {
+		v.check(isDate(inv.DueDate), "BR-03", "Förfallodatum är felaktigt")
+	}
+
+	lines := inv.Lines()
+	v.check(len(lines) > 0, "BR-16", "Fakturan saknar rader")
+
+	lineSum := decimal.Zero
+	for k, l := range lines {
+		n := k + 1
+		v.check(l.ID != "", "BR-21", "Rad %d saknar identifierare", n)
+		q := l.Quantity()
+		v.check(q != nil, "BR-22", "Rad %d saknar antal", n)
+		if q != nil {
+			v.check(q.UnitCode != "", "BR-23", "Rad %d saknar enhet", n)
+		}
+		v.check(l.Item.Name != "", "BR-25", "Rad %d saknar beskrivning", n)
+		v.check(!l.Price.PriceAmount.Value.IsNegative(), "BR-27", "Rad %d har ett negativt pris", n)
+		v.check(l.Item.TaxCategory.ID != "", "BR-CO-04", "Rad %d saknar momskategori", n)
+
+		if l.Item.TaxCategory.ID == VATCategoryStandard {
+			rate, _ := decimal.NewFromString(l.Item.TaxCategory.Percent)
+			v.check(rate.IsPositive(), "BR-S-05", "Rad %d har momskategori S men saknar momssats", n)
+		}
+		lineSum = lineSum.Add(l.LineExtensionAmount.Value)
+	}
+
+	requiresSellerVAT := false
+	requiresBuyerVAT := false
+	taxSum := decimal.Zero
+	for _, st := range inv.TaxTotal.TaxSubtotals {
+		cat := st.TaxCategory
+		rate, _ := decimal.NewFromString(cat.Percent)
+		expected := st.TaxableAmount.Value.Mul(rate).Div(decimal.NewFromInt(100)).Round(2)
+		v.check(st.TaxAmount.Value.Equal(expected), "BR-CO-17", "Momsbeloppet för kategori %s (%s %%) är felaktigt", cat.ID, cat.Percent)
+
+		switch cat.ID {
+		case VATCategoryStandard:
+			requiresSellerVAT = true
+		case VATCategoryExempt:
+			v.check(cat.ExemptionReason != "" || cat.ExemptionReasonCode != "", "BR-E-10", "Skäl för momsbefrielse saknas")
+		case VATCategoryReverseCharge:
+			requiresBuyerVAT = true
+			requiresSellerVAT = true
+			v.check(cat.ExemptionReason != "" || cat.ExemptionReasonCode != "", "BR-AE-10", "Skäl för omvänd betalningsskyldighet saknas")
+			v.check(st.TaxAmount.Value.IsZero(), "BR-AE-09", "Moms får inte debiteras vid omvänd betalningsskyldighet")
+		case VATCategoryIntraCommunity:
+			requiresBuyerVAT = true
+			requiresSellerVAT = true
+			v.check(cat.ExemptionReason != "" || cat.ExemptionReasonCode != "", "BR-IC-10", "Skäl för momsbefrielse vid unionsintern leverans saknas")
+			v.check(st.TaxAmount.Value.IsZero(), "BR-IC-09", "Moms får inte debiteras vid unionsintern leverans")
+			v.check(inv.Delivery != nil && isDate(inv.Delivery.ActualDeliveryDate), "BR-IC-11", "Leveransdatum saknas vid unionsintern leverans")
+			v.check(inv.Delivery != nil && inv.Delivery.CountryCode != "", "BR-IC-12", "Leveransland saknas vid unionsintern leverans")
+		case VATCategoryExport:
+			requiresSellerVAT = true
+			v.check(cat.ExemptionReason != "" || cat.ExemptionReasonCode != "", "BR-G-10", "Skäl för momsbefrielse vid export saknas")
+			v.check(st.TaxAmount.Value.IsZero(), "BR-G-09", "Moms får inte debiteras vid export")
+		}
+		taxSum = taxSum.Add(st.TaxAmount.Value)
+	}
+
+	if requiresSellerVAT {
+		hasVATNumber := false
+		for _, pts := range seller.PartyTaxScheme {
+			if pts.TaxScheme.ID == "VAT" && pts.CompanyID != "" {
+				hasVATNumber = true
+			}
+		}
+		v.check(hasVATNumber, "BR-S-02", "Säljarens momsregistreringsnummer saknas")
+	}
+
+	if requiresBuyerVAT {
+		hasVATNumber := false
+		for _, pts := range buyer.PartyTaxScheme {
+			if pts.TaxScheme.ID == "VAT" && pts.CompanyID != "" {
+				hasVATNumber = true
+			}
+		}
+		v.check(hasVATNumber, "BR-AE-02", "Köparens momsregistreringsnummer saknas")
+	}
+
+	totals := inv.LegalMonetaryTotal
+	v.check(inv.TaxTotal.TaxAmount.Value.Equal(taxSum), "BR-CO-14", "Total moms stämmer inte med summan av momsen per kategori")
+	v.check(totals.LineExtensionAmount.Value.Equal(lineSum), "BR-CO-10", "Summan av raderna stämmer inte med fakturans nettobelopp")
+	v.check(totals.TaxExclusiveAmount.Value.Equal(lineSum), "BR-CO-13", "Belopp exklusive moms stämmer inte med summan av raderna")
+	v.check(totals.TaxInclusiveAmount.Value.Equal(totals.TaxExclusiveAmount.Value.Add(taxSum)), "BR-CO-15", "Belopp inklusive moms stämmer inte")
+
+	payable := totals.TaxInclusiveAmount.Value
+	if totals.PrepaidAmount != nil {
+		payable = payable.Sub(totals.PrepaidAmount.Value)
+	}
+	if totals.PayableRoundingAmount != nil {
+		payable = payable.Add(totals.PayableRoundingAmount.Value)
+	}
+	v.check(totals.PayableAmount.Value.Equal(payable), "BR-CO-16", "Belopp att betala stämmer inte")
+
+	if totals.PayableAmount.Value.IsPositive() {
+		hasDueDate := inv.DueDate != "" || (inv.PaymentMeans != nil && inv.PaymentMeans.DueDate != "")
+		v.check(hasDueDate || inv.PaymentTerms != "", "BR-CO-25", "Förfallodatum eller betalningsvillkor måste anges när beloppet att betala är positivt")
+	}
+
+	return v.errors
+}
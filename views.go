@@ -14,6 +14,7 @@ import (
 	"github.com/yzzyx/faktura-pdf/views/customer"
 	"github.com/yzzyx/faktura-pdf/views/invoice"
 	"github.com/yzzyx/faktura-pdf/views/login"
+	"github.com/yzzyx/faktura-pdf/views/recurring"
 	"github.com/yzzyx/faktura-pdf/views/register"
 	"github.com/yzzyx/faktura-pdf/views/rut"
 	"github.com/yzzyx/faktura-pdf/views/start"
@@ -44,19 +45,36 @@ var routes = []routeInfo{
 	{URL: "company-view", Path: "/company/{id}", View: company.NewView(), RequireLogin: true},
 	{URL: "company-select", Path: "/company/{id}/select", View: company.NewSelect(), RequireLogin: true},
 	{URL: "rut-list", Path: "/rut", View: rut.NewList(), Methods: MethodGET, RequireLogin: true, RequireCompany: true},
+	{URL: "rut-batch-export", Path: "/rut/export", View: rut.NewBatchExport(), Methods: MethodPOST, RequireLogin: true, RequireCompany: true},
+	{URL: "rut-decision", Path: "/rut/decision", View: rut.NewDecision(), RequireLogin: true, RequireCompany: true},
+	{URL: "rut-sie", Path: "/rut/sie", View: invoice.NewSIEPayout(), Methods: MethodGET, RequireLogin: true, RequireCompany: true},
 	{URL: "rut-view", Path: "/rut/{id}", View: rut.NewView(), RequireLogin: true, RequireCompany: true},
 	{URL: "rut-flag", Path: "/rut/{id}/flag", View: rut.NewFlag(), RequireLogin: true, Methods: MethodPOST, RequireCompany: true},
 	{URL: "rut-export", Path: "/rut/{id}/export", View: rut.NewExport(), RequireLogin: true, RequireCompany: true},
 
 	{URL: "invoice-list", Path: "/invoice", View: invoice.NewList(false), Methods: MethodGET, RequireLogin: true, RequireCompany: true},
+	{URL: "invoice-bgmax", Path: "/invoice/bgmax", View: invoice.NewBgMax(), RequireLogin: true, RequireCompany: true},
+	{URL: "invoice-sie-period", Path: "/invoice/sie", View: invoice.NewSIEPeriod(), Methods: MethodGET, RequireLogin: true, RequireCompany: true},
+	{URL: "invoice-vat-report", Path: "/invoice/vat", View: invoice.NewVATReport(), Methods: MethodGET, RequireLogin: true, RequireCompany: true},
+	{URL: "reference-rates", Path: "/invoice/reference-rates", View: invoice.NewReferenceRates(), RequireLogin: true, RequireCompany: true},
 	{URL: "invoice-view", Path: "/invoice/{id}", View: invoice.NewView(false), RequireLogin: true, RequireCompany: true},
 	{URL: "invoice-view-offer", Path: "/invoice/{id}/offer", View: invoice.NewOfferPDF(), Methods: MethodGET, RequireLogin: true, RequireCompany: true},
 	{URL: "invoice-view-invoice", Path: "/invoice/{id}/invoice", View: invoice.NewInvoicePDF(), Methods: MethodGET, RequireLogin: true, RequireCompany: true},
 	{URL: "invoice-set-flag", Path: "/invoice/{id}/flag", View: invoice.NewFlag(false), RequireLogin: true, RequireCompany: true},
 	{URL: "invoice-sie", Path: "/invoice/{id}/sie", View: invoice.NewSIE(), Methods: MethodGET, RequireLogin: true, RequireCompany: true},
+	{URL: "invoice-peppol", Path: "/invoice/{id}/peppol", View: invoice.NewPeppol(), Methods: MethodGET, RequireLogin: true, RequireCompany: true},
+	{URL: "invoice-payment", Path: "/invoice/{id}/payment", View: invoice.NewPayment(), Methods: MethodPOST, RequireLogin: true, RequireCompany: true},
+	{URL: "invoice-buyer", Path: "/invoice/{id}/buyer", View: invoice.NewBuyer(), Methods: MethodPOST, RequireLogin: true, RequireCompany: true},
+	{URL: "invoice-deduction", Path: "/invoice/{id}/deduction", View: invoice.NewDeduction(), Methods: MethodPOST, RequireLogin: true, RequireCompany: true},
+	{URL: "invoice-reminder", Path: "/invoice/{id}/reminder", View: invoice.NewReminder(), Methods: MethodPOST, RequireLogin: true, RequireCompany: true},
+	{URL: "invoice-reminder-pdf", Path: "/invoice/{id}/reminder/{reminder}", View: invoice.NewReminder(), Methods: MethodGET, RequireLogin: true, RequireCompany: true},
+	{URL: "invoice-credit", Path: "/invoice/{id}/credit", View: invoice.NewCredit(), Methods: MethodPOST, RequireLogin: true, RequireCompany: true},
 	{URL: "invoice-attachment", Path: "/invoice/{id}/attachment/{attachment}", View: invoice.NewAttachment(false), Methods: MethodGET, RequireLogin: true, RequireCompany: true},
 	{URL: "invoice-attachment-add", Path: "/invoice/{id}/attachment", View: invoice.NewAttachment(false), Methods: MethodPOST, RequireLogin: true, RequireCompany: true},
 
+	{URL: "recurring-list", Path: "/recurring", View: recurring.NewList(), Methods: MethodGET, RequireLogin: true, RequireCompany: true},
+	{URL: "recurring-view", Path: "/recurring/{id}", View: recurring.NewView(), RequireLogin: true, RequireCompany: true},
+
 	{URL: "customer-list", Path: "/customer", View: customer.NewList(), Methods: MethodGET, RequireLogin: true, RequireCompany: true},
 	{URL: "offer-list", Path: "/offer", View: invoice.NewList(true), Methods: MethodGET, RequireLogin: true, RequireCompany: true},
 	{URL: "offer-view", Path: "/offer/{id}", View: invoice.NewView(true), RequireLogin: true, RequireCompany: true},
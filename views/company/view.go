@@ -3,8 +3,11 @@ package company
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/shopspring/decimal"
+	"github.com/yzzyx/faktura-pdf/cii"
 	"github.com/yzzyx/faktura-pdf/models"
 	"github.com/yzzyx/faktura-pdf/views"
 )
@@ -19,6 +22,47 @@ func NewView() *View {
 	return &View{}
 }
 
+// accountField is an account in the account map of the company, that can be edited in the company view
+type accountField struct {
+	Name    string
+	Field   string
+	Account *int
+	Value   int
+}
+
+// accountFields returns the editable accounts of the account map
+func accountFields(m *models.AccountMap) []accountField {
+	fields := []accountField{
+		{Name: "Kundfordringar", Field: "account.receivable", Account: &m.Receivable},
+		{Name: "Kundfordringar ROT/RUT", Field: "account.rotrutreceivable", Account: &m.RotRutReceivable},
+		{Name: "Bank", Field: "account.bank", Account: &m.Bank},
+		{Name: "Öresutjämning", Field: "account.rounding", Account: &m.Rounding},
+		{Name: "Påminnelseavgifter", Field: "account.reminderfees", Account: &m.ReminderFees},
+		{Name: "Dröjsmålsränta", Field: "account.lateinterest", Account: &m.LateInterest},
+		{Name: "Utgående moms 25 %", Field: "account.outputvat25", Account: &m.OutputVAT25},
+		{Name: "Utgående moms 12 %", Field: "account.outputvat12", Account: &m.OutputVAT12},
+		{Name: "Utgående moms 6 %", Field: "account.outputvat6", Account: &m.OutputVAT6},
+		{Name: "Försäljning varor 25 %", Field: "account.goods25", Account: &m.Goods25},
+		{Name: "Försäljning varor 12 %", Field: "account.goods12", Account: &m.Goods12},
+		{Name: "Försäljning varor 6 %", Field: "account.goods6", Account: &m.Goods6},
+		{Name: "Försäljning varor momsfri", Field: "account.goodsexempt", Account: &m.GoodsExempt},
+		{Name: "Försäljning tjänster 25 %", Field: "account.services25", Account: &m.Services25},
+		{Name: "Försäljning tjänster 12 %", Field: "account.services12", Account: &m.Services12},
+		{Name: "Försäljning tjänster 6 %", Field: "account.services6", Account: &m.Services6},
+		{Name: "Försäljning tjänster momsfri", Field: "account.servicesexempt", Account: &m.ServicesExempt},
+		{Name: "Omvänd skattskyldighet (bygg)", Field: "account.reversecharge", Account: &m.ReverseCharge},
+		{Name: "Försäljning varor inom EU", Field: "account.eugoods", Account: &m.EUGoods},
+		{Name: "Försäljning tjänster inom EU", Field: "account.euservices", Account: &m.EUServices},
+		{Name: "Export varor", Field: "account.exportgoods", Account: &m.ExportGoods},
+		{Name: "Export tjänster", Field: "account.exportservices", Account: &m.ExportServices},
+	}
+
+	for k := range fields {
+		fields[k].Value = *fields[k].Account
+	}
+	return fields
+}
+
 // HandleGet displays an invoice
 func (v *View) HandleGet() error {
 	var err error
@@ -29,15 +73,26 @@ func (v *View) HandleGet() error {
 	company.InvoiceNumber = 1000
 	company.PaymentType = models.PaymentTypeBG
 	company.InvoiceDueDays = 30
+	company.ReminderFee = models.MaxReminderFee
 
+	accounts := models.DefaultAccountMap
 	if id > 0 {
 		company, err = models.CompanyGet(v.Ctx, models.CompanyFilter{ID: id, UserID: v.Session.User.ID})
 		if err != nil {
 			return err
 		}
+
+		accounts, err = models.AccountMapGet(v.Ctx, company.ID)
+		if err != nil {
+			return err
+		}
 	}
 
 	v.SetData("c", company)
+	v.SetData("accountFields", accountFields(&accounts))
+	v.SetData("accountingMethods", models.AccountingMethods)
+	v.SetData("pdfRenderers", models.PDFRenderers)
+	v.SetData("facturXProfiles", cii.Profiles)
 
 	// Used to create list of ROT/RUT services in invoice row modal
 	v.SetData("rutServices", models.RUTServices)
@@ -78,11 +133,18 @@ func (v *View) HandlePost() error {
 		"paymentaccount": &company.PaymentAccount,
 		"paymenttype":    &company.PaymentType,
 		"vatnumber":      &company.VATNumber,
+		"ocrlevel":       &company.OCRLevel,
+		"ocrlengthdigit": &company.OCRLengthDigit,
+
+		"accountingmethod": &company.AccountingMethod,
 
 		"invoicenumber":    &company.InvoiceNumber,
 		"invoiceduedays":   &company.InvoiceDueDays,
 		"invoicereference": &company.InvoiceReference,
 		"invoicetext":      &company.InvoiceText,
+		"pdfrenderer":      &company.PDFRenderer,
+		"facturxprofile":   &company.FacturXProfile,
+		"reminderfee":      &company.ReminderFee,
 	}
 
 	for formName, field := range fields {
@@ -95,6 +157,33 @@ func (v *View) HandlePost() error {
 			*f = v.FormValueInt(formName)
 		case *models.PaymentType:
 			*f = models.PaymentType(v.FormValueInt(formName))
+		case *models.OCRLevel:
+			*f = models.OCRLevel(v.FormValueInt(formName))
+			if !f.Validate() {
+				return views.ErrBadRequest
+			}
+		case *models.AccountingMethod:
+			*f = models.AccountingMethod(v.FormValueInt(formName))
+			if !f.Validate() {
+				return views.ErrBadRequest
+			}
+		case *models.PDFRenderer:
+			*f = models.PDFRenderer(v.FormValueInt(formName))
+			if !f.Validate() {
+				return views.ErrBadRequest
+			}
+		case *cii.Profile:
+			*f = cii.Profile(v.FormValueInt(formName))
+			if !f.Validate() {
+				return views.ErrBadRequest
+			}
+		case *bool:
+			*f = v.FormValueBool(formName)
+		case *decimal.Decimal:
+			*f, err = decimal.NewFromString(strings.ReplaceAll(v.FormValueString(formName), ",", "."))
+			if err != nil {
+				return err
+			}
 		case *string:
 			*f = v.FormValueString(formName)
 		case **time.Time:
@@ -111,6 +200,10 @@ func (v *View) HandlePost() error {
 		updated = true
 	}
 
+	if company.ReminderFee.IsNegative() || company.ReminderFee.GreaterThan(models.MaxReminderFee) {
+		return fmt.Errorf("reminder fee must be between 0 and %s", models.MaxReminderFee)
+	}
+
 	isNewCompany := company.ID == 0
 
 	if updated {
@@ -120,6 +213,11 @@ func (v *View) HandlePost() error {
 		}
 	}
 
+	err = v.updateAccounts(company)
+	if err != nil {
+		return err
+	}
+
 	if isNewCompany {
 		err = company.AddUser(v.Ctx, v.Session.User)
 		if err != nil {
@@ -132,3 +230,35 @@ func (v *View) HandlePost() error {
 
 	return v.RedirectRoute("company-view", "id", strconv.Itoa(company.ID))
 }
+
+// updateAccounts saves the account map of the company, if any accounts were changed
+func (v *View) updateAccounts(company models.Company) error {
+	if company.ID == 0 {
+		return nil
+	}
+
+	accounts, err := models.AccountMapGet(v.Ctx, company.ID)
+	if err != nil {
+		return err
+	}
+
+	updated := false
+	for _, f := range accountFields(&accounts) {
+		if !v.FormValueExists(f.Field) {
+			continue
+		}
+
+		nr, err := strconv.Atoi(v.FormValueString(f.Field))
+		if err != nil || nr < 1000 || nr > 9999 {
+			return views.ErrBadRequest
+		}
+
+		*f.Account = nr
+		updated = true
+	}
+
+	if !updated {
+		return nil
+	}
+	return models.AccountMapSave(v.Ctx, accounts)
+}
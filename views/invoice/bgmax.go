@@ -0,0 +1,280 @@
+package invoice
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/yzzyx/faktura-pdf/bgmax"
+	"github.com/yzzyx/faktura-pdf/models"
+	"github.com/yzzyx/faktura-pdf/views"
+	"github.com/yzzyx/zerr"
+)
+
+// Statuses of a matched payment
+const (
+	MatchStatusUnmatched  = iota // No invoice found
+	MatchStatusPartial           // Invoice found, but the amount differs
+	MatchStatusMatched           // Invoice found, and the amount is correct
+	MatchStatusRegistered        // Payment has already been registered
+)
+
+// PaymentMatch describes a payment in a BgMax file, and the invoices it is matched to
+type PaymentMatch struct {
+	Payment  bgmax.Payment
+	Invoices []models.Invoice
+	Due      decimal.Decimal // Total outstanding amount for the matched invoices
+	Status   int
+}
+
+// InvoiceNumbers returns the numbers of the matched invoices as a comma separated list
+func (m PaymentMatch) InvoiceNumbers() string {
+	numbers := make([]string, len(m.Invoices))
+	for k, inv := range m.Invoices {
+		numbers[k] = strconv.Itoa(inv.Number)
+	}
+	return strings.Join(numbers, ", ")
+}
+
+// BgMax is the view-handler for importing payments from BgMax files
+type BgMax struct {
+	views.View
+}
+
+// NewBgMax creates a new handler for importing BgMax files
+func NewBgMax() *BgMax {
+	return &BgMax{}
+}
+
+// unpaidInvoice returns the invoice matching f, if exactly one unpaid invoice is found
+func unpaidInvoice(ctx context.Context, f models.InvoiceFilter) (*models.Invoice, error) {
+	f.FilterPaid = 2
+	lst, err := models.InvoiceList(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(lst) != 1 || lst[0].IsCreditNote || !lst[0].IsInvoiced {
+		return nil, nil
+	}
+	return &lst[0], nil
+}
+
+// paymentReferences returns the OCR reference and the invoice number that a payment reference is matched against.
+// The reference is only matched as an OCR reference if it is valid according to the OCR control of the company.
+// Since payers sometimes use the invoice number instead, it is also matched as an invoice number,
+// except with hard OCR control, where Bankgirot rejects payments without a valid OCR reference.
+func paymentReferences(company models.Company, ref string) (ocr string, number int) {
+	ref = onlyDigits(ref)
+	if ref == "" {
+		return "", 0
+	}
+
+	if company.ValidateOCR(ref) {
+		ocr = ref
+	}
+
+	if company.OCRLevel != models.OCRLevelHard {
+		number, _ = strconv.Atoi(ref)
+	}
+	return ocr, number
+}
+
+// findUnpaidInvoice returns the unpaid invoice referenced by ref, if any.
+// The reference is primarily matched against the OCR reference of the invoice, and then against the invoice number.
+func findUnpaidInvoice(ctx context.Context, company models.Company, ref string) (*models.Invoice, error) {
+	ocr, number := paymentReferences(company, ref)
+	if ocr != "" {
+		invoice, err := unpaidInvoice(ctx, models.InvoiceFilter{CompanyID: company.ID, OCR: ocr})
+		if err != nil || invoice != nil {
+			return invoice, err
+		}
+	}
+
+	if number > 0 {
+		return unpaidInvoice(ctx, models.InvoiceFilter{CompanyID: company.ID, Number: number})
+	}
+	return nil, nil
+}
+
+// matchPayment finds the invoices referenced by a payment, and checks if the amount is correct
+func matchPayment(ctx context.Context, company models.Company, p bgmax.Payment) (PaymentMatch, error) {
+	m := PaymentMatch{Payment: p}
+	if p.IsDeduction {
+		return m, nil
+	}
+
+	registered, err := models.ImportedPaymentExists(ctx, company.ID, p.Serial, p.Date)
+	if err != nil {
+		return m, err
+	}
+	if registered {
+		m.Status = MatchStatusRegistered
+		return m, nil
+	}
+
+	seen := map[int]bool{}
+	for _, ref := range p.References() {
+		invoice, err := findUnpaidInvoice(ctx, company, ref)
+		if err != nil {
+			return m, err
+		}
+
+		if invoice == nil || seen[invoice.ID] {
+			continue
+		}
+		seen[invoice.ID] = true
+
+		m.Invoices = append(m.Invoices, *invoice)
+		m.Due = m.Due.Add(invoice.Outstanding())
+	}
+
+	if len(m.Invoices) == 0 {
+		m.Status = MatchStatusUnmatched
+	} else if m.Due.Equal(p.Amount) {
+		m.Status = MatchStatusMatched
+	} else {
+		m.Status = MatchStatusPartial
+	}
+	return m, nil
+}
+
+// HandleGet shows the upload form
+func (v *BgMax) HandleGet() error {
+	return v.Render("invoice/bgmax.html")
+}
+
+// HandlePost parses an uploaded BgMax file and shows the matched payments,
+// or, if 'confirm' is set, marks the selected invoices as paid
+func (v *BgMax) HandlePost() error {
+	if v.FormValueExists("confirm") {
+		return v.confirm()
+	}
+
+	files := v.FormFiles("file")
+	if len(files) != 1 {
+		return views.ErrBadRequest
+	}
+
+	f, err := files[0].Open()
+	if err != nil {
+		return zerr.Wrap(err).WithString("filename", files[0].Filename)
+	}
+	defer f.Close()
+
+	file, err := bgmax.Parse(f)
+	if err != nil {
+		v.SetData("error", err.Error())
+		return v.Render("invoice/bgmax.html")
+	}
+
+	var matches []PaymentMatch
+	var warnings []string
+	account := onlyDigits(v.Session.Company.PaymentAccount)
+	for _, d := range file.Deposits {
+		if d.Bankgiro != account {
+			warnings = append(warnings, fmt.Sprintf("Insättningen %s avser bankgiro %s, som inte är företagets konto", d.Date.Format("2006-01-02"), d.Bankgiro))
+		}
+
+		for _, p := range d.Payments {
+			m, err := matchPayment(v.Ctx, v.Session.Company, p)
+			if err != nil {
+				return err
+			}
+			matches = append(matches, m)
+		}
+	}
+
+	if file.IsTest {
+		warnings = append(warnings, "Filen är en testfil från Bankgirot")
+	}
+
+	v.SetData("file", file)
+	v.SetData("matches", matches)
+	v.SetData("warnings", warnings)
+	return v.Render("invoice/bgmax-review.html")
+}
+
+// confirm registers payments for all invoices selected in the review form.
+// The form contains the fields 'pay[<n>]', 'number[<n>]', 'date[<n>]', 'amount[<n>]',
+// 'serial[<n>]' and 'reference[<n>]' for every payment, where 'number' is a comma separated list of invoice numbers.
+// If a payment covers several invoices, it is distributed in order over the invoices,
+// and any remaining amount is registered on the last invoice.
+// Nothing is registered if any of the payments already has been imported.
+func (v *BgMax) confirm() error {
+	count := v.FormValueInt("count")
+	for i := 0; i < count; i++ {
+		if !v.FormValueBool(fmt.Sprintf("pay[%d]", i)) {
+			continue
+		}
+
+		date, err := time.Parse("2006-01-02", v.FormValueString(fmt.Sprintf("date[%d]", i)))
+		if err != nil {
+			return views.ErrBadRequest
+		}
+
+		remaining, err := decimal.NewFromString(v.FormValueString(fmt.Sprintf("amount[%d]", i)))
+		if err != nil {
+			return views.ErrBadRequest
+		}
+
+		// The unique serial number stops the same payment from being imported twice,
+		// also when the same file is confirmed concurrently
+		serial := v.FormValueString(fmt.Sprintf("serial[%d]", i))
+		if serial != "" {
+			added, err := models.ImportedPaymentAdd(v.Ctx, v.Session.Company.ID, serial, date)
+			if err != nil {
+				return err
+			}
+			if !added {
+				return fmt.Errorf("payment %s from %s has already been registered", serial, date.Format("2006-01-02"))
+			}
+		}
+
+		numbers := strings.FieldsFunc(v.FormValueString(fmt.Sprintf("number[%d]", i)), func(r rune) bool {
+			return r == ',' || r == ' '
+		})
+		for k, number := range numbers {
+			// The payment is used up before the last invoices
+			if !remaining.IsPositive() {
+				break
+			}
+
+			n, err := strconv.Atoi(number)
+			if err != nil {
+				return views.ErrBadRequest
+			}
+
+			invoice, err := unpaidInvoice(v.Ctx, models.InvoiceFilter{CompanyID: v.Session.Company.ID, Number: n})
+			if err != nil {
+				return err
+			}
+
+			if invoice == nil {
+				return fmt.Errorf("invoice %d does not exist or is already paid", n)
+			}
+
+			amount := remaining
+			if k < len(numbers)-1 && invoice.Outstanding().LessThan(remaining) {
+				amount = invoice.Outstanding()
+			}
+			remaining = remaining.Sub(amount)
+
+			err = registerPayment(v.Ctx, *invoice, models.Payment{
+				Amount:    amount,
+				DatePaid:  date,
+				Method:    models.PaymentMethodBankgiro,
+				Reference: v.FormValueString(fmt.Sprintf("reference[%d]", i)),
+				Serial:    serial,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return v.RedirectRoute("invoice-list")
+}
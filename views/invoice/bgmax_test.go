@@ -0,0 +1,43 @@
+package invoice
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/yzzyx/faktura-pdf/models"
+)
+
+func TestPaymentReferences(t *testing.T) {
+	valid := models.GenerateOCR(125, true)
+	last := valid[len(valid)-1] - '0'
+	mistyped := valid[:len(valid)-1] + strconv.Itoa(int(last+1)%10)
+	number := func(s string) int {
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+
+	// With hard OCR control, references are never matched as invoice numbers
+	tests := []struct {
+		level  models.OCRLevel
+		ref    string
+		ocr    string
+		number int
+	}{
+		{models.OCRLevelNone, "125", "125", 125},
+		{models.OCRLevelSoft, valid, valid, number(valid)},
+		{models.OCRLevelSoft, mistyped, "", number(mistyped)},
+		{models.OCRLevelSoft, "125", "", 125},
+		{models.OCRLevelHard, valid, valid, 0},
+		{models.OCRLevelHard, mistyped, "", 0},
+		{models.OCRLevelHard, "125", "", 0},
+		{models.OCRLevelSoft, "", "", 0},
+	}
+
+	for _, tt := range tests {
+		company := models.Company{OCRLevel: tt.level, OCRLengthDigit: true}
+		ocr, number := paymentReferences(company, tt.ref)
+		if ocr != tt.ocr || number != tt.number {
+			t.Errorf("%s, reference %q: got OCR %q and number %d, expected OCR %q and number %d", tt.level, tt.ref, ocr, number, tt.ocr, tt.number)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package invoice
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/yzzyx/faktura-pdf/models"
+	"github.com/yzzyx/faktura-pdf/sie"
+)
+
+// verificationText returns the text used for all verifications of an invoice
+func verificationText(invoice models.Invoice) string {
+	if invoice.IsCreditNote && invoice.CreditInvoiceNumber != nil {
+		return fmt.Sprintf("Kreditfaktura #%d - %s (avser faktura #%d)", invoice.Number, invoice.Name, *invoice.CreditInvoiceNumber)
+	}
+	return fmt.Sprintf("Faktura #%d - %s", invoice.Number, invoice.Name)
+}
+
+// bookkeeping creates verifications for invoices, using the accounting method and the account map of a company
+type bookkeeping struct {
+	method   models.AccountingMethod
+	accounts models.AccountMap
+}
+
+// income returns the transactions crediting income and output VAT for the whole invoice,
+// including the part that is paid by Skatteverket as ROT/RUT.
+// Rows that are booked on the same account are added together.
+func (b bookkeeping) income(invoice models.Invoice) []sie.Transaction {
+	var transactions []sie.Transaction
+	index := map[int]int{}
+	add := func(account int, amount decimal.Decimal) {
+		if k, ok := index[account]; ok {
+			transactions[k].Belopp = transactions[k].Belopp.Add(amount)
+			return
+		}
+		index[account] = len(transactions)
+		transactions = append(transactions, sie.Transaction{KontoNr: account, Belopp: amount})
+	}
+
+	date := invoice.RateDate()
+	for _, row := range invoice.Rows {
+		totals := row.Totals(date, true, true)
+		add(b.accounts.Sales(row), totals.Excl.Neg())
+		if account := b.accounts.OutputVAT(row.VAT); account > 0 {
+			add(account, totals.Incl.Sub(totals.Excl).Neg())
+		}
+	}
+	return transactions
+}
+
+// cashIncome returns the income transactions of an invoice booked with the cash method,
+// including reminder fees and interest, and the total amount (including VAT) they correspond to
+func (b bookkeeping) cashIncome(invoice models.Invoice) ([]sie.Transaction, decimal.Decimal) {
+	totals := invoice.Totals(true, true)
+	transactions := b.income(invoice)
+	for _, r := range invoice.Reminders {
+		transactions = append(transactions,
+			sie.Transaction{KontoNr: b.accounts.ReminderFees, Belopp: r.Fee.Neg()},
+			sie.Transaction{KontoNr: b.accounts.LateInterest, Belopp: r.Interest.Neg()},
+		)
+	}
+	return transactions, totals.Incl.Add(invoice.ReminderCharges())
+}
+
+// shareOf returns the part 'amount' of the transactions, which sum up to 'total'.
+// Amounts are rounded to whole öre, and the rounding difference is booked on the rounding account.
+func (b bookkeeping) shareOf(transactions []sie.Transaction, amount, total decimal.Decimal) []sie.Transaction {
+	share := amount.Div(total)
+	sum := decimal.Zero
+	result := make([]sie.Transaction, 0, len(transactions)+1)
+	for _, t := range transactions {
+		part := t.Belopp.Mul(share).Round(2)
+		sum = sum.Add(part)
+		result = append(result, sie.Transaction{KontoNr: t.KontoNr, Belopp: part})
+	}
+
+	// Income is credited, so the transactions should sum up to the negated amount
+	result = append(result, sie.Transaction{KontoNr: b.accounts.Rounding, Belopp: amount.Neg().Sub(sum)})
+	return result
+}
+
+// rounded returns the verification with all amounts rounded to whole öre,
+// and the rounding difference booked on the rounding account
+func (b bookkeeping) rounded(ver sie.Verification) sie.Verification {
+	sum := decimal.Zero
+	for k := range ver.Transactions {
+		ver.Transactions[k].Belopp = ver.Transactions[k].Belopp.Round(2)
+		sum = sum.Add(ver.Transactions[k].Belopp)
+	}
+
+	if !sum.IsZero() {
+		ver.Transactions = append(ver.Transactions, sie.Transaction{KontoNr: b.accounts.Rounding, Belopp: sum.Neg()})
+	}
+	return ver
+}
+
+// cashPayment returns a verification for a payment of 'amount' to the bank account, booked with the cash method
+func (b bookkeeping) cashPayment(invoice models.Invoice, date time.Time, amount decimal.Decimal, text string) sie.Verification {
+	ver := sie.Verification{
+		VerDatum: date,
+		VerText:  text,
+		Transactions: []sie.Transaction{
+			{KontoNr: b.accounts.Bank, Belopp: amount},
+		},
+	}
+
+	income, total := b.cashIncome(invoice)
+	if total.IsZero() {
+		// Nothing to distribute the payment over
+		ver.Transactions = append(ver.Transactions, sie.Transaction{KontoNr: b.accounts.Rounding, Belopp: amount.Neg()})
+		return ver
+	}
+
+	ver.Transactions = append(ver.Transactions, b.shareOf(income, amount, total)...)
+	return ver
+}
+
+// invoiceVerifications returns the verifications for an invoice that has been sent.
+// With the accrual method, the invoice and any reminders are booked when sent, and the payments
+// against the receivables account. With the cash method, only the payments are booked.
+func (b bookkeeping) invoiceVerifications(invoice models.Invoice) []sie.Verification {
+	var verifications []sie.Verification
+
+	now := time.Now()
+	if invoice.DateInvoiced == nil {
+		invoice.DateInvoiced = &now
+	}
+
+	totals := invoice.Totals(true, true)
+	verText := verificationText(invoice)
+
+	if b.method == models.AccountingMethodCash {
+		for _, p := range invoice.Payments {
+			verifications = append(verifications, b.cashPayment(invoice, p.DatePaid, p.Amount, fmt.Sprintf("%s betalning %s", verText, p.Method.String())))
+		}
+		return verifications
+	}
+
+	verifications = append(verifications, b.rounded(sie.Verification{
+		VerDatum: *invoice.DateInvoiced,
+		VerText:  verText,
+		Transactions: append([]sie.Transaction{
+			{KontoNr: b.accounts.Receivable, Belopp: totals.Customer},
+			{KontoNr: b.accounts.RotRutReceivable, Belopp: totals.ROTRUT},
+		}, b.income(invoice)...),
+	}))
+
+	// Reminder fees and late-payment interest are booked when the reminder is sent
+	for _, r := range invoice.Reminders {
+		if r.Total().IsZero() {
+			continue
+		}
+
+		verifications = append(verifications, sie.Verification{
+			VerDatum: r.DateSent,
+			VerText:  fmt.Sprintf("%s påminnelse %d", verText, r.Number),
+			Transactions: []sie.Transaction{
+				{KontoNr: b.accounts.Receivable, Belopp: r.Total()},
+				{KontoNr: b.accounts.ReminderFees, Belopp: r.Fee.Neg()},
+				{KontoNr: b.accounts.LateInterest, Belopp: r.Interest.Neg()},
+			},
+		})
+	}
+
+	// One verification is created per registered payment
+	for _, p := range invoice.Payments {
+		verifications = append(verifications, sie.Verification{
+			VerDatum: p.DatePaid,
+			VerText:  fmt.Sprintf("%s betalning %s", verText, p.Method.String()),
+			Transactions: []sie.Transaction{
+				{KontoNr: b.accounts.Receivable, Belopp: p.Amount.Neg()},
+				{KontoNr: b.accounts.Bank, Belopp: p.Amount},
+			},
+		})
+	}
+
+	return verifications
+}
+
+// rutVerification returns the verification for a ROT/RUT payout from Skatteverket.
+// With the cash method, the income of the invoice is booked when the payout is received.
+func (b bookkeeping) rutVerification(r models.RUT) sie.Verification {
+	received := decimal.Zero
+	if r.ReceivedSum != nil {
+		received = decimal.NewFromInt(int64(*r.ReceivedSum))
+	}
+
+	text := fmt.Sprintf("Utbetalning %s %s", r.Type.String(), verificationText(r.Invoice))
+	if b.method == models.AccountingMethodCash {
+		return b.cashPayment(r.Invoice, *r.DatePaid, received, text)
+	}
+
+	return sie.Verification{
+		VerDatum: *r.DatePaid,
+		VerText:  text,
+		Transactions: []sie.Transaction{
+			{KontoNr: b.accounts.RotRutReceivable, Belopp: received.Neg()},
+			{KontoNr: b.accounts.Bank, Belopp: received},
+		},
+	}
+}
+
+// yearEndVerifications returns the verifications needed with the cash method when the invoice
+// is not fully paid at the end of the fiscal year 'year': the unpaid part is booked as a receivable
+// on the last day of the year, and the booking is reversed on the first day of the next year.
+// 'payouts' are the ROT/RUT requests for the invoice, one for each type of deduction.
+func (b bookkeeping) yearEndVerifications(invoice models.Invoice, payouts []models.RUT, year int) []sie.Verification {
+	end := time.Date(year, time.December, 31, 0, 0, 0, 0, time.Local)
+	if invoice.DateInvoiced == nil || invoice.DateInvoiced.After(end) {
+		return nil
+	}
+
+	invoice, customer, rotrut := outstandingAt(invoice, payouts, end)
+	outstanding := customer.Add(rotrut)
+	income, total := b.cashIncome(invoice)
+	if outstanding.IsZero() || total.IsZero() {
+		return nil
+	}
+
+	verText := verificationText(invoice)
+	closing := sie.Verification{
+		VerDatum: end,
+		VerText:  verText + " obetald vid räkenskapsårets slut",
+		Transactions: append([]sie.Transaction{
+			{KontoNr: b.accounts.Receivable, Belopp: customer},
+			{KontoNr: b.accounts.RotRutReceivable, Belopp: rotrut},
+		}, b.shareOf(income, outstanding, total)...),
+	}
+
+	reversal := sie.Verification{
+		VerDatum: end.AddDate(0, 0, 1),
+		VerText:  verText + " återföring av obetald faktura",
+	}
+	for _, t := range closing.Transactions {
+		reversal.Transactions = append(reversal.Transactions, sie.Transaction{KontoNr: t.KontoNr, Belopp: t.Belopp.Neg()})
+	}
+
+	return []sie.Verification{closing, reversal}
+}
+
+// outstandingAt returns the invoice as it was at 'end', with only the reminders sent by then,
+// and the amounts that the customer and Skatteverket had left to pay.
+func outstandingAt(invoice models.Invoice, payouts []models.RUT, end time.Time) (models.Invoice, decimal.Decimal, decimal.Decimal) {
+	var reminders []models.Reminder
+	for _, r := range invoice.Reminders {
+		if !r.DateSent.After(end) {
+			reminders = append(reminders, r)
+		}
+	}
+	invoice.Reminders = reminders
+
+	paid := decimal.Zero
+	for _, p := range invoice.Payments {
+		if !p.DatePaid.After(end) {
+			paid = paid.Add(p.Amount)
+		}
+	}
+	return invoice, invoice.AmountDue().Sub(paid), rotrutOutstanding(invoice, payouts, end)
+}
+
+// rotrutOutstanding returns the part of the ROT/RUT deduction of the invoice that is still outstanding at 'end'.
+// The deduction of each type is outstanding until its request has been paid out, unless the request
+// was rejected, in which case the customer pays the full amount.
+func rotrutOutstanding(invoice models.Invoice, payouts []models.RUT, end time.Time) decimal.Decimal {
+	settled := map[models.RUTType]bool{}
+	for _, p := range payouts {
+		paidOut := p.Status == models.RUTStatusPaid && p.DatePaid != nil && !p.DatePaid.After(end)
+		if paidOut || p.Status == models.RUTStatusRejected {
+			settled[p.Type] = true
+		}
+	}
+
+	date := invoice.RateDate()
+	outstanding := decimal.Zero
+	for _, row := range invoice.Rows {
+		if !row.IsRotRut || row.RotRutServiceType == nil {
+			continue
+		}
+
+		typ := models.RUTTypeROT
+		if row.RotRutServiceType.IsRUT() {
+			typ = models.RUTTypeRUT
+		}
+
+		if !settled[typ] {
+			outstanding = outstanding.Add(row.Totals(date, true, true).ROTRUT)
+		}
+	}
+	return outstanding.Round(2)
+}
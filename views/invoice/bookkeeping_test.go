@@ -0,0 +1,195 @@
+package invoice
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/yzzyx/faktura-pdf/models"
+	"github.com/yzzyx/faktura-pdf/sie"
+)
+
+func date(s string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func balance(transactions []sie.Transaction, account int) decimal.Decimal {
+	sum := decimal.Zero
+	for _, t := range transactions {
+		if t.KontoNr == account {
+			sum = sum.Add(t.Belopp)
+		}
+	}
+	return sum
+}
+
+func testInvoice() models.Invoice {
+	invoiced := date("2024-12-15")
+	return models.Invoice{
+		Number:       1001,
+		Name:         "Test",
+		IsInvoiced:   true,
+		DateInvoiced: &invoiced,
+		Rows: []models.InvoiceRow{
+			{Cost: decimal.NewFromInt(100), Count: decimal.NewFromInt(3), VAT: 0, Total: decimal.NewFromInt(300)},
+		},
+		Payments: []models.Payment{
+			{Amount: decimal.NewFromInt(100), DatePaid: date("2024-12-20")},
+			{Amount: decimal.NewFromInt(200), DatePaid: date("2025-01-10")},
+		},
+	}
+}
+
+func TestInvoiceVerificationsCash(t *testing.T) {
+	b := bookkeeping{method: models.AccountingMethodCash, accounts: models.DefaultAccountMap}
+	verifications := b.invoiceVerifications(testInvoice())
+	if len(verifications) != 2 {
+		t.Fatalf("got %d verifications, expected one per payment", len(verifications))
+	}
+
+	ver := verifications[0]
+	if !ver.Sum().IsZero() {
+		t.Errorf("verification is not balanced: %s", ver.Sum())
+	}
+
+	if !balance(ver.Transactions, 1510).IsZero() {
+		t.Errorf("cash method should not book receivables")
+	}
+
+	// 100 of 300 paid, which is a third of 240 + 60 VAT
+	if !balance(ver.Transactions, 3041).Equal(decimal.NewFromInt(-80)) || !balance(ver.Transactions, 2611).Equal(decimal.NewFromInt(-20)) {
+		t.Errorf("unexpected income and VAT: %+v", ver.Transactions)
+	}
+}
+
+func TestYearEndVerifications(t *testing.T) {
+	inv := testInvoice()
+	b := bookkeeping{method: models.AccountingMethodCash, accounts: models.DefaultAccountMap}
+
+	verifications := b.yearEndVerifications(inv, nil, 2024)
+	if len(verifications) != 2 {
+		t.Fatalf("got %d verifications, expected closing and reversal", len(verifications))
+	}
+
+	closing, reversal := verifications[0], verifications[1]
+	if !closing.Sum().IsZero() || !reversal.Sum().IsZero() {
+		t.Errorf("verifications are not balanced")
+	}
+
+	if !closing.VerDatum.Equal(date("2024-12-31")) || !reversal.VerDatum.Equal(date("2025-01-01")) {
+		t.Errorf("unexpected dates %s and %s", closing.VerDatum, reversal.VerDatum)
+	}
+
+	if !balance(closing.Transactions, 1510).Equal(decimal.NewFromInt(200)) || !balance(reversal.Transactions, 1510).Equal(decimal.NewFromInt(-200)) {
+		t.Errorf("expected 200 unpaid at year end: %+v", closing.Transactions)
+	}
+
+	if len(b.yearEndVerifications(inv, nil, 2025)) != 0 {
+		t.Errorf("invoice is fully paid at the end of 2025")
+	}
+
+	if len(b.yearEndVerifications(inv, nil, 2023)) != 0 {
+		t.Errorf("invoice was not sent in 2023")
+	}
+}
+
+func TestYearEndVerificationsROTAndRUT(t *testing.T) {
+	rot, rut := models.ROTServiceTypeBygg, models.RUTServiceTypeStadning
+	inv := testInvoice()
+	inv.Rows = []models.InvoiceRow{
+		{Cost: decimal.NewFromInt(1000), Count: decimal.NewFromInt(1), Total: decimal.NewFromInt(1000), IsRotRut: true, RotRutServiceType: &rot},
+		{Cost: decimal.NewFromInt(1000), Count: decimal.NewFromInt(1), Total: decimal.NewFromInt(1000), IsRotRut: true, RotRutServiceType: &rut},
+	}
+	inv.Payments = []models.Payment{{Amount: inv.AmountDue(), DatePaid: date("2024-12-20")}}
+
+	// ROT is 30% and RUT 50% of the cost in 2024. The ROT request is paid out before the end of the year,
+	// so only the RUT deduction is outstanding
+	paid := date("2024-12-28")
+	payouts := []models.RUT{
+		{Type: models.RUTTypeROT, Status: models.RUTStatusPaid, DatePaid: &paid},
+		{Type: models.RUTTypeRUT, Status: models.RUTStatusSent},
+	}
+
+	b := bookkeeping{method: models.AccountingMethodCash, accounts: models.DefaultAccountMap}
+	verifications := b.yearEndVerifications(inv, payouts, 2024)
+	if len(verifications) != 2 {
+		t.Fatalf("got %d verifications, expected closing and reversal", len(verifications))
+	}
+
+	closing := verifications[0]
+	if !closing.Sum().IsZero() {
+		t.Errorf("verification is not balanced: %s", closing.Sum())
+	}
+
+	if !balance(closing.Transactions, 1510).IsZero() || !balance(closing.Transactions, 1513).Equal(decimal.NewFromInt(500)) {
+		t.Errorf("expected the RUT deduction outstanding at year end: %+v", closing.Transactions)
+	}
+
+	// Once both requests are paid out, nothing is outstanding
+	payouts[1].Status = models.RUTStatusPaid
+	payouts[1].DatePaid = &paid
+	if len(b.yearEndVerifications(inv, payouts, 2024)) != 0 {
+		t.Errorf("both deductions are paid out at the end of 2024")
+	}
+}
+
+func TestInvoiceVerificationsAccounts(t *testing.T) {
+	account := 3999
+	inv := testInvoice()
+	inv.Rows = append(inv.Rows,
+		models.InvoiceRow{Cost: decimal.NewFromInt(112), Count: decimal.NewFromInt(1), VAT: 1, Total: decimal.NewFromInt(112), IsGoods: true},
+		models.InvoiceRow{Cost: decimal.NewFromInt(50), Count: decimal.NewFromInt(1), VAT: 3, Total: decimal.NewFromInt(50), Account: &account},
+	)
+
+	accounts := models.DefaultAccountMap
+	accounts.Receivable = 1511
+	b := bookkeeping{method: models.AccountingMethodAccrual, accounts: accounts}
+
+	ver := b.invoiceVerifications(inv)[0]
+	if !ver.Sum().IsZero() {
+		t.Errorf("verification is not balanced: %s", ver.Sum())
+	}
+
+	expected := map[int]int64{1511: 462, 3041: -240, 2611: -60, 3002: -100, 2620: -12, 3999: -50}
+	for nr, amount := range expected {
+		if !balance(ver.Transactions, nr).Equal(decimal.NewFromInt(amount)) {
+			t.Errorf("expected %d on account %d, got %s", amount, nr, balance(ver.Transactions, nr))
+		}
+	}
+}
+
+func TestInvoiceVerificationsRounding(t *testing.T) {
+	service := models.ROTServiceTypeBygg
+	inv := testInvoice()
+	inv.Rows = []models.InvoiceRow{
+		{Cost: decimal.RequireFromString("99.95"), Count: decimal.NewFromInt(1), Total: decimal.RequireFromString("99.95"), IsRotRut: true, RotRutServiceType: &service},
+	}
+
+	b := bookkeeping{method: models.AccountingMethodAccrual, accounts: models.DefaultAccountMap}
+	ver := b.invoiceVerifications(inv)[0]
+	for _, tr := range ver.Transactions {
+		if !tr.Belopp.Equal(tr.Belopp.Round(2)) {
+			t.Errorf("amount %s on account %d is not rounded to whole öre", tr.Belopp, tr.KontoNr)
+		}
+	}
+
+	if !ver.Sum().IsZero() {
+		t.Errorf("verification is not balanced: %s", ver.Sum())
+	}
+
+	// The customer pays 69.965 and Skatteverket 29.985, while 79.96 + 19.99 is credited
+	if !balance(ver.Transactions, 1510).Equal(inv.AmountDue()) || !balance(ver.Transactions, 3740).Equal(decimal.RequireFromString("-0.01")) {
+		t.Errorf("expected the amount due as receivable and the difference as rounding: %+v", ver.Transactions)
+	}
+
+	// Transaction amounts are rounded when written, so the written verification must balance as well
+	err := ver.Write(io.Discard)
+	if err != nil {
+		t.Error(err)
+	}
+}
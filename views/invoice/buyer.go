@@ -0,0 +1,61 @@
+package invoice
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/yzzyx/faktura-pdf/models"
+	"github.com/yzzyx/faktura-pdf/views"
+)
+
+var reBuyerPNR = regexp.MustCompile(`^\d{12}$`)
+
+// Buyer is the view-handler for adding and removing buyers that share the ROT/RUT deduction of an invoice
+type Buyer struct {
+	views.View
+}
+
+// NewBuyer creates a new handler for adding and removing buyers of an invoice
+func NewBuyer() *Buyer {
+	return &Buyer{}
+}
+
+// HandlePost adds a buyer to the invoice, or removes the buyer specified by 'remove'
+func (v *Buyer) HandlePost() error {
+	id := v.URLParamInt("id")
+	if id <= 0 {
+		return views.ErrBadRequest
+	}
+
+	invoice, err := models.InvoiceGet(v.Ctx, models.InvoiceFilter{ID: id, CompanyID: v.Session.Company.ID})
+	if err != nil {
+		return err
+	}
+
+	if buyerID := v.FormValueInt("remove"); buyerID > 0 {
+		err = models.BuyerRemove(v.Ctx, invoice.ID, buyerID)
+		if err != nil {
+			return err
+		}
+		return v.RedirectRoute("invoice-view", "id", strconv.Itoa(invoice.ID))
+	}
+
+	buyer := models.Buyer{
+		InvoiceID: invoice.ID,
+		Name:      strings.TrimSpace(v.FormValueString("name")),
+		PNR:       models.NormalizePNR(strings.TrimSpace(v.FormValueString("pnr"))),
+		Share:     v.FormValueInt("share"),
+	}
+
+	if !reBuyerPNR.MatchString(buyer.PNR) || buyer.Share <= 0 || buyer.Share > 100 {
+		return views.ErrBadRequest
+	}
+
+	_, err = models.BuyerAdd(v.Ctx, buyer)
+	if err != nil {
+		return err
+	}
+
+	return v.RedirectRoute("invoice-view", "id", strconv.Itoa(invoice.ID))
+}
@@ -0,0 +1,194 @@
+package invoice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/shopspring/decimal"
+	"github.com/yzzyx/faktura-pdf/models"
+	"github.com/yzzyx/faktura-pdf/views"
+)
+
+// Credit is the view-handler for creating credit notes
+type Credit struct {
+	views.View
+}
+
+// NewCredit creates a new handler for creating credit notes
+func NewCredit() *Credit {
+	return &Credit{}
+}
+
+// HandlePost creates a full or partial credit note for an invoice
+func (v *Credit) HandlePost() error {
+	var err error
+	var invoice models.Invoice
+
+	id := v.URLParamInt("id")
+	if id <= 0 {
+		return views.ErrBadRequest
+	}
+
+	invoice, err = models.InvoiceGet(v.Ctx, models.InvoiceFilter{ID: id, CompanyID: v.Session.Company.ID})
+	if err != nil {
+		return err
+	}
+
+	if !invoice.IsInvoiced {
+		return errors.New("invoice is not marked as sent - it can be changed instead of credited")
+	}
+
+	if invoice.IsCreditNote {
+		return errors.New("a credit note cannot be credited")
+	}
+
+	creditNote := models.Invoice{
+		Name:              invoice.Name,
+		Customer:          invoice.Customer,
+		RutApplicable:     invoice.RutApplicable,
+		Company:           invoice.Company,
+		IsCreditNote:      true,
+		CreditInvoiceID:   &invoice.ID,
+		CreditInvoiceDate: invoice.DateInvoiced,
+	}
+
+	// If 'full' is set, all rows are credited.
+	// Otherwise, the number of units to credit is specified per row as 'count[<row id>]'
+	creditAll := v.FormValueBool("full")
+	for _, row := range invoice.Rows {
+		count := row.Count
+		if !creditAll {
+			countStr := v.FormValueString(fmt.Sprintf("count[%d]", row.ID))
+			if countStr == "" {
+				continue
+			}
+
+			count, err = decimal.NewFromString(countStr)
+			if err != nil {
+				return fmt.Errorf("invalid count for row %d: %w", row.ID, err)
+			}
+		}
+
+		if count.IsZero() {
+			continue
+		}
+
+		if count.IsNegative() || count.GreaterThan(row.Count) {
+			return fmt.Errorf("cannot credit %s units of row '%s'", count, row.Description)
+		}
+
+		row.ID = 0
+		row.RowOrder = len(creditNote.Rows)
+		row.Count = count.Neg()
+		row.Total = row.Cost.Mul(row.Count)
+		creditNote.Rows = append(creditNote.Rows, row)
+	}
+
+	if len(creditNote.Rows) == 0 {
+		return errors.New("no rows selected for credit note")
+	}
+
+	// Make sure that we don't credit more than the original invoice
+	previous, err := models.InvoiceList(v.Ctx, models.InvoiceFilter{CompanyID: v.Session.Company.ID, CreditInvoiceID: invoice.ID})
+	if err != nil {
+		return err
+	}
+
+	credited := creditNote.Totals(true, false).Incl
+	for _, p := range previous {
+		credited = credited.Add(p.Totals(true, false).Incl)
+	}
+
+	if credited.Neg().GreaterThan(invoice.Totals(true, false).Incl) {
+		return errors.New("the credited amount exceeds the amount of the invoice")
+	}
+
+	creditNote.Number, err = v.Session.Company.GetNextInvoiceNumber(v.Ctx)
+	if err != nil {
+		return err
+	}
+
+	creditNote.ID, err = models.InvoiceSave(v.Ctx, creditNote)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range creditNote.Rows {
+		err = models.InvoiceRowAdd(v.Ctx, creditNote.ID, row)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = creditROTRUT(v.Ctx, invoice, creditNote, previous)
+	if err != nil {
+		return err
+	}
+
+	return v.RedirectRoute("invoice-view", "id", strconv.Itoa(creditNote.ID))
+}
+
+// creditedRequest returns the ROT/RUT request of a credited invoice, updated after a credit note that credits
+// 'credited' kronor of its deduction. 'remaining' is the deduction left on the invoice after all credit notes.
+// A request that has been sent to Skatteverket can't be changed, so crediting its deduction is refused.
+func creditedRequest(r models.RUT, credited, remaining int) (models.RUT, error) {
+	if credited == 0 || r.Status == models.RUTStatusRejected || r.Status == models.RUTStatusWithdrawn {
+		return r, nil
+	}
+
+	if r.Status != models.RUTStatusPending {
+		return r, fmt.Errorf("the %s request of the invoice has already been sent to Skatteverket, and its deduction cannot be credited", r.Type)
+	}
+
+	if remaining <= 0 {
+		r.Status = models.RUTStatusWithdrawn
+		return r, nil
+	}
+
+	if r.RequestedSum != nil && *r.RequestedSum > remaining {
+		r.RequestedSum = &remaining
+	}
+	return r, nil
+}
+
+// creditROTRUT updates the ROT/RUT requests of the invoice after the credit note has been created.
+// 'previous' are the credit notes that were already issued for the invoice.
+func creditROTRUT(ctx context.Context, invoice models.Invoice, creditNote models.Invoice, previous []models.Invoice) error {
+	rutRequests, err := models.RUTList(ctx, models.RUTFilter{InvoiceID: invoice.ID})
+	if err != nil {
+		return err
+	}
+
+	// The deductions of credit notes are negative
+	creditedROT, creditedRUT := creditNote.Deduction()
+	remainingROT, remainingRUT := invoice.Deduction()
+	for _, c := range append(previous, creditNote) {
+		rot, rut := c.Deduction()
+		remainingROT += rot
+		remainingRUT += rut
+	}
+
+	for _, r := range rutRequests {
+		credited, remaining := -creditedRUT, remainingRUT
+		if r.Type == models.RUTTypeROT {
+			credited, remaining = -creditedROT, remainingROT
+		}
+
+		updated, err := creditedRequest(r, credited, remaining)
+		if err != nil {
+			return err
+		}
+
+		if updated.Status == r.Status && updated.RequestedSum == r.RequestedSum {
+			continue
+		}
+
+		_, err = models.RUTSave(ctx, updated)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package invoice
+
+import (
+	"testing"
+
+	"github.com/yzzyx/faktura-pdf/models"
+)
+
+func TestCreditedRequest(t *testing.T) {
+	requested := 600
+	pending := models.RUT{Type: models.RUTTypeROT, Status: models.RUTStatusPending, RequestedSum: &requested}
+
+	// A partial credit reduces the requested amount to the deduction left on the invoice
+	r, err := creditedRequest(pending, 200, 400)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Status != models.RUTStatusPending || r.RequestedSum == nil || *r.RequestedSum != 400 {
+		t.Errorf("expected 400 kr still requested, got %+v", r)
+	}
+	if requested != 600 {
+		t.Errorf("expected the original request to be left unchanged")
+	}
+
+	// When the whole deduction is credited, the request is withdrawn
+	r, err = creditedRequest(pending, 600, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Status != models.RUTStatusWithdrawn {
+		t.Errorf("expected the request to be withdrawn, got status %s", r.Status)
+	}
+
+	// Credit notes without deduction leave the request alone
+	sent := pending
+	sent.Status = models.RUTStatusSent
+	if _, err = creditedRequest(sent, 0, 600); err != nil {
+		t.Errorf("expected credit notes without deduction to be allowed, got %s", err)
+	}
+
+	// The deduction of a request that has been sent can't be credited
+	for _, status := range []models.RUTStatus{models.RUTStatusSent, models.RUTStatusPaid} {
+		sent.Status = status
+		if _, err = creditedRequest(sent, 200, 400); err == nil {
+			t.Errorf("expected an error when crediting the deduction of a request that is %s", status)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package invoice
+
+import (
+	"strconv"
+
+	"github.com/yzzyx/faktura-pdf/models"
+	"github.com/yzzyx/faktura-pdf/views"
+)
+
+// Deduction is the view-handler for registering ROT/RUT deductions that the buyers of an invoice
+// have received from other companies
+type Deduction struct {
+	views.View
+}
+
+// NewDeduction creates a new handler for registering deductions from other companies
+func NewDeduction() *Deduction {
+	return &Deduction{}
+}
+
+// HandlePost registers a deduction for the buyer of the invoice given by 'pnr',
+// or removes the deduction specified by 'remove'
+func (v *Deduction) HandlePost() error {
+	id := v.URLParamInt("id")
+	if id <= 0 {
+		return views.ErrBadRequest
+	}
+
+	invoice, err := models.InvoiceGet(v.Ctx, models.InvoiceFilter{ID: id, CompanyID: v.Session.Company.ID})
+	if err != nil {
+		return err
+	}
+
+	if deductionID := v.FormValueInt("remove"); deductionID > 0 {
+		err = models.ExternalDeductionRemove(v.Ctx, v.Session.Company.ID, deductionID)
+		if err != nil {
+			return err
+		}
+		return v.RedirectRoute("invoice-view", "id", strconv.Itoa(invoice.ID))
+	}
+
+	// Deductions can only be registered for the buyers of the invoice
+	pnr := v.FormValueString("pnr")
+	isBuyer := false
+	for _, b := range invoice.ROTRUTBuyers() {
+		if b.PNR != "" && b.PNR == pnr {
+			isBuyer = true
+		}
+	}
+	if !isBuyer {
+		return views.ErrBadRequest
+	}
+
+	amount := v.FormValueInt("amount")
+	year := v.FormValueInt("year")
+	typ := models.RUTType(v.FormValueInt("type"))
+	if amount <= 0 || year <= 0 || (typ != models.RUTTypeROT && typ != models.RUTTypeRUT) {
+		return views.ErrBadRequest
+	}
+
+	_, err = models.ExternalDeductionAdd(v.Ctx, models.ExternalDeduction{
+		CompanyID:   v.Session.Company.ID,
+		PNR:         pnr,
+		Year:        year,
+		Type:        typ,
+		Amount:      amount,
+		Description: v.FormValueString("description"),
+	})
+	if err != nil {
+		return err
+	}
+
+	return v.RedirectRoute("invoice-view", "id", strconv.Itoa(invoice.ID))
+}
@@ -0,0 +1,221 @@
+package invoice
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/yzzyx/faktura-pdf/cii"
+	"github.com/yzzyx/faktura-pdf/models"
+	"github.com/yzzyx/faktura-pdf/pdfa"
+	"github.com/yzzyx/faktura-pdf/ubl"
+)
+
+// ciiParty converts a party in a Peppol invoice into a party in a CII invoice
+func ciiParty(p ubl.Party) cii.Party {
+	party := cii.Party{
+		Name: p.PartyLegalEntity.RegistrationName,
+		Address: &cii.Address{
+			Postcode:    p.PostalAddress.PostalZone,
+			LineOne:     p.PostalAddress.StreetName,
+			LineTwo:     p.PostalAddress.AdditionalStreetName,
+			CityName:    p.PostalAddress.CityName,
+			CountryCode: p.PostalAddress.CountryCode,
+		},
+	}
+
+	if id := p.PartyLegalEntity.CompanyID; id != nil {
+		party.LegalOrganization = &cii.LegalOrganization{ID: &cii.Identifier{SchemeID: id.SchemeID, Value: id.Value}}
+	}
+	if p.Contact != nil && (p.Contact.Telephone != "" || p.Contact.Email != "") {
+		party.Contact = &cii.Contact{}
+		if p.Contact.Telephone != "" {
+			party.Contact.Telephone = &cii.Telephone{Number: p.Contact.Telephone}
+		}
+		if p.Contact.Email != "" {
+			party.Contact.Email = &cii.Email{URI: p.Contact.Email}
+		}
+	}
+	if p.EndpointID.Value != "" {
+		party.ElectronicAddress = &cii.Identifier{SchemeID: p.EndpointID.SchemeID, Value: p.EndpointID.Value}
+	}
+	for _, t := range p.PartyTaxScheme {
+		party.TaxRegistrations = append(party.TaxRegistrations, cii.TaxRegistration{ID: cii.Identifier{SchemeID: "VA", Value: t.CompanyID}})
+	}
+	return party
+}
+
+// ciiAmount returns a pointer to a UBL amount converted to a CII amount, or nil if the amount isn't set
+func ciiAmount(a *ubl.Amount) *cii.Amount {
+	if a == nil {
+		return nil
+	}
+	amount := cii.NewAmount(a.Value)
+	return &amount
+}
+
+// buildFacturXInvoice converts an invoice into an EN 16931 CII invoice, with the information included in profile.
+// The invoice is created from the Peppol invoice, so that both e-invoices contain the same amounts.
+func buildFacturXInvoice(invoice models.Invoice, profile cii.Profile) *cii.Invoice {
+	u := buildPeppolInvoice(invoice)
+
+	doc := cii.NewInvoice()
+	doc.ID = u.ID
+	doc.IssueDate = *cii.NewDate(u.IssueDate)
+	if u.IsCreditNote() {
+		doc.TypeCode = cii.TypeCodeCreditNote
+	}
+	if u.Note != "" {
+		doc.Notes = append(doc.Notes, cii.Note{Content: u.Note})
+	}
+
+	for _, l := range u.Lines() {
+		q := l.Quantity()
+		doc.Lines = append(doc.Lines, cii.Line{
+			ID:             l.ID,
+			Name:           l.Item.Name,
+			NetPrice:       cii.NewAmount(l.Price.PriceAmount.Value),
+			BilledQuantity: cii.Quantity{UnitCode: q.UnitCode, Text: q.Text},
+			Tax: cii.TradeTax{
+				TypeCode:     "VAT",
+				CategoryCode: l.Item.TaxCategory.ID,
+				Percent:      l.Item.TaxCategory.Percent,
+			},
+			LineTotalAmount: cii.NewAmount(l.LineExtensionAmount.Value),
+		})
+	}
+
+	doc.Agreement = cii.HeaderAgreement{
+		BuyerReference: u.BuyerReference,
+		Seller:         ciiParty(u.AccountingSupplierParty),
+		Buyer:          ciiParty(u.AccountingCustomerParty),
+	}
+
+	settlement := &doc.Settlement
+	settlement.CurrencyCode = u.DocumentCurrencyCode
+	if pm := u.PaymentMeans; pm != nil {
+		settlement.PaymentReference = pm.PaymentID
+		settlement.PaymentMeans = &cii.PaymentMeans{TypeCode: pm.Code}
+		if pm.PayeeAccount != nil {
+			settlement.PaymentMeans.PayeeAccount = &cii.FinancialAccount{ProprietaryID: pm.PayeeAccount.ID}
+		}
+	}
+
+	for _, st := range u.TaxTotal.TaxSubtotals {
+		settlement.Taxes = append(settlement.Taxes, cii.TradeTax{
+			CalculatedAmount:    ciiAmount(&st.TaxAmount),
+			TypeCode:            "VAT",
+			ExemptionReason:     st.TaxCategory.ExemptionReason,
+			BasisAmount:         ciiAmount(&st.TaxableAmount),
+			CategoryCode:        st.TaxCategory.ID,
+			ExemptionReasonCode: st.TaxCategory.ExemptionReasonCode,
+			Percent:             st.TaxCategory.Percent,
+		})
+	}
+
+	if u.DueDate != "" {
+		settlement.PaymentTerms = &cii.PaymentTerms{DueDate: cii.NewDate(u.DueDate)}
+	}
+
+	total := u.LegalMonetaryTotal
+	settlement.Summation = cii.MonetarySummation{
+		LineTotalAmount:     ciiAmount(&total.LineExtensionAmount),
+		TaxBasisTotalAmount: cii.NewAmount(total.TaxExclusiveAmount.Value),
+		TaxTotalAmount:      cii.NewAmount(u.TaxTotal.TaxAmount.Value),
+		RoundingAmount:      ciiAmount(total.PayableRoundingAmount),
+		GrandTotalAmount:    cii.NewAmount(total.TaxInclusiveAmount.Value),
+		TotalPrepaidAmount:  ciiAmount(total.PrepaidAmount),
+		DuePayableAmount:    cii.NewAmount(total.PayableAmount.Value),
+	}
+	settlement.Summation.TaxTotalAmount.CurrencyID = u.DocumentCurrencyCode
+
+	if u.BillingReference != nil {
+		settlement.InvoiceReference = &cii.ReferencedDocument{IssuerAssignedID: u.BillingReference.ID}
+	}
+
+	doc.ApplyProfile(profile)
+	return doc
+}
+
+// facturXMetadata returns the XMP metadata describing the embedded Factur-X invoice,
+// and the extension schema defining the Factur-X properties
+func facturXMetadata(profile cii.Profile) string {
+	property := func(name string, description string) string {
+		return `<rdf:li rdf:parseType="Resource">
+<pdfaProperty:name>` + name + `</pdfaProperty:name>
+<pdfaProperty:valueType>Text</pdfaProperty:valueType>
+<pdfaProperty:category>external</pdfaProperty:category>
+<pdfaProperty:description>` + description + `</pdfaProperty:description>
+</rdf:li>
+`
+	}
+
+	return `<rdf:Description rdf:about="" xmlns:fx="urn:factur-x:pdfa:CrossIndustryDocument:invoice:1p0#">
+<fx:DocumentType>INVOICE</fx:DocumentType>
+<fx:DocumentFileName>` + cii.FileName + `</fx:DocumentFileName>
+<fx:Version>1.0</fx:Version>
+<fx:ConformanceLevel>` + profile.String() + `</fx:ConformanceLevel>
+</rdf:Description>
+<rdf:Description rdf:about="" xmlns:pdfaExtension="http://www.aiim.org/pdfa/ns/extension/" xmlns:pdfaSchema="http://www.aiim.org/pdfa/ns/schema#" xmlns:pdfaProperty="http://www.aiim.org/pdfa/ns/property#">
+<pdfaExtension:schemas>
+<rdf:Bag>
+<rdf:li rdf:parseType="Resource">
+<pdfaSchema:schema>Factur-X PDFA Extension Schema</pdfaSchema:schema>
+<pdfaSchema:namespaceURI>urn:factur-x:pdfa:CrossIndustryDocument:invoice:1p0#</pdfaSchema:namespaceURI>
+<pdfaSchema:prefix>fx</pdfaSchema:prefix>
+<pdfaSchema:property>
+<rdf:Seq>
+` + property("DocumentFileName", "name of the embedded XML invoice file") +
+		property("DocumentType", "INVOICE") +
+		property("Version", "The actual version of the Factur-X XML schema") +
+		property("ConformanceLevel", "The conformance level of the embedded Factur-X data") + `</rdf:Seq>
+</pdfaSchema:property>
+</rdf:li>
+</rdf:Bag>
+</pdfaExtension:schemas>
+</rdf:Description>`
+}
+
+// facturXSupported returns true if the PDF of the invoice can be converted into a conforming PDF/A-3 document.
+// PDF/A requires all fonts to be embedded, which the native renderer doesn't do for the standard fonts,
+// and attachments merged into the document are not converted, so only LaTeX documents without
+// merged attachments are supported.
+func facturXSupported(company models.Company, invoice models.Invoice) bool {
+	return company.PDFRenderer == models.PDFRendererLaTeX && !invoice.MergeAttachments
+}
+
+// facturXPDF converts a PDF invoice into a PDF/A-3b document, with the invoice embedded
+// as Factur-X/ZUGFeRD XML, using the profile selected by the company
+func facturXPDF(invoice models.Invoice, pdf []byte) ([]byte, error) {
+	profile := invoice.Company.FacturXProfile
+
+	buf := &bytes.Buffer{}
+	err := buildFacturXInvoice(invoice, profile).Write(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	// The XML of the smaller profiles isn't a complete invoice, and only contains data from the PDF invoice
+	relationship := pdfa.RelationshipAlternative
+	if !profile.IsInvoice() {
+		relationship = pdfa.RelationshipData
+	}
+
+	title := fmt.Sprintf("Faktura %d", invoice.Number)
+	if invoice.IsCreditNote {
+		title = fmt.Sprintf("Kreditfaktura %d", invoice.Number)
+	}
+
+	return pdfa.Convert(pdf, pdfa.Options{
+		Title:    title,
+		Author:   invoice.Company.Name,
+		Producer: "FakturaPDF",
+		XMP:      facturXMetadata(profile),
+		Attachments: []pdfa.Attachment{{
+			Name:         cii.FileName,
+			Description:  "Factur-X/ZUGFeRD-faktura",
+			MIMEType:     "text/xml",
+			Relationship: relationship,
+			Data:         buf.Bytes(),
+		}},
+	})
+}
@@ -0,0 +1,134 @@
+package invoice
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/yzzyx/faktura-pdf/cii"
+	"github.com/yzzyx/faktura-pdf/models"
+)
+
+func facturXTestInvoice() models.Invoice {
+	inv := testInvoice()
+	inv.Company = models.Company{
+		Name:           "Trädgårdsfirman AB",
+		CompanyID:      "556036-0793",
+		VATNumber:      "SE556036079301",
+		PaymentType:    models.PaymentTypeBG,
+		PaymentAccount: "123-4567",
+		Email:          "info@example.com",
+	}
+	inv.Customer = models.Customer{Name: "Kund GmbH", City: "Berlin", Country: "DE", VATNumber: "DE123456789"}
+	return inv
+}
+
+func TestBuildFacturXInvoice(t *testing.T) {
+	buf := &bytes.Buffer{}
+	err := buildFacturXInvoice(facturXTestInvoice(), cii.ProfileEN16931).Write(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xml := buf.String()
+
+	// The elements must be in the order of the schema
+	expected := []string{
+		"<ram:ID>urn:cen.eu:en16931:2017</ram:ID>",
+		"<ram:ID>1001</ram:ID>",
+		"<ram:TypeCode>380</ram:TypeCode>",
+		`<udt:DateTimeString format="102">20241215</udt:DateTimeString>`,
+		"<ram:IncludedSupplyChainTradeLineItem>",
+		"<ram:BilledQuantity unitCode=\"C62\">3</ram:BilledQuantity>",
+		"<ram:LineTotalAmount>240.00</ram:LineTotalAmount>",
+		"<ram:SellerTradeParty>",
+		`<ram:ID schemeID="0007">5560360793</ram:ID>`,
+		"<ram:URIID>info@example.com</ram:URIID>",
+		`<ram:ID schemeID="VA">SE556036079301</ram:ID>`,
+		"<ram:BuyerTradeParty>",
+		"<ram:CountryID>DE</ram:CountryID>",
+		`<ram:ID schemeID="VA">DE123456789</ram:ID>`,
+		"<ram:ApplicableHeaderTradeDelivery></ram:ApplicableHeaderTradeDelivery>",
+		"<ram:InvoiceCurrencyCode>SEK</ram:InvoiceCurrencyCode>",
+		"<ram:ProprietaryID>1234567</ram:ProprietaryID>",
+		"<ram:CalculatedAmount>60.00</ram:CalculatedAmount>",
+		"<ram:CategoryCode>S</ram:CategoryCode>",
+		"<ram:RateApplicablePercent>25.00</ram:RateApplicablePercent>",
+		"<ram:TaxBasisTotalAmount>240.00</ram:TaxBasisTotalAmount>",
+		`<ram:TaxTotalAmount currencyID="SEK">60.00</ram:TaxTotalAmount>`,
+		"<ram:GrandTotalAmount>300.00</ram:GrandTotalAmount>",
+		"<ram:DuePayableAmount>300.00</ram:DuePayableAmount>",
+	}
+	pos := 0
+	for _, s := range expected {
+		k := strings.Index(xml[pos:], s)
+		if k < 0 {
+			t.Fatalf("expected %s after position %d in\n%s", s, pos, xml)
+		}
+		pos += k + len(s)
+	}
+
+	// The minimum profile only contains the totals of the invoice
+	minimum := buildFacturXInvoice(facturXTestInvoice(), cii.ProfileMinimum)
+	if minimum.Guideline != "urn:factur-x.eu:1p0:minimum" || len(minimum.Lines) > 0 || len(minimum.Settlement.Taxes) > 0 ||
+		minimum.Agreement.Seller.Contact != nil || minimum.Agreement.Buyer.Address != nil {
+		t.Errorf("expected the minimum profile to only contain the totals, got %+v", minimum)
+	}
+}
+
+func TestBuildFacturXCreditNote(t *testing.T) {
+	credited := 1000
+	inv := facturXTestInvoice()
+	inv.IsCreditNote = true
+	inv.CreditInvoiceNumber = &credited
+	inv.Rows[0].Count = inv.Rows[0].Count.Neg()
+	inv.Rows[0].Total = inv.Rows[0].Total.Neg()
+
+	doc := buildFacturXInvoice(inv, cii.ProfileEN16931)
+	if doc.TypeCode != cii.TypeCodeCreditNote {
+		t.Errorf("got type code %s, expected a credit note", doc.TypeCode)
+	}
+	if doc.Settlement.Summation.DuePayableAmount.Text != "300.00" || doc.Lines[0].BilledQuantity.Text != "3" {
+		t.Errorf("expected the amounts of the credit note to be positive")
+	}
+	if doc.Settlement.InvoiceReference == nil || doc.Settlement.InvoiceReference.IssuerAssignedID != "1000" {
+		t.Errorf("expected a reference to the credited invoice")
+	}
+}
+
+func TestFacturXPDF(t *testing.T) {
+	inv := facturXTestInvoice()
+	pdf, err := generateNativePDF(inv, pdfInvoice, pdfTokens(inv, nil), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := facturXPDF(inv, pdf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"/AFRelationship /Alternative", "(factur-x.xml)", "<fx:ConformanceLevel>EN 16931</fx:ConformanceLevel>", "/GTS_PDFA1"} {
+		if !bytes.Contains(data, []byte(s)) {
+			t.Errorf("expected the document to contain %s", s)
+		}
+	}
+}
+
+func TestFacturXSupported(t *testing.T) {
+	inv := facturXTestInvoice()
+	latex := models.Company{PDFRenderer: models.PDFRendererLaTeX}
+	native := models.Company{PDFRenderer: models.PDFRendererNative}
+
+	if !facturXSupported(latex, inv) {
+		t.Errorf("expected documents rendered with LaTeX to be supported")
+	}
+
+	// The native renderer doesn't embed its fonts, and merged attachments aren't PDF/A
+	if facturXSupported(native, inv) {
+		t.Errorf("expected documents from the native renderer to be refused")
+	}
+
+	inv.MergeAttachments = true
+	if facturXSupported(latex, inv) {
+		t.Errorf("expected documents with merged attachments to be refused")
+	}
+}
@@ -61,6 +61,11 @@ func (v *Flag) HandleGet() error {
 		return errors.New("invalid flag")
 	}
 
+	// Invoices that have been sent must be credited instead of removed
+	if flag == "deleted" && val && invoice.IsInvoiced && !v.IsOffer {
+		return errors.New("invoice is marked as sent and cannot be removed - create a credit note instead")
+	}
+
 	var createRUT, createInvoice bool
 
 	switch flag {
@@ -69,10 +74,30 @@ func (v *Flag) HandleGet() error {
 	case "invoiced":
 		invoice.IsInvoiced = val
 		invoice.DateInvoiced = &date
+		if val && invoice.OCR == "" {
+			invoice.OCR = v.Session.Company.OCR(invoice.Number)
+		}
 	case "paid":
+		// Register the outstanding amount as a payment
+		if val && !invoice.IsFullyPaid() {
+			err = registerPayment(v.Ctx, invoice, models.Payment{
+				Amount:   invoice.Outstanding(),
+				DatePaid: date,
+				Method:   defaultPaymentMethod(v.Session.Company),
+			})
+			if err != nil {
+				return err
+			}
+			return v.RedirectRoute("invoice-view", "id", strconv.Itoa(id))
+		}
+
+		// The paid flag follows the registered payments, which must be removed first
+		if !val && len(invoice.Payments) > 0 {
+			return errors.New("invoice has registered payments - remove the payments to mark it as unpaid")
+		}
 		invoice.IsPaid = val
 		invoice.DatePaid = &date
-		createRUT = invoice.RutApplicable && val
+		createRUT = invoice.RutApplicable && val && !invoice.IsCreditNote
 
 	// Flags for offers
 	case "offered":
@@ -103,6 +128,7 @@ func (v *Flag) HandleGet() error {
 	if createInvoice {
 		newInv := invoice
 		newInv.ID = 0
+		newInv.DateInvoiced = nil
 		newInv.DateDue = nil
 		newInv.Status = models.InvoiceStatusInitial
 		newInv.IsOffer = false
@@ -19,7 +19,7 @@ func NewInvoicePDF() *InvoicePDF {
 	return &InvoicePDF{}
 }
 
-// HandleGet renders a PDF invoice
+// HandleGet renders a PDF invoice. With format=facturx, the invoice is returned as a Factur-X/ZUGFeRD PDF/A-3 document.
 func (v *InvoicePDF) HandleGet() error {
 	f := models.InvoiceFilter{
 		ID:             v.URLParamInt("id"),
@@ -36,13 +36,30 @@ func (v *InvoicePDF) HandleGet() error {
 		return err
 	}
 
-	data, err := generatePDF(v.Ctx, invoice, "invoice.tex")
+	// Hybrid e-invoices are PDF/A-3 documents with the invoice embedded as XML
+	facturX := v.FormValueString("format") == "facturx"
+	if facturX && !facturXSupported(invoice.Company, invoice) {
+		return views.ErrBadRequest
+	}
+
+	data, err := renderPDF(v.Ctx, invoice, pdfInvoice, nil)
 	if err != nil {
 		return err
 	}
 
+	if facturX {
+		data, err = facturXPDF(invoice, data)
+		if err != nil {
+			return err
+		}
+	}
+
 	now := time.Now()
-	name := fmt.Sprintf("faktura-%d-%s-%s.pdf", invoice.Number, invoice.Name, now.Format("2006-01-02"))
+	prefix := "faktura"
+	if invoice.IsCreditNote {
+		prefix = "kreditfaktura"
+	}
+	name := fmt.Sprintf("%s-%d-%s-%s.pdf", prefix, invoice.Number, invoice.Name, now.Format("2006-01-02"))
 	name = strings.ReplaceAll(name, " ", "_")
 
 	headers := v.ResponseHeaders()
@@ -0,0 +1,267 @@
+package invoice
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/yzzyx/faktura-pdf/models"
+)
+
+// Delimiters of LaTeX templates, chosen so that they don't collide with the braces of LaTeX
+const (
+	latexLeftDelim  = "<<"
+	latexRightDelim = ">>"
+)
+
+var latexReplacer = strings.NewReplacer(`\`, `\textbackslash{}`,
+	`^`, `\textasciicircum{}`,
+	`~`, `\textasciitilde{}`,
+	`<`, `\textless{}`,
+	`>`, `\textgreater{}`,
+	`#`, `\#`,
+	`$`, `\$`,
+	`%`, `\%`,
+	`&`, `\&`,
+	`_`, `\_`,
+	`{`, `\{`,
+	`}`, `\}`)
+
+// latexEscape escapes the characters in s that have a special meaning in LaTeX.
+// Empty strings are replaced with a non-breaking space, so that lines ending with \\ are never empty.
+func latexEscape(s string) string {
+	if s == "" {
+		return "~"
+	}
+	return latexReplacer.Replace(s)
+}
+
+// latexRaw is text that is inserted in a LaTeX template without escaping
+type latexRaw string
+
+// latexValue formats a value for output in a LaTeX template.
+// Amounts are formatted with two decimals, and dates as YYYY-MM-DD.
+func latexValue(v interface{}) string {
+	// Optional values, such as dates that haven't been set yet, are printed as empty
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return latexEscape("")
+		}
+		return latexValue(rv.Elem().Interface())
+	}
+
+	switch t := v.(type) {
+	case latexRaw:
+		return string(t)
+	case string:
+		return latexEscape(t)
+	case decimal.Decimal:
+		return t.StringFixedBank(2)
+	case time.Time:
+		return t.Format("2006-01-02")
+	case fmt.Stringer:
+		return latexEscape(t.String())
+	case nil:
+		return latexEscape("")
+	}
+
+	return latexEscape(fmt.Sprint(v))
+}
+
+var latexFuncs = template.FuncMap{
+	"latex": latexValue,
+	"raw": func(s string) latexRaw {
+		return latexRaw(s)
+	},
+	"number": func(d decimal.Decimal) string {
+		return d.Truncate(2).String()
+	},
+	"neg": func(d decimal.Decimal) decimal.Decimal {
+		return d.Neg()
+	},
+	"join": strings.Join,
+}
+
+// latexRow is an invoice row, with the amounts printed on the invoice
+type latexRow struct {
+	models.InvoiceRow
+	Totals models.InvoiceTotals // Including VAT and ROT/RUT
+}
+
+// latexVATGroup is the sum of the rows with the same VAT type
+type latexVATGroup struct {
+	VAT    models.VATType
+	Excl   decimal.Decimal
+	Amount decimal.Decimal // VAT
+}
+
+// latexData is the information available to LaTeX templates
+type latexData struct {
+	Invoice   *models.Invoice
+	Company   models.Company
+	Customer  models.Customer
+	Totals    models.InvoiceTotals // Including VAT and ROT/RUT
+	ToPay     decimal.Decimal      // Total after ROT/RUT deduction
+	Rows      []latexRow
+	VATGroups []latexVATGroup
+	VATNotes  []string
+	QRImage   string
+
+	InvoiceDate time.Time
+	DueDate     time.Time
+
+	// The same information as in the tokens of old templates, by lowercase token name
+	Tokens map[string]string
+}
+
+// newLaTeXData collects the information available to LaTeX templates
+func newLaTeXData(invoice models.Invoice, tokens map[string]string) latexData {
+	data := latexData{
+		Invoice:  &invoice,
+		Company:  invoice.Company,
+		Customer: invoice.Customer,
+		Totals:   invoice.Totals(true, true),
+		VATNotes: invoice.VATNotes(),
+		QRImage:  tokens["qrimage"],
+		Tokens:   tokens,
+	}
+	data.ToPay = data.Totals.Incl.Sub(data.Totals.ROTRUT)
+	data.InvoiceDate, data.DueDate = pdfDates(invoice)
+
+	date := invoice.RateDate()
+	groups := map[models.VATType]int{}
+	for _, row := range invoice.Rows {
+		totals := row.Totals(date, true, true)
+		data.Rows = append(data.Rows, latexRow{InvoiceRow: row, Totals: totals})
+
+		k, ok := groups[row.VAT]
+		if !ok {
+			k = len(data.VATGroups)
+			groups[row.VAT] = k
+			data.VATGroups = append(data.VATGroups, latexVATGroup{VAT: row.VAT})
+		}
+		data.VATGroups[k].Excl = data.VATGroups[k].Excl.Add(totals.Excl)
+		data.VATGroups[k].Amount = data.VATGroups[k].Amount.Add(totals.Incl.Sub(totals.Excl))
+	}
+	return data
+}
+
+// isLaTeXTemplate returns true if the template uses the template language,
+// and false if it is an old template with <token>s
+func isLaTeXTemplate(text string) bool {
+	return strings.Contains(text, latexLeftDelim)
+}
+
+// escapeLaTeXActions adds the function 'latex' to the end of all actions in the node that print a value,
+// so that all output is escaped. Values passed through 'raw' or 'latex' are not escaped again.
+func escapeLaTeXActions(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			escapeLaTeXActions(c)
+		}
+	case *parse.IfNode:
+		escapeLaTeXActions(n.List)
+		escapeLaTeXActions(n.ElseList)
+	case *parse.RangeNode:
+		escapeLaTeXActions(n.List)
+		escapeLaTeXActions(n.ElseList)
+	case *parse.WithNode:
+		escapeLaTeXActions(n.List)
+		escapeLaTeXActions(n.ElseList)
+	case *parse.ActionNode:
+		if len(n.Pipe.Decl) > 0 || len(n.Pipe.Cmds) == 0 {
+			return
+		}
+
+		last := n.Pipe.Cmds[len(n.Pipe.Cmds)-1]
+		if id, ok := last.Args[0].(*parse.IdentifierNode); ok && (id.Ident == "raw" || id.Ident == "latex") {
+			return
+		}
+		n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+			NodeType: parse.NodeCommand,
+			Pos:      n.Pos,
+			Args:     []parse.Node{parse.NewIdentifier("latex").SetPos(n.Pos)},
+		})
+	}
+}
+
+// executeLaTeXTemplate renders a LaTeX template written in the template language
+func executeLaTeXTemplate(name string, text string, data latexData) (string, error) {
+	tmpl, err := template.New(filepath.Base(name)).
+		Delims(latexLeftDelim, latexRightDelim).
+		Funcs(latexFuncs).
+		Option("missingkey=zero").
+		Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			escapeLaTeXActions(t.Tree.Root)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	err = tmpl.Execute(buf, data)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// replaceLaTeXTokens renders an old template, by replacing <token>s with the information in tokens.
+// The part of the template between <row> and </row> is repeated for every invoice row.
+func replaceLaTeXTokens(text string, invoice models.Invoice, tokens map[string]string) string {
+	startRow := strings.Index(text, "<row>")
+	endRow := strings.Index(text, "</row>")
+
+	if startRow > -1 && endRow > -1 {
+		rowStr := text[startRow+5 : endRow]
+		rowData := ""
+
+		date := invoice.RateDate()
+		for _, row := range invoice.Rows {
+			rowTotals := row.Totals(date, true, true)
+
+			s := strings.ReplaceAll(rowStr, "<description>", latexEscape(row.Description))
+			s = strings.ReplaceAll(s, "<price>", rowTotals.PPU.StringFixedBank(2))
+			s = strings.ReplaceAll(s, "<count>", row.Count.Truncate(2).String())
+			s = strings.ReplaceAll(s, "<unit>", latexEscape(row.Unit.String()))
+			s = strings.ReplaceAll(s, "<vat>", latexEscape(row.VAT.String()))
+			s = strings.ReplaceAll(s, "<rowtotal>", rowTotals.Total.StringFixedBank(2))
+
+			rotRut := ""
+			if row.IsRotRut {
+				rotRut = "ja"
+			}
+			s = strings.ReplaceAll(s, "<isRotRut>", rotRut)
+			rowData += s
+		}
+		text = text[0:startRow] + rowData + text[endRow+6:]
+	}
+
+	re := regexp.MustCompile("<([^>]*)>")
+	matches := re.FindAllStringSubmatchIndex(text, -1)
+	updated := ""
+	prevPos := 0
+	for _, m := range matches {
+		keyname := strings.ToLower(text[m[2]:m[3]])
+		if v, ok := tokens[keyname]; ok {
+			updated += text[prevPos:m[0]] + latexEscape(v)
+			prevPos = m[1]
+		}
+	}
+	return updated + text[prevPos:]
+}
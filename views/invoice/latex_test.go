@@ -0,0 +1,73 @@
+package invoice
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/yzzyx/faktura-pdf/models"
+)
+
+func TestExecuteLaTeXTemplate(t *testing.T) {
+	inv := testInvoice()
+	inv.Company = models.Company{Name: "Smith & Söner"}
+	inv.Customer = models.Customer{Name: "50% rabatt_kund"}
+	inv.AdditionalInfo = `\input{/etc/passwd}`
+
+	tokens := pdfTokens(inv, nil)
+	text := `<<.Company.Name>>|<<.Customer.Name>>|<<.Invoice.AdditionalInfo>>|<<raw "\\hline">>|` +
+		`<<.Invoice.Number>>|<<.InvoiceDate>>|<<.Invoice.DatePaid>>|<<.ToPay>>|<<range .Rows>><<number .Count>> <<.Totals.Total>><<end>>|` +
+		`<<range .VATGroups>><<.VAT>> <<.Amount>><<end>>|<<.Tokens.ocr>>`
+	out, err := executeLaTeXTemplate("test.tex", text, newLaTeXData(inv, tokens))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `Smith \& Söner|50\% rabatt\_kund|\textbackslash{}input\{/etc/passwd\}|\hline|` +
+		`1001|2024-12-15|~|300.00|3 300.00|25 \% 60.00|` + inv.PaymentReference()
+	if out != expected {
+		t.Errorf("got\n%s\nexpected\n%s", out, expected)
+	}
+
+	_, err = executeLaTeXTemplate("test.tex", "<<.Invoice.Missing>>", newLaTeXData(inv, tokens))
+	if err == nil {
+		t.Errorf("expected an error for an unknown field")
+	}
+}
+
+func TestReplaceLaTeXTokens(t *testing.T) {
+	inv := testInvoice()
+	inv.Customer = models.Customer{Name: "A & B"}
+
+	text := `<customerName> <unknown> <row><description>;<count>;<rowtotal>
+</row><invoiceNumber>`
+	out := replaceLaTeXTokens(text, inv, pdfTokens(inv, nil))
+	expected := "A \\& B <unknown> ~;3;300.00\n1001"
+	if out != expected {
+		t.Errorf("got %q, expected %q", out, expected)
+	}
+}
+
+func TestInvoiceTemplate(t *testing.T) {
+	d, err := ioutil.ReadFile("../../invoice.tex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isLaTeXTemplate(string(d)) {
+		t.Fatalf("expected invoice.tex to use the template language")
+	}
+
+	credited := 1000
+	inv := testInvoice()
+	inv.IsCreditNote = true
+	inv.CreditInvoiceNumber = &credited
+	out, err := executeLaTeXTemplate("invoice.tex", string(d), newLaTeXData(inv, pdfTokens(inv, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"Kreditfaktura", "Krediterar faktura 1000", "Summa moms 25 \\%"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("expected the invoice to contain %q", s)
+		}
+	}
+}
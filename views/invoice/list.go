@@ -43,12 +43,19 @@ func (v *List) HandleGet() error {
 		filterPaid = true
 	}
 
+	filterOverdue := false
+	if !v.IsOffer && v.FormValueBool("overdue") {
+		f.Overdue = true
+		filterOverdue = true
+	}
+
 	invoices, err := models.InvoiceList(v.Ctx, f)
 	if err != nil {
 		return err
 	}
 
 	v.SetData("filterPaid", filterPaid)
+	v.SetData("filterOverdue", filterOverdue)
 	v.SetData("invoices", invoices)
 	v.SetData("isOffer", v.IsOffer)
 
@@ -0,0 +1,177 @@
+package invoice
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif" // Decoders of the image formats that can be attached
+	_ "image/jpeg"
+	"image/png"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/yzzyx/faktura-pdf/models"
+	"github.com/yzzyx/faktura-pdf/pdfa"
+	"github.com/yzzyx/zerr"
+)
+
+// imagePDF creates a PDF document with the image f on a page of its own, scaled to fit the page
+func imagePDF(f models.File) ([]byte, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(f.Contents))
+	if err != nil {
+		return nil, err
+	}
+
+	// JPEG images are embedded as they are, other images are converted to PNG images
+	// with 8 bits per channel, since that is what the PDF library supports
+	data := f.Contents
+	imageType := "JPG"
+	if format != "jpeg" {
+		img, _, err := image.Decode(bytes.NewReader(f.Contents))
+		if err != nil {
+			return nil, err
+		}
+		rgba := image.NewNRGBA(img.Bounds())
+		draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+
+		buf := &bytes.Buffer{}
+		err = png.Encode(buf, rgba)
+		if err != nil {
+			return nil, err
+		}
+		data = buf.Bytes()
+		imageType = "PNG"
+	}
+
+	orientation := "P"
+	if cfg.Width > cfg.Height {
+		orientation = "L"
+	}
+	pdf := gofpdf.New(orientation, "mm", "A4", "")
+	pdf.SetMargins(nativeMargin, nativeMargin, nativeMargin)
+	pdf.SetAutoPageBreak(false, nativeMargin)
+	pdf.AddPage()
+
+	info := pdf.RegisterImageOptionsReader(f.Name, gofpdf.ImageOptions{ImageType: imageType}, bytes.NewReader(data))
+	if err := pdf.Error(); err != nil {
+		return nil, err
+	}
+
+	// Large images are scaled down to fit the page, small images are kept in their original size
+	pageWidth, pageHeight := pdf.GetPageSize()
+	maxWidth, maxHeight := pageWidth-2*nativeMargin, pageHeight-2*nativeMargin
+	width, height := info.Extent()
+	scale := 1.0
+	if width*scale > maxWidth {
+		scale = maxWidth / width
+	}
+	if height*scale > maxHeight {
+		scale = maxHeight / height
+	}
+	width, height = width*scale, height*scale
+	pdf.ImageOptions(f.Name, (pageWidth-width)/2, nativeMargin, width, height, false, gofpdf.ImageOptions{}, 0, "")
+
+	buf := &bytes.Buffer{}
+	err = pdf.Output(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// attachmentIndex creates the page index of the attachments, where the attachment names[k] starts on page pages[k]
+func attachmentIndex(names []string, pages []int) ([]byte, error) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.SetMargins(nativeMargin, nativeMargin, nativeMargin)
+	pdf.SetAutoPageBreak(true, nativeMargin)
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 20)
+	pdf.SetTextColor(nativePrimary[0], nativePrimary[1], nativePrimary[2])
+	pdf.CellFormat(0, 12, "Bilagor", "", 1, "LB", false, 0, "")
+	pdf.SetTextColor(0, 0, 0)
+	pdf.Ln(4)
+
+	pdf.SetFont("Helvetica", "", 10)
+	for k, n := range names {
+		pdf.CellFormat(10, nativeLine, fmt.Sprintf("%d.", k+1), "", 0, "L", false, 0, "")
+		pdf.CellFormat(140, nativeLine, tr(n), "", 0, "L", false, 0, "")
+		pdf.CellFormat(0, nativeLine, tr(fmt.Sprintf("sida %d", pages[k])), "", 1, "R", false, 0, "")
+	}
+
+	buf := &bytes.Buffer{}
+	err := pdf.Output(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// mergeAttachments appends the attached PDF documents and images to the document, in the order of the attachments,
+// after a page index listing them. Other types of attachments are not included.
+func mergeAttachments(document []byte, attachments []models.File) ([]byte, error) {
+	var documents [][]byte
+	var names []string
+	var pageCounts []int
+	for _, a := range attachments {
+		var data []byte
+		var err error
+		switch {
+		case a.IsPDF():
+			data = a.Contents
+		case a.IsImage():
+			data, err = imagePDF(a)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, zerr.Wrap(err).WithString("filename", a.Name)
+		}
+
+		count, err := pdfa.PageCount(data)
+		if err != nil {
+			return nil, zerr.Wrap(err).WithString("filename", a.Name)
+		}
+		documents = append(documents, data)
+		names = append(names, a.Name)
+		pageCounts = append(pageCounts, count)
+	}
+
+	if len(documents) == 0 {
+		return document, nil
+	}
+
+	documentPages, err := pdfa.PageCount(document)
+	if err != nil {
+		return nil, err
+	}
+
+	// The page numbers in the index depends on the length of the index,
+	// so it is recreated until the number of pages in the index is known
+	var index []byte
+	indexPages := 1
+	for {
+		pages := make([]int, len(names))
+		next := documentPages + indexPages + 1
+		for k, count := range pageCounts {
+			pages[k] = next
+			next += count
+		}
+
+		index, err = attachmentIndex(names, pages)
+		if err != nil {
+			return nil, err
+		}
+		count, err := pdfa.PageCount(index)
+		if err != nil {
+			return nil, err
+		}
+		if count == indexPages {
+			break
+		}
+		indexPages = count
+	}
+
+	return pdfa.Merge(append([][]byte{document, index}, documents...)...)
+}
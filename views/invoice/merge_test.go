@@ -0,0 +1,64 @@
+package invoice
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/yzzyx/faktura-pdf/models"
+	"github.com/yzzyx/faktura-pdf/pdfa"
+)
+
+func TestMergeAttachments(t *testing.T) {
+	inv := testInvoice()
+	document, err := generateNativePDF(inv, pdfInvoice, pdfTokens(inv, nil), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attached, err := generateNativePDF(inv, pdfOffer, pdfTokens(inv, nil), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img := image.NewRGBA64(image.Rect(0, 0, 300, 200))
+	img.Set(10, 10, color.RGBA64{R: 0xffff, A: 0xffff})
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	attachments := []models.File{
+		{Name: "ritning.png", MIMEType: "image/png", Contents: buf.Bytes()},
+		{Name: "anteckningar.txt", MIMEType: "text/plain", Contents: []byte("Inte med")},
+		{Name: "offert.pdf", MIMEType: "application/pdf", Contents: attached},
+	}
+	merged, err := mergeAttachments(document, attachments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := pdfa.PageCount(merged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	documentPages, _ := pdfa.PageCount(document)
+	attachedPages, _ := pdfa.PageCount(attached)
+
+	// The index and the image are on one page each, the text file is left out
+	if expected := documentPages + 2 + attachedPages; count != expected {
+		t.Errorf("got %d pages, expected %d", count, expected)
+	}
+
+	// Documents without attachments are not changed
+	unchanged, err := mergeAttachments(document, attachments[1:2])
+	if err != nil || !bytes.Equal(unchanged, document) {
+		t.Errorf("expected the document to be unchanged, got %v", err)
+	}
+
+	_, err = mergeAttachments(document, []models.File{{Name: "trasig.jpg", MIMEType: "image/jpeg", Contents: []byte("inte en bild")}})
+	if err == nil {
+		t.Errorf("expected an error for an invalid image")
+	}
+}
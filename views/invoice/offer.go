@@ -37,7 +37,7 @@ func (v *OfferPDF) HandleGet() error {
 		return err
 	}
 
-	data, err := generatePDF(v.Ctx, invoice, "offer.tex")
+	data, err := renderPDF(v.Ctx, invoice, pdfOffer, nil)
 	if err != nil {
 		return err
 	}
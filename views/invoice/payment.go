@@ -0,0 +1,142 @@
+package invoice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/yzzyx/faktura-pdf/models"
+	"github.com/yzzyx/faktura-pdf/views"
+)
+
+// Payment is the view-handler for registering and removing payments of an invoice
+type Payment struct {
+	views.View
+}
+
+// NewPayment creates a new handler for registering payments
+func NewPayment() *Payment {
+	return &Payment{}
+}
+
+// setInvoicePaid marks an invoice as paid, and creates ROT/RUT-requests if applicable
+func setInvoicePaid(ctx context.Context, invoice models.Invoice, date time.Time) error {
+	invoice.IsPaid = true
+	invoice.DatePaid = &date
+
+	_, err := models.InvoiceSave(ctx, invoice)
+	if err != nil {
+		return err
+	}
+
+	if invoice.RutApplicable && !invoice.IsCreditNote {
+		return createROTRUTFromInvoice(ctx, invoice)
+	}
+	return nil
+}
+
+// registerPayment adds a payment to an invoice, and marks the invoice as paid
+// if the outstanding amount has been paid
+func registerPayment(ctx context.Context, invoice models.Invoice, p models.Payment) error {
+	var err error
+
+	// Payments of invoices are positive, and refunds of credit notes are negative
+	if p.Amount.IsZero() || p.Amount.IsNegative() != invoice.AmountDue().IsNegative() {
+		return fmt.Errorf("invalid amount %s for a payment of invoice %d", p.Amount, invoice.Number)
+	}
+
+	p.InvoiceID = invoice.ID
+	p.ID, err = models.PaymentAdd(ctx, p)
+	if err != nil {
+		return err
+	}
+
+	invoice.Payments = append(invoice.Payments, p)
+	if !invoice.IsPaid && invoice.IsFullyPaid() {
+		return setInvoicePaid(ctx, invoice, p.DatePaid)
+	}
+	return nil
+}
+
+// defaultPaymentMethod returns the payment method matching the account type of the company
+func defaultPaymentMethod(company models.Company) models.PaymentMethod {
+	if company.PaymentType == models.PaymentTypePG {
+		return models.PaymentMethodPlusgiro
+	}
+	return models.PaymentMethodBankgiro
+}
+
+// HandlePost registers a new payment, or removes the payment specified by 'remove'
+func (v *Payment) HandlePost() error {
+	id := v.URLParamInt("id")
+	if id <= 0 {
+		return views.ErrBadRequest
+	}
+
+	invoice, err := models.InvoiceGet(v.Ctx, models.InvoiceFilter{ID: id, CompanyID: v.Session.Company.ID})
+	if err != nil {
+		return err
+	}
+
+	if !invoice.IsInvoiced {
+		return errors.New("invoice is not marked as sent")
+	}
+
+	if paymentID := v.FormValueInt("remove"); paymentID > 0 {
+		err = models.PaymentRemove(v.Ctx, invoice.ID, paymentID)
+		if err != nil {
+			return err
+		}
+
+		var payments []models.Payment
+		for _, p := range invoice.Payments {
+			if p.ID != paymentID {
+				payments = append(payments, p)
+			}
+		}
+		invoice.Payments = payments
+
+		// The invoice is no longer fully paid
+		if invoice.IsPaid && !invoice.IsFullyPaid() {
+			invoice.IsPaid = false
+			invoice.DatePaid = nil
+			_, err = models.InvoiceSave(v.Ctx, invoice)
+			if err != nil {
+				return err
+			}
+		}
+
+		return v.RedirectRoute("invoice-view", "id", strconv.Itoa(invoice.ID))
+	}
+
+	amount, err := decimal.NewFromString(strings.ReplaceAll(strings.ReplaceAll(v.FormValueString("amount"), " ", ""), ",", "."))
+	if err != nil || amount.IsZero() {
+		return views.ErrBadRequest
+	}
+
+	date, err := time.Parse("2006-01-02", v.FormValueString("date"))
+	if err != nil {
+		return views.ErrBadRequest
+	}
+
+	method := models.PaymentMethod(v.FormValueInt("method"))
+	if !method.Validate() {
+		return views.ErrBadRequest
+	}
+
+	err = registerPayment(v.Ctx, invoice, models.Payment{
+		Amount:    amount.Round(2),
+		DatePaid:  date,
+		Method:    method,
+		Reference: v.FormValueString("reference"),
+	})
+	if err != nil {
+		return err
+	}
+
+	return v.RedirectRoute("invoice-view", "id", strconv.Itoa(invoice.ID))
+}
@@ -0,0 +1,33 @@
+package invoice
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/yzzyx/faktura-pdf/models"
+)
+
+func TestRegisterPaymentAmount(t *testing.T) {
+	creditNote := testInvoice()
+	creditNote.IsCreditNote = true
+	creditNote.Rows[0].Count = decimal.NewFromInt(-3)
+	creditNote.Rows[0].Total = decimal.NewFromInt(-300)
+
+	// Invalid amounts are rejected before anything is saved
+	tests := []struct {
+		invoice models.Invoice
+		amount  int64
+	}{
+		{testInvoice(), 0},
+		{testInvoice(), -100},
+		{creditNote, 100},
+	}
+
+	for _, tt := range tests {
+		err := registerPayment(context.Background(), tt.invoice, models.Payment{Amount: decimal.NewFromInt(tt.amount)})
+		if err == nil {
+			t.Errorf("expected a payment of %d to be rejected", tt.amount)
+		}
+	}
+}
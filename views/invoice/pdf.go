@@ -5,144 +5,160 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
-	"log"
-	"os"
-	"os/exec"
-	"path"
 	"path/filepath"
-	"regexp"
-	"strconv"
 	"strings"
 	"time"
 
+	"github.com/yzzyx/faktura-pdf/latex"
 	"github.com/yzzyx/faktura-pdf/models"
 )
 
-func generatePDF(ctx context.Context, invoice models.Invoice, templateFile string) (pdfFile []byte, err error) {
-	rep := strings.NewReplacer(`\`, `\textbackslash{}`,
-		`^`, `\textasciicircum{}`,
-		`~`, `\textasciitilde{}`,
-		`<`, `\textless{}`,
-		`>`, `\textgreater{}`,
-		`#`, `\#`,
-		`$`, `\$`,
-		`%`, `\%`,
-		`&`, `\&`,
-		`_`, `\_`,
-		`{`, `\{`,
-		`}`, `\}`)
-
-	latexEscape := func(s string) string {
-		if s == "" {
-			return "~"
-		}
-
-		return rep.Replace(s)
-	}
-
-	d, err := ioutil.ReadFile(templateFile)
-	if err != nil {
-		return nil, err
-	}
+// pdfDocument is a type of document that is rendered from an invoice
+type pdfDocument int
 
-	template := string(d)
+const (
+	pdfInvoice pdfDocument = iota
+	pdfOffer
+	pdfReminder
+)
 
-	invoicedate := time.Now()
-	dueDate := time.Now().AddDate(0, 1, 0)
-	if invoice.DateInvoiced != nil {
-		invoicedate = *invoice.DateInvoiced
+// templateFile returns the LaTeX template of the document. Companies can use their own invoice and offer templates.
+func (d pdfDocument) templateFile(company models.Company) string {
+	switch d {
+	case pdfOffer:
+		if company.OfferTemplate != "" {
+			return company.OfferTemplate
+		}
+		return "offer.tex"
+	case pdfReminder:
+		return "reminder.tex"
 	}
-
-	if invoice.DateDue != nil {
-		dueDate = *invoice.DateDue
+	if company.InvoiceTemplate != "" {
+		return company.InvoiceTemplate
 	}
+	return "invoice.tex"
+}
 
-	startRow := strings.Index(template, "<row>")
-	endRow := strings.Index(template, "</row>")
-
-	totals := models.InvoiceTotals{}
-
-	if startRow > -1 && endRow > -1 {
-		rowStr := template[startRow+5 : endRow]
-		rowData := ""
-
-		for _, row := range invoice.Rows {
-			rowTotals := row.Totals(true, true)
-			totals = totals.Add(rowTotals)
-
-			s := strings.ReplaceAll(rowStr, "<description>", latexEscape(row.Description))
-			s = strings.ReplaceAll(s, "<price>", rowTotals.PPU.StringFixedBank(2))
-			s = strings.ReplaceAll(s, "<count>", row.Count.Truncate(2).String())
-			s = strings.ReplaceAll(s, "<unit>", latexEscape(row.Unit.String()))
-			s = strings.ReplaceAll(s, "<vat>", latexEscape(row.VAT.String()))
-			s = strings.ReplaceAll(s, "<rowtotal>", rowTotals.Total.StringFixedBank(2))
+// renderPDF creates a PDF document from the invoice, with the renderer selected by the company.
+// Additional tokens can be specified in extraTokens, e.g. the amounts of a reminder.
+// Attached PDF documents and images are appended to invoices and offers, if selected on the invoice.
+func renderPDF(ctx context.Context, invoice models.Invoice, document pdfDocument, extraTokens map[string]string) ([]byte, error) {
+	merge := invoice.MergeAttachments && document != pdfReminder
 
-			rotRut := ""
-			if row.IsRotRut {
-				rotRut = "ja"
-			}
-			s = strings.ReplaceAll(s, "<isRotRut>", rotRut)
-			rowData += s
+	var attachments []models.File
+	var err error
+	if merge || invoice.Company.PDFRenderer == models.PDFRendererNative {
+		attachments, err = models.FileList(ctx, models.FileFilter{InvoiceID: invoice.ID, CompanyID: invoice.Company.ID, IncludeContent: merge})
+		if err != nil {
+			return nil, err
 		}
-		template = template[0:startRow] + rowData + template[endRow+6:]
 	}
 
-	tmpdir, err := ioutil.TempDir("", "faktura-pdf-*")
+	var data []byte
+	if invoice.Company.PDFRenderer == models.PDFRendererNative {
+		data, err = generateNativePDF(invoice, document, pdfTokens(invoice, extraTokens), attachments)
+	} else {
+		data, err = generatePDF(ctx, invoice, document.templateFile(invoice.Company), extraTokens)
+	}
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		err := os.RemoveAll(tmpdir)
-		if err != nil {
-			log.Printf("could not remove temp folder: %v", err)
-		}
-	}()
 
-	qrImagePath := path.Join(tmpdir, "qrimage.png")
-	qrImage, err := os.OpenFile(qrImagePath, os.O_RDWR|os.O_CREATE, 0755)
-	if err != nil {
-		return nil, err
+	if merge {
+		return mergeAttachments(data, attachments)
 	}
-	defer qrImage.Close()
+	return data, nil
+}
 
-	qrInfo := PaymentQRInfo{
+// paymentQRInfo returns the information in the payment QR code of the invoice
+func paymentQRInfo(invoice models.Invoice) PaymentQRInfo {
+	totals := invoice.Totals(true, true)
+	invoicedate, dueDate := pdfDates(invoice)
+	return PaymentQRInfo{
 		Version:          2,
 		Type:             1,
 		Name:             invoice.Company.Name,
 		CompanyID:        invoice.Company.CompanyID,
-		InvoiceReference: strconv.Itoa(invoice.Number),
+		InvoiceReference: invoice.PaymentReference(),
 		InvoiceDate:      invoicedate.Format("20060102"),
 		DueDate:          dueDate.Format("20060102"),
 		DueAmount:        totals.Incl.Sub(totals.ROTRUT),
 		PaymentType:      invoice.Company.PaymentType.String(),
 		Account:          invoice.Company.PaymentAccount,
 	}
+}
 
-	err = GenerateQR(qrInfo, qrImage)
-	if err != nil {
-		return nil, err
+// pdfDates returns the invoice date and the due date printed on the invoice.
+// Invoices that haven't been sent yet are shown as if they were sent today.
+func pdfDates(invoice models.Invoice) (invoicedate time.Time, dueDate time.Time) {
+	invoicedate = time.Now()
+	dueDate = time.Now().AddDate(0, 1, 0)
+	if invoice.DateInvoiced != nil {
+		invoicedate = *invoice.DateInvoiced
+	}
+
+	if invoice.DateDue != nil {
+		dueDate = *invoice.DateDue
+	}
+	return invoicedate, dueDate
+}
+
+// pdfTokens returns the information from the invoice that is printed on the documents, by lowercase token name.
+// Additional tokens can be specified in extraTokens, which overrides the tokens set by the invoice.
+func pdfTokens(invoice models.Invoice, extraTokens map[string]string) map[string]string {
+	totals := invoice.Totals(true, true)
+	invoicedate, dueDate := pdfDates(invoice)
+
+	invoiceTitle := "Faktura"
+	creditReference := ""
+	if invoice.IsCreditNote {
+		invoiceTitle = "Kreditfaktura"
+		if invoice.CreditInvoiceNumber != nil {
+			creditReference = fmt.Sprintf("Krediterar faktura %d", *invoice.CreditInvoiceNumber)
+		}
+	}
+
+	// Buyers sharing the ROT/RUT deduction are listed on the invoice
+	var buyers []string
+	for _, b := range invoice.Buyers {
+		buyers = append(buyers, fmt.Sprintf("%s (%s) %d %%", b.Name, b.PNR, b.Share))
+	}
+	rotRutBuyers := ""
+	if len(buyers) > 0 {
+		rotRutBuyers = "ROT/RUT-avdraget fördelas mellan köparna " + strings.Join(buyers, ", ")
+	}
+
+	// The buyer's VAT number and the reason that no VAT is charged must be given when the buyer accounts for the VAT
+	customerVATNumber := ""
+	if invoice.Customer.VATNumber != "" {
+		customerVATNumber = "Momsreg.nr: " + invoice.Customer.VATNumber
 	}
 
-	replaceMap := map[string]string{
-		"customername":     invoice.Customer.Name,
-		"customeremail":    invoice.Customer.Email,
-		"customeraddress1": invoice.Customer.Address1,
-		"customeraddress2": invoice.Customer.Address2,
-		"customerpostcode": invoice.Customer.Postcode,
-		"customercity":     invoice.Customer.City,
-		"invoicedate":      invoicedate.Format("2006-01-02"),
-		"duedate":          dueDate.Format("2006-01-02"),
-		"invoicenumber":    fmt.Sprintf("%d", invoice.Number),
-		"total":            totals.Total.StringFixedBank(2),
-		"totalexcl":        totals.Excl.StringFixedBank(2),
-		"totalinclrut":     totals.Incl.Sub(totals.ROTRUT).StringFixedBank(2),
-		"totalvat25":       totals.VAT25.StringFixedBank(2),
-		"totalvat12":       totals.VAT12.StringFixedBank(2),
-		"totalvat6":        totals.VAT6.StringFixedBank(2),
-		"totalrut":         totals.ROTRUT.StringFixedBank(2),
-		"totalrot":         totals.ROTRUT.StringFixedBank(2),
-		"additionalinfo":   invoice.AdditionalInfo,
-		"qrimage":          qrImagePath,
+	tokens := map[string]string{
+		"invoicetitle":      invoiceTitle,
+		"creditreference":   creditReference,
+		"customername":      invoice.Customer.Name,
+		"customeremail":     invoice.Customer.Email,
+		"customeraddress1":  invoice.Customer.Address1,
+		"customeraddress2":  invoice.Customer.Address2,
+		"customerpostcode":  invoice.Customer.Postcode,
+		"customercity":      invoice.Customer.City,
+		"customervatnumber": customerVATNumber,
+		"invoicedate":       invoicedate.Format("2006-01-02"),
+		"duedate":           dueDate.Format("2006-01-02"),
+		"invoicenumber":     fmt.Sprintf("%d", invoice.Number),
+		"ocr":               invoice.PaymentReference(),
+		"total":             totals.Total.StringFixedBank(2),
+		"totalexcl":         totals.Excl.StringFixedBank(2),
+		"totalinclrut":      totals.Incl.Sub(totals.ROTRUT).StringFixedBank(2),
+		"totalvat25":        totals.VAT25.StringFixedBank(2),
+		"totalvat12":        totals.VAT12.StringFixedBank(2),
+		"totalvat6":         totals.VAT6.StringFixedBank(2),
+		"totalrut":          totals.ROTRUT.StringFixedBank(2),
+		"totalrot":          totals.ROTRUT.StringFixedBank(2),
+		"additionalinfo":    invoice.AdditionalInfo,
+		"rotrutbuyers":      rotRutBuyers,
+		"vatnotes":          strings.Join(invoice.VATNotes(), ". "),
 
 		"companyname":           invoice.Company.Name,
 		"companyemail":          invoice.Company.Email,
@@ -159,41 +175,52 @@ func generatePDF(ctx context.Context, invoice models.Invoice, templateFile strin
 		"companyhomepage":       invoice.Company.Homepage,
 	}
 
-	re := regexp.MustCompile("<([^>]*)>")
-	matches := re.FindAllStringSubmatchIndex(template, -1)
-	updatedTemplate := ""
-	prevPos := 0
-	for _, m := range matches {
-		keyname := strings.ToLower(template[m[2]:m[3]])
-		if v, ok := replaceMap[keyname]; ok {
-			updatedTemplate += template[prevPos:m[0]] + latexEscape(v)
-			prevPos = m[1]
-		}
+	for k, v := range extraTokens {
+		tokens[strings.ToLower(k)] = v
 	}
-	template = updatedTemplate + template[prevPos:]
-
-	filename := fmt.Sprintf("invoice-%s-%d", time.Now().Format("2006-01-02"), invoice.Number)
-	cmd := exec.CommandContext(ctx, "xelatex",
-		"-jobname", filename,
-		"-output-directory", tmpdir,
-		"-no-shell-escape",
-		"-8bit",
-		"-file-line-error",
-		"-interaction=batch")
-	cmd.Stdin = bytes.NewBufferString(template)
-	stdout := &bytes.Buffer{}
-	cmd.Stdout = stdout
-	err = cmd.Run()
+	return tokens
+}
+
+// generatePDF creates a PDF from templateFile, either by executing it as a template with the information
+// from the invoice, or, for old templates, by replacing <token>s with information from the invoice.
+// Additional tokens can be specified in extraTokens, which overrides the tokens set by the invoice.
+// The document is created by LaTeX in the worker pool, and is aborted if ctx is cancelled.
+func generatePDF(ctx context.Context, invoice models.Invoice, templateFile string, extraTokens map[string]string) (pdfFile []byte, err error) {
+	d, err := ioutil.ReadFile(templateFile)
 	if err != nil {
-		fmt.Println(template)
-		fmt.Println(stdout.String())
 		return nil, err
 	}
 
-	pdfFile, err = ioutil.ReadFile(filepath.Join(tmpdir, filename+".pdf"))
+	qrImage := &bytes.Buffer{}
+	err = GenerateQR(paymentQRInfo(invoice), qrImage)
+	if err != nil {
+		return nil, err
+	}
+
+	// The QR code is written next to the document by the worker
+	replaceMap := pdfTokens(invoice, extraTokens)
+	replaceMap["qrimage"] = "qrimage.png"
+
+	template := string(d)
+	if isLaTeXTemplate(template) {
+		template, err = executeLaTeXTemplate(templateFile, template, newLaTeXData(invoice, replaceMap))
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		template = replaceLaTeXTokens(template, invoice, replaceMap)
+	}
+
+	// Files used by the template, e.g. logotypes, are found next to the template
+	inputDir, err := filepath.Abs(filepath.Dir(templateFile))
 	if err != nil {
 		return nil, err
 	}
 
-	return pdfFile, nil
+	return latex.Render(ctx, latex.Job{
+		Name:     fmt.Sprintf("invoice-%s-%d", time.Now().Format("2006-01-02"), invoice.Number),
+		Source:   template,
+		Files:    map[string][]byte{"qrimage.png": qrImage.Bytes()},
+		InputDir: inputDir,
+	})
 }
@@ -0,0 +1,408 @@
+package invoice
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/shopspring/decimal"
+	"github.com/yzzyx/faktura-pdf/models"
+)
+
+// Colors used by the native renderer, the same as in the LaTeX templates
+var (
+	nativePrimary   = [3]int{0x51, 0x95, 0x48}
+	nativeSecondary = [3]int{0x88, 0xC4, 0x25}
+	nativeGray      = [3]int{0xE6, 0xE6, 0xE6}
+)
+
+const (
+	nativeMargin    = 20.0 // Left, top and right margin, in mm
+	nativeFooter    = 40.0 // Space reserved for the footer, in mm
+	nativeLineSmall = 4.5  // Line height of small text
+	nativeLine      = 5.5  // Line height of normal text
+)
+
+// nativeColumn is a column in the table of invoice rows
+type nativeColumn struct {
+	title string
+	width float64
+	align string
+}
+
+var nativeColumns = []nativeColumn{
+	{"Beskrivning", 68, "L"},
+	{"à pris (inkl. moms)", 26, "R"},
+	{"Antal", 14, "R"},
+	{"Enhet", 14, "L"},
+	{"Totalt", 22, "R"},
+	{"Moms", 16, "R"},
+	{"ROT/RUT", 10, "C"},
+}
+
+// nativePDF renders invoices, offers and reminders in Go, without external tools
+type nativePDF struct {
+	*gofpdf.Fpdf
+	tr     func(string) string // Converts UTF-8 to the encoding of the core fonts
+	tokens map[string]string
+}
+
+// generateNativePDF creates a PDF document with the same layout as the LaTeX templates.
+// tokens contains the information printed on the document, as returned by pdfTokens.
+func generateNativePDF(invoice models.Invoice, document pdfDocument, tokens map[string]string, attachments []models.File) ([]byte, error) {
+	p := &nativePDF{Fpdf: gofpdf.New("P", "mm", "A4", ""), tokens: tokens}
+	p.tr = p.UnicodeTranslatorFromDescriptor("")
+	p.SetMargins(nativeMargin, nativeMargin, nativeMargin)
+	p.SetAutoPageBreak(true, nativeFooter)
+	p.AliasNbPages("")
+	p.SetCreator("faktura-pdf", true)
+	p.SetAuthor(invoice.Company.Name, true)
+	p.SetFooterFunc(func() { p.footer(invoice, document) })
+
+	title := tokens["invoicetitle"]
+	switch document {
+	case pdfOffer:
+		title = "Offert"
+	case pdfReminder:
+		title = "Påminnelse"
+	}
+	p.SetTitle(fmt.Sprintf("%s %s", title, tokens["invoicenumber"]), true)
+
+	p.AddPage()
+	p.header(title)
+
+	switch document {
+	case pdfInvoice:
+		var qrImage bytes.Buffer
+		err := GenerateQR(paymentQRInfo(invoice), &qrImage)
+		if err != nil {
+			return nil, err
+		}
+		p.RegisterImageOptionsReader("qr", gofpdf.ImageOptions{ImageType: "PNG"}, &qrImage)
+
+		p.boxes(true, [][2]string{
+			{"Att betala", tokens["totalinclrut"] + " kr"},
+			{"Förfallodatum", tokens["duedate"]},
+			{"Referensnummer / OCR", tokens["ocr"]},
+			{tokens["companypaymenttype"], tokens["companypaymentaccount"]},
+		})
+		p.details([][2]string{
+			{"Fakturanummer", tokens["invoicenumber"]}, {"Fakturadatum", tokens["invoicedate"]},
+			{"Vår referens", tokens["companyreference"]}, {"Förfallodatum", tokens["duedate"]},
+		})
+		p.rows(invoice)
+		p.totals(invoice)
+		p.texts(tokens["creditreference"], tokens["additionalinfo"], tokens["rotrutbuyers"], tokens["vatnotes"], invoice.Company.InvoiceText)
+	case pdfOffer:
+		p.boxes(false, nil)
+		p.details([][2]string{
+			{"Offertnummer", tokens["invoicenumber"]}, {"Offertdatum", tokens["invoicedate"]},
+			{"Vår referens", tokens["companyreference"]}, {"", ""},
+		})
+		p.rows(invoice)
+		p.totals(invoice)
+		p.texts(tokens["additionalinfo"], tokens["rotrutbuyers"], tokens["vatnotes"], invoice.Company.OfferText)
+	case pdfReminder:
+		p.boxes(false, [][2]string{
+			{"Att betala", tokens["remindertotal"] + " kr"},
+			{"Förfallodatum", tokens["reminderduedate"]},
+			{"Referensnummer / OCR", tokens["ocr"]},
+			{tokens["companypaymenttype"], tokens["companypaymentaccount"]},
+		})
+		p.details([][2]string{
+			{"Påminnelse nr", tokens["remindernumber"]}, {"Datum", tokens["reminderdate"]},
+			{"Fakturanummer", tokens["invoicenumber"]}, {"Fakturadatum", tokens["invoicedate"]},
+			{"Vår referens", tokens["companyreference"]}, {"Ursprungligt förfallodatum", tokens["duedate"]},
+		})
+		p.reminder()
+	}
+
+	var names []string
+	for _, a := range attachments {
+		names = append(names, a.Name)
+	}
+	if len(names) > 0 && document != pdfReminder {
+		p.texts("Bilagor: " + strings.Join(names, ", "))
+	}
+
+	var buf bytes.Buffer
+	err := p.Output(&buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (p *nativePDF) setTextColor(c [3]int) {
+	p.SetTextColor(c[0], c[1], c[2])
+}
+
+// header prints the title of the document and the name of the company
+func (p *nativePDF) header(title string) {
+	p.SetFont("Helvetica", "B", 28)
+	p.setTextColor(nativePrimary)
+	p.CellFormat(70, 14, p.tr(title), "", 0, "LB", false, 0, "")
+	p.SetFont("Helvetica", "B", 20)
+	p.setTextColor(nativeSecondary)
+	p.CellFormat(0, 14, p.tr(p.tokens["companyname"]), "", 1, "RB", false, 0, "")
+	p.SetTextColor(0, 0, 0)
+	p.Ln(8)
+}
+
+// boxes prints the customer details, and the payment details if there are any.
+// The payment QR code of the invoice is printed next to the payment details if qr is set.
+func (p *nativePDF) boxes(qr bool, payment [][2]string) {
+	const height = 32.0
+	x, y := p.GetXY()
+	p.SetDrawColor(0x99, 0x99, 0x99)
+
+	p.RoundedRect(x, y, 52, height, 2, "1234", "D")
+	p.SetXY(x+3, y+3)
+	p.SetFont("Helvetica", "B", 9)
+	p.CellFormat(46, nativeLineSmall, p.tr("Kunduppgifter"), "", 2, "L", false, 0, "")
+	p.SetFont("Helvetica", "", 9)
+	lines := []string{
+		p.tokens["customername"],
+		p.tokens["customeraddress1"],
+		p.tokens["customeraddress2"],
+		strings.TrimSpace(p.tokens["customerpostcode"] + " " + p.tokens["customercity"]),
+		p.tokens["customervatnumber"],
+	}
+	for _, l := range lines {
+		if l != "" {
+			p.CellFormat(46, nativeLineSmall, p.tr(l), "", 2, "L", false, 0, "")
+		}
+	}
+
+	if len(payment) > 0 {
+		px := x + 56
+		pw := 170 - 56.0
+		p.RoundedRect(px, y, pw, height, 2, "1234", "D")
+
+		labelWidth := pw - 6
+		if qr {
+			p.ImageOptions("qr", px+pw-29, y+2, 28, 28, false, gofpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+			labelWidth -= 30
+		}
+
+		p.SetFont("Helvetica", "", 10)
+		p.setTextColor(nativePrimary)
+		p.SetXY(px+3, y+(height-float64(len(payment))*nativeLine)/2)
+		for _, row := range payment {
+			p.SetX(px + 3)
+			p.CellFormat(44, nativeLine, p.tr(row[0]), "", 0, "L", false, 0, "")
+			p.CellFormat(labelWidth-44, nativeLine, p.tr(row[1]), "", 1, "R", false, 0, "")
+		}
+		p.SetTextColor(0, 0, 0)
+	}
+
+	p.SetXY(x, y+height+6)
+}
+
+// details prints pairs of labels and values, two pairs on each line
+func (p *nativePDF) details(pairs [][2]string) {
+	for k, pair := range pairs {
+		ln := 0
+		if k%2 == 1 {
+			ln = 1
+		}
+		p.SetFont("Helvetica", "B", 9)
+		p.CellFormat(45, 6, p.tr(pair[0]), "", 0, "L", false, 0, "")
+		p.SetFont("Helvetica", "", 9)
+		p.CellFormat(40, 6, p.tr(pair[1]), "", ln, "L", false, 0, "")
+	}
+	p.Ln(4)
+}
+
+// tableHeader prints the header of the table of invoice rows
+func (p *nativePDF) tableHeader() {
+	p.SetFont("Helvetica", "B", 8)
+	p.SetFillColor(nativePrimary[0], nativePrimary[1], nativePrimary[2])
+	p.SetTextColor(0xFF, 0xFF, 0xFF)
+	for _, c := range nativeColumns {
+		p.CellFormat(c.width, 7, p.tr(c.title), "", 0, c.align, true, 0, "")
+	}
+	p.Ln(-1)
+	p.SetTextColor(0, 0, 0)
+}
+
+// rows prints the table of invoice rows. Long descriptions are wrapped,
+// and the table header is repeated if the table continues on the next page.
+func (p *nativePDF) rows(invoice models.Invoice) {
+	_, pageHeight := p.GetPageSize()
+	p.tableHeader()
+
+	date := invoice.RateDate()
+	for k, row := range invoice.Rows {
+		rowTotals := row.Totals(date, true, true)
+		p.SetFont("Helvetica", "", 9)
+		description := p.SplitLines([]byte(p.tr(row.Description)), nativeColumns[0].width-2)
+		if len(description) == 0 {
+			description = [][]byte{nil}
+		}
+		height := float64(len(description)) * nativeLine
+
+		if p.GetY()+height > pageHeight-nativeFooter {
+			p.AddPage()
+			p.tableHeader()
+			p.SetFont("Helvetica", "", 9)
+		}
+
+		fill := k%2 == 1
+		p.SetFillColor(nativeGray[0], nativeGray[1], nativeGray[2])
+
+		rotRut := ""
+		if row.IsRotRut {
+			rotRut = "ja"
+		}
+		values := []string{
+			"",
+			rowTotals.PPU.StringFixedBank(2),
+			row.Count.Truncate(2).String(),
+			row.Unit.String(),
+			rowTotals.Total.StringFixedBank(2),
+			row.VAT.String(),
+			rotRut,
+		}
+
+		x, y := p.GetXY()
+		for n, c := range nativeColumns {
+			p.CellFormat(c.width, height, p.tr(values[n]), "", 0, c.align, fill, 0, "")
+		}
+		for n, line := range description {
+			p.SetXY(x+1, y+float64(n)*nativeLine)
+			p.CellFormat(nativeColumns[0].width-2, nativeLine, string(line), "", 0, "L", false, 0, "")
+		}
+		p.SetXY(x, y+height)
+	}
+	p.SetDrawColor(0x99, 0x99, 0x99)
+	p.Line(nativeMargin, p.GetY(), 210-nativeMargin, p.GetY())
+	p.Ln(2)
+}
+
+// nativeAmount formats an amount in kronor
+func nativeAmount(d decimal.Decimal) string {
+	return d.StringFixedBank(2) + " kr"
+}
+
+// totals prints the amount to pay, the ROT/RUT deduction and the VAT of each rate
+func (p *nativePDF) totals(invoice models.Invoice) {
+	totals := invoice.Totals(true, true)
+	var lines [][2]string
+	if !totals.ROTRUT.IsZero() {
+		lines = append(lines,
+			[2]string{"Totalt inkl. moms", nativeAmount(totals.Incl)},
+			[2]string{"Preliminär skattereduktion (ROT/RUT)", nativeAmount(totals.ROTRUT.Neg())},
+		)
+	}
+	lines = append(lines, [2]string{"Att betala", nativeAmount(totals.Incl.Sub(totals.ROTRUT))})
+
+	vat := []struct {
+		name   string
+		amount decimal.Decimal
+	}{{"25", totals.VAT25}, {"12", totals.VAT12}, {"6", totals.VAT6}}
+	for _, v := range vat {
+		if !v.amount.IsZero() {
+			lines = append(lines, [2]string{fmt.Sprintf("Varav moms (%s %%)", v.name), nativeAmount(v.amount)})
+		}
+	}
+
+	for _, l := range lines {
+		style := ""
+		if l[0] == "Att betala" {
+			style = "B"
+		}
+		p.SetFont("Helvetica", style, 10)
+		p.CellFormat(130, 6, p.tr(l[0]), "", 0, "R", false, 0, "")
+		p.CellFormat(40, 6, p.tr(l[1]), "", 1, "R", false, 0, "")
+	}
+	p.Ln(6)
+}
+
+// texts prints paragraphs of text, skipping the ones that are empty
+func (p *nativePDF) texts(paragraphs ...string) {
+	p.SetFont("Helvetica", "", 9)
+	for _, t := range paragraphs {
+		if strings.TrimSpace(t) == "" {
+			continue
+		}
+		p.MultiCell(0, nativeLineSmall, p.tr(t), "", "L", false)
+		p.Ln(2)
+	}
+}
+
+// reminder prints the specification of the amount to pay for a reminder
+func (p *nativePDF) reminder() {
+	t := p.tokens
+	p.texts(fmt.Sprintf("Enligt våra noteringar har nedanstående faktura ännu inte blivit betald. Vänligen betala det "+
+		"återstående beloppet senast %s. Om betalning redan har skett kan du bortse från denna påminnelse.", t["reminderduedate"]))
+
+	interest := fmt.Sprintf("Dröjsmålsränta %s %%", t["interestrate"])
+	if t["interestperiod"] != "" {
+		interest += fmt.Sprintf(" (%s)", t["interestperiod"])
+	}
+	lines := [][2]string{
+		{"Fakturabelopp", t["invoiceamount"] + " kr"},
+		{"Inbetalt", "-" + t["amountpaid"] + " kr"},
+		{"Tidigare påminnelseavgifter och räntor", t["previouscharges"] + " kr"},
+		{"Påminnelseavgift", t["reminderfee"] + " kr"},
+		{interest, t["reminderinterest"] + " kr"},
+	}
+
+	p.SetFont("Helvetica", "B", 8)
+	p.SetFillColor(nativePrimary[0], nativePrimary[1], nativePrimary[2])
+	p.SetTextColor(0xFF, 0xFF, 0xFF)
+	p.CellFormat(130, 7, p.tr("Specifikation"), "", 0, "L", true, 0, "")
+	p.CellFormat(40, 7, p.tr("Belopp"), "", 1, "R", true, 0, "")
+	p.SetTextColor(0, 0, 0)
+
+	p.SetFont("Helvetica", "", 9)
+	for _, l := range lines {
+		p.CellFormat(130, 6, p.tr(l[0]), "", 0, "L", false, 0, "")
+		p.CellFormat(40, 6, p.tr(l[1]), "", 1, "R", false, 0, "")
+	}
+	p.SetFont("Helvetica", "B", 9)
+	p.CellFormat(130, 7, p.tr("Att betala"), "T", 0, "L", false, 0, "")
+	p.CellFormat(40, 7, p.tr(t["remindertotal"]+" kr"), "T", 1, "R", false, 0, "")
+	p.Ln(6)
+
+	p.texts("Dröjsmålsränta debiteras enligt räntelagen med referensräntan plus 8 procentenheter.",
+		fmt.Sprintf("Märk betalningen med referensnummer %s.", t["ocr"]))
+}
+
+// footer prints the contact details of the company and the page number at the bottom of every page
+func (p *nativePDF) footer(invoice models.Invoice, document pdfDocument) {
+	t := p.tokens
+	p.SetY(-32)
+	p.SetFont("Helvetica", "", 8)
+
+	if document == pdfInvoice && invoice.Company.ReminderFee.IsPositive() {
+		p.CellFormat(0, nativeLineSmall, p.tr(fmt.Sprintf("Vid betalning efter förfallodagen tillkommer påminnelseavgift om %s kr samt dröjsmålsränta enligt räntelagen.",
+			invoice.Company.ReminderFee.StringFixedBank(0))), "", 1, "L", false, 0, "")
+	}
+
+	p.SetDrawColor(0x99, 0x99, 0x99)
+	p.Line(nativeMargin, p.GetY(), 210-nativeMargin, p.GetY())
+	p.Ln(1)
+
+	rows := [][6]string{
+		{"Telefon:", t["companytelephone"], "Org.nr.", t["companyid"], t["companypaymenttype"] + ":", t["companypaymentaccount"]},
+		{"Hemsida:", t["companyhomepage"], "VAT.nr.", t["companyvatnumber"], "E-post:", t["companyemail"]},
+	}
+	widths := [6]float64{16, 40, 14, 40, 16, 44}
+	for _, row := range rows {
+		for n, v := range row {
+			style := ""
+			if n%2 == 0 {
+				style = "B"
+			}
+			p.SetFont("Helvetica", style, 8)
+			p.CellFormat(widths[n], nativeLineSmall, p.tr(v), "", 0, "L", false, 0, "")
+		}
+		p.Ln(-1)
+	}
+
+	p.SetFont("Helvetica", "", 7)
+	p.CellFormat(0, nativeLineSmall, fmt.Sprintf("Sida %d av {nb}", p.PageNo()), "", 0, "R", false, 0, "")
+}
@@ -0,0 +1,48 @@
+package invoice
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/yzzyx/faktura-pdf/models"
+)
+
+func TestGenerateNativePDF(t *testing.T) {
+	rot := models.ROTServiceTypeBygg
+	inv := testInvoice()
+	inv.Company = models.Company{Name: "Trädgårdsfirman AB", PaymentType: models.PaymentTypeBG, PaymentAccount: "123-4567", ReminderFee: decimal.NewFromInt(60)}
+	inv.Customer = models.Customer{Name: "Åsa Öberg", Address1: "Ängsvägen 1", Postcode: "123 45", City: "Växjö"}
+	inv.AdditionalInfo = "Arbetet utfört under vecka 12"
+	inv.Rows = append(inv.Rows, models.InvoiceRow{
+		Description:       "Beskärning av fruktträd, bortforsling av ris och en lång beskrivning som inte får plats på en rad i tabellen",
+		Cost:              decimal.NewFromInt(500),
+		Count:             decimal.NewFromInt(4),
+		Total:             decimal.NewFromInt(2000),
+		IsRotRut:          true,
+		RotRutServiceType: &rot,
+	})
+
+	reminderTokens := map[string]string{"reminderTotal": "360.00", "reminderNumber": "1"}
+	for _, document := range []pdfDocument{pdfInvoice, pdfOffer, pdfReminder} {
+		data, err := generateNativePDF(inv, document, pdfTokens(inv, reminderTokens), []models.File{{Name: "ritning.pdf"}})
+		if err != nil {
+			t.Fatalf("document %d: %v", document, err)
+		}
+		if !bytes.HasPrefix(data, []byte("%PDF-")) {
+			t.Errorf("document %d: expected a PDF document", document)
+		}
+	}
+
+	// Rows that don't fit on one page continue on the next page
+	for i := 0; i < 60; i++ {
+		inv.Rows = append(inv.Rows, inv.Rows[0])
+	}
+	data, err := generateNativePDF(inv, pdfInvoice, pdfTokens(inv, nil), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte("/Count 3")) && !bytes.Contains(data, []byte("/Count 2")) {
+		t.Errorf("expected the invoice rows to continue on the next page")
+	}
+}
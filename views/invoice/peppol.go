@@ -0,0 +1,322 @@
+package invoice
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/shopspring/decimal"
+	"github.com/yzzyx/faktura-pdf/models"
+	"github.com/yzzyx/faktura-pdf/ubl"
+	"github.com/yzzyx/faktura-pdf/views"
+)
+
+// Peppol is the view-handler for exporting an invoice as a Peppol BIS Billing 3.0 document
+type Peppol struct {
+	views.View
+}
+
+// NewPeppol creates a new handler for exporting Peppol BIS Billing 3.0 documents
+func NewPeppol() *Peppol {
+	return &Peppol{}
+}
+
+// unitCodes maps our units to UN/ECE Recommendation 20 codes
+var unitCodes = map[models.UnitType]string{
+	0: "C62", // one
+	1: "H87", // piece
+	2: "HUR", // hour
+	3: "DAY", // day
+}
+
+// electronicAddressScheme is the Peppol scheme used for swedish organisation numbers
+const electronicAddressScheme = "0007"
+
+func onlyDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// buildPeppolInvoice converts an invoice into a Peppol BIS Billing 3.0 invoice or credit note
+func buildPeppolInvoice(invoice models.Invoice) *ubl.Invoice {
+	const currency = "SEK"
+
+	// Credit notes have negative rows, but all amounts in a UBL credit note are positive
+	sign := decimal.NewFromInt(1)
+	if invoice.IsCreditNote {
+		sign = sign.Neg()
+	}
+
+	issueDate := time.Now()
+	dueDate := time.Now().AddDate(0, 1, 0)
+	if invoice.DateInvoiced != nil {
+		issueDate = *invoice.DateInvoiced
+	}
+	if invoice.DateDue != nil {
+		dueDate = *invoice.DateDue
+	}
+
+	company := invoice.Company
+	customer := invoice.Customer
+	orgNr := onlyDigits(company.CompanyID)
+
+	doc := ubl.NewInvoice()
+	doc.ID = strconv.Itoa(invoice.Number)
+	doc.IssueDate = issueDate.Format("2006-01-02")
+	doc.DueDate = dueDate.Format("2006-01-02")
+	doc.DocumentCurrencyCode = currency
+	doc.BuyerReference = customer.Reference
+
+	if invoice.IsCreditNote && invoice.CreditInvoiceNumber != nil {
+		doc.BillingReference = &ubl.BillingReference{ID: strconv.Itoa(*invoice.CreditInvoiceNumber)}
+	}
+
+	doc.AccountingSupplierParty = ubl.Party{
+		EndpointID: ubl.Identifier{SchemeID: electronicAddressScheme, Value: orgNr},
+		Name:       company.Name,
+		PostalAddress: ubl.Address{
+			StreetName:           company.Address1,
+			AdditionalStreetName: company.Address2,
+			CityName:             company.City,
+			PostalZone:           company.Postcode,
+			CountryCode:          "SE",
+		},
+		PartyLegalEntity: ubl.PartyLegalEntity{
+			RegistrationName: company.Name,
+			CompanyID:        &ubl.Identifier{SchemeID: electronicAddressScheme, Value: orgNr},
+		},
+		Contact: &ubl.Contact{
+			Telephone: company.Telephone,
+			Email:     company.Email,
+		},
+	}
+
+	if company.VATNumber != "" {
+		doc.AccountingSupplierParty.PartyTaxScheme = append(doc.AccountingSupplierParty.PartyTaxScheme,
+			ubl.PartyTaxScheme{CompanyID: company.VATNumber, TaxScheme: ubl.TaxScheme{ID: "VAT"}})
+	}
+
+	// The Peppol ID is written as <scheme>:<identifier>, e.g. 0007:5560360793
+	var buyerEndpoint ubl.Identifier
+	if parts := strings.SplitN(customer.PeppolID, ":", 2); len(parts) == 2 {
+		buyerEndpoint = ubl.Identifier{SchemeID: strings.TrimSpace(parts[0]), Value: strings.TrimSpace(parts[1])}
+	}
+
+	doc.AccountingCustomerParty = ubl.Party{
+		EndpointID: buyerEndpoint,
+		Name:       customer.Name,
+		PostalAddress: ubl.Address{
+			StreetName:           customer.Address1,
+			AdditionalStreetName: customer.Address2,
+			CityName:             customer.City,
+			PostalZone:           customer.Postcode,
+			CountryCode:          customer.CountryCode(),
+		},
+		PartyLegalEntity: ubl.PartyLegalEntity{
+			RegistrationName: customer.Name,
+		},
+	}
+
+	if customer.VATNumber != "" {
+		doc.AccountingCustomerParty.PartyTaxScheme = append(doc.AccountingCustomerParty.PartyTaxScheme,
+			ubl.PartyTaxScheme{CompanyID: customer.VATNumber, TaxScheme: ubl.TaxScheme{ID: "VAT"}})
+	}
+
+	if customer.Email != "" || customer.Telephone != "" {
+		doc.AccountingCustomerParty.Contact = &ubl.Contact{
+			Telephone: customer.Telephone,
+			Email:     customer.Email,
+		}
+	}
+
+	branch := "SE:BANKGIRO"
+	if company.PaymentType == models.PaymentTypePG {
+		branch = "SE:PLUSGIRO"
+	}
+	doc.PaymentMeans = &ubl.PaymentMeans{
+		Code:      ubl.PaymentMeansCreditTransfer,
+		PaymentID: invoice.PaymentReference(),
+		PayeeAccount: &ubl.FinancialAccount{
+			ID:       onlyDigits(company.PaymentAccount),
+			BranchID: branch,
+		},
+	}
+
+	// Create lines, and group the taxable amounts per VAT category
+	type taxGroup struct {
+		category ubl.TaxCategory
+		rate     decimal.Decimal
+		taxable  decimal.Decimal
+	}
+	taxable := map[string]*taxGroup{}
+	categories := []string{}
+	lineSum := decimal.Zero
+	intraCommunity := false
+	date := invoice.RateDate()
+	for k, row := range invoice.Rows {
+		rowTotals := row.Totals(date, false, false)
+		quantity := row.Count.Mul(sign)
+		price := rowTotals.PPUExcl.Round(2)
+		lineAmount := price.Mul(quantity).Round(2)
+
+		category := peppolTaxCategory(row.VAT, row.IsGoods, date)
+		invoiced := ubl.NewQuantity(quantity, unitCodes[row.Unit])
+		doc.AddLine(ubl.Line{
+			ID:                  strconv.Itoa(k + 1),
+			InvoicedQuantity:    &invoiced,
+			LineExtensionAmount: ubl.NewAmount(lineAmount, currency),
+			Item: ubl.Item{
+				Name:        row.Description,
+				TaxCategory: category,
+			},
+			Price: ubl.Price{PriceAmount: ubl.NewAmount(price, currency)},
+		})
+
+		key := category.ID + " " + category.Percent
+		g, ok := taxable[key]
+		if !ok {
+			g = &taxGroup{category: category, rate: row.VAT.Rate(date)}
+			taxable[key] = g
+			categories = append(categories, key)
+		}
+		g.taxable = g.taxable.Add(lineAmount)
+		lineSum = lineSum.Add(lineAmount)
+		intraCommunity = intraCommunity || category.ID == ubl.VATCategoryIntraCommunity
+	}
+
+	// Intra-community supplies of goods must state where, and when, the goods were delivered
+	if intraCommunity {
+		doc.Delivery = &ubl.Delivery{
+			ActualDeliveryDate: doc.IssueDate,
+			CountryCode:        customer.CountryCode(),
+		}
+	}
+
+	taxSum := decimal.Zero
+	for _, key := range categories {
+		g := taxable[key]
+		taxAmount := g.taxable.Mul(g.rate).Round(2)
+		doc.TaxTotal.TaxSubtotals = append(doc.TaxTotal.TaxSubtotals, ubl.TaxSubtotal{
+			TaxableAmount: ubl.NewAmount(g.taxable, currency),
+			TaxAmount:     ubl.NewAmount(taxAmount, currency),
+			TaxCategory:   g.category,
+		})
+		taxSum = taxSum.Add(taxAmount)
+	}
+	doc.TaxTotal.TaxAmount = ubl.NewAmount(taxSum, currency)
+
+	taxInclusive := lineSum.Add(taxSum)
+	doc.LegalMonetaryTotal = ubl.MonetaryTotal{
+		LineExtensionAmount: ubl.NewAmount(lineSum, currency),
+		TaxExclusiveAmount:  ubl.NewAmount(lineSum, currency),
+		TaxInclusiveAmount:  ubl.NewAmount(taxInclusive, currency),
+	}
+
+	// The ROT/RUT deduction is paid by Skatteverket, and is therefore
+	// deducted from the amount the customer pays
+	totals := invoice.Totals(true, true)
+	payable := taxInclusive
+	rotRut := totals.ROTRUT.Mul(sign).Round(2)
+	if !rotRut.IsZero() {
+		prepaid := ubl.NewAmount(rotRut, currency)
+		doc.LegalMonetaryTotal.PrepaidAmount = &prepaid
+		doc.Note = fmt.Sprintf("Skattereduktion för ROT/RUT om %s kr har dragits av från beloppet att betala", rotRut.StringFixed(2))
+		payable = payable.Sub(rotRut)
+	}
+
+	// Make sure that the amount to pay is the same as on the PDF invoice
+	amountDue := totals.Customer.Mul(sign).Round(2)
+	if rounding := amountDue.Sub(payable); !rounding.IsZero() {
+		r := ubl.NewAmount(rounding, currency)
+		doc.LegalMonetaryTotal.PayableRoundingAmount = &r
+	}
+	doc.LegalMonetaryTotal.PayableAmount = ubl.NewAmount(amountDue, currency)
+
+	if invoice.IsCreditNote {
+		doc.ConvertToCreditNote()
+	}
+	return doc
+}
+
+// peppolTaxCategory returns the VAT category of a VAT type. Sales to other EU countries
+// are split into intra-community supply of goods, and services where the buyer accounts for the VAT.
+func peppolTaxCategory(vatType models.VATType, goods bool, date time.Time) ubl.TaxCategory {
+	rate := vatType.Rate(date).Mul(decimal.NewFromInt(100))
+	category := ubl.TaxCategory{
+		ID:        ubl.VATCategoryStandard,
+		Percent:   rate.StringFixed(2),
+		TaxScheme: ubl.TaxScheme{ID: "VAT"},
+	}
+
+	switch {
+	case vatType == models.VATTypeReverseCharge:
+		category.ID = ubl.VATCategoryReverseCharge
+		category.ExemptionReasonCode = "VATEX-EU-AE"
+		category.ExemptionReason = "Omvänd betalningsskyldighet"
+	case vatType == models.VATTypeEU && goods:
+		category.ID = ubl.VATCategoryIntraCommunity
+		category.ExemptionReasonCode = "VATEX-EU-IC"
+		category.ExemptionReason = "Unionsintern leverans"
+	case vatType == models.VATTypeEU:
+		category.ID = ubl.VATCategoryReverseCharge
+		category.ExemptionReasonCode = "VATEX-EU-AE"
+		category.ExemptionReason = "Omvänd betalningsskyldighet"
+	case vatType == models.VATTypeExport:
+		category.ID = ubl.VATCategoryExport
+		category.ExemptionReasonCode = "VATEX-EU-G"
+		category.ExemptionReason = "Export"
+	case rate.IsZero():
+		category.ID = ubl.VATCategoryExempt
+		category.ExemptionReason = "Momsfri"
+	}
+	return category
+}
+
+// HandleGet validates and returns the invoice as a Peppol BIS Billing 3.0 document
+func (v *Peppol) HandleGet() error {
+	f := models.InvoiceFilter{
+		ID:             v.URLParamInt("id"),
+		CompanyID:      v.Session.Company.ID,
+		IncludeCompany: true,
+	}
+
+	if f.ID <= 0 {
+		return views.ErrBadRequest
+	}
+
+	invoice, err := models.InvoiceGet(v.Ctx, f)
+	if err != nil {
+		return err
+	}
+
+	doc := buildPeppolInvoice(invoice)
+	if errs := doc.Validate(); len(errs) > 0 {
+		v.SetData("title", fmt.Sprintf("E-faktura för faktura %d kunde inte skapas", invoice.Number))
+		v.SetData("errors", errs)
+		v.SetData("invoice", invoice)
+		return v.Render("invoice/validation-errors.html")
+	}
+
+	b := &bytes.Buffer{}
+	err = doc.Write(b)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("faktura-%d.xml", invoice.Number)
+	if invoice.IsCreditNote {
+		name = fmt.Sprintf("kreditfaktura-%d.xml", invoice.Number)
+	}
+
+	headers := v.ResponseHeaders()
+	headers.Set("Content-Type", "application/xml")
+	headers.Set("Content-Disposition", "attachment; filename="+name)
+	return v.RenderBytes(b.Bytes())
+}
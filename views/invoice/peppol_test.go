@@ -0,0 +1,57 @@
+package invoice
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/yzzyx/faktura-pdf/models"
+	"github.com/yzzyx/faktura-pdf/ubl"
+)
+
+func TestBuildPeppolInvoiceRounding(t *testing.T) {
+	service := models.ROTServiceTypeBygg
+	inv := facturXTestInvoice()
+	inv.Rows = []models.InvoiceRow{
+		{Cost: decimal.RequireFromString("99.95"), Count: decimal.NewFromInt(1), Total: decimal.RequireFromString("99.95"), IsRotRut: true, RotRutServiceType: &service},
+	}
+
+	// 79.96 + 19.99 VAT - 29.99 ROT gives 69.96, while the customer pays 69.965 rounded to 69.97
+	doc := buildPeppolInvoice(inv)
+	totals := doc.LegalMonetaryTotal
+	if totals.PrepaidAmount == nil || totals.PrepaidAmount.Text != "29.99" {
+		t.Errorf("expected the ROT deduction as prepaid amount, got %+v", totals.PrepaidAmount)
+	}
+	if totals.PayableRoundingAmount == nil || totals.PayableRoundingAmount.Text != "0.01" {
+		t.Errorf("expected a rounding amount of 0.01, got %+v", totals.PayableRoundingAmount)
+	}
+	if totals.PayableAmount.Text != "69.97" {
+		t.Errorf("got payable amount %s, expected 69.97", totals.PayableAmount.Text)
+	}
+
+	for _, err := range doc.Validate() {
+		if e, ok := err.(ubl.ValidationError); ok && e.Rule == "BR-CO-16" {
+			t.Errorf("unexpected validation error: %s", err)
+		}
+	}
+}
+
+func TestBuildPeppolInvoiceIntraCommunity(t *testing.T) {
+	inv := facturXTestInvoice()
+	inv.Customer.Country = "de"
+	inv.Rows[0].VAT = models.VATTypeEU
+	inv.Rows[0].IsGoods = true
+
+	doc := buildPeppolInvoice(inv)
+	if code := doc.AccountingCustomerParty.PostalAddress.CountryCode; code != "DE" {
+		t.Errorf("got buyer country %s, expected DE", code)
+	}
+	if doc.Delivery == nil || doc.Delivery.CountryCode != "DE" || doc.Delivery.ActualDeliveryDate != "2024-12-15" {
+		t.Errorf("expected delivery to DE at the invoice date, got %+v", doc.Delivery)
+	}
+
+	for _, err := range doc.Validate() {
+		if e, ok := err.(ubl.ValidationError); ok && (e.Rule == "BR-IC-11" || e.Rule == "BR-IC-12") {
+			t.Errorf("unexpected validation error: %s", err)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package invoice
 
 import (
 	"encoding/json"
+	"image"
+	"image/draw"
 	"image/png"
 	"io"
 
@@ -43,6 +45,10 @@ func GenerateQR(info PaymentQRInfo, w io.Writer) error {
 		return err
 	}
 
-	err = png.Encode(w, qrcode)
+	// The code is encoded as an 8-bit grayscale image, since 16-bit images aren't supported by all PDF renderers
+	img := image.NewGray(qrcode.Bounds())
+	draw.Draw(img, img.Bounds(), qrcode, qrcode.Bounds().Min, draw.Src)
+
+	err = png.Encode(w, img)
 	return err
 }
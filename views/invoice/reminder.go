@@ -0,0 +1,226 @@
+package invoice
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/yzzyx/faktura-pdf/models"
+	"github.com/yzzyx/faktura-pdf/views"
+)
+
+// defaultReminderDueDays is the number of days the customer has to pay after a reminder is sent
+const defaultReminderDueDays = 10
+
+// Reminder is the view-handler for creating payment reminders, and getting reminder PDFs
+type Reminder struct {
+	views.View
+}
+
+// NewReminder creates a new handler for payment reminders
+func NewReminder() *Reminder {
+	return &Reminder{}
+}
+
+// reminderPrincipal returns the amount of the invoice that is still unpaid, excluding reminder charges
+func reminderPrincipal(invoice models.Invoice) decimal.Decimal {
+	principal := invoice.AmountDue().Sub(invoice.ReminderCharges()).Sub(invoice.AmountPaid())
+	if principal.IsNegative() {
+		return decimal.Zero
+	}
+	return principal
+}
+
+// HandleGet returns a reminder as PDF
+func (v *Reminder) HandleGet() error {
+	f := models.InvoiceFilter{
+		ID:             v.URLParamInt("id"),
+		CompanyID:      v.Session.Company.ID,
+		IncludeCompany: true,
+	}
+	reminderID := v.URLParamInt("reminder")
+
+	if f.ID <= 0 || reminderID <= 0 {
+		return views.ErrBadRequest
+	}
+
+	invoice, err := models.InvoiceGet(v.Ctx, f)
+	if err != nil {
+		return err
+	}
+
+	var reminder *models.Reminder
+	previousCharges := decimal.Zero
+	for k := range invoice.Reminders {
+		if invoice.Reminders[k].ID == reminderID {
+			reminder = &invoice.Reminders[k]
+			break
+		}
+		previousCharges = previousCharges.Add(invoice.Reminders[k].Total())
+	}
+
+	if reminder == nil {
+		return views.ErrNotFound
+	}
+
+	invoiceAmount := invoice.AmountDue().Sub(invoice.ReminderCharges())
+	amountPaid := invoice.AmountPaid()
+	total := invoiceAmount.Add(previousCharges).Add(reminder.Total()).Sub(amountPaid)
+
+	interestPeriod := ""
+	if reminder.InterestFrom != nil && reminder.InterestTo != nil {
+		interestPeriod = fmt.Sprintf("%s -- %s", reminder.InterestFrom.Format("2006-01-02"), reminder.InterestTo.Format("2006-01-02"))
+	}
+
+	tokens := map[string]string{
+		"reminderNumber":   strconv.Itoa(reminder.Number),
+		"reminderDate":     reminder.DateSent.Format("2006-01-02"),
+		"reminderDueDate":  reminder.DateDue.Format("2006-01-02"),
+		"reminderFee":      reminder.Fee.StringFixedBank(2),
+		"reminderInterest": reminder.Interest.StringFixedBank(2),
+		"interestRate":     reminder.InterestRate.StringFixedBank(2),
+		"interestPeriod":   interestPeriod,
+		"invoiceAmount":    invoiceAmount.StringFixedBank(2),
+		"amountPaid":       amountPaid.StringFixedBank(2),
+		"previousCharges":  previousCharges.StringFixedBank(2),
+		"reminderTotal":    total.StringFixedBank(2),
+	}
+
+	data, err := renderPDF(v.Ctx, invoice, pdfReminder, tokens)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("paminnelse-%d-%d-%s.pdf", invoice.Number, reminder.Number, invoice.Name)
+	name = strings.ReplaceAll(name, " ", "_")
+
+	headers := v.ResponseHeaders()
+	headers.Set("Content-Type", "application/pdf")
+	headers.Set("Content-Disposition", "attachment; filename="+name)
+	return v.RenderBytes(data)
+}
+
+// HandlePost creates a new reminder for an overdue invoice,
+// optionally charging the reminder fee of the company and late-payment interest
+func (v *Reminder) HandlePost() error {
+	id := v.URLParamInt("id")
+	if id <= 0 {
+		return views.ErrBadRequest
+	}
+
+	invoice, err := models.InvoiceGet(v.Ctx, models.InvoiceFilter{ID: id, CompanyID: v.Session.Company.ID})
+	if err != nil {
+		return err
+	}
+
+	if !invoice.IsOverdue() {
+		return errors.New("invoice is not overdue")
+	}
+
+	date := time.Now()
+	if v.FormValueExists("date") {
+		date, err = time.Parse("2006-01-02", v.FormValueString("date"))
+		if err != nil {
+			return views.ErrBadRequest
+		}
+	}
+
+	dueDate := date.AddDate(0, 0, defaultReminderDueDays)
+	if v.FormValueString("date_due") != "" {
+		dueDate, err = time.Parse("2006-01-02", v.FormValueString("date_due"))
+		if err != nil {
+			return views.ErrBadRequest
+		}
+	}
+
+	reminder := models.Reminder{
+		InvoiceID: invoice.ID,
+		Number:    len(invoice.Reminders) + 1,
+		DateSent:  date,
+		DateDue:   dueDate,
+	}
+
+	if v.FormValueBool("fee") {
+		reminder.Fee = v.Session.Company.ReminderFee
+	}
+
+	if v.FormValueBool("interest") {
+		rates, err := models.ReferenceRateList(v.Ctx)
+		if err != nil {
+			return err
+		}
+
+		// Interest is charged from the due date, or from where the previous reminder stopped
+		from := *invoice.DateDue
+		for _, r := range invoice.Reminders {
+			if r.InterestTo != nil && r.InterestTo.After(from) {
+				from = *r.InterestTo
+			}
+		}
+
+		if date.After(from) {
+			reminder.Interest = models.LateInterest(reminderPrincipal(invoice), from, date, rates)
+			reminder.InterestRate = models.LateInterestRate(rates, date)
+			reminder.InterestFrom = &from
+			reminder.InterestTo = &date
+		}
+	}
+
+	_, err = models.ReminderAdd(v.Ctx, reminder)
+	if err != nil {
+		return err
+	}
+
+	return v.RedirectRoute("invoice-view", "id", strconv.Itoa(invoice.ID))
+}
+
+// ReferenceRates is the view-handler for maintaining the table of reference rates
+type ReferenceRates struct {
+	views.View
+}
+
+// NewReferenceRates creates a new handler for the reference rates
+func NewReferenceRates() *ReferenceRates {
+	return &ReferenceRates{}
+}
+
+// HandleGet lists all reference rates
+func (v *ReferenceRates) HandleGet() error {
+	rates, err := models.ReferenceRateList(v.Ctx)
+	if err != nil {
+		return err
+	}
+
+	v.SetData("rates", rates)
+	v.SetData("margin", models.LateInterestMargin)
+	v.SetData("currentRate", models.LateInterestRate(rates, time.Now()))
+	return v.Render("invoice/reference-rates.html")
+}
+
+// HandlePost adds or updates a reference rate
+func (v *ReferenceRates) HandlePost() error {
+	dateFrom, err := time.Parse("2006-01-02", v.FormValueString("date_from"))
+	if err != nil {
+		return views.ErrBadRequest
+	}
+
+	// The reference rate is set for a half-year at a time
+	if dateFrom.Day() != 1 || (dateFrom.Month() != time.January && dateFrom.Month() != time.July) {
+		return errors.New("reference rates are valid from January 1st or July 1st")
+	}
+
+	rate, err := decimal.NewFromString(strings.ReplaceAll(v.FormValueString("rate"), ",", "."))
+	if err != nil {
+		return views.ErrBadRequest
+	}
+
+	err = models.ReferenceRateSave(v.Ctx, models.ReferenceRate{DateFrom: dateFrom, Rate: rate})
+	if err != nil {
+		return err
+	}
+
+	return v.RedirectRoute("reference-rates")
+}
@@ -20,6 +20,27 @@ func NewSIE() *SIE {
 	return &SIE{}
 }
 
+// newSIEExport returns an import file (4I) for the company, for a fiscal year.
+// The fiscal year is assumed to be the calendar year.
+func newSIEExport(company models.Company, year int) sie.SIE {
+	return sie.SIE{
+		Kind:           sie.KindImport,
+		Flag:           0,
+		Fnamn:          company.Name,
+		OrgNr:          company.CompanyID,
+		Program:        "FakturaPDF",
+		ProgramVersion: "0.1",
+		Type:           4,
+		FiscalYears: []sie.FiscalYear{
+			{
+				Start: time.Date(year, time.January, 1, 0, 0, 0, 0, time.Local),
+				End:   time.Date(year, time.December, 31, 0, 0, 0, 0, time.Local),
+			},
+		},
+		Checksum: true,
+	}
+}
+
 // HandleGet creates and sends a SIE file for the invoice that can be imported to accounting systems
 func (v *SIE) HandleGet() error {
 	var err error
@@ -36,56 +57,44 @@ func (v *SIE) HandleGet() error {
 		return fmt.Errorf("invoice is not marked as sent")
 	}
 
-	now := time.Now()
-	if invoice.DateInvoiced == nil {
-		invoice.DateInvoiced = &now
+	year := time.Now().Year()
+	if invoice.DateInvoiced != nil {
+		year = invoice.DateInvoiced.Year()
+	}
+
+	accounts, err := models.AccountMapGet(v.Ctx, invoice.Company.ID)
+	if err != nil {
+		return err
 	}
 
-	totals := invoice.Totals(true, true)
+	b := bookkeeping{method: invoice.Company.AccountingMethod, accounts: accounts}
+	export := newSIEExport(invoice.Company, year)
+	export.Verifications = b.invoiceVerifications(invoice)
 
-	export := sie.SIE{
-		Flag:           0,
-		Fnamn:          invoice.Company.Name,
-		Program:        "FakturaPDF",
-		ProgramVersion: "0.1",
-		Type:           4,
-		Verifications: []sie.Verification{
-			{
-				VerDatum: *invoice.DateInvoiced,
-				VerText:  fmt.Sprintf("Faktura #%d - %s", invoice.Number, invoice.Name),
-				Transactions: []sie.Transaction{
-					{KontoNr: 1510, Belopp: totals.Customer},                                   // Kundfodringar
-					{KontoNr: 1513, Belopp: totals.ROTRUT},                                     // Kundfodringar - delad faktura (ROT/RUT)
-					{KontoNr: 2611, Belopp: totals.VAT25.Add(totals.ROTRUTTotals.VAT25).Neg()}, // Utgående moms på försäljning inom Sverige, 25 %
-					{KontoNr: 2620, Belopp: totals.VAT12.Add(totals.ROTRUTTotals.VAT12).Neg()}, // Utgående moms 12 %
-					{KontoNr: 2630, Belopp: totals.VAT6.Add(totals.ROTRUTTotals.VAT6).Neg()},   // Utgående moms 6 %
-					{KontoNr: 3001, Belopp: totals.TotalVAT25.Neg()},                           // Försäljning varor inom Sverige, 25 % moms
-					{KontoNr: 3002, Belopp: totals.TotalVAT12.Neg()},                           // Försäljning varor inom Sverige, 12 % moms
-					{KontoNr: 3003, Belopp: totals.TotalVAT6.Neg()},                            // Försäljning varor inom Sverige, 6 % moms
-					//{KontoNr: 3740, Belopp: 0},                 // Öres- och kronutjämning
-				},
-			},
-		},
+	payouts, err := models.RUTList(v.Ctx, models.RUTFilter{InvoiceID: invoice.ID, CompanyID: v.Session.Company.ID})
+	if err != nil {
+		return err
 	}
 
-	if invoice.IsPaid {
-		if invoice.DatePaid == nil {
-			invoice.DatePaid = &now
+	// An invoice with both ROT and RUT rows has one request for each
+	for k := range payouts {
+		payouts[k].Invoice = invoice
+		if payouts[k].Status == models.RUTStatusPaid && payouts[k].DatePaid != nil {
+			export.Verifications = append(export.Verifications, b.rutVerification(payouts[k]))
 		}
+	}
 
-		export.Verifications = append(export.Verifications, sie.Verification{
-			VerDatum: *invoice.DatePaid,
-			VerText:  fmt.Sprintf("Faktura #%d - %s betalad", invoice.Number, invoice.Name),
-			Transactions: []sie.Transaction{
-				{KontoNr: 1510, Belopp: totals.Customer.Neg()}, // Kundfodringar
-				{KontoNr: 1930, Belopp: totals.Customer},       // Företags/affärskonto
-			},
-		})
+	// With the cash method, the unpaid amount is booked at the end of every fiscal year that has passed
+	if b.method == models.AccountingMethodCash {
+		for y := year; y < time.Now().Year(); y++ {
+			export.Verifications = append(export.Verifications, b.yearEndVerifications(invoice, payouts, y)...)
+		}
 	}
+	export.AddAccounts(accounts.Names())
 
-	b := &bytes.Buffer{}
+	buf := &bytes.Buffer{}
 
-	err = export.Write(b)
+	err = export.Write(buf)
 	if err != nil {
 		return err
 	}
@@ -93,5 +102,5 @@ func (v *SIE) HandleGet() error {
 	name := fmt.Sprintf("faktura-%d.si", invoice.Number)
 	headers := v.ResponseHeaders()
 	headers.Set("Content-Disposition", "attachment; filename="+name)
-	return v.RenderBytes(b.Bytes())
+	return v.RenderBytes(buf.Bytes())
 }
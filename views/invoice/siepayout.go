@@ -0,0 +1,81 @@
+package invoice
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/yzzyx/faktura-pdf/models"
+	"github.com/yzzyx/faktura-pdf/sie"
+	"github.com/yzzyx/faktura-pdf/views"
+)
+
+// SIEPayout is the view-handler for getting a SIE file with ROT/RUT payouts from Skatteverket
+type SIEPayout struct {
+	views.View
+}
+
+// NewSIEPayout creates a new handler for getting a SIE file with ROT/RUT payouts
+func NewSIEPayout() *SIEPayout {
+	return &SIEPayout{}
+}
+
+// HandleGet creates and sends a SIE file with verifications for the paid ROT/RUT requests given by the parameter 'id'
+func (v *SIEPayout) HandleGet() error {
+	company := v.Session.Company
+	accounts, err := models.AccountMapGet(v.Ctx, company.ID)
+	if err != nil {
+		return err
+	}
+	b := bookkeeping{method: company.AccountingMethod, accounts: accounts}
+
+	var verifications []sie.Verification
+	for _, id := range v.FormValueStringSlice("id") {
+		rutID, err := strconv.Atoi(id)
+		if err != nil || rutID <= 0 {
+			return views.ErrBadRequest
+		}
+
+		payout, err := models.RUTGet(v.Ctx, models.RUTFilter{
+			ID:             rutID,
+			CompanyID:      company.ID,
+			FilterStatus:   []models.RUTStatus{models.RUTStatusPaid},
+			IncludeInvoice: true,
+		})
+		if err != nil {
+			return err
+		}
+
+		if payout.DatePaid == nil {
+			continue
+		}
+		verifications = append(verifications, b.rutVerification(payout))
+	}
+
+	if len(verifications) == 0 {
+		return views.ErrBadRequest
+	}
+
+	sort.SliceStable(verifications, func(i, j int) bool {
+		return verifications[i].VerDatum.Before(verifications[j].VerDatum)
+	})
+
+	// The fiscal year of the file is the year of the latest payout
+	last := verifications[len(verifications)-1].VerDatum
+	export := newSIEExport(company, last.Year())
+	export.Verifications = verifications
+	export.AddAccounts(accounts.Names())
+
+	buf := &bytes.Buffer{}
+	err = export.Write(buf)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("utbetalningar-%s.si", time.Now().Format("20060102"))
+	headers := v.ResponseHeaders()
+	headers.Set("Content-Disposition", "attachment; filename="+name)
+	return v.RenderBytes(buf.Bytes())
+}
@@ -0,0 +1,150 @@
+package invoice
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/yzzyx/faktura-pdf/models"
+	"github.com/yzzyx/faktura-pdf/views"
+)
+
+// siePeriodSerie is the verification series used in period exports
+const siePeriodSerie = "F"
+
+// Period describes a date range that can be exported
+type Period struct {
+	Name string
+	From time.Time
+	To   time.Time
+}
+
+// SIEPeriod is the view-handler for exporting all invoices and payments in a period as one SIE file
+type SIEPeriod struct {
+	views.View
+}
+
+// NewSIEPeriod creates a new handler for exporting SIE files for a period
+func NewSIEPeriod() *SIEPeriod {
+	return &SIEPeriod{}
+}
+
+// periods returns the periods that can be selected directly
+func periods(now time.Time) []Period {
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	quarter := time.Date(now.Year(), ((now.Month()-1)/3)*3+1, 1, 0, 0, 0, 0, time.Local)
+	year := time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, time.Local)
+
+	return []Period{
+		{Name: "Denna månad", From: month, To: month.AddDate(0, 1, -1)},
+		{Name: "Föregående månad", From: month.AddDate(0, -1, 0), To: month.AddDate(0, 0, -1)},
+		{Name: "Detta kvartal", From: quarter, To: quarter.AddDate(0, 3, -1)},
+		{Name: "Föregående kvartal", From: quarter.AddDate(0, -3, 0), To: quarter.AddDate(0, 0, -1)},
+		{Name: "Detta räkenskapsår", From: year, To: year.AddDate(1, 0, -1)},
+		{Name: "Föregående räkenskapsår", From: year.AddDate(-1, 0, 0), To: year.AddDate(0, 0, -1)},
+	}
+}
+
+// inPeriod checks if t is within the period, including both the first and the last day
+func inPeriod(t time.Time, from, to time.Time) bool {
+	return !t.Before(from) && t.Before(to.AddDate(0, 0, 1))
+}
+
+// HandleGet shows the export form, or, if 'from' and 'to' are set, sends a SIE file
+// with all invoices, credit notes, reminders, payments and ROT/RUT payouts in the period
+func (v *SIEPeriod) HandleGet() error {
+	v.SetData("periods", periods(time.Now()))
+	v.SetData("serie", siePeriodSerie)
+
+	if !v.FormValueExists("from") || !v.FormValueExists("to") {
+		return v.Render("invoice/sie-period.html")
+	}
+
+	from, err := time.ParseInLocation("2006-01-02", v.FormValueString("from"), time.Local)
+	if err != nil {
+		return views.ErrBadRequest
+	}
+
+	to, err := time.ParseInLocation("2006-01-02", v.FormValueString("to"), time.Local)
+	if err != nil {
+		return views.ErrBadRequest
+	}
+
+	v.SetData("from", from)
+	v.SetData("to", to)
+
+	// The fiscal year is assumed to be the calendar year, and a SIE file only covers one fiscal year
+	if to.Before(from) || from.Year() != to.Year() {
+		v.SetData("error", "Perioden måste ligga inom ett räkenskapsår")
+		return v.Render("invoice/sie-period.html")
+	}
+
+	payouts, err := models.RUTList(v.Ctx, models.RUTFilter{
+		CompanyID:      v.Session.Company.ID,
+		IncludeInvoice: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	accounts, err := models.AccountMapGet(v.Ctx, v.Session.Company.ID)
+	if err != nil {
+		return err
+	}
+
+	b := bookkeeping{method: v.Session.Company.AccountingMethod, accounts: accounts}
+	export := newSIEExport(v.Session.Company, from.Year())
+	payoutsByInvoice := map[int][]models.RUT{}
+	for _, r := range payouts {
+		payoutsByInvoice[r.Invoice.ID] = append(payoutsByInvoice[r.Invoice.ID], r)
+		if r.Status == models.RUTStatusPaid && r.DatePaid != nil && inPeriod(*r.DatePaid, from, to) {
+			export.Verifications = append(export.Verifications, b.rutVerification(r))
+		}
+	}
+
+	invoices, err := models.InvoiceList(v.Ctx, models.InvoiceFilter{CompanyID: v.Session.Company.ID, OnlyInvoiced: true})
+	if err != nil {
+		return err
+	}
+
+	for _, invoice := range invoices {
+		verifications := b.invoiceVerifications(invoice)
+
+		// With the cash method, unpaid invoices are booked at the end of the previous and the current
+		// fiscal year, and the bookings are reversed on the first day of the following year
+		if b.method == models.AccountingMethodCash {
+			verifications = append(verifications, b.yearEndVerifications(invoice, payoutsByInvoice[invoice.ID], from.Year()-1)...)
+			verifications = append(verifications, b.yearEndVerifications(invoice, payoutsByInvoice[invoice.ID], from.Year())...)
+		}
+
+		for _, ver := range verifications {
+			if inPeriod(ver.VerDatum, from, to) {
+				export.Verifications = append(export.Verifications, ver)
+			}
+		}
+	}
+
+	// Verifications are numbered in date order
+	sort.SliceStable(export.Verifications, func(i, j int) bool {
+		return export.Verifications[i].VerDatum.Before(export.Verifications[j].VerDatum)
+	})
+	for k := range export.Verifications {
+		export.Verifications[k].Serie = siePeriodSerie
+		export.Verifications[k].VerNr = strconv.Itoa(k + 1)
+	}
+
+	export.AddAccounts(accounts.Names())
+
+	buf := &bytes.Buffer{}
+	err = export.Write(buf)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("bokforing-%s-%s.si", from.Format("20060102"), to.Format("20060102"))
+	headers := v.ResponseHeaders()
+	headers.Set("Content-Disposition", "attachment; filename="+name)
+	return v.RenderBytes(buf.Bytes())
+}
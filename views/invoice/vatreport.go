@@ -0,0 +1,204 @@
+package invoice
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/yzzyx/faktura-pdf/models"
+	"github.com/yzzyx/faktura-pdf/views"
+)
+
+// VATBox is a box in the VAT return (momsdeklaration)
+type VATBox struct {
+	Number int
+	Name   string
+	Amount decimal.Decimal
+}
+
+// Kronor returns the amount of the box in whole kronor, as it is reported to Skatteverket
+func (b VATBox) Kronor() int64 {
+	return b.Amount.IntPart()
+}
+
+// VATReport is the view-handler for summarizing the sales of a period into the boxes of the VAT return
+type VATReport struct {
+	views.View
+}
+
+// NewVATReport creates a new handler for the VAT report
+func NewVATReport() *VATReport {
+	return &VATReport{}
+}
+
+// vatReportShare is a part of an invoice that is reported in the VAT return
+type vatReportShare struct {
+	Invoice models.Invoice
+	Date    time.Time       // Date that decides which period the part is reported in
+	Share   decimal.Decimal // Part of the amounts of the invoice, 1 for the whole invoice
+}
+
+// vatReportShares returns the parts of the invoice that are reported in the period between 'from' and 'to'.
+// With the accrual method, the whole invoice is reported at the invoice date. With the cash method,
+// every payment and ROT/RUT payout is reported at the date it was received, in proportion to its share
+// of the invoice, and the unpaid part is reported at the end of the fiscal year and reversed on the first
+// day of the following year, like the bookkeeping does.
+func vatReportShares(invoice models.Invoice, payouts []models.RUT, method models.AccountingMethod, from, to time.Time) []vatReportShare {
+	if invoice.DateInvoiced == nil {
+		return nil
+	}
+
+	var shares []vatReportShare
+	add := func(date time.Time, share decimal.Decimal) {
+		if !share.IsZero() && inPeriod(date, from, to) {
+			shares = append(shares, vatReportShare{Invoice: invoice, Date: date, Share: share})
+		}
+	}
+
+	if method != models.AccountingMethodCash {
+		add(*invoice.DateInvoiced, decimal.NewFromInt(1))
+		return shares
+	}
+
+	total := invoice.Totals(true, true).Incl.Add(invoice.ReminderCharges())
+	if total.IsZero() {
+		return nil
+	}
+
+	for _, p := range invoice.Payments {
+		add(p.DatePaid, p.Amount.Div(total))
+	}
+
+	for _, r := range payouts {
+		if r.Status == models.RUTStatusPaid && r.DatePaid != nil && r.ReceivedSum != nil {
+			add(*r.DatePaid, decimal.NewFromInt(int64(*r.ReceivedSum)).Div(total))
+		}
+	}
+
+	for year := from.Year() - 1; year <= to.Year(); year++ {
+		end := time.Date(year, time.December, 31, 0, 0, 0, 0, time.Local)
+		if invoice.DateInvoiced.After(end) {
+			continue
+		}
+
+		atEnd, customer, rotrut := outstandingAt(invoice, payouts, end)
+		total := atEnd.Totals(true, true).Incl.Add(atEnd.ReminderCharges())
+		if total.IsZero() {
+			continue
+		}
+
+		share := customer.Add(rotrut).Div(total)
+		add(end, share)
+		add(end.AddDate(0, 0, 1), share.Neg())
+	}
+	return shares
+}
+
+// vatReportBoxes sums up the rows of the reported parts of the invoices into the boxes of the VAT return.
+// Credit notes have negative rows, and therefore reduce the amounts.
+func vatReportBoxes(shares []vatReportShare) []VATBox {
+	boxes := []VATBox{
+		{Number: 5, Name: "Momspliktig försäljning som inte ingår i ruta 06, 07 eller 08"},
+		{Number: 10, Name: "Utgående moms 25 %"},
+		{Number: 11, Name: "Utgående moms 12 %"},
+		{Number: 12, Name: "Utgående moms 6 %"},
+		{Number: 35, Name: "Försäljning av varor till ett annat EU-land"},
+		{Number: 36, Name: "Försäljning av varor utanför EU"},
+		{Number: 39, Name: "Försäljning av tjänster till näringsidkare i annat EU-land enligt huvudregeln"},
+		{Number: 40, Name: "Övrig försäljning av tjänster omsatta utanför Sverige"},
+		{Number: 41, Name: "Försäljning när köparen är betalningsskyldig i Sverige"},
+		{Number: 42, Name: "Övrig försäljning m.m."},
+	}
+	index := map[int]int{}
+	for k, b := range boxes {
+		index[b.Number] = k
+	}
+	add := func(box int, amount decimal.Decimal) {
+		boxes[index[box]].Amount = boxes[index[box]].Amount.Add(amount)
+	}
+
+	outputVAT := map[models.VATType]int{0: 10, 1: 11, 2: 12}
+	for _, s := range shares {
+		date := s.Invoice.RateDate()
+		for _, row := range s.Invoice.Rows {
+			totals := row.Totals(date, true, true)
+			excl := totals.Excl.Mul(s.Share).Round(2)
+			switch {
+			case row.VAT == models.VATTypeReverseCharge:
+				add(41, excl)
+			case row.VAT == models.VATTypeEU && row.IsGoods:
+				add(35, excl)
+			case row.VAT == models.VATTypeEU:
+				add(39, excl)
+			case row.VAT == models.VATTypeExport && row.IsGoods:
+				add(36, excl)
+			case row.VAT == models.VATTypeExport:
+				add(40, excl)
+			case outputVAT[row.VAT] > 0:
+				add(5, excl)
+				add(outputVAT[row.VAT], totals.Incl.Sub(totals.Excl).Mul(s.Share).Round(2))
+			default:
+				add(42, excl)
+			}
+		}
+	}
+	return boxes
+}
+
+// HandleGet shows the period selection, or, if 'from' and 'to' are set,
+// the boxes of the VAT return for all invoices in the period
+func (v *VATReport) HandleGet() error {
+	v.SetData("periods", periods(time.Now()))
+
+	if !v.FormValueExists("from") || !v.FormValueExists("to") {
+		return v.Render("invoice/vat-report.html")
+	}
+
+	from, err := time.ParseInLocation("2006-01-02", v.FormValueString("from"), time.Local)
+	if err != nil {
+		return views.ErrBadRequest
+	}
+
+	to, err := time.ParseInLocation("2006-01-02", v.FormValueString("to"), time.Local)
+	if err != nil {
+		return views.ErrBadRequest
+	}
+
+	v.SetData("from", from)
+	v.SetData("to", to)
+
+	if to.Before(from) {
+		v.SetData("error", "Periodens slutdatum är före startdatum")
+		return v.Render("invoice/vat-report.html")
+	}
+
+	invoices, err := models.InvoiceList(v.Ctx, models.InvoiceFilter{CompanyID: v.Session.Company.ID, OnlyInvoiced: true})
+	if err != nil {
+		return err
+	}
+
+	payouts, err := models.RUTList(v.Ctx, models.RUTFilter{CompanyID: v.Session.Company.ID})
+	if err != nil {
+		return err
+	}
+
+	payoutsByInvoice := map[int][]models.RUT{}
+	for _, r := range payouts {
+		payoutsByInvoice[r.Invoice.ID] = append(payoutsByInvoice[r.Invoice.ID], r)
+	}
+
+	method := v.Session.Company.AccountingMethod
+	var shares []vatReportShare
+	var included []models.Invoice
+	for _, invoice := range invoices {
+		invoiceShares := vatReportShares(invoice, payoutsByInvoice[invoice.ID], method, from, to)
+		if len(invoiceShares) > 0 {
+			shares = append(shares, invoiceShares...)
+			included = append(included, invoice)
+		}
+	}
+
+	v.SetData("method", method)
+	v.SetData("invoices", included)
+	v.SetData("boxes", vatReportBoxes(shares))
+	return v.Render("invoice/vat-report.html")
+}
@@ -0,0 +1,119 @@
+package invoice
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/yzzyx/faktura-pdf/models"
+)
+
+func TestVATReportBoxes(t *testing.T) {
+	invoiced := date("2025-03-10")
+	row := func(cost int64, vat models.VATType, goods bool) models.InvoiceRow {
+		return models.InvoiceRow{Cost: decimal.NewFromInt(cost), Count: decimal.NewFromInt(1), Total: decimal.NewFromInt(cost), VAT: vat, IsGoods: goods}
+	}
+
+	invoices := []models.Invoice{
+		{DateInvoiced: &invoiced, Rows: []models.InvoiceRow{
+			row(1250, 0, false),
+			row(112, 1, true),
+			row(100, 3, false),
+		}},
+		{DateInvoiced: &invoiced, Rows: []models.InvoiceRow{
+			row(1000, models.VATTypeReverseCharge, false),
+			row(2000, models.VATTypeEU, true),
+			row(3000, models.VATTypeEU, false),
+			row(4000, models.VATTypeExport, true),
+			row(5000, models.VATTypeExport, false),
+		}},
+		// Credit note
+		{DateInvoiced: &invoiced, IsCreditNote: true, Rows: []models.InvoiceRow{
+			{Cost: decimal.NewFromInt(125), Count: decimal.NewFromInt(-1), Total: decimal.NewFromInt(-125), VAT: 0},
+		}},
+	}
+
+	expected := map[int]int64{5: 1000 + 100 - 100, 10: 250 - 25, 11: 12, 12: 0, 35: 2000, 36: 4000, 39: 3000, 40: 5000, 41: 1000, 42: 100}
+	var shares []vatReportShare
+	for _, inv := range invoices {
+		shares = append(shares, vatReportShares(inv, nil, models.AccountingMethodAccrual, date("2025-01-01"), date("2025-03-31"))...)
+	}
+
+	for _, box := range vatReportBoxes(shares) {
+		if box.Kronor() != expected[box.Number] {
+			t.Errorf("expected %d in box %d, got %s", expected[box.Number], box.Number, box.Amount)
+		}
+	}
+}
+
+func TestVATReportSharesCash(t *testing.T) {
+	invoiced := date("2025-03-10")
+	inv := models.Invoice{
+		DateInvoiced: &invoiced,
+		Rows: []models.InvoiceRow{
+			{Cost: decimal.NewFromInt(1250), Count: decimal.NewFromInt(1), Total: decimal.NewFromInt(1250), VAT: 0},
+		},
+		Payments: []models.Payment{
+			{Amount: decimal.NewFromInt(500), DatePaid: date("2025-03-20")},
+			{Amount: decimal.NewFromInt(750), DatePaid: date("2025-05-10")},
+		},
+	}
+
+	// Each payment is reported in its own period, in proportion to its share of the invoice
+	tests := []struct {
+		from, to string
+		sales    int64
+		vat      int64
+	}{
+		{"2025-01-01", "2025-03-31", 400, 100},
+		{"2025-04-01", "2025-06-30", 600, 150},
+		{"2025-01-01", "2025-12-31", 1000, 250},
+	}
+
+	for _, tt := range tests {
+		boxes := vatReportBoxes(vatReportShares(inv, nil, models.AccountingMethodCash, date(tt.from), date(tt.to)))
+		for _, box := range boxes {
+			if (box.Number == 5 && box.Kronor() != tt.sales) || (box.Number == 10 && box.Kronor() != tt.vat) {
+				t.Errorf("%s - %s: got %s in box %d, expected sales %d and VAT %d", tt.from, tt.to, box.Amount, box.Number, tt.sales, tt.vat)
+			}
+		}
+	}
+}
+
+func TestVATReportSharesYearEnd(t *testing.T) {
+	// 300 including VAT, with 100 paid in 2024 and 200 in 2025
+	inv := testInvoice()
+
+	// The unpaid part is reported at the end of 2024, so the whole invoice is reported in 2024
+	boxes := vatReportBoxes(vatReportShares(inv, nil, models.AccountingMethodCash, date("2024-10-01"), date("2024-12-31")))
+	if boxes[0].Kronor() != 240 || boxes[1].Kronor() != 60 {
+		t.Errorf("expected the whole invoice in the last period of 2024, got %s and %s", boxes[0].Amount, boxes[1].Amount)
+	}
+
+	// The payment in 2025 is cancelled out by the reversal of the year end booking
+	boxes = vatReportBoxes(vatReportShares(inv, nil, models.AccountingMethodCash, date("2025-01-01"), date("2025-03-31")))
+	if !boxes[0].Amount.IsZero() || !boxes[1].Amount.IsZero() {
+		t.Errorf("expected nothing reported in 2025, got %s and %s", boxes[0].Amount, boxes[1].Amount)
+	}
+}
+
+func TestInvoiceVerificationsReverseCharge(t *testing.T) {
+	inv := testInvoice()
+	inv.Rows = []models.InvoiceRow{
+		{Cost: decimal.NewFromInt(1000), Count: decimal.NewFromInt(1), Total: decimal.NewFromInt(1000), VAT: models.VATTypeReverseCharge},
+		{Cost: decimal.NewFromInt(200), Count: decimal.NewFromInt(1), Total: decimal.NewFromInt(200), VAT: models.VATTypeEU, IsGoods: true},
+		{Cost: decimal.NewFromInt(300), Count: decimal.NewFromInt(1), Total: decimal.NewFromInt(300), VAT: models.VATTypeExport},
+	}
+
+	b := bookkeeping{method: models.AccountingMethodAccrual, accounts: models.DefaultAccountMap}
+	ver := b.invoiceVerifications(inv)[0]
+	if !ver.Sum().IsZero() {
+		t.Errorf("verification is not balanced: %s", ver.Sum())
+	}
+
+	expected := map[int]int64{1510: 1500, 3231: -1000, 3108: -200, 3305: -300, 2611: 0}
+	for nr, amount := range expected {
+		if !balance(ver.Transactions, nr).Equal(decimal.NewFromInt(amount)) {
+			t.Errorf("expected %d on account %d, got %s", amount, nr, balance(ver.Transactions, nr))
+		}
+	}
+}
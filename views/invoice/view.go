@@ -48,6 +48,7 @@ func (v *View) HandleGet() error {
 	v.SetData("today", time.Now())
 	v.SetData("defaultDueDate", time.Now().AddDate(0, 1, 0))
 	v.SetData("isOffer", v.IsOffer)
+	v.SetData("facturX", facturXSupported(v.Session.Company, invoice))
 
 	if invoice.ID > 0 {
 		attachments, err := models.FileList(v.Ctx, models.FileFilter{
@@ -60,6 +61,45 @@ func (v *View) HandleGet() error {
 		}
 		v.SetData("attachments", attachments)
 
+		if !v.IsOffer {
+			v.SetData("amountDue", invoice.AmountDue())
+			v.SetData("amountPaid", invoice.AmountPaid())
+			v.SetData("outstanding", invoice.Outstanding())
+			v.SetData("paymentMethods", models.PaymentMethods)
+			v.SetData("defaultPaymentMethod", defaultPaymentMethod(v.Session.Company))
+		}
+
+		if invoice.IsOverdue() {
+			rates, err := models.ReferenceRateList(v.Ctx)
+			if err != nil {
+				return err
+			}
+			v.SetData("lateInterestRate", models.LateInterestRate(rates, time.Now()))
+			v.SetData("reminderFee", v.Session.Company.ReminderFee)
+			v.SetData("reminderDueDate", time.Now().AddDate(0, 0, defaultReminderDueDays))
+		}
+
+		if !v.IsOffer && !invoice.IsCreditNote {
+			creditNotes, err := models.InvoiceList(v.Ctx, models.InvoiceFilter{
+				CompanyID:       v.Session.Company.ID,
+				CreditInvoiceID: invoice.ID,
+			})
+			if err != nil {
+				return err
+			}
+			v.SetData("creditNotes", creditNotes)
+		}
+
+		if invoice.RutApplicable && !invoice.IsCreditNote {
+			v.SetData("buyerShares", invoice.BuyerShares())
+		}
+
+		if !v.IsOffer && invoice.RutApplicable && !invoice.IsCreditNote {
+			err = v.setDeductionData(invoice)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	// Used to create list of ROT/RUT services in invoice row modal
@@ -67,6 +107,7 @@ func (v *View) HandleGet() error {
 	v.SetData("rotServices", models.ROTServices)
 	v.SetData("defaultRUTService", models.RUTServiceTypeTradgardsarbete)
 	v.SetData("defaultROTService", models.ROTServiceTypeBygg)
+	v.SetData("rates", invoiceRates(invoice.RateDate()))
 
 	if invoice.DateDue != nil {
 		daysLeft := invoice.DateDue.Sub(time.Now()) / (time.Hour * 24)
@@ -76,6 +117,69 @@ func (v *View) HandleGet() error {
 	return v.Render("invoice/view.html")
 }
 
+// invoiceRates returns the VAT and ROT/RUT rates in force at date, as fractions.
+// They are used to calculate the totals of the invoice when rows are changed.
+func invoiceRates(date time.Time) map[string]interface{} {
+	vat := map[models.VATType]float64{}
+	for _, vatType := range models.VATTypeList() {
+		vat[vatType], _ = vatType.Rate(date).Float64()
+	}
+
+	rot, _ := models.ROTServiceTypeBygg.DeductionRate(date).Float64()
+	rut, _ := models.RUTServiceTypeStadning.DeductionRate(date).Float64()
+	return map[string]interface{}{
+		"vat": vat,
+		"rot": rot,
+		"rut": rut,
+	}
+}
+
+// buyerDeduction is the yearly ROT/RUT deduction of one of the buyers of an invoice
+type buyerDeduction struct {
+	Buyer    models.Buyer
+	Usage    models.DeductionUsage
+	ROT      int // The buyer's share of the ROT deduction of the invoice
+	RUT      int // The buyer's share of the RUT deduction of the invoice
+	Exceeded bool
+}
+
+// setDeductionData shows how much of each buyer's yearly ROT/RUT deduction is used,
+// and if the buyer's share of the deduction of the invoice would exceed what is left.
+// The deduction counts towards the year the invoice is paid, or the current year if it is not yet paid.
+func (v *View) setDeductionData(invoice models.Invoice) error {
+	year := time.Now().Year()
+	if invoice.DatePaid != nil {
+		year = invoice.DatePaid.Year()
+	}
+
+	rot, rut := invoice.Deduction()
+	buyers := invoice.ROTRUTBuyers()
+	rotShares := models.SplitByShare(int64(rot), buyers)
+	rutShares := models.SplitByShare(int64(rut), buyers)
+
+	var deductions []buyerDeduction
+	for k, b := range buyers {
+		if b.PNR == "" {
+			continue
+		}
+
+		usage, err := models.DeductionUsageGet(v.Ctx, v.Session.Company.ID, b.PNR, year, invoice.ID)
+		if err != nil {
+			return err
+		}
+
+		d := buyerDeduction{Buyer: b, Usage: usage, ROT: int(rotShares[k]), RUT: int(rutShares[k])}
+		d.Exceeded = usage.Exceeds(d.ROT, d.RUT)
+		deductions = append(deductions, d)
+	}
+
+	v.SetData("deductionYear", year)
+	v.SetData("deductions", deductions)
+	v.SetData("deductionLimit", models.DeductionLimit)
+	v.SetData("deductionLimitROT", models.DeductionLimitROT)
+	return nil
+}
+
 // HandlePost saves/updates an invoice
 func (v *View) HandlePost() error {
 	var err error
@@ -99,18 +203,25 @@ func (v *View) HandlePost() error {
 
 	var customerID int
 	fields := map[string]interface{}{
-		"customer.id":        &customerID,
-		"customer.name":      &invoice.Customer.Name,
-		"customer.email":     &invoice.Customer.Email,
-		"customer.address1":  &invoice.Customer.Address1,
-		"customer.address2":  &invoice.Customer.Address2,
-		"customer.postcode":  &invoice.Customer.Postcode,
-		"customer.city":      &invoice.Customer.City,
-		"customer.pnr":       &invoice.Customer.PNR,
-		"customer.telephone": &invoice.Customer.Telephone,
-		"additional_info":    &invoice.AdditionalInfo,
-		"date_due":           &invoice.DateDue,
-		"date_invoiced":      &invoice.DateInvoiced,
+		"customer.id":                     &customerID,
+		"customer.name":                   &invoice.Customer.Name,
+		"customer.email":                  &invoice.Customer.Email,
+		"customer.address1":               &invoice.Customer.Address1,
+		"customer.address2":               &invoice.Customer.Address2,
+		"customer.postcode":               &invoice.Customer.Postcode,
+		"customer.city":                   &invoice.Customer.City,
+		"customer.pnr":                    &invoice.Customer.PNR,
+		"customer.telephone":              &invoice.Customer.Telephone,
+		"customer.peppol_id":              &invoice.Customer.PeppolID,
+		"customer.reference":              &invoice.Customer.Reference,
+		"customer.property_designation":   &invoice.Customer.PropertyDesignation,
+		"customer.apartment_number":       &invoice.Customer.ApartmentNumber,
+		"customer.housing_cooperative_id": &invoice.Customer.HousingCooperativeID,
+		"customer.vat_number":             &invoice.Customer.VATNumber,
+		"customer.country":                &invoice.Customer.Country,
+		"additional_info":                 &invoice.AdditionalInfo,
+		"date_due":                        &invoice.DateDue,
+		"date_invoiced":                   &invoice.DateInvoiced,
 	}
 
 	customerUpdated := false
@@ -148,6 +259,11 @@ func (v *View) HandlePost() error {
 		updated = true
 	}
 
+	if v.FormValueExists("merge_attachments_set") {
+		invoice.MergeAttachments = v.FormValueBool("merge_attachments")
+		updated = true
+	}
+
 	// Validate customer change
 	if customerID != invoice.Customer.ID {
 		if customerID > 0 {
@@ -238,6 +354,20 @@ func (v *View) HandlePost() error {
 		}
 	}
 
+	attachmentOrderText := v.FormValueString("attachmentorder")
+	if attachmentOrderText != "" {
+		attachmentOrder := []int{}
+		err = json.Unmarshal([]byte(attachmentOrderText), &attachmentOrder)
+		if err != nil {
+			return err
+		}
+
+		err = models.InvoiceSetAttachmentOrder(v.Ctx, invoice, attachmentOrder)
+		if err != nil {
+			return err
+		}
+	}
+
 	if v.IsOffer {
 		return v.RedirectRoute("offer-view", "id", strconv.Itoa(invoice.ID))
 	}
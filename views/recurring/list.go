@@ -0,0 +1,53 @@
+package recurring
+
+import (
+	"sort"
+	"time"
+
+	"github.com/yzzyx/faktura-pdf/models"
+	"github.com/yzzyx/faktura-pdf/views"
+)
+
+// upcomingDays is the number of days ahead that upcoming runs are listed
+const upcomingDays = 90
+
+// Run is an upcoming run of a recurring invoice
+type Run struct {
+	Date      time.Time
+	Recurring models.RecurringInvoice
+}
+
+// List is the view-handler for listing recurring invoices
+type List struct {
+	views.View
+}
+
+// NewList creates a new handler for listing recurring invoices
+func NewList() *List {
+	return &List{}
+}
+
+// HandleGet displays a list of recurring invoices, and the invoices that will be issued in the near future
+func (v *List) HandleGet() error {
+	recurring, err := models.RecurringInvoiceList(v.Ctx, models.RecurringInvoiceFilter{CompanyID: v.Session.Company.ID})
+	if err != nil {
+		return err
+	}
+
+	until := time.Now().AddDate(0, 0, upcomingDays)
+	var runs []Run
+	for _, r := range recurring {
+		for _, date := range r.Upcoming(until) {
+			runs = append(runs, Run{Date: date, Recurring: r})
+		}
+	}
+
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].Date.Before(runs[j].Date)
+	})
+
+	v.SetData("recurring", recurring)
+	v.SetData("runs", runs)
+	v.SetData("upcomingDays", upcomingDays)
+	return v.Render("recurring/list.html")
+}
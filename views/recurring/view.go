@@ -0,0 +1,268 @@
+package recurring
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/yzzyx/faktura-pdf/models"
+	"github.com/yzzyx/faktura-pdf/views"
+)
+
+// View is the view-handler for viewing and editing a recurring invoice
+type View struct {
+	views.View
+}
+
+// NewView creates a new handler for viewing and editing a recurring invoice
+func NewView() *View {
+	return &View{}
+}
+
+// HandleGet displays a recurring invoice.
+// New recurring invoices can be based on an existing invoice by setting 'invoice' to its ID.
+func (v *View) HandleGet() error {
+	var err error
+	var recurring models.RecurringInvoice
+
+	id := v.URLParamInt("id")
+	if id > 0 {
+		recurring, err = models.RecurringInvoiceGet(v.Ctx, models.RecurringInvoiceFilter{ID: id, CompanyID: v.Session.Company.ID})
+		if err != nil {
+			return err
+		}
+
+		issued, err := models.InvoiceList(v.Ctx, models.InvoiceFilter{
+			CompanyID:          v.Session.Company.ID,
+			RecurringInvoiceID: recurring.ID,
+			Direction:          "DESC",
+		})
+		if err != nil {
+			return err
+		}
+		v.SetData("issued", issued)
+		v.SetData("upcoming", recurring.Upcoming(time.Now().AddDate(1, 0, 0)))
+	} else {
+		recurring.DateStart = time.Now()
+		recurring.DueDays = v.Session.Company.InvoiceDueDays
+		recurring.IsActive = true
+
+		if invoiceID := v.FormValueInt("invoice"); invoiceID > 0 {
+			invoice, err := models.InvoiceGet(v.Ctx, models.InvoiceFilter{ID: invoiceID, CompanyID: v.Session.Company.ID})
+			if err != nil {
+				return err
+			}
+
+			recurring.Name = invoice.Name
+			recurring.Customer = invoice.Customer
+			recurring.RutApplicable = invoice.RutApplicable
+			recurring.AdditionalInfo = invoice.AdditionalInfo
+			recurring.Rows = invoice.Rows
+		}
+	}
+
+	customers, err := models.CustomerList(v.Ctx, models.CustomerFilter{CompanyID: v.Session.Company.ID})
+	if err != nil {
+		return err
+	}
+
+	v.SetData("recurring", recurring)
+	v.SetData("customers", customers)
+	v.SetData("intervals", models.RecurringIntervals)
+	v.SetData("rutServices", models.RUTServices)
+	v.SetData("rotServices", models.ROTServices)
+	return v.Render("recurring/view.html")
+}
+
+// parseRows reads the rows of the recurring invoice from the form.
+// Rows without a description are ignored.
+func (v *View) parseRows() ([]models.InvoiceRow, error) {
+	var rows []models.InvoiceRow
+
+	descriptions := v.FormValueStringSlice("row.description[]")
+	costs := v.FormValueStringSlice("row.cost[]")
+	counts := v.FormValueStringSlice("row.count[]")
+	units := v.FormValueStringSlice("row.unit[]")
+	vats := v.FormValueStringSlice("row.vat[]")
+	services := v.FormValueStringSlice("row.rot_rut_service_type[]")
+	goods := v.FormValueStringSlice("row.is_goods[]")
+	accounts := v.FormValueStringSlice("row.account[]")
+	hours := v.FormValueStringSlice("row.rot_rut_hours[]")
+
+	if len(costs) != len(descriptions) || len(counts) != len(descriptions) || len(units) != len(descriptions) ||
+		len(vats) != len(descriptions) || len(services) != len(descriptions) || len(goods) != len(descriptions) ||
+		len(accounts) != len(descriptions) || len(hours) != len(descriptions) {
+		return nil, views.ErrBadRequest
+	}
+
+	for k, description := range descriptions {
+		description = strings.TrimSpace(description)
+		if description == "" {
+			continue
+		}
+
+		row := models.InvoiceRow{RowOrder: len(rows), Description: description}
+
+		var err error
+		row.Cost, err = decimal.NewFromString(strings.ReplaceAll(costs[k], ",", "."))
+		if err != nil {
+			return nil, fmt.Errorf("invalid price for row '%s': %w", description, err)
+		}
+
+		row.Count, err = decimal.NewFromString(strings.ReplaceAll(counts[k], ",", "."))
+		if err != nil {
+			return nil, fmt.Errorf("invalid count for row '%s': %w", description, err)
+		}
+
+		unit, err := strconv.Atoi(units[k])
+		if err != nil || !models.UnitType(unit).Validate() {
+			return nil, fmt.Errorf("invalid unit for row '%s'", description)
+		}
+		row.Unit = models.UnitType(unit)
+
+		vat, err := strconv.Atoi(vats[k])
+		if err != nil || !models.VATType(vat).Validate() {
+			return nil, fmt.Errorf("invalid VAT for row '%s'", description)
+		}
+		row.VAT = models.VATType(vat)
+		row.IsGoods = goods[k] == "1"
+
+		if accounts[k] != "" {
+			account, err := strconv.Atoi(accounts[k])
+			if err != nil || account < 1000 || account > 9999 {
+				return nil, fmt.Errorf("invalid account for row '%s'", description)
+			}
+			row.Account = &account
+		}
+
+		if services[k] != "" {
+			serviceType, err := strconv.Atoi(services[k])
+			if err != nil {
+				return nil, fmt.Errorf("invalid ROT/RUT service for row '%s'", description)
+			}
+
+			st := models.ROTRUTServiceType(serviceType)
+			if !st.IsROT() && !st.IsRUT() {
+				return nil, fmt.Errorf("invalid ROT/RUT service for row '%s'", description)
+			}
+			row.IsRotRut = true
+			row.RotRutServiceType = &st
+		}
+
+		if hours[k] != "" {
+			h, err := strconv.Atoi(hours[k])
+			if err != nil || h < 0 {
+				return nil, fmt.Errorf("invalid number of hours for row '%s'", description)
+			}
+			row.RotRutHours = &h
+		}
+
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// HandlePost saves a recurring invoice.
+// If 'skip' is set, the next run is skipped instead.
+func (v *View) HandlePost() error {
+	var err error
+	var recurring models.RecurringInvoice
+
+	id := v.URLParamInt("id")
+	if id > 0 {
+		recurring, err = models.RecurringInvoiceGet(v.Ctx, models.RecurringInvoiceFilter{ID: id, CompanyID: v.Session.Company.ID})
+		if err != nil {
+			return err
+		}
+	} else {
+		recurring.Company.ID = v.Session.Company.ID
+	}
+
+	if v.FormValueBool("skip") {
+		if recurring.ID <= 0 || recurring.DateNext == nil {
+			return views.ErrBadRequest
+		}
+
+		recurring.DateNext = recurring.NextRun(recurring.DateNext.AddDate(0, 0, 1))
+		_, err = models.RecurringInvoiceSave(v.Ctx, recurring)
+		if err != nil {
+			return err
+		}
+		return v.RedirectRoute("recurring-view", "id", strconv.Itoa(recurring.ID))
+	}
+
+	recurring.Name = v.FormValueString("name")
+	recurring.AdditionalInfo = v.FormValueString("additional_info")
+	recurring.RutApplicable = v.FormValueBool("rut_applicable")
+	recurring.SendDirectly = v.FormValueBool("send_directly")
+	recurring.IsActive = v.FormValueBool("is_active")
+	recurring.DueDays = v.FormValueInt("due_days")
+
+	if recurring.DueDays < 0 {
+		return errors.New("number of days until due date cannot be negative")
+	}
+
+	recurring.Interval = models.RecurringInterval(v.FormValueInt("interval"))
+	if !recurring.Interval.Validate() {
+		return errors.New("invalid interval")
+	}
+
+	recurring.DateStart, err = time.Parse("2006-01-02", v.FormValueString("date_start"))
+	if err != nil {
+		return views.ErrBadRequest
+	}
+
+	recurring.DateEnd = nil
+	if v.FormValueString("date_end") != "" {
+		dateEnd, err := time.Parse("2006-01-02", v.FormValueString("date_end"))
+		if err != nil {
+			return views.ErrBadRequest
+		}
+
+		if dateEnd.Before(recurring.DateStart) {
+			return errors.New("end date cannot be before start date")
+		}
+		recurring.DateEnd = &dateEnd
+	}
+
+	customers, err := models.CustomerList(v.Ctx, models.CustomerFilter{
+		ID:        v.FormValueInt("customer"),
+		CompanyID: v.Session.Company.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(customers) != 1 {
+		return errors.New("invalid customer selection")
+	}
+	recurring.Customer = customers[0]
+
+	recurring.Rows, err = v.parseRows()
+	if err != nil {
+		return err
+	}
+
+	if len(recurring.Rows) == 0 {
+		return errors.New("a recurring invoice must have at least one row")
+	}
+
+	// Runs that are already due, but not yet issued, are kept.
+	// Otherwise, the schedule starts from today, so that changing
+	// the start date doesn't issue invoices for past periods.
+	from := time.Now()
+	if recurring.DateNext != nil && recurring.DateNext.Before(from) {
+		from = *recurring.DateNext
+	}
+	recurring.DateNext = recurring.NextRun(from)
+
+	recurring.ID, err = models.RecurringInvoiceSave(v.Ctx, recurring)
+	if err != nil {
+		return err
+	}
+
+	return v.RedirectRoute("recurring-view", "id", strconv.Itoa(recurring.ID))
+}
@@ -0,0 +1,146 @@
+package rut
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/yzzyx/faktura-pdf/models"
+	"github.com/yzzyx/faktura-pdf/rotrut"
+	"github.com/yzzyx/faktura-pdf/views"
+)
+
+// BatchExport is the view-handler for creating SKV request files for all pending ROT/RUT requests
+type BatchExport struct {
+	views.View
+}
+
+// NewBatchExport creates a new handler for creating SKV request files for all pending ROT/RUT requests
+func NewBatchExport() *BatchExport {
+	return &BatchExport{}
+}
+
+// batchFile is a request file with cases of one type
+type batchFile struct {
+	Name     string
+	Type     models.RUTType
+	Requests []models.RUT
+}
+
+// batches splits requests into files with one type of requests in each file,
+// and at most rotrut.MaxArenden cases per file. A request has one case for each buyer of the invoice.
+func batches(rutRequests []models.RUT, date time.Time) []batchFile {
+	var files []batchFile
+	for _, typ := range []models.RUTType{models.RUTTypeROT, models.RUTTypeRUT} {
+		var current *batchFile
+		cases := 0
+		for _, r := range rutRequests {
+			if r.Type != typ {
+				continue
+			}
+
+			n := len(r.Invoice.ROTRUTBuyers())
+			if current == nil || cases+n > rotrut.MaxArenden {
+				files = append(files, batchFile{
+					Name: fmt.Sprintf("%s-%s-%d", typ, date.Format("060102"), len(files)+1),
+					Type: typ,
+				})
+				current = &files[len(files)-1]
+				cases = 0
+			}
+			current.Requests = append(current.Requests, r)
+			cases += n
+		}
+	}
+	return files
+}
+
+// HandlePost creates a zip archive with request files for all pending ROT/RUT requests that can be exported,
+// and marks the requests as sent
+func (v *BatchExport) HandlePost() error {
+	f := models.RUTFilter{
+		CompanyID:      v.Session.Company.ID,
+		FilterStatus:   []models.RUTStatus{models.RUTStatusPending},
+		IncludeInvoice: true,
+	}
+
+	rutRequests, err := models.RUTList(v.Ctx, f)
+	if err != nil {
+		return err
+	}
+
+	// Requests that are not ready, e.g. where the invoice is not paid yet, are left as pending
+	var ready []models.RUT
+	for _, r := range rutRequests {
+		if exportError(r) == nil {
+			ready = append(ready, r)
+		}
+	}
+
+	if len(ready) == 0 {
+		return errors.New("det finns inga ärenden som är redo att skickas in")
+	}
+
+	now := time.Now()
+	var files []*rotrut.Begaran
+	var errs []error
+	for _, b := range batches(ready, now) {
+		begaran, err := newBegaran(b.Name, b.Type, b.Requests)
+		if err != nil {
+			return err
+		}
+
+		errs = append(errs, begaran.Validate(invoiceDates(b.Requests))...)
+		files = append(files, begaran)
+	}
+
+	// No requests are marked as sent unless all files are valid
+	if len(errs) > 0 {
+		v.SetData("title", "Underlag för ROT/RUT-ärenden kunde inte skapas")
+		v.SetData("errors", errs)
+		return v.Render("rut/validation-errors.html")
+	}
+
+	buf := &bytes.Buffer{}
+	archive := zip.NewWriter(buf)
+	for _, begaran := range files {
+		output, err := xml.MarshalIndent(begaran, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		w, err := archive.Create(begaran.NamnPaBegaran.Namn + ".xml")
+		if err != nil {
+			return err
+		}
+
+		_, err = w.Write(append([]byte(xml.Header), output...))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = archive.Close()
+	if err != nil {
+		return err
+	}
+
+	// All requests are marked as sent within the same transaction
+	for _, r := range ready {
+		r.Status = models.RUTStatusSent
+		r.DateSent = &now
+		_, err = models.RUTSave(v.Ctx, r)
+		if err != nil {
+			return err
+		}
+	}
+
+	name := fmt.Sprintf("rotrut-%s.zip", now.Format("2006-01-02"))
+	headers := v.ResponseHeaders()
+	headers.Set("Content-Type", "application/zip")
+	headers.Set("Content-Disposition", "attachment; filename="+name)
+	return v.RenderBytes(buf.Bytes())
+}
@@ -0,0 +1,55 @@
+package rut
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yzzyx/faktura-pdf/models"
+)
+
+func TestBatches(t *testing.T) {
+	var rutRequests []models.RUT
+	for k := 0; k < 150; k++ {
+		rutRequests = append(rutRequests, models.RUT{ID: k, Type: models.RUTTypeROT})
+	}
+	rutRequests = append(rutRequests, models.RUT{ID: 150, Type: models.RUTTypeRUT})
+
+	files := batches(rutRequests, time.Date(2024, time.October, 17, 0, 0, 0, 0, time.Local))
+	if len(files) != 3 {
+		t.Fatalf("got %d files, expected 2 ROT and 1 RUT", len(files))
+	}
+
+	expected := []struct {
+		name  string
+		typ   models.RUTType
+		count int
+	}{
+		{"ROT-241017-1", models.RUTTypeROT, 100},
+		{"ROT-241017-2", models.RUTTypeROT, 50},
+		{"RUT-241017-3", models.RUTTypeRUT, 1},
+	}
+	for k, e := range expected {
+		if files[k].Name != e.name || files[k].Type != e.typ || len(files[k].Requests) != e.count {
+			t.Errorf("file %d: got %s with %d requests, expected %s with %d", k, files[k].Name, len(files[k].Requests), e.name, e.count)
+		}
+	}
+
+	if len(batches(nil, time.Now())) != 0 {
+		t.Errorf("expected no files without requests")
+	}
+}
+
+func TestBatchesBuyers(t *testing.T) {
+	// Requests with two buyers have two cases each
+	buyers := []models.Buyer{{PNR: "198001011231", Share: 50}, {PNR: "198112189876", Share: 50}}
+
+	var rutRequests []models.RUT
+	for k := 0; k < 51; k++ {
+		rutRequests = append(rutRequests, models.RUT{ID: k, Type: models.RUTTypeRUT, Invoice: models.Invoice{Buyers: buyers}})
+	}
+
+	files := batches(rutRequests, time.Date(2024, time.October, 17, 0, 0, 0, 0, time.Local))
+	if len(files) != 2 || len(files[0].Requests) != 50 || len(files[1].Requests) != 1 {
+		t.Errorf("expected 50 requests in the first file and 1 in the second")
+	}
+}
@@ -0,0 +1,265 @@
+package rut
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/yzzyx/faktura-pdf/models"
+	"github.com/yzzyx/faktura-pdf/rotrut"
+	"github.com/yzzyx/faktura-pdf/views"
+	"github.com/yzzyx/zerr"
+)
+
+// Statuses of a decision matched against the requests of a company
+const (
+	DecisionStatusUnmatched = iota // No request found
+	DecisionStatusRejected         // Request found, and no amount is approved
+	DecisionStatusPartial          // Request found, and a part of the requested amount is approved
+	DecisionStatusApproved         // Request found, and the requested amount is approved
+)
+
+// DecisionMatch describes a decision in a decision file, and the request it is matched to
+type DecisionMatch struct {
+	Arende    rotrut.BeslutArende
+	Date      time.Time
+	RUT       *models.RUT
+	Requested int64 // The buyer's part of the requested amount
+	Status    int
+}
+
+// Decision is the view-handler for importing decision files from Skatteverket
+type Decision struct {
+	views.View
+}
+
+// NewDecision creates a new handler for importing decision files
+func NewDecision() *Decision {
+	return &Decision{}
+}
+
+// matchDecisions finds the request that each decision concerns, by invoice number and buyer.
+// If the invoice has several buyers, each buyer has a separate decision.
+// Only requests that have not yet been paid or rejected are matched.
+func matchDecisions(arenden []rotrut.BeslutArende, rutRequests []models.RUT) []DecisionMatch {
+	var matches []DecisionMatch
+	for _, a := range arenden {
+		m := DecisionMatch{Arende: a}
+		m.Date, _ = a.Date()
+
+		for k := range rutRequests {
+			r := &rutRequests[k]
+			if strconv.Itoa(r.Invoice.Number) != string(a.FakturaNr) {
+				continue
+			}
+
+			buyers := r.Invoice.ROTRUTBuyers()
+			var requested []int64
+			if r.RequestedSum != nil {
+				requested = models.SplitByShare(int64(*r.RequestedSum), buyers)
+			}
+
+			for n, b := range buyers {
+				if models.NormalizePNR(b.PNR) != string(a.Kopare) {
+					continue
+				}
+
+				m.RUT = r
+				if requested != nil {
+					m.Requested = requested[n]
+				}
+			}
+		}
+
+		switch {
+		case m.RUT == nil:
+			m.Status = DecisionStatusUnmatched
+		case a.GodkantBelopp <= 0:
+			m.Status = DecisionStatusRejected
+		case m.RUT.RequestedSum != nil && int64(a.GodkantBelopp) < m.Requested:
+			m.Status = DecisionStatusPartial
+		default:
+			m.Status = DecisionStatusApproved
+		}
+		matches = append(matches, m)
+	}
+	return matches
+}
+
+// openRequests returns all requests of the company that are waiting for a decision
+func openRequests(ctx context.Context, companyID int) ([]models.RUT, error) {
+	return models.RUTList(ctx, models.RUTFilter{
+		CompanyID:      companyID,
+		FilterStatus:   []models.RUTStatus{models.RUTStatusPending, models.RUTStatusSent},
+		IncludeInvoice: true,
+	})
+}
+
+// HandleGet shows the upload form
+func (v *Decision) HandleGet() error {
+	return v.Render("rut/decision.html")
+}
+
+// HandlePost parses an uploaded decision file and shows the matched requests,
+// or, if 'confirm' is set, updates the selected requests
+func (v *Decision) HandlePost() error {
+	if v.FormValueExists("confirm") {
+		return v.confirm()
+	}
+
+	files := v.FormFiles("file")
+	if len(files) != 1 {
+		return views.ErrBadRequest
+	}
+
+	f, err := files[0].Open()
+	if err != nil {
+		return zerr.Wrap(err).WithString("filename", files[0].Filename)
+	}
+	defer f.Close()
+
+	arenden, err := rotrut.ParseBeslut(f)
+	if err != nil {
+		v.SetData("error", err.Error())
+		return v.Render("rut/decision.html")
+	}
+
+	rutRequests, err := openRequests(v.Ctx, v.Session.Company.ID)
+	if err != nil {
+		return err
+	}
+
+	v.SetData("matches", matchDecisions(arenden, rutRequests))
+	return v.Render("rut/decision-review.html")
+}
+
+// decisionResult returns the sum of the approved amounts and the date of the latest decision of a request,
+// and whether every buyer of the invoice has a decision
+func decisionResult(buyers []models.Buyer, decisions []models.RUTDecision) (amount int, date time.Time, complete bool) {
+	byPNR := map[string]models.RUTDecision{}
+	for _, d := range decisions {
+		byPNR[d.PNR] = d
+	}
+
+	complete = true
+	for _, b := range buyers {
+		d, ok := byPNR[models.NormalizePNR(b.PNR)]
+		if !ok {
+			complete = false
+			continue
+		}
+
+		amount += d.Amount
+		if d.DateDecided.After(date) {
+			date = d.DateDecided
+		}
+	}
+	return amount, date, complete
+}
+
+// confirm registers the decisions selected in the review form.
+// The form contains the fields 'apply[<n>]', 'id[<n>]', 'pnr[<n>]', 'amount[<n>]' and 'date[<n>]' for every decision.
+// A request has one decision for each buyer of the invoice, and is updated when every buyer has a decision,
+// with the approved amounts of the buyers added together.
+// Requests where an amount is approved are marked as paid, and the other requests as rejected.
+func (v *Decision) confirm() error {
+	var ids []int
+	seen := map[int]bool{}
+
+	count := v.FormValueInt("count")
+	for i := 0; i < count; i++ {
+		if !v.FormValueBool(fmt.Sprintf("apply[%d]", i)) {
+			continue
+		}
+
+		date, err := time.ParseInLocation("2006-01-02", v.FormValueString(fmt.Sprintf("date[%d]", i)), time.Local)
+		if err != nil {
+			return views.ErrBadRequest
+		}
+
+		pnr := models.NormalizePNR(v.FormValueString(fmt.Sprintf("pnr[%d]", i)))
+		if pnr == "" {
+			return views.ErrBadRequest
+		}
+
+		id := v.FormValueInt(fmt.Sprintf("id[%d]", i))
+		rutRequest, err := models.RUTGet(v.Ctx, models.RUTFilter{
+			ID:           id,
+			CompanyID:    v.Session.Company.ID,
+			FilterStatus: []models.RUTStatus{models.RUTStatusPending, models.RUTStatusSent},
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = models.RUTDecisionSave(v.Ctx, models.RUTDecision{
+			RUTID:       rutRequest.ID,
+			PNR:         pnr,
+			Amount:      v.FormValueInt(fmt.Sprintf("amount[%d]", i)),
+			DateDecided: date,
+		})
+		if err != nil {
+			return err
+		}
+
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	var updated, waiting []models.RUT
+	for _, id := range ids {
+		rutRequest, err := models.RUTGet(v.Ctx, models.RUTFilter{
+			ID:             id,
+			CompanyID:      v.Session.Company.ID,
+			IncludeInvoice: true,
+		})
+		if err != nil {
+			return err
+		}
+
+		decisions, err := models.RUTDecisionList(v.Ctx, id)
+		if err != nil {
+			return err
+		}
+
+		// The request stays open until Skatteverket has decided for all buyers
+		amount, date, complete := decisionResult(rutRequest.Invoice.ROTRUTBuyers(), decisions)
+		if !complete {
+			waiting = append(waiting, rutRequest)
+			continue
+		}
+
+		rutRequest.DatePaid = &date
+		if amount > 0 {
+			rutRequest.Status = models.RUTStatusPaid
+			rutRequest.ReceivedSum = &amount
+		} else {
+			rutRequest.Status = models.RUTStatusRejected
+		}
+
+		if rutRequest.DateSent == nil {
+			rutRequest.DateSent = &date
+		}
+
+		_, err = models.RUTSave(v.Ctx, rutRequest)
+		if err != nil {
+			return err
+		}
+		updated = append(updated, rutRequest)
+	}
+
+	var paid []string
+	for _, r := range updated {
+		if r.Status == models.RUTStatusPaid {
+			paid = append(paid, strconv.Itoa(r.ID))
+		}
+	}
+
+	v.SetData("updated", updated)
+	v.SetData("waiting", waiting)
+	v.SetData("paid", paid)
+	return v.Render("rut/decision-result.html")
+}
@@ -0,0 +1,109 @@
+package rut
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yzzyx/faktura-pdf/models"
+	"github.com/yzzyx/faktura-pdf/rotrut"
+)
+
+func TestMatchDecisions(t *testing.T) {
+	requested := 2000
+	rutRequests := []models.RUT{
+		{ID: 1, RequestedSum: &requested, Invoice: models.Invoice{Number: 1001, Customer: models.Customer{PNR: "800101-1231"}}},
+		{ID: 2, RequestedSum: &requested, Invoice: models.Invoice{Number: 1002, Customer: models.Customer{PNR: "19811218-9876"}}},
+		{ID: 3, RequestedSum: &requested, Invoice: models.Invoice{Number: 1003, Customer: models.Customer{PNR: "198001011231"}}},
+	}
+
+	arenden := []rotrut.BeslutArende{
+		{Kopare: "198001011231", FakturaNr: "1001", GodkantBelopp: 2000, Beslutsdatum: "2024-10-20"},
+		{Kopare: "198112189876", FakturaNr: "1002", GodkantBelopp: 1500, Beslutsdatum: "2024-10-20"},
+		{Kopare: "198001011231", FakturaNr: "1003", GodkantBelopp: 0, Beslutsdatum: "2024-10-20"},
+		{Kopare: "198112189876", FakturaNr: "1001", GodkantBelopp: 2000, Beslutsdatum: "2024-10-20"},
+	}
+
+	expected := []struct {
+		id     int
+		status int
+	}{
+		{1, DecisionStatusApproved},
+		{2, DecisionStatusPartial},
+		{3, DecisionStatusRejected},
+		{0, DecisionStatusUnmatched}, // Buyer does not match the invoice
+	}
+
+	matches := matchDecisions(arenden, rutRequests)
+	if len(matches) != len(expected) {
+		t.Fatalf("got %d matches, expected %d", len(matches), len(expected))
+	}
+
+	for k, e := range expected {
+		m := matches[k]
+		id := 0
+		if m.RUT != nil {
+			id = m.RUT.ID
+		}
+
+		if id != e.id || m.Status != e.status {
+			t.Errorf("decision %d: got request %d with status %d, expected request %d with status %d", k, id, m.Status, e.id, e.status)
+		}
+	}
+}
+
+func TestMatchDecisionsBuyers(t *testing.T) {
+	requested := 3000
+	rutRequests := []models.RUT{
+		{ID: 1, RequestedSum: &requested, Invoice: models.Invoice{
+			Number:   1001,
+			Customer: models.Customer{PNR: "800101-1231"},
+			Buyers: []models.Buyer{
+				{PNR: "19800101-1231", Share: 50},
+				{PNR: "198112189876", Share: 50},
+			},
+		}},
+	}
+
+	arenden := []rotrut.BeslutArende{
+		{Kopare: "198001011231", FakturaNr: "1001", GodkantBelopp: 1500, Beslutsdatum: "2024-10-20"},
+		{Kopare: "198112189876", FakturaNr: "1001", GodkantBelopp: 1000, Beslutsdatum: "2024-10-20"},
+	}
+
+	matches := matchDecisions(arenden, rutRequests)
+	if len(matches) != 2 || matches[0].RUT == nil || matches[1].RUT == nil {
+		t.Fatalf("expected both decisions to match the request")
+	}
+
+	if matches[0].Requested != 1500 || matches[0].Status != DecisionStatusApproved {
+		t.Errorf("expected first buyer to be approved, got status %d for %d kr", matches[0].Status, matches[0].Requested)
+	}
+	if matches[1].Requested != 1500 || matches[1].Status != DecisionStatusPartial {
+		t.Errorf("expected second buyer to be partially approved, got status %d for %d kr", matches[1].Status, matches[1].Requested)
+	}
+}
+
+func TestDecisionResult(t *testing.T) {
+	buyers := []models.Buyer{
+		{PNR: "19800101-1231", Share: 50},
+		{PNR: "811218-9876", Share: 50},
+	}
+
+	decisions := []models.RUTDecision{
+		{PNR: "198001011231", Amount: 1500, DateDecided: time.Date(2024, 10, 20, 0, 0, 0, 0, time.Local)},
+	}
+
+	_, _, complete := decisionResult(buyers, decisions)
+	if complete {
+		t.Errorf("expected the request to wait for the second buyer")
+	}
+
+	decisions = append(decisions, models.RUTDecision{PNR: "198112189876", Amount: 1000, DateDecided: time.Date(2024, 10, 27, 0, 0, 0, 0, time.Local)})
+	amount, date, complete := decisionResult(buyers, decisions)
+	if !complete {
+		t.Fatalf("expected every buyer to have a decision")
+	}
+
+	if amount != 2500 || !date.Equal(time.Date(2024, 10, 27, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("got %d kr on %s, expected 2500 kr on the date of the latest decision", amount, date)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/xml"
 	"errors"
 	"fmt"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -25,143 +24,321 @@ func NewExport() *Export {
 	return &Export{}
 }
 
-var rePNR6_4 = regexp.MustCompile(`\d{6}-\d{4}`)
-var rePNR8_4 = regexp.MustCompile(`\d{8}-\d{4}`)
+// work contains the hours and material per type of service for the invoice rows in a ROT/RUT request
+type work struct {
+	hours    map[models.ROTRUTServiceType]rotrut.AntalTimmarTYPE
+	material map[models.ROTRUTServiceType]decimal.Decimal
 
-// HandleGet creates an xml export file
-func (v *Export) HandleGet() error {
-	f := models.RUTFilter{
-		ID:             v.URLParamInt("id"),
-		CompanyID:      v.Session.Company.ID,
-		IncludeInvoice: true,
+	labour decimal.Decimal // Price for the work that deduction is requested for
+	other  decimal.Decimal // Other costs, e.g. material and travel
+}
+
+// timmarMaterial returns the hours and material for a type of service
+func (w work) timmarMaterial(s models.ROTRUTServiceType) *rotrut.TimmarMaterialTYPE {
+	return &rotrut.TimmarMaterialTYPE{
+		AntalTimmar:     w.hours[s],
+		Materialkostnad: rotrut.MaterialkostnadTYPE(w.material[s].IntPart()),
 	}
+}
 
-	if f.ID <= 0 {
-		return views.ErrBadRequest
+// requestWork summarizes the rows of the invoice that belong to a ROT/RUT request.
+// Rows with deduction of the other type belong to another request, and are not included.
+// Rows without deduction are other costs, and material for a type of service if one is set on the row.
+func requestWork(rutRequest models.RUT) (work, error) {
+	w := work{
+		hours:    map[models.ROTRUTServiceType]rotrut.AntalTimmarTYPE{},
+		material: map[models.ROTRUTServiceType]decimal.Decimal{},
 	}
 
-	rutRequest, err := models.RUTGet(v.Ctx, f)
-	if err != nil {
-		return err
+	sameType := func(s models.ROTRUTServiceType) bool {
+		if rutRequest.Type == models.RUTTypeROT {
+			return s.IsROT()
+		}
+		return s.IsRUT()
 	}
 
-	var rutBegaran *rotrut.HushallBegaranTYPE
+	for _, r := range rutRequest.Invoice.Rows {
+		if r.IsRotRut && r.RotRutServiceType != nil && !sameType(*r.RotRutServiceType) {
+			continue
+		}
 
-	// Convert PNR to format YYYYMMDDXXXX, which
-	// is the format required by SKV
-	pnr := rutRequest.Invoice.Customer.PNR
-	if rePNR6_4.MatchString(pnr) {
-		year, err := strconv.Atoi(pnr[0:2])
-		if err != nil {
-			return fmt.Errorf("Personnummret inleds inte med siffra")
+		if !r.IsRotRut || r.RotRutServiceType == nil {
+			w.other = w.other.Add(r.Total)
+			if r.RotRutServiceType != nil && sameType(*r.RotRutServiceType) {
+				w.material[*r.RotRutServiceType] = w.material[*r.RotRutServiceType].Add(r.Total)
+			}
+			continue
+		}
+
+		var hours rotrut.AntalTimmarTYPE
+		if r.Unit == 2 {
+			hours = rotrut.AntalTimmarTYPE(r.Count.IntPart())
+		} else if r.RotRutHours != nil {
+			hours = rotrut.AntalTimmarTYPE(*r.RotRutHours)
+		}
+
+		if hours == 0 {
+			continue
 		}
-		if year > 20 {
-			pnr = "19" + strings.ReplaceAll(pnr, "-", "")
-		} else {
-			pnr = "20" + strings.ReplaceAll(pnr, "-", "")
+
+		switch *r.RotRutServiceType {
+		case models.RUTServiceTypeTransportTillForsaljning:
+			return w, errors.New("export av transport till försäljning ej implementerat")
+		case models.RUTServiceTypeTvattVidTvattinrattning:
+			return w, errors.New("export av tvätt vid tvättinrättning ej implementerat")
 		}
-	} else if rePNR8_4.MatchString(pnr) {
-		pnr = strings.ReplaceAll(pnr, "-", "")
-	}
-
-	if rutRequest.Type == models.RUTTypeRUT {
-		rutBegaran = &rotrut.HushallBegaranTYPE{
-			Arenden: []rotrut.HushallArendeTYPE{
-				{
-					Kopare:          rotrut.PeOrgNrTYPE(pnr),
-					BegartBelopp:    rotrut.BeloppTYPE(*rutRequest.RequestedSum),
-					FakturaNr:       rotrut.FakturaNrTYPE(strconv.Itoa(rutRequest.Invoice.Number)),
-					BetalningsDatum: rotrut.DatumTYPE(rutRequest.Invoice.DatePaid.Format("2006-01-02")),
-					UtfortArbete: &rotrut.ArendeUtfortArbeteRutTYPE{
-						Stadning:                 &rotrut.TimmarMaterialTYPE{},
-						KladOchTextilvard:        &rotrut.TimmarMaterialTYPE{},
-						Snoskottning:             &rotrut.TimmarMaterialTYPE{},
-						Tradgardsarbete:          &rotrut.TimmarMaterialTYPE{},
-						Barnpassning:             &rotrut.TimmarMaterialTYPE{},
-						Personligomsorg:          &rotrut.TimmarMaterialTYPE{},
-						Flyttjanster:             &rotrut.TimmarMaterialTYPE{},
-						ItTjanster:               &rotrut.TimmarMaterialTYPE{},
-						ReparationAvVitvaror:     &rotrut.TimmarMaterialTYPE{},
-						Moblering:                &rotrut.TimmarMaterialTYPE{},
-						TillsynAvBostad:          &rotrut.TimmarMaterialTYPE{},
-						TransportTillForsaljning: nil, // Not implemented
-						TvattVidTvattinrattning:  nil, // Not implemented
-					},
-				},
+
+		w.labour = w.labour.Add(r.Total)
+		w.hours[*r.RotRutServiceType] += hours
+	}
+
+	return w, nil
+}
+
+// buyerCase is the part of a ROT/RUT request that concerns one of the buyers of the invoice
+type buyerCase struct {
+	pnr       string
+	requested int64
+	work      work
+}
+
+// buyerCases splits a ROT/RUT request into one case per buyer of the invoice.
+// The requested amount, the price, the other costs, the hours and the material are split in proportion
+// to the shares of the buyers.
+func buyerCases(rutRequest models.RUT, w work) []buyerCase {
+	buyers := rutRequest.Invoice.ROTRUTBuyers()
+	cases := make([]buyerCase, len(buyers))
+	for k, b := range buyers {
+		cases[k] = buyerCase{
+			pnr: models.NormalizePNR(b.PNR),
+			work: work{
+				hours:    map[models.ROTRUTServiceType]rotrut.AntalTimmarTYPE{},
+				material: map[models.ROTRUTServiceType]decimal.Decimal{},
 			},
 		}
-		rutBegaran.SetXMLNS()
+	}
 
-		arende := &rutBegaran.Arenden[0]
-		ua := arende.UtfortArbete
-		arbetsKostnad := decimal.Decimal{}
-		ovrigKostnad := decimal.Decimal{}
+	for k, part := range models.SplitByShare(int64(*rutRequest.RequestedSum), buyers) {
+		cases[k].requested = part
+	}
 
-		for _, r := range rutRequest.Invoice.Rows {
-			if !r.IsRotRut || r.RotRutServiceType == nil {
-				ovrigKostnad = ovrigKostnad.Add(r.Total)
-				continue
-			}
+	for k, part := range models.SplitByShare(w.labour.RoundBank(0).IntPart(), buyers) {
+		cases[k].work.labour = decimal.NewFromInt(part)
+	}
 
-			var hours rotrut.AntalTimmarTYPE
-			if r.Unit == 2 {
-				hours = rotrut.AntalTimmarTYPE(r.Count.IntPart())
-			} else if r.RotRutHours != nil {
-				hours = rotrut.AntalTimmarTYPE(*r.RotRutHours)
-			}
+	for k, part := range models.SplitByShare(w.other.IntPart(), buyers) {
+		cases[k].work.other = decimal.NewFromInt(part)
+	}
 
-			if hours == 0 {
-				continue
-			}
+	for s, hours := range w.hours {
+		for k, part := range models.SplitByShare(int64(hours), buyers) {
+			cases[k].work.hours[s] = rotrut.AntalTimmarTYPE(part)
+		}
+	}
 
-			arbetsKostnad = arbetsKostnad.Add(r.Total)
-			switch *r.RotRutServiceType {
-			case models.RUTServiceTypeStadning:
-				ua.Stadning.AntalTimmar += hours
-			case models.RUTServiceTypeKladOchTextilvard:
-				ua.KladOchTextilvard.AntalTimmar += hours
-			case models.RUTServiceTypeSnoskottning:
-				ua.Snoskottning.AntalTimmar += hours
-			case models.RUTServiceTypeTradgardsarbete:
-				ua.Tradgardsarbete.AntalTimmar += hours
-			case models.RUTServiceTypeBarnpassning:
-				ua.Barnpassning.AntalTimmar += hours
-			case models.RUTServiceTypePersonligomsorg:
-				ua.Personligomsorg.AntalTimmar += hours
-			case models.RUTServiceTypeFlyttjanster:
-				ua.Flyttjanster.AntalTimmar += hours
-			case models.RUTServiceTypeITTjanster:
-				ua.ItTjanster.AntalTimmar += hours
-			case models.RUTServiceTypeReparationAvVitvaror:
-				ua.ReparationAvVitvaror.AntalTimmar += hours
-			case models.RUTServiceTypeMoblering:
-				ua.Moblering.AntalTimmar += hours
-			case models.RUTServiceTypeTillsynAvBostad:
-				ua.TillsynAvBostad.AntalTimmar += hours
-			case models.RUTServiceTypeTransportTillForsaljning:
-				return errors.New("export av transport till försäljning ej implementerat")
-			case models.RUTServiceTypeTvattVidTvattinrattning:
-				return errors.New("export av tvätt vid tvättinrättning ej implementerat")
-			}
+	for s, material := range w.material {
+		for k, part := range models.SplitByShare(material.IntPart(), buyers) {
+			cases[k].work.material[s] = decimal.NewFromInt(part)
+		}
+	}
+
+	return cases
+}
+
+// exportError returns the reason why a ROT/RUT request cannot be exported, or nil if it can be exported
+func exportError(rutRequest models.RUT) error {
+	if rutRequest.RequestedSum == nil || *rutRequest.RequestedSum == 0 {
+		return errors.New("inget belopp är begärt")
+	}
+
+	if rutRequest.Invoice.DatePaid == nil {
+		return errors.New("fakturan är inte betalad")
+	}
+
+	for _, b := range rutRequest.Invoice.ROTRUTBuyers() {
+		if b.PNR == "" {
+			return errors.New("inget personnummer är angivet")
+		}
+	}
+
+	if shares := rutRequest.Invoice.BuyerShares(); shares != 100 {
+		return fmt.Errorf("köparnas andelar är tillsammans %d %%, men måste vara 100 %%", shares)
+	}
+
+	if rutRequest.Type == models.RUTTypeROT && !rutRequest.Invoice.Customer.HasROTProperty() {
+		return errors.New("fastighetsbeteckning, eller lägenhetsnummer och bostadsrättsföreningens organisationsnummer, måste anges för ROT-ärenden")
+	}
+
+	_, err := requestWork(rutRequest)
+	return err
+}
+
+// rutArende creates the case of a buyer for a RUT request
+func rutArende(rutRequest models.RUT, c buyerCase) rotrut.HushallArendeTYPE {
+	w := c.work
+	return rotrut.HushallArendeTYPE{
+		Kopare:          rotrut.PeOrgNrTYPE(c.pnr),
+		BegartBelopp:    rotrut.BeloppTYPE(c.requested),
+		FakturaNr:       rotrut.FakturaNrTYPE(strconv.Itoa(rutRequest.Invoice.Number)),
+		BetalningsDatum: rotrut.DatumTYPE(rutRequest.Invoice.DatePaid.Format("2006-01-02")),
+		PrisForArbete:   w.labour.StringFixedBank(0),
+		BetaltBelopp:    rotrut.BeloppTYPE(w.labour.Sub(decimal.NewFromInt(c.requested)).IntPart()),
+		Ovrigkostnad:    rotrut.OvrigKostnadTYPE(w.other.IntPart()),
+		UtfortArbete: &rotrut.ArendeUtfortArbeteRutTYPE{
+			Stadning:                 w.timmarMaterial(models.RUTServiceTypeStadning),
+			KladOchTextilvard:        w.timmarMaterial(models.RUTServiceTypeKladOchTextilvard),
+			Snoskottning:             w.timmarMaterial(models.RUTServiceTypeSnoskottning),
+			Tradgardsarbete:          w.timmarMaterial(models.RUTServiceTypeTradgardsarbete),
+			Barnpassning:             w.timmarMaterial(models.RUTServiceTypeBarnpassning),
+			Personligomsorg:          w.timmarMaterial(models.RUTServiceTypePersonligomsorg),
+			Flyttjanster:             w.timmarMaterial(models.RUTServiceTypeFlyttjanster),
+			ItTjanster:               w.timmarMaterial(models.RUTServiceTypeITTjanster),
+			ReparationAvVitvaror:     w.timmarMaterial(models.RUTServiceTypeReparationAvVitvaror),
+			Moblering:                w.timmarMaterial(models.RUTServiceTypeMoblering),
+			TillsynAvBostad:          w.timmarMaterial(models.RUTServiceTypeTillsynAvBostad),
+			TransportTillForsaljning: nil, // Not implemented
+			TvattVidTvattinrattning:  nil, // Not implemented
+		},
+	}
+}
+
+// rotArende creates the case of a buyer for a ROT request.
+// The property where the work was performed is either given as a property designation
+// for a house, or as the apartment number and the organisation number of the housing cooperative.
+func rotArende(rutRequest models.RUT, c buyerCase) rotrut.RotArendeTYPE {
+	w := c.work
+	customer := rutRequest.Invoice.Customer
+	fakturaNr := rotrut.FakturaNrTYPE(strconv.Itoa(rutRequest.Invoice.Number))
+	ovrigKostnad := rotrut.OvrigKostnadTYPE(w.other.IntPart())
+
+	arende := rotrut.RotArendeTYPE{
+		Kopare:          rotrut.PeOrgNrTYPE(c.pnr),
+		BegartBelopp:    rotrut.BeloppTYPE(c.requested),
+		FakturaNr:       &fakturaNr,
+		BetalningsDatum: rotrut.DatumTYPE(rutRequest.Invoice.DatePaid.Format("2006-01-02")),
+		PrisForArbete:   w.labour.StringFixedBank(0),
+		BetaltBelopp:    rotrut.BeloppTYPE(w.labour.Sub(decimal.NewFromInt(c.requested)).IntPart()),
+		Ovrigkostnad:    &ovrigKostnad,
+		UtfortArbete:    &rotrut.ArendeUtfortArbeteRotTYPE{},
+	}
+
+	if customer.PropertyDesignation != "" {
+		fastighet := rotrut.FastighetsbeteckningTYPE(customer.PropertyDesignation)
+		arende.Fastighetsbeteckning = &fastighet
+	} else {
+		lagenhet := rotrut.LagenhetsNrTYPE(customer.ApartmentNumber)
+		brf := rotrut.BrfOrgNrTYPE(strings.ReplaceAll(customer.HousingCooperativeID, "-", ""))
+		arende.LagenhetsNr = &lagenhet
+		arende.BrfOrgNr = &brf
+	}
+
+	// Only the types of work that have been performed are included
+	ua := arende.UtfortArbete
+	fields := map[models.ROTRUTServiceType]**rotrut.TimmarMaterialTYPE{
+		models.ROTServiceTypeBygg:                 &ua.Bygg,
+		models.ROTServiceTypeEl:                   &ua.El,
+		models.ROTServiceTypeGlasPlatarbete:       &ua.GlasPlatarbete,
+		models.ROTServiceTypeMarkDraneringsarbete: &ua.MarkDraneringarbete,
+		models.ROTServiceTypeMurning:              &ua.Murning,
+		models.ROTServiceTypeMalningTapetsering:   &ua.MalningTapetsering,
+		models.ROTServiceTypeVVS:                  &ua.Vvs,
+	}
+	for s, field := range fields {
+		_, hasHours := w.hours[s]
+		_, hasMaterial := w.material[s]
+		if hasHours || hasMaterial {
+			*field = w.timmarMaterial(s)
 		}
+	}
 
-		arende.PrisForArbete = arbetsKostnad.StringFixedBank(0)
-		arende.BetaltBelopp = rotrut.BeloppTYPE(arbetsKostnad.Sub(decimal.NewFromInt(int64(*rutRequest.RequestedSum))).IntPart())
-		arende.Ovrigkostnad = rotrut.OvrigKostnadTYPE(ovrigKostnad.IntPart())
+	return arende
+}
+
+// newBegaran creates a request to Skatteverket, containing one case for each buyer of each of the ROT/RUT requests.
+// All requests must be of the same type.
+func newBegaran(name string, typ models.RUTType, rutRequests []models.RUT) (*rotrut.Begaran, error) {
+	begaran := rotrut.NewBegaran(name)
+	if typ == models.RUTTypeROT {
+		begaran.RotBegaran = &rotrut.RotBegaranTYPE{}
+		begaran.RotBegaran.SetXMLNS()
 	} else {
-		return errors.New("export av ROT-ärenden ej implementerad")
+		begaran.HushallBegaran = &rotrut.HushallBegaranTYPE{}
+		begaran.HushallBegaran.SetXMLNS()
+	}
+
+	for _, r := range rutRequests {
+		if r.Type != typ {
+			return nil, fmt.Errorf("faktura %d: ärendet är inte ett %s-ärende", r.Invoice.Number, typ)
+		}
+
+		err := exportError(r)
+		if err != nil {
+			return nil, fmt.Errorf("faktura %d: %w", r.Invoice.Number, err)
+		}
+
+		w, err := requestWork(r)
+		if err != nil {
+			return nil, fmt.Errorf("faktura %d: %w", r.Invoice.Number, err)
+		}
+
+		// Every buyer of the invoice is a separate case
+		for _, c := range buyerCases(r, w) {
+			if typ == models.RUTTypeROT {
+				begaran.RotBegaran.Arenden = append(begaran.RotBegaran.Arenden, rotArende(r, c))
+			} else {
+				begaran.HushallBegaran.Arenden = append(begaran.HushallBegaran.Arenden, rutArende(r, c))
+			}
+		}
+	}
+
+	return begaran, nil
+}
+
+// invoiceDates returns the date deciding the deduction rate of each invoice in rutRequests, by invoice number
+func invoiceDates(rutRequests []models.RUT) map[string]time.Time {
+	dates := make(map[string]time.Time, len(rutRequests))
+	for _, r := range rutRequests {
+		dates[strconv.Itoa(r.Invoice.Number)] = r.Invoice.RateDate()
+	}
+	return dates
+}
+
+// HandleGet creates an xml export file
+func (v *Export) HandleGet() error {
+	f := models.RUTFilter{
+		ID:             v.URLParamInt("id"),
+		CompanyID:      v.Session.Company.ID,
+		IncludeInvoice: true,
+	}
+
+	if f.ID <= 0 {
+		return views.ErrBadRequest
+	}
+
+	rutRequest, err := models.RUTGet(v.Ctx, f)
+	if err != nil {
+		return err
 	}
 
 	now := time.Now()
-	begaran := rotrut.NewBegaran(fmt.Sprintf("%s-%d", rutRequest.Type, rutRequest.Invoice.Number))
-	begaran.HushallBegaran = rutBegaran
+	begaran, err := newBegaran(fmt.Sprintf("%s-%d", rutRequest.Type, rutRequest.Invoice.Number), rutRequest.Type, []models.RUT{rutRequest})
+	if err != nil {
+		return err
+	}
+
+	if errs := begaran.Validate(invoiceDates([]models.RUT{rutRequest})); len(errs) > 0 {
+		v.SetData("title", fmt.Sprintf("Underlag för %s-ärende för faktura %d kunde inte skapas", rutRequest.Type, rutRequest.Invoice.Number))
+		v.SetData("errors", errs)
+		v.SetData("rut", rutRequest)
+		return v.Render("rut/validation-errors.html")
+	}
 
 	output, err := xml.MarshalIndent(begaran, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	name := fmt.Sprintf("RUT-%d-%s.xml", rutRequest.Invoice.Number, now.Format("2006-01-02"))
+	name := fmt.Sprintf("%s-%d-%s.xml", rutRequest.Type, rutRequest.Invoice.Number, now.Format("2006-01-02"))
 	name = strings.ReplaceAll(name, " ", "_")
 
 	headers := v.ResponseHeaders()
@@ -0,0 +1,163 @@
+package rut
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/yzzyx/faktura-pdf/models"
+)
+
+func serviceType(s models.ROTRUTServiceType) *models.ROTRUTServiceType {
+	return &s
+}
+
+func testROTRequest() models.RUT {
+	invoiced := time.Date(2024, time.February, 1, 0, 0, 0, 0, time.Local)
+	paid := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.Local)
+	requested := 3000
+	hours := 4
+
+	return models.RUT{
+		Type:         models.RUTTypeROT,
+		RequestedSum: &requested,
+		Invoice: models.Invoice{
+			Number:       1001,
+			DateInvoiced: &invoiced,
+			DatePaid:     &paid,
+			Customer:     models.Customer{PNR: "19800101-1231", PropertyDesignation: "Uppsala Kåbo 1:1"},
+			Rows: []models.InvoiceRow{
+				{Description: "Snickeri", Unit: 2, Count: decimal.NewFromInt(10), IsRotRut: true, RotRutServiceType: serviceType(models.ROTServiceTypeBygg), Total: decimal.NewFromInt(6000)},
+				{Description: "Elinstallation", Unit: 1, Count: decimal.NewFromInt(1), IsRotRut: true, RotRutServiceType: serviceType(models.ROTServiceTypeEl), RotRutHours: &hours, Total: decimal.NewFromInt(4000)},
+				{Description: "Virke", Count: decimal.NewFromInt(1), RotRutServiceType: serviceType(models.ROTServiceTypeBygg), Total: decimal.NewFromInt(1500)},
+				{Description: "Resa", Count: decimal.NewFromInt(1), Total: decimal.NewFromInt(200)},
+				{Description: "Städning", Unit: 2, Count: decimal.NewFromInt(2), IsRotRut: true, RotRutServiceType: serviceType(models.RUTServiceTypeStadning), Total: decimal.NewFromInt(800)},
+			},
+		},
+	}
+}
+
+func TestROTExport(t *testing.T) {
+	r := testROTRequest()
+	w, err := requestWork(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	arende := rotArende(r, buyerCases(r, w)[0])
+	if arende.PrisForArbete != "10000" || arende.BetaltBelopp != 7000 || *arende.Ovrigkostnad != 1700 {
+		t.Errorf("unexpected amounts: labour %s, paid %d, other %d", arende.PrisForArbete, arende.BetaltBelopp, *arende.Ovrigkostnad)
+	}
+
+	ua := arende.UtfortArbete
+	if ua.Bygg == nil || ua.Bygg.AntalTimmar != 10 || ua.Bygg.Materialkostnad != 1500 {
+		t.Errorf("unexpected hours and material for Bygg: %+v", ua.Bygg)
+	}
+	if ua.El == nil || ua.El.AntalTimmar != 4 || ua.El.Materialkostnad != 0 {
+		t.Errorf("unexpected hours and material for El: %+v", ua.El)
+	}
+	if ua.Vvs != nil {
+		t.Errorf("work that has not been performed should not be included")
+	}
+
+	if arende.Fastighetsbeteckning == nil || *arende.Fastighetsbeteckning != "Uppsala Kåbo 1:1" || arende.BrfOrgNr != nil {
+		t.Errorf("expected property designation to be set")
+	}
+
+	doc, err := newBegaran("ROT-1001", models.RUTTypeROT, []models.RUT{r})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if errs := doc.Validate(invoiceDates([]models.RUT{r})); len(errs) > 0 {
+		t.Errorf("unexpected validation errors: %v", errs)
+	}
+
+	output, err := xml.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(output), "<Ovrigkostnad>1700</Ovrigkostnad><Fastighetsbeteckning>Uppsala Kåbo 1:1</Fastighetsbeteckning><UtfortArbete>") {
+		t.Errorf("unexpected output %s", output)
+	}
+}
+
+func TestROTExportApartment(t *testing.T) {
+	r := testROTRequest()
+	r.Invoice.Customer.PropertyDesignation = ""
+	r.Invoice.Customer.ApartmentNumber = "1101"
+
+	if exportError(r) == nil {
+		t.Errorf("expected error when organisation number of the housing cooperative is missing")
+	}
+
+	r.Invoice.Customer.HousingCooperativeID = "716400-1237"
+	if err := exportError(r); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := requestWork(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	arende := rotArende(r, buyerCases(r, w)[0])
+	if arende.Fastighetsbeteckning != nil || *arende.LagenhetsNr != "1101" || *arende.BrfOrgNr != "7164001237" {
+		t.Errorf("unexpected property %v %v %v", arende.Fastighetsbeteckning, arende.LagenhetsNr, arende.BrfOrgNr)
+	}
+}
+
+func TestROTExportBuyers(t *testing.T) {
+	r := testROTRequest()
+	r.Invoice.Buyers = []models.Buyer{
+		{Name: "Anna", PNR: "19800101-1231", Share: 60},
+		{Name: "Bertil", PNR: "198112189876", Share: 30},
+	}
+
+	if exportError(r) == nil {
+		t.Errorf("expected error when the shares of the buyers are not 100 %%")
+	}
+
+	r.Invoice.Buyers[1].Share = 40
+	doc, err := newBegaran("ROT-1001", models.RUTTypeROT, []models.RUT{r})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if errs := doc.Validate(invoiceDates([]models.RUT{r})); len(errs) > 0 {
+		t.Errorf("unexpected validation errors: %v", errs)
+	}
+
+	arenden := doc.RotBegaran.Arenden
+	if len(arenden) != 2 {
+		t.Fatalf("expected one case per buyer, got %d", len(arenden))
+	}
+
+	expected := []struct {
+		kopare    string
+		price     string
+		requested int
+		paid      int
+		other     int
+		hours     int
+		material  int
+	}{
+		{"198001011231", "6000", 1800, 4200, 1020, 6, 900},
+		{"198112189876", "4000", 1200, 2800, 680, 4, 600},
+	}
+	for k, e := range expected {
+		a := arenden[k]
+		if string(a.Kopare) != e.kopare || a.PrisForArbete != e.price || int(a.BegartBelopp) != e.requested ||
+			int(a.BetaltBelopp) != e.paid || int(*a.Ovrigkostnad) != e.other {
+			t.Errorf("case %d: unexpected buyer or amounts: %s, price %s, requested %d, paid %d, other %d",
+				k, a.Kopare, a.PrisForArbete, a.BegartBelopp, a.BetaltBelopp, *a.Ovrigkostnad)
+		}
+
+		bygg := a.UtfortArbete.Bygg
+		if bygg == nil || int(bygg.AntalTimmar) != e.hours || int(bygg.Materialkostnad) != e.material {
+			t.Errorf("case %d: unexpected hours and material for Bygg: %+v", k, bygg)
+		}
+	}
+}
@@ -22,6 +22,7 @@ func (v *List) HandleGet() error {
 		models.RUTStatusPending,
 		models.RUTStatusSent,
 		models.RUTStatusRejected,
+		models.RUTStatusWithdrawn,
 	}
 
 	f.OrderBy = v.FormValueString("orderby")
@@ -7,6 +7,7 @@ import (
 
 	"github.com/shopspring/decimal"
 	"github.com/yzzyx/faktura-pdf/models"
+	"github.com/yzzyx/faktura-pdf/rates"
 	"github.com/yzzyx/faktura-pdf/views"
 )
 
@@ -20,6 +21,12 @@ func NewView() *View {
 	return &View{}
 }
 
+// requestBuyer is one of the buyers of an invoice, and the buyer's part of the requested amount
+type requestBuyer struct {
+	Buyer     models.Buyer
+	Requested int64
+}
+
 // HandleGet displays a ROT/RUT request
 func (v *View) HandleGet() error {
 	f := models.RUTFilter{
@@ -37,31 +44,70 @@ func (v *View) HandleGet() error {
 		return err
 	}
 
-	multiplier := decimal.NewFromFloat(0.5)
+	// The deduction is calculated with the rates in force when the invoice was issued
+	date := rutRequest.Invoice.RateDate()
+	deductionRate := rates.RUT.At(date)
 	if rutRequest.Type == models.RUTTypeROT {
-		multiplier = decimal.NewFromFloat(0.3)
+		deductionRate = rates.ROT.At(date)
 	}
 
 	maxAmount := decimal.NewFromInt(0)
 	filteredRows := []models.InvoiceRow{}
+	otherRows := []models.InvoiceRow{}
 	for _, r := range rutRequest.Invoice.Rows {
 		if !r.IsRotRut || r.RotRutServiceType == nil {
+			otherRows = append(otherRows, r)
 			continue
 		}
 
 		if (rutRequest.Type == models.RUTTypeRUT && r.RotRutServiceType.IsRUT()) ||
 			(rutRequest.Type == models.RUTTypeROT && r.RotRutServiceType.IsROT()) {
-			maxAmount = maxAmount.Add(r.Total.Mul(multiplier))
+			maxAmount = maxAmount.Add(r.Total.Mul(r.RotRutServiceType.DeductionRate(date)))
 			filteredRows = append(filteredRows, r)
 		}
 	}
 
-	canExport := len(filteredRows) > 0 && rutRequest.Invoice.Customer.PNR != "" && rutRequest.RequestedSum != nil && *rutRequest.RequestedSum != 0
+	customer := rutRequest.Invoice.Customer
+	hasProperty := customer.HasROTProperty()
+
+	// Every buyer must have a personal identity number, and their shares must add up to the whole deduction
+	buyers := rutRequest.Invoice.ROTRUTBuyers()
+	hasPNR := true
+	var requested []int64
+	if rutRequest.RequestedSum != nil {
+		requested = models.SplitByShare(int64(*rutRequest.RequestedSum), buyers)
+	}
+
+	var requestBuyers []requestBuyer
+	for k, b := range buyers {
+		rb := requestBuyer{Buyer: b}
+		if requested != nil {
+			rb.Requested = requested[k]
+		}
+		requestBuyers = append(requestBuyers, rb)
+		hasPNR = hasPNR && b.PNR != ""
+	}
+	buyerShares := rutRequest.Invoice.BuyerShares()
+
+	canExport := len(filteredRows) > 0 && hasPNR && buyerShares == 100 && rutRequest.RequestedSum != nil && *rutRequest.RequestedSum != 0 &&
+		(rutRequest.Type != models.RUTTypeROT || hasProperty)
+
+	services := models.RUTServices
+	if rutRequest.Type == models.RUTTypeROT {
+		services = models.ROTServices
+	}
 
 	v.SetData("today", time.Now())
 	v.SetData("rut", rutRequest)
 	v.SetData("maxAmount", maxAmount)
+	v.SetData("deductionRate", deductionRate)
 	v.SetData("filteredRows", filteredRows)
+	v.SetData("otherRows", otherRows)
+	v.SetData("services", services)
+	v.SetData("hasProperty", hasProperty)
+	v.SetData("hasPNR", hasPNR)
+	v.SetData("buyers", requestBuyers)
+	v.SetData("buyerShares", buyerShares)
 	v.SetData("canExport", canExport)
 	v.SetData("hasRequestedSum", rutRequest.RequestedSum != nil)
 
@@ -94,7 +140,13 @@ func (v *View) HandlePost() error {
 
 	for _, formKey := range v.FormKeys() {
 		var rowID int
-		n, err := fmt.Sscanf(formKey, "hours[%d]", &rowID)
+		n, err := fmt.Sscanf(formKey, "material[%d]", &rowID)
+		if n == 1 && err == nil {
+			setMaterial(&rutRequest, rowID, v.FormValueString(formKey))
+			continue
+		}
+
+		n, err = fmt.Sscanf(formKey, "hours[%d]", &rowID)
 		if n != 1 || err != nil {
 			continue
 		}
@@ -122,3 +174,30 @@ func (v *View) HandlePost() error {
 
 	return v.RedirectRoute("rut-view", "id", strconv.Itoa(rutRequest.ID))
 }
+
+// setMaterial sets the type of service that a row without deduction is material for.
+// An empty value means that the row is not material for any of the services in the request.
+func setMaterial(rutRequest *models.RUT, rowID int, value string) {
+	for k := range rutRequest.Invoice.Rows {
+		row := &rutRequest.Invoice.Rows[k]
+		if row.ID != rowID || row.IsRotRut {
+			continue
+		}
+
+		row.RotRutServiceType = nil
+		if value == "" {
+			continue
+		}
+
+		s, err := strconv.Atoi(value)
+		if err != nil {
+			continue
+		}
+
+		serviceType := models.ROTRUTServiceType(s)
+		if (rutRequest.Type == models.RUTTypeROT && serviceType.IsROT()) ||
+			(rutRequest.Type == models.RUTTypeRUT && serviceType.IsRUT()) {
+			row.RotRutServiceType = &serviceType
+		}
+	}
+}